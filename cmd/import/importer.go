@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"wbtest/internal/interfaces"
+	"wbtest/internal/model"
+)
+
+// DefaultBatchSize число заказов в пачке по умолчанию
+const DefaultBatchSize = 100
+
+// RecordError ошибка импорта одной записи
+// Record - номер записи в файле начиная с 1
+type RecordError struct {
+	Record   int
+	OrderUID string
+	Err      error
+}
+
+func (e RecordError) Error() string {
+	if e.OrderUID == "" {
+		return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+	}
+	return fmt.Sprintf("record %d (order %s): %v", e.Record, e.OrderUID, e.Err)
+}
+
+// Result итог импорта
+type Result struct {
+	Imported int
+	Failed   []RecordError
+}
+
+// Importer загружает заказы из файла в БД
+// Каждый заказ проходит ту же валидацию, что и заказы из Kafka
+type Importer struct {
+	repo      interfaces.OrderRepository
+	validator interfaces.OrderValidator
+	batchSize int
+}
+
+// NewImporter создает импорт, batchSize <= 0 - DefaultBatchSize
+func NewImporter(repo interfaces.OrderRepository, validator interfaces.OrderValidator, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Importer{repo: repo, validator: validator, batchSize: batchSize}
+}
+
+// Import читает заказы из r и сохраняет их пачками по batchSize
+// Формат - JSON массив заказов или заказы подряд (JSON lines, вывод генератора).
+// Невалидная запись пропускается и попадает в Result.Failed, ошибка разбора
+// самого файла прерывает импорт: дальше границы записей неизвестны
+func (i *Importer) Import(ctx context.Context, r io.Reader) (*Result, error) {
+	result := &Result{}
+	batch := make([]record, 0, i.batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		i.saveBatch(ctx, batch, result)
+		batch = batch[:0]
+		return ctx.Err()
+	}
+
+	err := readRecords(r, func(rec record) error {
+		batch = append(batch, rec)
+		if len(batch) < i.batchSize {
+			return nil
+		}
+		return flush()
+	})
+	// Записи, прочитанные до ошибки разбора, все равно сохраняются
+	if flushErr := flush(); err == nil {
+		err = flushErr
+	}
+	return result, err
+}
+
+// record необработанная запись файла
+type record struct {
+	number int
+	data   json.RawMessage
+}
+
+// saveBatch проверяет и сохраняет пачку заказов
+func (i *Importer) saveBatch(ctx context.Context, batch []record, result *Result) {
+	imported := 0
+	for _, rec := range batch {
+		order, err := model.DecodeOrder("", rec.data)
+		if err != nil {
+			result.Failed = append(result.Failed, RecordError{Record: rec.number, Err: fmt.Errorf("decode: %w", err)})
+			continue
+		}
+		if err := i.validator.Validate(order); err != nil {
+			result.Failed = append(result.Failed, RecordError{Record: rec.number, OrderUID: order.OrderUID, Err: err})
+			continue
+		}
+		if err := i.repo.SaveOrder(ctx, order); err != nil {
+			result.Failed = append(result.Failed, RecordError{Record: rec.number, OrderUID: order.OrderUID, Err: fmt.Errorf("save: %w", err)})
+			continue
+		}
+		imported++
+	}
+	result.Imported += imported
+
+	log.Printf("Records %d-%d: imported %d of %d",
+		batch[0].number, batch[len(batch)-1].number, imported, len(batch))
+}
+
+// readRecords вызывает handle для каждой записи файла
+func readRecords(r io.Reader, handle func(record) error) error {
+	reader := bufio.NewReader(r)
+	decoder := json.NewDecoder(reader)
+
+	array, err := startsWithArray(reader)
+	if err != nil {
+		return err
+	}
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("read array start: %w", err)
+		}
+	}
+
+	for number := 1; ; number++ {
+		if array && !decoder.More() {
+			if _, err := decoder.Token(); err != nil {
+				return fmt.Errorf("read array end: %w", err)
+			}
+			return nil
+		}
+
+		var data json.RawMessage
+		if err := decoder.Decode(&data); err != nil {
+			if err == io.EOF && !array {
+				return nil
+			}
+			return fmt.Errorf("record %d: %w", number, err)
+		}
+		if err := handle(record{number: number, data: data}); err != nil {
+			return err
+		}
+	}
+}
+
+// startsWithArray сообщает, что первый значащий символ - начало JSON массива
+func startsWithArray(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			return b[0] == '[', nil
+		}
+		if _, err := reader.ReadByte(); err != nil {
+			return false, err
+		}
+	}
+}
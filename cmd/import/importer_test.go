@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wbtest/internal/mocks"
+	"wbtest/internal/model"
+	"wbtest/internal/validator"
+
+	"github.com/golang/mock/gomock"
+)
+
+// newImportOrder возвращает валидный заказ с заданным UID
+func newImportOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Now().Add(-time.Hour),
+		OofShard:        "1",
+	}
+}
+
+// writeImportFile записывает заказы во временный файл
+// Второй заказ невалиден: у него нет товаров
+func writeImportFile(t *testing.T, array bool) string {
+	t.Helper()
+
+	invalid := newImportOrder("import-order-2")
+	invalid.Items = nil
+	orders := []*model.Order{newImportOrder("import-order-1"), invalid, newImportOrder("import-order-3")}
+
+	var content []byte
+	if array {
+		data, err := json.MarshalIndent(orders, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = data
+	} else {
+		lines := make([]string, 0, len(orders))
+		for _, order := range orders {
+			data, err := json.Marshal(order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, string(data))
+		}
+		content = []byte(strings.Join(lines, "\n") + "\n")
+	}
+
+	path := filepath.Join(t.TempDir(), "orders.json")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImporter_Import(t *testing.T) {
+	for _, array := range []bool{false, true} {
+		t.Run(fmt.Sprintf("array=%v", array), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mocks.NewMockOrderRepository(ctrl)
+			var saved []string
+			repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, order *model.Order) error {
+					saved = append(saved, order.OrderUID)
+					return nil
+				}).Times(2)
+
+			f, err := os.Open(writeImportFile(t, array))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			importer := NewImporter(repo, validator.NewOrderValidator(), 2)
+			result, err := importer.Import(context.Background(), f)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+
+			if result.Imported != 2 {
+				t.Errorf("Imported = %d, want 2", result.Imported)
+			}
+			if len(result.Failed) != 1 {
+				t.Fatalf("Failed = %v, want 1 record", result.Failed)
+			}
+			failure := result.Failed[0]
+			if failure.Record != 2 || failure.OrderUID != "import-order-2" {
+				t.Errorf("Failed[0] = %+v, want record 2 import-order-2", failure)
+			}
+			if strings.Join(saved, ",") != "import-order-1,import-order-3" {
+				t.Errorf("saved = %v", saved)
+			}
+		})
+	}
+}
+
+func TestImporter_SaveError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockOrderRepository(ctrl)
+	repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(fmt.Errorf("connection refused")).Times(2)
+
+	f, err := os.Open(writeImportFile(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	result, err := NewImporter(repo, validator.NewOrderValidator(), 0).Import(context.Background(), f)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if result.Imported != 0 || len(result.Failed) != 3 {
+		t.Errorf("Imported = %d, Failed = %d, want 0 and 3", result.Imported, len(result.Failed))
+	}
+}
+
+func TestImporter_MalformedFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockOrderRepository(ctrl)
+	repo.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	data, err := json.Marshal(newImportOrder("import-order-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := string(data) + "\n{\"order_uid\": "
+
+	result, err := NewImporter(repo, validator.NewOrderValidator(), 10).Import(context.Background(), strings.NewReader(input))
+	if err == nil {
+		t.Fatal("Import() error = nil, want syntax error")
+	}
+	if result.Imported != 1 {
+		t.Errorf("Imported = %d, want 1", result.Imported)
+	}
+}
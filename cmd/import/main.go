@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"wbtest/internal/config"
+	"wbtest/internal/db"
+	"wbtest/internal/validator"
+)
+
+func main() {
+	var (
+		file      = flag.String("file", "", "Orders file: JSON array or JSON lines")
+		batchSize = flag.Int("batch", DefaultBatchSize, "Orders per batch")
+	)
+	flag.Parse()
+
+	if *file == "" {
+		fmt.Println("Usage: go run ./cmd/import -file <orders.json> [-batch 100]")
+		os.Exit(1)
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	itemStatuses, err := validator.ParseStatusRanges(cfg.Validation.ItemStatuses)
+	if err != nil {
+		log.Fatalf("Invalid VALIDATION_ITEM_STATUSES: %v", err)
+	}
+	orderUIDFormat, err := validator.ParseOrderUIDFormat(cfg.Validation.OrderUIDFormat, cfg.Validation.OrderUIDPattern)
+	if err != nil {
+		log.Fatalf("Invalid VALIDATION_ORDER_UID_FORMAT: %v", err)
+	}
+	orderValidator := validator.NewOrderValidator(
+		validator.WithItemTrackNumberMatch(cfg.Validation.ItemTrackNumberMatch),
+		validator.WithAllowedEntries(cfg.Validation.AllowedEntries),
+		validator.WithMaxFutureSkew(cfg.Validation.MaxFutureSkew),
+		validator.WithAllowedItemStatuses(itemStatuses),
+		validator.WithOrderUIDFormat(orderUIDFormat),
+	)
+
+	dbConn, err := db.New(cfg.DatabaseURL(),
+		db.WithStatementTimeout(cfg.Database.StatementTimeout),
+		db.WithConnectRetry(cfg.Database.ConnectMaxAttempts, cfg.Database.ConnectInitialDelay, cfg.Database.ConnectMaxDelay),
+	)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbConn.Close()
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := NewImporter(dbConn, orderValidator, *batchSize).Import(ctx, f)
+	for _, failure := range result.Failed {
+		log.Printf("Failed %v", failure)
+	}
+	log.Printf("Imported %d orders, failed %d", result.Imported, len(result.Failed))
+
+	if err != nil {
+		log.Fatalf("Import stopped: %v", err)
+	}
+	if len(result.Failed) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"wbtest/internal/model"
+)
+
+// OrderHook дополнительная обработка заказа из Kafka: обогащение, антифрод и т.п.
+// Хуки выполняются по порядку регистрации после валидации и до записи в БД и могут менять заказ
+// Ошибка хука отправляет сообщение в DLQ
+type OrderHook func(ctx context.Context, order *model.Order) error
+
+// namedHook хук с именем для логов и причины DLQ
+type namedHook struct {
+	name string
+	run  OrderHook
+}
+
+// registeredHooks хуки, зарегистрированные через RegisterHook
+var registeredHooks []namedHook
+
+// RegisterHook регистрирует хук для приложений, создаваемых NewApp
+// Вызывается из init() отдельного файла, так развертывание добавляет свою обработку
+// без изменений обработчика сообщений
+func RegisterHook(name string, hook OrderHook) {
+	registeredHooks = append(registeredHooks, namedHook{name: name, run: hook})
+}
+
+// AddHook добавляет хук приложению
+func (a *App) AddHook(name string, hook OrderHook) {
+	a.hooks = append(a.hooks, namedHook{name: name, run: hook})
+}
+
+// HookError ошибка хука обработки заказа
+type HookError struct {
+	Hook     string
+	OrderUID string
+	Err      error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s rejected order %s: %v", e.Hook, e.OrderUID, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// runHooks выполняет хуки приложения по порядку, первая ошибка останавливает обработку
+func (h *MessageHandler) runHooks(ctx context.Context, order *model.Order) error {
+	for _, hook := range h.app.hooks {
+		if err := hook.run(ctx, order); err != nil {
+			return &HookError{Hook: hook.name, OrderUID: order.OrderUID, Err: err}
+		}
+	}
+	return nil
+}
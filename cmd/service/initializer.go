@@ -5,37 +5,69 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/pprof"
 	"strconv"
 	"time"
 
 	"wbtest/internal/cache"
+	"wbtest/internal/circuitbreaker"
 	"wbtest/internal/config"
 	"wbtest/internal/db"
 	"wbtest/internal/dlq"
+	"wbtest/internal/health"
 	httpapi "wbtest/internal/http"
 	"wbtest/internal/interfaces"
 	"wbtest/internal/kafka"
+	"wbtest/internal/logger"
+	"wbtest/internal/metrics"
 	"wbtest/internal/migrations"
 	"wbtest/internal/model"
+	"wbtest/internal/ratelimit"
 	"wbtest/internal/retry"
 	"wbtest/internal/validator"
+
+	"github.com/sirupsen/logrus"
 )
 
 // App представляет основное приложение
 type App struct {
 	Config       *config.Config
+	Logger       *logger.Logger
 	DB           interfaces.OrderRepository
 	Cache        interfaces.OrderCache
 	Validator    interfaces.OrderValidator
 	Consumer     interfaces.MessageConsumer
 	RetryService interfaces.RetryService
 	DLQService   interfaces.DLQService
+	Producer     interfaces.MessageProducer
 	HTTPServer   *http.Server
+	InFlight     *httpapi.InFlightMiddleware
+	Metrics      *metrics.Metrics
+	// AdminServer служебный сервер с pprof, nil если pprof выключен
+	AdminServer *http.Server
+	// Outbound публикует события о сохраненных заказах, nil если OUTBOUND_TOPIC не задан
+	Outbound interfaces.MessageProducer
+	// StatsHistory снимки статистики кеша для /stats/history, nil если история выключена
+	StatsHistory *cache.StatsHistory
+	// Reconciler сверка кеша с БД, nil если сверка выключена
+	Reconciler *cache.Reconciler
+	// Health фоновые проверки зависимостей для /health, nil если проверки выполняются на каждый запрос
+	Health *health.Health
+
+	// hooks дополнительная обработка заказов из Kafka перед записью в БД
+	hooks []namedHook
 }
 
 // NewApp создает приложение с компонентами
 func NewApp(cfg *config.Config) (*App, error) {
-	app := &App{Config: cfg}
+	app := &App{Config: cfg, Logger: logger.New(cfg.Logger)}
+	// Формат времени задается до первой сериализации заказа
+	if err := model.SetTimeFormat(cfg.App.JSONTimeFormat); err != nil {
+		return nil, err
+	}
+	if cfg.Metrics.Enabled {
+		app.Metrics = metrics.New()
+	}
 
 	// Инициализация БД
 	if err := app.initDB(); err != nil {
@@ -48,11 +80,18 @@ func NewApp(cfg *config.Config) (*App, error) {
 	}
 
 	// Инициализация валидатора
-	app.initValidator()
+	if err := app.initValidator(); err != nil {
+		return nil, err
+	}
 
 	// Инициализация retry сервиса
 	app.initRetryService()
 
+	// Инициализация Kafka producer
+	if err := app.initKafkaProducer(); err != nil {
+		return nil, err
+	}
+
 	// Инициализация DLQ сервиса
 	if err := app.initDLQService(); err != nil {
 		return nil, err
@@ -63,9 +102,15 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
+	// Хуки обработки заказов, зарегистрированные через RegisterHook
+	app.initHooks()
+
 	// Инициализация HTTP сервера
 	app.initHTTPServer()
 
+	// Инициализация служебного сервера
+	app.initAdminServer()
+
 	return app, nil
 }
 
@@ -73,12 +118,18 @@ func NewApp(cfg *config.Config) (*App, error) {
 func (a *App) initDB() error {
 	log.Println("Initializing database connection...")
 
-	dbConn, err := db.New(a.Config.DatabaseURL())
+	dbCfg := a.Config.Database
+	dbConn, err := db.New(a.Config.DatabaseURL(),
+		db.WithStatementTimeout(dbCfg.StatementTimeout),
+		db.WithConnectRetry(dbCfg.ConnectMaxAttempts, dbCfg.ConnectInitialDelay, dbCfg.ConnectMaxDelay),
+	)
 	if err != nil {
 		return err
 	}
 
-	a.DB = dbConn
+	a.DB = dbConn.WithSoftDelete(a.Config.Database.SoftDelete).
+		WithSlowQueryLog(a.Config.Database.SlowQueryThreshold, a.Logger.ForComponent("db")).
+		WithMetrics(a.Metrics)
 	log.Println("Database connected successfully")
 	return nil
 }
@@ -88,46 +139,181 @@ func (a *App) initCache() error {
 	log.Println("Initializing cache...")
 
 	// Создаем кеш с настройками из конфигурации
-	orderCache := cache.NewOrderCache(a.Config.Cache.MaxSize, time.Duration(a.Config.Cache.TTLMinutes)*time.Minute)
+	opts := []cache.Option{
+		cache.WithEvictionStrategy(cache.EvictionStrategy(a.Config.Cache.EvictionStrategy)),
+		cache.WithEvictionWarnRate(a.Config.Cache.EvictionWarnRate),
+		cache.WithCleanupInterval(a.Config.Cache.CleanupInterval),
+		cache.WithMetrics(a.Metrics),
+		cache.WithLogger(a.Logger.ForComponent("cache")),
+	}
+	if a.Config.Cache.RecentOrderAge > 0 {
+		opts = append(opts, cache.WithTTLPolicy(cache.RecencyTTLPolicy(a.Config.Cache.RecentOrderAge, a.Config.Cache.OldOrderTTL)))
+	}
+	orderCache := cache.NewOrderCache(
+		a.Config.Cache.MaxSize,
+		time.Duration(a.Config.Cache.TTLMinutes)*time.Minute,
+		opts...,
+	)
 	a.Cache = orderCache
 
+	if a.Config.Cache.StatsHistoryInterval > 0 {
+		a.StatsHistory = cache.NewStatsHistory(orderCache, a.Config.Cache.StatsHistorySize)
+		a.StatsHistory.Start(a.Config.Cache.StatsHistoryInterval)
+	}
+
 	// Пытаемся загрузить заказы из БД в кеш
 	log.Println("Loading orders from database...")
-	ctx, cancel := context.WithTimeout(context.Background(), a.Config.App.DatabaseLoadTimeout)
-	defer cancel()
-
-	orders, err := a.DB.LoadAllOrders(ctx)
+	loaded, err := a.warmCache(orderCache.(*cache.OrderCache))
 	if err != nil {
 		log.Printf("Warning: Failed to load orders from database: %v", err)
 		log.Println("Starting with empty cache...")
-		orders = []*model.Order{}
 	}
-
-	a.Cache.LoadAll(orders)
-	log.Printf("Cache loaded: %d orders", len(orders))
+	log.Printf("Cache loaded: %d orders (workers=%d)", loaded, a.Config.Cache.LoadWorkers)
+
+	if a.Config.Cache.ReconcileInterval > 0 && a.DB != nil {
+		a.Reconciler = cache.NewReconciler(orderCache.(*cache.OrderCache), a.DB, a.Config.Cache.ReconcileSampleRate).
+			WithHealing(a.Config.Cache.ReconcileHeal).
+			WithGracePeriod(a.Config.Cache.ReconcileInterval).
+			WithMetrics(a.Metrics).
+			WithLogger(a.Logger.ForComponent("cache"))
+		a.Reconciler.Start(a.Config.Cache.ReconcileInterval)
+	}
 
 	return nil
 }
 
+// warmCache загружает заказы из БД в кеш
+// Если БД ненадолго недоступна на старте, загрузка повторяется с backoff по CACHE_WARM_*,
+// каждая попытка получает свой DB_LOAD_TIMEOUT
+func (a *App) warmCache(orderCache *cache.OrderCache) (int, error) {
+	warm := retry.NewRetryService(&config.RetryConfig{
+		MaxAttempts:  max(a.Config.Cache.WarmMaxAttempts, 1),
+		InitialDelay: a.Config.Cache.WarmInitialDelay,
+		MaxDelay:     a.Config.Cache.WarmMaxDelay,
+		Multiplier:   2,
+	})
+
+	var loaded int
+	attempt := 0
+	err := warm.ExecuteWithRetry(func() error {
+		attempt++
+		ctx, cancel := context.WithTimeout(context.Background(), a.Config.App.DatabaseLoadTimeout)
+		defer cancel()
+
+		var err error
+		loaded, err = cache.Load(ctx, a.DB, orderCache, a.Config.Cache.LoadWorkers)
+		if err != nil {
+			log.Printf("Cache warm attempt %d failed: %v", attempt, err)
+		}
+		return err
+	})
+	return loaded, err
+}
+
 // initValidator создает валидатор
-func (a *App) initValidator() {
+func (a *App) initValidator() error {
 	log.Println("Initializing validator...")
-	a.Validator = validator.NewOrderValidator()
+
+	itemStatuses, err := validator.ParseStatusRanges(a.Config.Validation.ItemStatuses)
+	if err != nil {
+		return fmt.Errorf("invalid VALIDATION_ITEM_STATUSES: %w", err)
+	}
+	orderUIDFormat, err := validator.ParseOrderUIDFormat(a.Config.Validation.OrderUIDFormat, a.Config.Validation.OrderUIDPattern)
+	if err != nil {
+		return fmt.Errorf("invalid VALIDATION_ORDER_UID_FORMAT: %w", err)
+	}
+
+	a.Validator = validator.NewOrderValidator(
+		validator.WithItemTrackNumberMatch(a.Config.Validation.ItemTrackNumberMatch),
+		validator.WithAllowedEntries(a.Config.Validation.AllowedEntries),
+		validator.WithMaxFutureSkew(a.Config.Validation.MaxFutureSkew),
+		validator.WithAllowedItemStatuses(itemStatuses),
+		validator.WithOrderUIDFormat(orderUIDFormat),
+	)
 	log.Println("Validator initialized")
+	return nil
 }
 
 // initRetryService создает retry сервис
 func (a *App) initRetryService() {
 	log.Println("Initializing retry service...")
-	a.RetryService = retry.NewRetryService(&a.Config.Retry)
+	service := retry.NewRetryService(&a.Config.Retry).(*retry.RetryService)
+	a.RetryService = service.WithMetrics(a.Metrics).WithRetryable(isRetryable)
+	a.watchBreaker("retry", service.Breaker())
 	log.Println("Retry service initialized")
 }
 
+// watchBreaker публикует состояние breaker name в метриках и пишет в лог его переходы
+// Алерт на открытие любого breaker настраивается по circuit_breaker_state == 1
+// nil breaker (бюджет повторов выключен) игнорируется
+func (a *App) watchBreaker(name string, breaker *circuitbreaker.CircuitBreaker) {
+	if breaker == nil {
+		return
+	}
+	if a.Metrics != nil {
+		a.Metrics.SetBreakerState(name, breaker.GetState())
+	}
+
+	// Callback выполняется под блокировкой breaker, обращаться к нему отсюда нельзя
+	breaker.WithStateChangeCallback(func(from, to circuitbreaker.State) {
+		if a.Metrics != nil {
+			a.Metrics.BreakerStateChanged(name, from, to)
+		}
+		if from == to {
+			return
+		}
+		entry := a.Logger.WithFields(logrus.Fields{"breaker": name, "from": from.String(), "to": to.String()})
+		if to == circuitbreaker.StateOpen {
+			entry.Warn("Circuit breaker opened")
+		} else {
+			entry.Info("Circuit breaker state changed")
+		}
+	})
+}
+
+// initKafkaProducer создает Kafka producer основного топика
+func (a *App) initKafkaProducer() error {
+	log.Printf("Initializing Kafka producer: brokers=%v, topic=%s", a.Config.Kafka.Brokers, a.Config.Kafka.Topic)
+
+	compression, err := kafka.ParseCompression(a.Config.Kafka.Compression)
+	if err != nil {
+		return fmt.Errorf("invalid kafka config: %w", err)
+	}
+	a.Producer = kafka.NewProducer(a.Config.Kafka.Brokers, a.Config.Kafka.Topic).WithCompression(compression)
+
+	if a.Config.Kafka.OutboundTopic != "" {
+		log.Printf("Initializing outbound producer: topic=%s", a.Config.Kafka.OutboundTopic)
+		// Событие отправляется асинхронно: синхронная запись ждала бы накопления пачки
+		// и задерживала обработку каждого заказа
+		a.Outbound = kafka.NewProducer(a.Config.Kafka.Brokers, a.Config.Kafka.OutboundTopic).
+			WithCompression(compression).
+			WithAsync(a.logOutboundError)
+	}
+
+	log.Println("Kafka producer initialized")
+	return nil
+}
+
+// logOutboundError записывает в лог событие, которое не удалось доставить в OUTBOUND_TOPIC
+func (a *App) logOutboundError(key []byte, err error) {
+	a.Logger.ForComponent("kafka").Errorf("[KAFKA] Failed to publish processed event for order %s: %v", key, err)
+}
+
 // initDLQService создает DLQ сервис
 func (a *App) initDLQService() error {
 	log.Println("Initializing DLQ service...")
 
-	a.DLQService = dlq.NewDLQService(&a.Config.DLQ, a.Config.Kafka.Brokers)
+	// Повторная отправка в основной топик только при явном включении
+	var requeue interfaces.MessageProducer
+	if a.Config.DLQ.RequeueEnabled {
+		requeue = a.Producer
+	}
+
+	compression, err := kafka.ParseCompression(a.Config.Kafka.Compression)
+	if err != nil {
+		return fmt.Errorf("invalid kafka config: %w", err)
+	}
+	a.DLQService = dlq.NewDLQService(&a.Config.DLQ, a.Config.Kafka.Brokers, requeue, dlq.WithCompression(compression))
 	log.Println("DLQ service initialized")
 
 	return nil
@@ -138,24 +324,114 @@ func (a *App) initKafkaConsumer() error {
 	log.Printf("Initializing Kafka consumer: brokers=%v, topic=%s, groupID=%s",
 		a.Config.Kafka.Brokers, a.Config.Kafka.Topic, a.Config.Kafka.GroupID)
 
-	consumer := kafka.NewConsumer(a.Config.Kafka.Brokers, a.Config.Kafka.Topic, a.Config.Kafka.GroupID)
+	balancer, err := kafka.ParseGroupBalancer(a.Config.Kafka.GroupBalancer)
+	if err != nil {
+		return fmt.Errorf("invalid kafka config: %w", err)
+	}
+
+	consumer := kafka.NewConsumer(a.Config.Kafka.Brokers, a.Config.Kafka.Topic, a.Config.Kafka.GroupID,
+		kafka.WithGroupBalancer(balancer),
+		kafka.WithSessionTimeout(time.Duration(a.Config.Kafka.SessionTimeoutMs)*time.Millisecond),
+		kafka.WithHeartbeatInterval(time.Duration(a.Config.Kafka.HeartbeatIntervalMs)*time.Millisecond),
+	).
+		WithReconnectBackoff(a.Config.Kafka.ReconnectMinBackoff, a.Config.Kafka.ReconnectMaxBackoff).
+		WithConcurrency(a.Config.Kafka.ConsumerConcurrency).
+		WithDrainTimeout(a.Config.App.ShutdownTimeout(a.Config.App.KafkaShutdownTimeout)).
+		WithPanicHandler(a.sendPanicToDLQ).
+		WithLogger(a.Logger.ForComponent("kafka")).
+		WithMetrics(a.Metrics)
 	a.Consumer = consumer
 
 	log.Println("Kafka consumer initialized")
 	return nil
 }
 
+// sendPanicToDLQ отправляет в DLQ сообщение, на котором обработчик запаниковал
+// Consumer уже записал панику в лог и пропустил сообщение, без DLQ оно было бы потеряно
+// ctx несет заголовки сообщения (kafka.HeadersFromContext), они сохраняются в DLQ для повтора
+func (a *App) sendPanicToDLQ(ctx context.Context, msg []byte, recovered interface{}) {
+	if a.DLQService == nil {
+		return
+	}
+	reason := fmt.Sprintf("%s: %v", dlq.ReasonPanic, recovered)
+	if err := a.DLQService.SendToDLQ(ctx, msg, reason); err != nil {
+		a.Logger.ForComponent("kafka").Errorf("Failed to send panicked message to DLQ: %v", err)
+	}
+}
+
+// initHooks подключает зарегистрированные хуки обработки заказов
+func (a *App) initHooks() {
+	for _, hook := range registeredHooks {
+		a.AddHook(hook.name, hook.run)
+		log.Printf("Order hook registered: %s", hook.name)
+	}
+}
+
 // initHTTPServer создает HTTP сервер
 func (a *App) initHTTPServer() {
 	log.Println("Initializing HTTP server...")
 
 	// Создаем API с кешем и БД
-	api := httpapi.NewServer(a.Cache, a.DB)
+	api := httpapi.NewServer(a.Cache, a.DB).
+		WithAdmin(a.Config.HTTP.AdminEnabled).
+		WithMaxBodyBytes(a.Config.HTTP.MaxBodyBytes).
+		WithOrderMaxAge(a.Config.HTTP.OrderCacheMaxAge).
+		WithDBReadTimeout(a.Config.HTTP.DBReadTimeout).
+		WithCacheReloadInterval(a.Config.HTTP.CacheReloadInterval).
+		WithStatsTTL(a.Config.HTTP.StatsCacheTTL).
+		WithPrettyJSON(a.Config.HTTP.PrettyJSON).
+		WithMaxListRows(a.Config.HTTP.MaxListRows).
+		WithMaintenance(a.Config.HTTP.MaintenanceMode).
+		WithStrictJSON(a.Config.Validation.StrictJSON).
+		WithRequireSchemaVersion(a.Config.HTTP.RequireSchemaVersion).
+		WithStatsHistory(a.StatsHistory)
+
+	// /health проверяет доступность БД, Kafka и топика DLQ, /health/ready добавляет их версии
+	checks := health.New()
+	if dbConn, ok := a.DB.(*db.DB); ok {
+		checks.AddChecker(health.NewDatabaseChecker("postgres", dbConn.Ping).WithVersion(dbConn.ServerVersion))
+	}
+	if consumer, ok := a.Consumer.(*kafka.Consumer); ok {
+		checks.AddChecker(health.NewKafkaChecker("kafka", consumer.Ping).WithVersion(consumer.ClusterInfo))
+	}
+	if a.DLQService != nil {
+		checks.AddChecker(health.NewDLQChecker("dlq", a.DLQService))
+	}
+	api.WithHealth(checks)
+	// Фоновые проверки избавляют БД и Kafka от нагрузки частыми пробами
+	if a.Config.HTTP.HealthCheckInterval > 0 {
+		checks.WithMaxStaleness(a.Config.HTTP.HealthMaxStaleness).Start(a.Config.HTTP.HealthCheckInterval)
+		a.Health = checks
+	}
+
+	// Бюджет повторов можно сбросить через /admin/breaker/reset
+	if retryService, ok := a.RetryService.(*retry.RetryService); ok {
+		api.WithBreaker("retry", retryService.Breaker())
+	}
+
+	// Оборачиваем API в middleware
+	var handler http.Handler = api
+	handler = httpapi.NewAuthMiddleware(a.Config.HTTP.APIKeys).
+		WithProtectReads(a.Config.HTTP.AuthProtectReads).
+		Handler(handler)
+	handler = httpapi.NewTimeoutMiddleware(a.Config.HTTP.RequestTimeout).
+		WithExempt(httpapi.ExportPath).
+		Handler(handler)
+	a.InFlight = httpapi.NewInFlightMiddleware()
+	handler = a.InFlight.Handler(handler)
+	handler = a.rateLimit(handler)
+	handler = httpapi.NewAccessLogMiddleware(a.Logger.Logger).Handler(handler)
+	// Метрики учитывают и запросы, отклоненные лимитом или при остановке
+	if a.Metrics != nil {
+		handler = a.Metrics.WithRouteLabel(httpapi.RouteLabel).HTTPMiddleware(handler)
+	}
+	// Recovery оборачивает все остальные middleware
+	handler = httpapi.NewRecoveryMiddleware(a.Logger.Logger).Handler(handler)
 
 	// Создаем HTTP сервер
 	a.HTTPServer = &http.Server{
 		Addr:         ":" + strconv.Itoa(a.Config.HTTP.Port),
-		Handler:      api,
+		Handler:      handler,
 		ReadTimeout:  a.Config.HTTP.ReadTimeout,
 		WriteTimeout: a.Config.HTTP.WriteTimeout,
 		IdleTimeout:  a.Config.HTTP.IdleTimeout,
@@ -164,11 +440,80 @@ func (a *App) initHTTPServer() {
 	log.Printf("HTTP server configured on port %d", a.Config.HTTP.Port)
 }
 
+// rateLimit ограничивает частоту запросов к API по IP клиента
+// Запросы сверх лимита отклоняются до аутентификации и обработки, но попадают в access log
+func (a *App) rateLimit(next http.Handler) http.Handler {
+	cfg := a.Config.RateLimit
+	if cfg.Requests <= 0 {
+		return next
+	}
+
+	// Подсети уже проверены валидатором конфигурации
+	allow, _ := ratelimit.ParseIPList(cfg.AllowCIDRs)
+	deny, _ := ratelimit.ParseIPList(cfg.DenyCIDRs)
+
+	log.Printf("Rate limit: %d requests per %v, %d allowed and %d denied networks",
+		cfg.Requests, cfg.Window, len(allow), len(deny))
+	return ratelimit.NewMiddleware(ratelimit.MiddlewareConfig{
+		Requests: cfg.Requests,
+		Window:   cfg.Window,
+		Burst:    cfg.Burst,
+	}, a.Logger.Logger).
+		WithAllowList(allow).
+		WithDenyList(deny).
+		Handler(next)
+}
+
+// initAdminServer создает служебный сервер на порту метрик: метрики и, при PPROF_ENABLED, pprof
+// Служебные эндпоинты не регистрируются на сервере API, чтобы не быть доступными снаружи
+func (a *App) initAdminServer() {
+	if a.Metrics == nil && !a.Config.Metrics.PprofEnabled {
+		return
+	}
+
+	mux := http.NewServeMux()
+	if a.Config.Metrics.PprofEnabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	if a.Metrics != nil {
+		mux.Handle(a.Config.Metrics.Path, a.Metrics.Handler())
+	}
+
+	a.AdminServer = &http.Server{
+		Addr:        ":" + strconv.Itoa(a.Config.Metrics.Port),
+		Handler:     mux,
+		ReadTimeout: a.Config.HTTP.ReadTimeout,
+		IdleTimeout: a.Config.HTTP.IdleTimeout,
+	}
+
+	log.Printf("Admin server configured on port %d", a.Config.Metrics.Port)
+}
+
 // Close закрывает ресурсы
 func (a *App) Close() error {
 	log.Println("Closing application resources...")
 
+	a.closeDB()
+	a.closeKafka()
+	a.closeDLQ()
+
+	log.Println("Application resources closed")
+	return nil
+}
+
+// closeDB останавливает кеш и закрывает БД
+func (a *App) closeDB() {
 	// Закрываем кеш
+	if a.StatsHistory != nil {
+		a.StatsHistory.Stop()
+	}
+	if a.Reconciler != nil {
+		a.Reconciler.Stop()
+	}
 	if cacheImpl, ok := a.Cache.(*cache.OrderCache); ok {
 		cacheImpl.Stop()
 	}
@@ -177,7 +522,10 @@ func (a *App) Close() error {
 	if a.DB != nil {
 		a.DB.Close()
 	}
+}
 
+// closeKafka закрывает consumer и producer-ы
+func (a *App) closeKafka() {
 	// Закрываем Kafka consumer
 	if a.Consumer != nil {
 		if err := a.Consumer.Close(); err != nil {
@@ -185,15 +533,28 @@ func (a *App) Close() error {
 		}
 	}
 
-	// Закрываем DLQ service
+	// Закрываем Kafka producer
+	if a.Producer != nil {
+		if err := a.Producer.Close(); err != nil {
+			log.Printf("Error closing Kafka producer: %v", err)
+		}
+	}
+
+	// Закрываем producer событий о сохраненных заказах
+	if a.Outbound != nil {
+		if err := a.Outbound.Close(); err != nil {
+			log.Printf("Error closing outbound producer: %v", err)
+		}
+	}
+}
+
+// closeDLQ закрывает DLQ service
+func (a *App) closeDLQ() {
 	if a.DLQService != nil {
 		if err := a.DLQService.Close(); err != nil {
 			log.Printf("Error closing DLQ service: %v", err)
 		}
 	}
-
-	log.Println("Application resources closed")
-	return nil
 }
 
 // runMigrations запускает миграции базы данных
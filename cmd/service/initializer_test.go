@@ -1,10 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"wbtest/internal/circuitbreaker"
 	"wbtest/internal/config"
+	"wbtest/internal/dlq"
+	httpapi "wbtest/internal/http"
+	"wbtest/internal/kafka"
+	"wbtest/internal/logger"
+	"wbtest/internal/metrics"
+	"wbtest/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewApp(t *testing.T) {
@@ -72,3 +87,321 @@ func TestApp_Close(t *testing.T) {
 		t.Errorf("Expected no error when closing empty app, got: %v", err)
 	}
 }
+
+func TestApp_initAdminServer_Pprof(t *testing.T) {
+	cfg := &config.Config{
+		HTTP:    config.HTTPConfig{Port: 8080},
+		Metrics: config.MetricsConfig{Port: 9090, Path: "/metrics", PprofEnabled: true},
+	}
+	app := &App{Config: cfg, Logger: logger.New(cfg.Logger), Cache: NewMockCache()}
+	app.initHTTPServer()
+	app.initAdminServer()
+
+	if app.AdminServer == nil {
+		t.Fatal("Expected admin server to be created when pprof is enabled")
+	}
+	if app.AdminServer.Addr != ":9090" {
+		t.Errorf("Expected admin server on :9090, got %s", app.AdminServer.Addr)
+	}
+
+	rr := httptest.NewRecorder()
+	app.AdminServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected pprof status %d on admin server, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	app.HTTPServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected pprof to be absent on API server, got status %d", rr.Code)
+	}
+}
+
+func TestApp_initAdminServer_Metrics(t *testing.T) {
+	cfg := &config.Config{
+		HTTP:    config.HTTPConfig{Port: 8080},
+		Metrics: config.MetricsConfig{Enabled: true, Port: 9090, Path: "/metrics"},
+	}
+	app := &App{
+		Config:  cfg,
+		Logger:  logger.New(cfg.Logger),
+		Cache:   NewMockCache(),
+		Metrics: metrics.NewWithRegistry(prometheus.NewRegistry()),
+	}
+	app.initHTTPServer()
+	app.initAdminServer()
+
+	if app.AdminServer == nil {
+		t.Fatal("Expected admin server to be created when metrics are enabled")
+	}
+
+	rr := httptest.NewRecorder()
+	app.AdminServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected metrics status %d on admin server, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	app.AdminServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected pprof to be absent when disabled, got status %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	app.HTTPServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected metrics to be absent on API server, got status %d", rr.Code)
+	}
+}
+
+// inFlightCache запоминает число выполняющихся HTTP запросов в момент чтения из кеша
+type inFlightCache struct {
+	*MockCache
+	gauge    prometheus.Gauge
+	inFlight float64
+}
+
+func (c *inFlightCache) Get(orderUID string) (*model.Order, bool) {
+	c.inFlight = testutil.ToFloat64(c.gauge)
+	return c.MockCache.Get(orderUID)
+}
+
+func TestApp_initHTTPServer_Metrics(t *testing.T) {
+	cfg := &config.Config{}
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	cache := &inFlightCache{MockCache: NewMockCache(), gauge: m.InFlightRequests}
+	cache.Set(&model.Order{OrderUID: "b563feb7b2b84b6test"})
+	app := &App{Config: cfg, Logger: logger.New(cfg.Logger), Cache: cache, Metrics: m}
+	app.initHTTPServer()
+
+	rr := httptest.NewRecorder()
+	app.HTTPServer.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/order/b563feb7b2b84b6test", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// Обработчик API выполняется внутри middleware метрик
+	if cache.inFlight != 1 {
+		t.Errorf("Expected 1 in-flight request during handling, got %v", cache.inFlight)
+	}
+	if got := testutil.ToFloat64(m.InFlightRequests); got != 0 {
+		t.Errorf("Expected 0 in-flight requests after completion, got %v", got)
+	}
+	// Номер заказа не попадает в метки
+	if got := testutil.ToFloat64(m.HTTPRequestsTotal.WithLabelValues("GET", "/order/{uid}", "OK")); got != 1 {
+		t.Errorf("Expected request counted under /order/{uid}, got %v", got)
+	}
+}
+
+func TestApp_initHTTPServer_RateLimit(t *testing.T) {
+	cfg := &config.Config{
+		RateLimit: config.RateLimitConfig{
+			Requests:   1,
+			Window:     time.Hour,
+			AllowCIDRs: []string{"10.0.0.0/8"},
+			DenyCIDRs:  []string{"203.0.113.0/24"},
+		},
+	}
+	app := &App{Config: cfg, Logger: logger.New(cfg.Logger), Cache: NewMockCache()}
+	app.initHTTPServer()
+
+	status := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		app.HTTPServer.Handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Адрес из allowlist не ограничивается
+	for i := 0; i < 3; i++ {
+		if code := status("10.1.2.3:5000"); code == http.StatusTooManyRequests || code == http.StatusForbidden {
+			t.Fatalf("Expected allowlisted request %d to pass, got status %d", i, code)
+		}
+	}
+
+	// Адрес из denylist всегда отклоняется
+	if code := status("203.0.113.7:5000"); code != http.StatusForbidden {
+		t.Errorf("Expected denylisted request to get %d, got %d", http.StatusForbidden, code)
+	}
+
+	// Остальные адреса ограничиваются лимитом
+	if code := status("192.0.2.1:5000"); code == http.StatusTooManyRequests {
+		t.Fatalf("Expected first request to pass, got status %d", code)
+	}
+	if code := status("192.0.2.1:5000"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected second request to get %d, got %d", http.StatusTooManyRequests, code)
+	}
+}
+
+func TestApp_initAdminServer_Disabled(t *testing.T) {
+	app := &App{Config: &config.Config{}}
+	app.initAdminServer()
+
+	if app.AdminServer != nil {
+		t.Error("Expected no admin server when metrics and pprof are disabled")
+	}
+}
+
+func TestApp_initCache_RetriesWarm(t *testing.T) {
+	tests := []struct {
+		name      string
+		loadErrs  int
+		wantLoads int
+		wantWarm  bool
+	}{
+		{name: "db recovers", loadErrs: 2, wantLoads: 3, wantWarm: true},
+		{name: "db stays down", loadErrs: 5, wantLoads: 3, wantWarm: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDB.orders["order-1"] = &model.Order{OrderUID: "order-1"}
+			for i := 0; i < tt.loadErrs; i++ {
+				mockDB.loadErrs = append(mockDB.loadErrs, errors.New("connection refused"))
+			}
+
+			cfg := &config.Config{
+				Cache: config.CacheConfig{
+					MaxSize:          100,
+					TTLMinutes:       60,
+					CleanupInterval:  5 * time.Minute,
+					WarmMaxAttempts:  3,
+					WarmInitialDelay: time.Millisecond,
+					WarmMaxDelay:     time.Millisecond,
+				},
+				App: config.AppConfig{DatabaseLoadTimeout: time.Second},
+			}
+			app := &App{Config: cfg, Logger: logger.New(cfg.Logger), DB: mockDB}
+
+			if err := app.initCache(); err != nil {
+				t.Fatalf("initCache() error = %v", err)
+			}
+			defer app.Cache.Stop()
+
+			if mockDB.loads != tt.wantLoads {
+				t.Errorf("Expected %d load attempts, got %d", tt.wantLoads, mockDB.loads)
+			}
+			if _, ok := app.Cache.Get("order-1"); ok != tt.wantWarm {
+				t.Errorf("Expected cached order = %v, got %v", tt.wantWarm, ok)
+			}
+		})
+	}
+}
+
+func TestApp_watchBreaker(t *testing.T) {
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	app := &App{Config: &config.Config{}, Logger: logger.New(logger.Config{Level: "info"}), Metrics: m}
+
+	breaker := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: time.Hour})
+	app.watchBreaker("retry", breaker)
+
+	state := m.CircuitBreakerState.WithLabelValues("retry")
+	if got := testutil.ToFloat64(state); got != float64(circuitbreaker.StateClosed) {
+		t.Errorf("Expected closed breaker gauge 0, got %v", got)
+	}
+
+	// Одна ошибка открывает breaker с FailureThreshold 1
+	_, _ = breaker.Execute(context.Background(), func() (interface{}, error) {
+		return nil, errors.New("connection refused")
+	})
+	if breaker.GetState() != circuitbreaker.StateOpen {
+		t.Fatalf("Expected breaker to be open, got %s", breaker.GetState())
+	}
+	if got := testutil.ToFloat64(state); got != float64(circuitbreaker.StateOpen) {
+		t.Errorf("Expected open breaker gauge 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.CircuitBreakerTransitions.WithLabelValues("retry", "closed", "open")); got != 1 {
+		t.Errorf("Expected 1 closed->open transition, got %v", got)
+	}
+
+	breaker.Reset()
+	if got := testutil.ToFloat64(state); got != float64(circuitbreaker.StateClosed) {
+		t.Errorf("Expected gauge 0 after reset, got %v", got)
+	}
+
+	// Сброс закрытого breaker не считается переходом
+	breaker.Reset()
+	if got := testutil.CollectAndCount(m.CircuitBreakerTransitions); got != 2 {
+		t.Errorf("Expected 2 transition series, got %d", got)
+	}
+}
+
+func TestApp_sendPanicToDLQ(t *testing.T) {
+	dlqService := &MockDLQService{}
+	app := &App{Config: &config.Config{}, Logger: logger.New(logger.Config{Level: "info"}), DLQService: dlqService}
+
+	ctx := kafka.ContextWithHeaders(context.Background(), kafka.Headers{kafka.HeaderTraceID: "trace-123"})
+	app.sendPanicToDLQ(ctx, []byte(`{"order_uid":"b563feb7b2b84b6test"}`), "index out of range")
+
+	if len(dlqService.reasons) != 1 {
+		t.Fatalf("Expected 1 DLQ message, got %d", len(dlqService.reasons))
+	}
+	reason := dlqService.reasons[0]
+	if dlq.ReasonCategory(reason) != dlq.ReasonPanic || !strings.Contains(reason, "index out of range") {
+		t.Errorf("Expected panic reason with panic value, got %q", reason)
+	}
+	if !dlq.IsPermanentReason(reason) {
+		t.Error("Expected panic reason to be permanent")
+	}
+	if got := dlqService.headers[0][kafka.HeaderTraceID]; got != "trace-123" {
+		t.Errorf("Expected message headers to reach DLQ, got trace-id %q", got)
+	}
+
+	// Без DLQ сообщение только пропускается
+	app.DLQService = nil
+	app.sendPanicToDLQ(context.Background(), []byte("{}"), "boom")
+}
+
+func TestApp_stopHTTP_DrainDelay(t *testing.T) {
+	inFlight := httpapi.NewInFlightMiddleware()
+	server := httptest.NewServer(inFlight.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer server.Close()
+
+	app := &App{
+		Config:     &config.Config{App: config.AppConfig{HTTPDrainDelay: 100 * time.Millisecond}},
+		HTTPServer: server.Config,
+		InFlight:   inFlight,
+	}
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- app.stopHTTP(context.Background()) }()
+
+	// Во время паузы listener открыт, а новые запросы получают 503
+	time.Sleep(20 * time.Millisecond)
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected listener to stay open during drain delay: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 during drain delay, got %d", resp.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("stopHTTP() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected shutdown to wait for drain delay, took %v", elapsed)
+	}
+}
+
+func TestApp_stopHTTP_DrainDelayCancelled(t *testing.T) {
+	app := &App{
+		Config:   &config.Config{App: config.AppConfig{HTTPDrainDelay: time.Hour}},
+		InFlight: httpapi.NewInFlightMiddleware(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	app.stopHTTP(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected drain delay to stop at shutdown deadline, took %v", elapsed)
+	}
+}
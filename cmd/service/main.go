@@ -7,7 +7,6 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"wbtest/internal/config"
 	"wbtest/internal/logger"
@@ -38,11 +37,16 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("Failed to initialize application")
 	}
-	defer func() {
-		if err := app.Close(); err != nil {
-			log.WithError(err).Error("Error closing application")
+	// Проверяем зависимости до запуска, чтобы сразу увидеть все ошибки конфигурации
+	if cfg.App.PreflightTimeout > 0 {
+		preflightCtx, preflightCancel := context.WithTimeout(context.Background(), cfg.App.PreflightTimeout)
+		err := app.Preflight(preflightCtx)
+		preflightCancel()
+		if err != nil {
+			app.Close()
+			log.WithError(err).Fatal("Startup self-check failed")
 		}
-	}()
+	}
 
 	// Создаем контекст для graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -56,7 +60,9 @@ func main() {
 	messageHandler := NewMessageHandler(app)
 
 	// Запускаем обработчик сообщений Kafka
+	consumerDone := make(chan struct{})
 	go func() {
+		defer close(consumerDone)
 		if err := messageHandler.StartKafkaConsumer(ctx); err != nil && ctx.Err() == nil {
 			log.WithError(err).Error("Kafka consumer stopped with error")
 		}
@@ -64,7 +70,7 @@ func main() {
 
 	// Запускаем обработчик DLQ
 	go func() {
-		if err := app.DLQService.ProcessDLQ(); err != nil {
+		if err := app.DLQService.ProcessDLQ(ctx); err != nil {
 			log.WithError(err).Error("DLQ processor error")
 		}
 	}()
@@ -77,6 +83,16 @@ func main() {
 		}
 	}()
 
+	// Запускаем служебный сервер с метриками и pprof
+	if app.AdminServer != nil {
+		go func() {
+			log.WithField("port", cfg.Metrics.Port).Info("Starting admin server")
+			if err := app.AdminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.WithError(err).Error("Admin server error")
+			}
+		}()
+	}
+
 	log.Info("Order service started successfully")
 	log.Info("Waiting for shutdown signal...")
 
@@ -84,25 +100,11 @@ func main() {
 	<-sigChan
 	log.Info("Received shutdown signal, starting graceful shutdown...")
 
-	// Graceful shutdown с таймаутом из конфигурации
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.App.GracefulShutdownTimeout)
-	defer shutdownCancel()
-
-	// Останавливаем HTTP сервер
-	if err := app.HTTPServer.Shutdown(shutdownCtx); err != nil {
-		log.WithError(err).Error("HTTP server shutdown error")
+	// Останавливаем компоненты по очереди, у каждого свой таймаут
+	shutdown := app.newShutdownManager(cancel, consumerDone)
+	if err := shutdown.Shutdown(context.Background(), cfg.App.GracefulShutdownTimeout); err != nil {
+		log.WithError(err).Warn("Graceful shutdown finished with errors")
 	} else {
-		log.Info("HTTP server stopped gracefully")
-	}
-
-	// Отменяем контекст для остановки Kafka consumer
-	cancel()
-
-	// Ждем завершения всех горутин
-	select {
-	case <-shutdownCtx.Done():
-		log.Warn("Graceful shutdown timeout exceeded")
-	case <-time.After(cfg.App.ShutdownWaitTimeout):
 		log.Info("Graceful shutdown completed")
 	}
 
@@ -3,58 +3,180 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"wbtest/internal/db"
+	"wbtest/internal/dlq"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/kafka"
+	"wbtest/internal/logger"
 	"wbtest/internal/model"
+	"wbtest/internal/validator"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
 )
 
 // MessageHandler обрабатывает Kafka сообщения
 type MessageHandler struct {
-	app *App
+	app          *App
+	backpressure *kafka.Backpressure
+	// compacted заменяет заказ целиком, версия заказа - offset сообщения
+	compacted bool
+	// dedup пропускает повторно доставленные сообщения, nil - выключено
+	dedup *kafka.Deduplicator
+	// maxItems предельное число товаров в заказе, 0 - без ограничения
+	maxItems int
+	// sanitizer очищает строки заказа перед валидацией, nil - выключено
+	sanitizer *validator.Sanitizer
+	// defaultCurrency подставляется в заказ без валюты, пусто - заказ отклоняется
+	defaultCurrency string
+	// writes ограничивает одновременные записи в БД, nil - без ограничения
+	writes *semaphore.Weighted
+	// strictJSON отправляет в DLQ заказы с полями, которых нет в модели
+	strictJSON bool
 }
 
 // NewMessageHandler создает обработчик
 func NewMessageHandler(app *App) *MessageHandler {
-	return &MessageHandler{app: app}
+	handler := &MessageHandler{app: app}
+
+	// Приостанавливаем чтение из Kafka, если запись в БД не успевает
+	if app.Config != nil && app.Consumer != nil && app.Config.Kafka.BackpressureThreshold > 0 {
+		handler.backpressure = kafka.NewBackpressure(
+			app.Consumer,
+			app.Config.Kafka.BackpressureThreshold,
+			app.Config.Kafka.BackpressureCooldown,
+		)
+	}
+
+	// В compacted топике сообщения - upsert, старые версии не должны затирать новые
+	if app.Config != nil {
+		handler.compacted = app.Config.Kafka.Compacted
+	}
+
+	// Kafka может доставить сообщение повторно, его не нужно записывать еще раз
+	if app.Config != nil && app.Config.Kafka.DedupWindow > 0 {
+		handler.dedup = kafka.NewDeduplicator(app.Config.Kafka.DedupWindow)
+	}
+
+	// Заказ с огромным числом товаров превращается в тяжелую транзакцию
+	if app.Config != nil {
+		handler.maxItems = app.Config.Validation.MaxItemsPerOrder
+	}
+
+	// Поток сообщений из Kafka не должен занять весь пул соединений и оставить без них HTTP
+	if app.Config != nil && app.Config.Database.MaxConcurrentWrites > 0 {
+		handler.writes = semaphore.NewWeighted(int64(app.Config.Database.MaxConcurrentWrites))
+	}
+
+	// Управляющие символы и слишком длинные строки ломают запись в БД
+	if app.Config != nil && app.Config.Validation.Sanitize {
+		handler.sanitizer = validator.NewSanitizer(app.Config.Validation.SanitizeMaxLength)
+	}
+
+	// Часть поставщиков не присылает валюту, без нее заказ не проходит валидацию
+	if app.Config != nil {
+		handler.defaultCurrency = app.Config.Validation.DefaultCurrency
+	}
+
+	// Опечатка в имени поля иначе теряется без следа
+	if app.Config != nil {
+		handler.strictJSON = app.Config.Validation.StrictJSON
+	}
+
+	return handler
 }
 
 // HandleMessage обрабатывает сообщение
+// Заголовки сообщения берутся из контекста: trace-id попадает в лог обработки,
+// schema-version выбирает формат разбора тела
 func (h *MessageHandler) HandleMessage(ctx context.Context, msg []byte) error {
-	log.Printf("[KAFKA] Received message: %s", string(msg))
+	headers := kafka.HeadersFromContext(ctx)
+	entry := h.logEntry(headers)
+	ctx = logger.WithContext(ctx, entry)
+
+	entry.Infof("[KAFKA] Received message: %s", string(msg))
 
 	// Обрабатываем сообщение с retry логикой
 	processMessage := func() error {
-		var order model.Order
-		if err := json.Unmarshal(msg, &order); err != nil {
+		order, err := h.decodeOrder(headers[kafka.HeaderSchemaVersion], msg)
+		if err != nil {
 			return fmt.Errorf("failed to parse JSON: %w", err)
 		}
 
+		// Отсекаем слишком большие заказы до валидации и записи в БД
+		if err := h.checkItemLimit(order); err != nil {
+			return err
+		}
+
+		if h.sanitizer != nil {
+			if changed := h.sanitizer.Sanitize(order); len(changed) > 0 {
+				entry.Warnf("[KAFKA] Sanitized order %s fields: %s", order.OrderUID, strings.Join(changed, ", "))
+			}
+		}
+
+		if h.fillCurrency(order) {
+			entry.Warnf("[KAFKA] Order %s has no currency, using default %s", order.OrderUID, h.defaultCurrency)
+		}
+
 		// Валидируем заказ
-		if err := h.app.Validator.Validate(&order); err != nil {
+		if err := h.app.Validator.Validate(order); err != nil {
 			return fmt.Errorf("order validation failed: %w", err)
 		}
 
-		log.Printf("[KAFKA] Parsed and validated order: %s", order.OrderUID)
+		entry.Infof("[KAFKA] Parsed and validated order: %s", order.OrderUID)
 
-		// Сохраняем в БД
-		if err := h.app.DB.SaveOrder(ctx, &order); err != nil {
+		if h.dedup.Seen(order.OrderUID, msg) {
+			entry.Infof("[KAFKA] Skipping duplicate message for order %s", order.OrderUID)
+			h.recordDuplicate()
+			return nil
+		}
+
+		// Хуки развертывания: обогащение, антифрод
+		if err := h.runHooks(ctx, order); err != nil {
+			return err
+		}
+
+		// Сохраняем в БД, задержка записи управляет backpressure
+		saved, err := h.saveOrder(ctx, order)
+		if err != nil {
 			return fmt.Errorf("failed to save order %s: %w", order.OrderUID, err)
 		}
+		if !saved {
+			entry.Infof("[KAFKA] Skipping stale order %s: stored version is newer", order.OrderUID)
+			h.dedup.Remember(order.OrderUID, msg)
+			return nil
+		}
 
-		// Обновляем кеш
-		h.app.Cache.Set(&order)
-		log.Printf("[KAFKA] Order %s saved and cached", order.OrderUID)
+		// В compacted топике сохраненная версия - последняя, ее и кешируем.
+		// Иначе более новый заказ из параллельного сообщения не затираем
+		if h.compacted {
+			h.app.Cache.Set(order)
+			entry.Infof("[KAFKA] Order %s saved and cached", order.OrderUID)
+		} else if h.app.Cache.SetIfNewer(order) {
+			entry.Infof("[KAFKA] Order %s saved and cached", order.OrderUID)
+		} else {
+			entry.Infof("[KAFKA] Order %s saved, newer version is already cached", order.OrderUID)
+		}
+		h.dedup.Remember(order.OrderUID, msg)
+
+		h.publishProcessed(ctx, order)
 		return nil
 	}
 
 	// Выполняем обработку с retry
-	if err := h.app.RetryService.ExecuteWithRetry(processMessage); err != nil {
-		log.Printf("[KAFKA] Failed to process message after retries: %v", err)
+	if err := h.app.RetryService.ExecuteWithRetryNamed("process_message", processMessage); err != nil {
+		entry.Errorf("[KAFKA] Failed to process message after retries: %v", err)
+		h.recordValidationFailure(err)
 
 		// Отправляем в DLQ
-		if dlqErr := h.app.DLQService.SendToDLQ(msg, err.Error()); dlqErr != nil {
-			log.Printf("[KAFKA] Failed to send message to DLQ: %v", dlqErr)
+		if dlqErr := h.app.DLQService.SendToDLQ(ctx, msg, dlqReason(err)); dlqErr != nil {
+			entry.Errorf("[KAFKA] Failed to send message to DLQ: %v", dlqErr)
 		}
 		return err
 	}
@@ -62,12 +184,171 @@ func (h *MessageHandler) HandleMessage(ctx context.Context, msg []byte) error {
 	return nil
 }
 
+// decodeOrder разбирает заказ, в строгом режиме неизвестные поля - ошибка разбора
+func (h *MessageHandler) decodeOrder(version string, msg []byte) (*model.Order, error) {
+	if h.strictJSON {
+		return model.DecodeOrderStrict(version, msg)
+	}
+	return model.DecodeOrder(version, msg)
+}
+
+// saveOrder записывает заказ в БД, не превышая предел одновременных записей
+// Время ожидания слота в задержку backpressure не входит
+// В compacted режиме заказ заменяется по offset сообщения, false - в БД версия новее.
+// Без положения сообщения в контексте версию сравнить не с чем, и заказ записывается
+// как обычно, без замены сохраненного
+func (h *MessageHandler) saveOrder(ctx context.Context, order *model.Order) (bool, error) {
+	if h.writes != nil {
+		if err := h.writes.Acquire(ctx, 1); err != nil {
+			return false, fmt.Errorf("waiting for db write slot: %w", err)
+		}
+		defer h.writes.Release(1)
+	}
+
+	start := time.Now()
+	defer func() { h.backpressure.Observe(time.Since(start)) }()
+
+	if pos, ok := kafka.PositionFromContext(ctx); h.compacted && ok {
+		return h.app.DB.UpsertOrder(ctx, order, pos.Offset)
+	}
+	return true, h.app.DB.SaveOrder(ctx, order)
+}
+
+// logEntry возвращает запись лога для сообщения с его trace-id
+func (h *MessageHandler) logEntry(headers kafka.Headers) *logrus.Entry {
+	var entry *logrus.Entry
+	if h.app.Logger != nil {
+		entry = h.app.Logger.ForComponent("kafka")
+	} else {
+		entry = logrus.NewEntry(logrus.StandardLogger())
+	}
+	if traceID := headers[kafka.HeaderTraceID]; traceID != "" {
+		entry = entry.WithField("trace_id", traceID)
+	}
+	return entry
+}
+
+// publishProcessed сообщает внешним сервисам, что заказ сохранен
+// Ошибка публикации только логируется: заказ уже записан, повтор обработки его не исправит.
+// Outbound producer асинхронный, ошибки доставки логирует его обработчик, см. logOutboundError
+func (h *MessageHandler) publishProcessed(ctx context.Context, order *model.Order) {
+	if h.app.Outbound == nil {
+		return
+	}
+
+	event, err := json.Marshal(&model.OrderProcessedEvent{
+		OrderUID:  order.OrderUID,
+		Status:    model.OrderStatusSaved,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.FromContext(ctx).Errorf("[KAFKA] Failed to marshal processed event for order %s: %v", order.OrderUID, err)
+		return
+	}
+
+	// trace-id исходного сообщения уходит в заголовки события, версия схемы - своя у события
+	headers := kafka.Headers{kafka.HeaderSchemaVersion: model.OrderProcessedEventVersion}
+	if traceID := kafka.HeadersFromContext(ctx)[kafka.HeaderTraceID]; traceID != "" {
+		headers[kafka.HeaderTraceID] = traceID
+	}
+	ctx = kafka.ContextWithHeaders(ctx, headers)
+	if err := h.app.Outbound.Produce(ctx, []byte(order.OrderUID), event); err != nil {
+		logger.FromContext(ctx).Errorf("[KAFKA] Failed to publish processed event for order %s: %v", order.OrderUID, err)
+	}
+}
+
+// checkItemLimit отклоняет заказ, в котором товаров больше maxItems
+func (h *MessageHandler) checkItemLimit(order *model.Order) error {
+	if h.maxItems <= 0 || len(order.Items) <= h.maxItems {
+		return nil
+	}
+	appErr := apperrors.NewWithCode(
+		apperrors.ErrorTypeValidation,
+		fmt.Sprintf("validation failed: order %s has %d items, limit is %d",
+			order.OrderUID, len(order.Items), h.maxItems),
+		"TOO_MANY_ITEMS",
+	)
+	appErr.Cause = &validator.RuleError{Rules: []string{validator.RuleItemsCount}}
+	return appErr
+}
+
+// fillCurrency подставляет валюту по умолчанию в заказ без валюты
+// Возвращает true, если валюта была подставлена
+func (h *MessageHandler) fillCurrency(order *model.Order) bool {
+	if h.defaultCurrency == "" || strings.TrimSpace(order.Payment.Currency) != "" {
+		return false
+	}
+	order.Payment.Currency = h.defaultCurrency
+	return true
+}
+
+// recordDuplicate учитывает пропущенное повторное сообщение
+func (h *MessageHandler) recordDuplicate() {
+	if h.app.Metrics == nil {
+		return
+	}
+	topic := ""
+	if h.app.Config != nil {
+		topic = h.app.Config.Kafka.Topic
+	}
+	h.app.Metrics.KafkaMessagesDeduplicated.WithLabelValues(topic).Inc()
+}
+
+// recordValidationFailure учитывает в метриках правила, которые не прошел заказ
+func (h *MessageHandler) recordValidationFailure(err error) {
+	var appErr *apperrors.AppError
+	if h.app.Metrics == nil || !errors.As(err, &appErr) || appErr.Type != apperrors.ErrorTypeValidation {
+		return
+	}
+	for _, rule := range validator.FailedRules(err) {
+		h.app.Metrics.ValidationFailures.WithLabelValues(rule).Inc()
+	}
+}
+
+// dlqReason формирует причину для DLQ в формате "<категория>: <ошибка>"
+// По категории DLQ решает, имеет ли смысл повторная обработка
+func dlqReason(err error) string {
+	category := dlq.ReasonDBError
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var unknownErr *model.UnknownFieldError
+	var hookErr *HookError
+	var appErr *apperrors.AppError
+	switch {
+	case errors.As(err, &hookErr):
+		category = dlq.ReasonHookRejected
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &unknownErr),
+		errors.Is(err, model.ErrUnsupportedSchemaVersion):
+		category = dlq.ReasonParseError
+	case errors.As(err, &appErr) && appErr.Type == apperrors.ErrorTypeValidation:
+		category = dlq.ReasonValidationFailed
+	case db.IsConstraintViolation(err):
+		category = dlq.ReasonConstraintViolation
+	}
+
+	return category + ": " + err.Error()
+}
+
+// isRetryable сообщает, что повтор обработки может пройти
+// Сбой сериализации и deadlock в Postgres повторяются всегда: транзакцию прервала
+// конкурентная запись. Ошибки разбора, валидации и нарушения ограничений
+// не повторяются и не расходуют бюджет повторов
+func isRetryable(err error) bool {
+	if db.IsRetryable(err) {
+		return true
+	}
+	return !apperrors.IsPermanent(err) && !dlq.IsPermanentReason(dlqReason(err))
+}
+
 // StartKafkaConsumer запускает consumer
 func (h *MessageHandler) StartKafkaConsumer(ctx context.Context) error {
 	log.Println("Starting Kafka consumer...")
 
-	return h.app.Consumer.ReadMessages(ctx, func(msg []byte) {
-		if err := h.HandleMessage(ctx, msg); err != nil {
+	defer h.backpressure.Stop()
+
+	return h.app.Consumer.ReadMessages(ctx, func(msgCtx context.Context, msg []byte) {
+		if err := h.HandleMessage(msgCtx, msg); err != nil {
 			log.Printf("[KAFKA] Error handling message: %v", err)
 		}
 	})
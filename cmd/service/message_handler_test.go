@@ -1,27 +1,60 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"wbtest/internal/cache"
+	"wbtest/internal/config"
+	"wbtest/internal/dlq"
+	apperrors "wbtest/internal/errors"
 	"wbtest/internal/interfaces"
+	"wbtest/internal/kafka"
+	"wbtest/internal/logger"
+	"wbtest/internal/metrics"
 	"wbtest/internal/model"
+	"wbtest/internal/retry"
+	"wbtest/internal/validator"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // MockDB мок БД
 type MockDB struct {
-	orders map[string]*model.Order
+	orders    map[string]*model.Order
+	saveDelay time.Duration // имитация медленной записи
+	saves     int
+	saveErrs  []error // ошибки первых вызовов SaveOrder, по одной на вызов
+	loads     int
+	loadErrs  []error          // ошибки первых вызовов LoadAllOrders, по одной на вызов
+	versions  map[string]int64 // версии заказов, записанных через UpsertOrder
 }
 
 func NewMockDB() *MockDB {
 	return &MockDB{
-		orders: make(map[string]*model.Order),
+		orders:   make(map[string]*model.Order),
+		versions: make(map[string]int64),
 	}
 }
 
-func (m *MockDB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
+func (m *MockDB) LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	m.loads++
+	if len(m.loadErrs) > 0 {
+		err := m.loadErrs[0]
+		m.loadErrs = m.loadErrs[1:]
+		return nil, err
+	}
+
 	var orders []*model.Order
 	for _, order := range m.orders {
 		orders = append(orders, order)
@@ -30,17 +63,89 @@ func (m *MockDB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
 }
 
 func (m *MockDB) SaveOrder(ctx context.Context, order *model.Order) error {
+	time.Sleep(m.saveDelay)
+	if len(m.saveErrs) > 0 {
+		err := m.saveErrs[0]
+		m.saveErrs = m.saveErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
 	m.orders[order.OrderUID] = order
+	m.saves++
 	return nil
 }
 
-func (m *MockDB) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+func (m *MockDB) UpsertOrder(ctx context.Context, order *model.Order, version int64) (bool, error) {
+	if stored, ok := m.versions[order.OrderUID]; ok && stored > version {
+		return false, nil
+	}
+	m.versions[order.OrderUID] = version
+	m.orders[order.OrderUID] = order
+	m.saves++
+	return true, nil
+}
+
+func (m *MockDB) GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error) {
 	if order, exists := m.orders[orderUID]; exists {
 		return order, nil
 	}
 	return nil, nil
 }
 
+func (m *MockDB) GetOrderByTrackNumber(ctx context.Context, trackNumber string) ([]*model.Order, error) {
+	var orders []*model.Order
+	for _, order := range m.orders {
+		if order.TrackNumber == trackNumber {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MockDB) FindOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	var orders []*model.Order
+	for _, order := range m.orders {
+		if options.Matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MockDB) LoadOrdersAfter(ctx context.Context, afterUID string, limit int, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	var orders []*model.Order
+	for uid, order := range m.orders {
+		if uid > afterUID && options.Matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	if limit < len(orders) {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+func (m *MockDB) DeleteOrder(ctx context.Context, orderUID string) error {
+	delete(m.orders, orderUID)
+	return nil
+}
+
+func (m *MockDB) RestoreOrder(ctx context.Context, orderUID string) error {
+	return nil
+}
+
+func (m *MockDB) GetOrderStats(ctx context.Context, since time.Time, topServices int) (*model.OrderStats, error) {
+	return &model.OrderStats{}, nil
+}
+
+func (m *MockDB) CountOrdersByBrand(ctx context.Context, brand string, sampleSize int) (*model.BrandCount, error) {
+	return &model.BrandCount{Brand: brand, SampleOrderUIDs: []string{}}, nil
+}
+
 func (m *MockDB) Close() {}
 
 // MockCache мок кеша
@@ -65,6 +170,18 @@ func (m *MockCache) Set(order *model.Order) {
 	m.orders[order.OrderUID] = order
 }
 
+func (m *MockCache) SetCacheOnly(order *model.Order) {
+	m.orders[order.OrderUID] = order
+}
+
+func (m *MockCache) SetIfNewer(order *model.Order) bool {
+	if cached, exists := m.orders[order.OrderUID]; exists && cached.DateCreated.After(order.DateCreated) {
+		return false
+	}
+	m.orders[order.OrderUID] = order
+	return true
+}
+
 func (m *MockCache) LoadAll(orders []*model.Order) {
 	for _, order := range orders {
 		m.orders[order.OrderUID] = order
@@ -75,10 +192,29 @@ func (m *MockCache) Delete(orderUID string) {
 	delete(m.orders, orderUID)
 }
 
+func (m *MockCache) DeleteWhere(pred func(*model.Order) bool) int {
+	removed := 0
+	for uid, order := range m.orders {
+		if pred(order) {
+			delete(m.orders, uid)
+			removed++
+		}
+	}
+	return removed
+}
+
 func (m *MockCache) Size() int {
 	return len(m.orders)
 }
 
+func (m *MockCache) Keys() []string {
+	keys := make([]string, 0, len(m.orders))
+	for key := range m.orders {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 func (m *MockCache) Clear() {
 	m.orders = make(map[string]*model.Order)
 }
@@ -111,14 +247,23 @@ func (m *MockRetryService) ExecuteWithRetry(operation func() error) error {
 	return operation()
 }
 
+func (m *MockRetryService) ExecuteWithRetryNamed(name string, operation func() error) error {
+	return operation()
+}
+
 // MockDLQService мок DLQ
-type MockDLQService struct{}
+type MockDLQService struct {
+	reasons []string
+	headers []kafka.Headers
+}
 
-func (m *MockDLQService) SendToDLQ(message []byte, reason string) error {
+func (m *MockDLQService) SendToDLQ(ctx context.Context, message []byte, reason string) error {
+	m.reasons = append(m.reasons, reason)
+	m.headers = append(m.headers, kafka.HeadersFromContext(ctx))
 	return nil
 }
 
-func (m *MockDLQService) ProcessDLQ() error {
+func (m *MockDLQService) ProcessDLQ(ctx context.Context) error {
 	return nil
 }
 
@@ -230,6 +375,108 @@ func TestMessageHandler_HandleMessage_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestMessageHandler_HandleMessage_UnknownField(t *testing.T) {
+	msg := []byte(`{"order_uid": "order-with-typo", "payment": {"ammount": 1817}}`)
+
+	tests := []struct {
+		name       string
+		strictJSON bool
+		wantDLQ    bool
+	}{
+		{name: "lenient by default", strictJSON: false, wantDLQ: false},
+		{name: "strict mode", strictJSON: true, wantDLQ: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDB()
+			mockDLQService := &MockDLQService{}
+			app := &App{
+				Config:       &config.Config{Validation: config.ValidationConfig{StrictJSON: tt.strictJSON}},
+				DB:           mockDB,
+				Cache:        NewMockCache(),
+				Validator:    &MockValidator{},
+				RetryService: &MockRetryService{},
+				DLQService:   mockDLQService,
+			}
+			handler := NewMessageHandler(app)
+
+			err := handler.HandleMessage(context.Background(), msg)
+			if (err != nil) != tt.wantDLQ {
+				t.Fatalf("HandleMessage() error = %v, want error %v", err, tt.wantDLQ)
+			}
+
+			if !tt.wantDLQ {
+				if _, ok := mockDB.orders["order-with-typo"]; !ok {
+					t.Error("Expected order to be saved in lenient mode")
+				}
+				return
+			}
+			if len(mockDLQService.reasons) != 1 {
+				t.Fatalf("Expected 1 DLQ message, got %d", len(mockDLQService.reasons))
+			}
+			reason := mockDLQService.reasons[0]
+			if dlq.ReasonCategory(reason) != dlq.ReasonParseError || !strings.Contains(reason, `unknown field "payment.ammount"`) {
+				t.Errorf("Expected parse_error reason naming payment.ammount, got %q", reason)
+			}
+			if len(mockDB.orders) != 0 {
+				t.Error("Expected order with unknown field not to be saved")
+			}
+		})
+	}
+}
+
+func TestMessageHandler_HandleMessage_HookRejects(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDLQService := &MockDLQService{}
+	app := &App{
+		DB:           mockDB,
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   mockDLQService,
+	}
+
+	// Антифрод: заказы подозрительного покупателя отклоняются
+	var checked []string
+	app.AddHook("fraud", func(ctx context.Context, order *model.Order) error {
+		checked = append(checked, order.OrderUID)
+		if order.CustomerID == "fraudster" {
+			return errors.New("customer is blocked")
+		}
+		return nil
+	})
+	handler := NewMessageHandler(app)
+
+	for _, order := range []*model.Order{
+		{OrderUID: "order-ok", CustomerID: "customer"},
+		{OrderUID: "order-fraud", CustomerID: "fraudster"},
+	} {
+		data, err := json.Marshal(order)
+		if err != nil {
+			t.Fatalf("Failed to marshal order: %v", err)
+		}
+		_ = handler.HandleMessage(context.Background(), data)
+	}
+
+	if strings.Join(checked, ",") != "order-ok,order-fraud" {
+		t.Errorf("Expected hook to check both orders, got %v", checked)
+	}
+	if _, ok := mockDB.orders["order-ok"]; !ok {
+		t.Error("Expected accepted order to be saved")
+	}
+	if _, ok := mockDB.orders["order-fraud"]; ok {
+		t.Error("Expected rejected order not to be saved")
+	}
+	if len(mockDLQService.reasons) != 1 {
+		t.Fatalf("Expected 1 DLQ message, got %d", len(mockDLQService.reasons))
+	}
+	reason := mockDLQService.reasons[0]
+	if dlq.ReasonCategory(reason) != dlq.ReasonHookRejected || !strings.Contains(reason, "hook fraud rejected order order-fraud") {
+		t.Errorf("Expected hook_rejected reason from fraud hook, got %q", reason)
+	}
+}
+
 func TestMessageHandler_HandleMessage_InvalidOrder(t *testing.T) {
 	// Создаем моки
 	mockDB := NewMockDB()
@@ -269,3 +516,771 @@ func TestMessageHandler_HandleMessage_InvalidOrder(t *testing.T) {
 		t.Error("Expected error for invalid order")
 	}
 }
+
+func TestDLQReason(t *testing.T) {
+	var syntaxErr error
+	var order model.Order
+	if err := json.Unmarshal([]byte(`{"invalid": json}`), &order); err != nil {
+		syntaxErr = fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	tests := []struct {
+		name      string
+		err       error
+		category  string
+		retryable bool
+	}{
+		{
+			name:     "parse error",
+			err:      syntaxErr,
+			category: dlq.ReasonParseError,
+		},
+		{
+			name:     "validation error",
+			err:      fmt.Errorf("order validation failed: %w", apperrors.New(apperrors.ErrorTypeValidation, "bad order")),
+			category: dlq.ReasonValidationFailed,
+		},
+		{
+			name:      "database error",
+			err:       errors.New("failed to save order: connection refused"),
+			category:  dlq.ReasonDBError,
+			retryable: true,
+		},
+		{
+			name:      "serialization failure",
+			err:       fmt.Errorf("failed to save order: %w", &pgconn.PgError{Code: "40001"}),
+			category:  dlq.ReasonDBError,
+			retryable: true,
+		},
+		{
+			name:     "constraint violation",
+			err:      fmt.Errorf("failed to save order: %w", &pgconn.PgError{Code: "23505", ConstraintName: "payment_pkey"}),
+			category: dlq.ReasonConstraintViolation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := dlqReason(tt.err)
+			if got := dlq.ReasonCategory(reason); got != tt.category {
+				t.Errorf("Expected category %s, got %s (reason: %s)", tt.category, got, reason)
+			}
+			if got := isRetryable(tt.err); got != tt.retryable {
+				t.Errorf("Expected retryable %v, got %v", tt.retryable, got)
+			}
+		})
+	}
+}
+
+// MockConsumer мок consumer, считающий паузы и возобновления
+type MockConsumer struct {
+	mu      sync.Mutex
+	pauses  int
+	resumes int
+}
+
+func (m *MockConsumer) ReadMessages(ctx context.Context, handle func(context.Context, []byte)) error {
+	return nil
+}
+
+func (m *MockConsumer) Pause() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauses++
+}
+
+func (m *MockConsumer) Resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resumes++
+}
+
+func (m *MockConsumer) Close() error { return nil }
+
+func (m *MockConsumer) counts() (int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pauses, m.resumes
+}
+
+func TestMessageHandler_Backpressure(t *testing.T) {
+	mockDB := NewMockDB()
+	mockConsumer := &MockConsumer{}
+
+	app := &App{
+		DB:           mockDB,
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+		Consumer:     mockConsumer,
+	}
+
+	handler := NewMessageHandler(app)
+	handler.backpressure = kafka.NewBackpressure(mockConsumer, 20*time.Millisecond, time.Hour)
+
+	newMessage := func(uid string) []byte {
+		data, err := json.Marshal(&model.Order{OrderUID: uid})
+		if err != nil {
+			t.Fatalf("Failed to marshal order: %v", err)
+		}
+		return data
+	}
+
+	// Медленная БД приостанавливает чтение
+	mockDB.saveDelay = 50 * time.Millisecond
+	if err := handler.HandleMessage(context.Background(), newMessage("slow-order-1")); err != nil {
+		t.Fatalf("HandleMessage() error = %v", err)
+	}
+	if pauses, resumes := mockConsumer.counts(); pauses != 1 || resumes != 0 {
+		t.Fatalf("Expected 1 pause and 0 resumes after slow write, got %d and %d", pauses, resumes)
+	}
+
+	// Повторная медленная запись не ставит паузу второй раз
+	if err := handler.HandleMessage(context.Background(), newMessage("slow-order-2")); err != nil {
+		t.Fatalf("HandleMessage() error = %v", err)
+	}
+	if pauses, _ := mockConsumer.counts(); pauses != 1 {
+		t.Errorf("Expected single pause while already paused, got %d", pauses)
+	}
+
+	// Восстановление БД возобновляет чтение
+	mockDB.saveDelay = 0
+	if err := handler.HandleMessage(context.Background(), newMessage("fast-order")); err != nil {
+		t.Fatalf("HandleMessage() error = %v", err)
+	}
+	if pauses, resumes := mockConsumer.counts(); pauses != 1 || resumes != 1 {
+		t.Errorf("Expected 1 pause and 1 resume after recovery, got %d and %d", pauses, resumes)
+	}
+	if handler.backpressure.Paused() {
+		t.Error("Expected backpressure to be released")
+	}
+}
+
+func TestMessageHandler_HandleMessage_ValidationFailureMetric(t *testing.T) {
+	app := &App{
+		DB:           NewMockDB(),
+		Cache:        NewMockCache(),
+		Validator:    validator.NewOrderValidator(),
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+		Metrics:      metrics.NewWithRegistry(prometheus.NewRegistry()),
+	}
+	handler := NewMessageHandler(app)
+
+	order := &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "not-an-email",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      1817,
+			PaymentDT:   1637907727,
+			Bank:        "alpha",
+			GoodsTotal:  317,
+		},
+		Items: []model.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Now(),
+		OofShard:        "1",
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	if err := handler.HandleMessage(context.Background(), data); err == nil {
+		t.Fatal("Expected validation error for invalid email")
+	}
+
+	if got := testutil.ToFloat64(app.Metrics.ValidationFailures.WithLabelValues(validator.RuleEmail)); got != 1 {
+		t.Errorf("Expected email validation failures 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(app.Metrics.ValidationFailures.WithLabelValues(validator.RuleCurrency)); got != 0 {
+		t.Errorf("Expected currency validation failures 0, got %v", got)
+	}
+}
+
+func TestMessageHandler_HandleMessage_CompactedUpsertsByOffset(t *testing.T) {
+	mockDB := NewMockDB()
+	mockCache := NewMockCache()
+	app := &App{
+		Config:       &config.Config{Kafka: config.KafkaConfig{Compacted: true}},
+		DB:           mockDB,
+		Cache:        mockCache,
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+	}
+	handler := NewMessageHandler(app)
+
+	send := func(offset int64, createdAt time.Time, city string) {
+		t.Helper()
+		data, err := json.Marshal(&model.Order{
+			OrderUID:    "compacted-order",
+			DateCreated: createdAt,
+			Delivery:    model.Delivery{City: city},
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal order: %v", err)
+		}
+		ctx := kafka.ContextWithPosition(context.Background(), kafka.Position{Offset: offset})
+		if err := handler.HandleMessage(ctx, data); err != nil {
+			t.Fatalf("HandleMessage() error = %v", err)
+		}
+	}
+
+	now := time.Now()
+	send(10, now, "first")
+	// Версию определяет offset, а не дата заказа от клиента
+	send(11, now.Add(-time.Hour), "second")
+	if got := mockDB.orders["compacted-order"].Delivery.City; got != "second" {
+		t.Errorf("Expected later offset to replace order, got city %q", got)
+	}
+
+	// Старая версия пришла после новой и не должна ее затереть
+	send(5, now.Add(time.Hour), "stale")
+	if got := mockDB.orders["compacted-order"].Delivery.City; got != "second" {
+		t.Errorf("Expected DB to keep newer order, got city %q", got)
+	}
+	cached, ok := mockCache.Get("compacted-order")
+	if !ok || cached.Delivery.City != "second" {
+		t.Errorf("Expected cache to keep newer order, got %+v", cached)
+	}
+}
+
+func TestMessageHandler_HandleMessage_CacheKeepsNewer(t *testing.T) {
+	orderCache := cache.NewOrderCache(10, time.Hour)
+	defer orderCache.Stop()
+
+	app := &App{
+		Config:       &config.Config{},
+		DB:           NewMockDB(),
+		Cache:        orderCache,
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+	}
+	handler := NewMessageHandler(app)
+
+	newer := time.Now()
+	for _, order := range []*model.Order{
+		{OrderUID: "cached-order", DateCreated: newer, Delivery: model.Delivery{City: "new"}},
+		// Старое сообщение обработано позже нового
+		{OrderUID: "cached-order", DateCreated: newer.Add(-time.Hour), Delivery: model.Delivery{City: "old"}},
+	} {
+		data, err := json.Marshal(order)
+		if err != nil {
+			t.Fatalf("Failed to marshal order: %v", err)
+		}
+		if err := handler.HandleMessage(context.Background(), data); err != nil {
+			t.Fatalf("HandleMessage() error = %v", err)
+		}
+	}
+
+	cached, ok := orderCache.Get("cached-order")
+	if !ok || cached.Delivery.City != "new" {
+		t.Errorf("Expected cache to keep newer order, got %+v", cached)
+	}
+}
+
+func TestMessageHandler_HandleMessage_Dedup(t *testing.T) {
+	mockDB := NewMockDB()
+	app := &App{
+		Config:       &config.Config{Kafka: config.KafkaConfig{Topic: "orders", DedupWindow: time.Minute}},
+		DB:           mockDB,
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+		Metrics:      metrics.NewWithRegistry(prometheus.NewRegistry()),
+	}
+	handler := NewMessageHandler(app)
+
+	data, err := json.Marshal(&model.Order{OrderUID: "dedup-order", DateCreated: time.Now()})
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := handler.HandleMessage(context.Background(), data); err != nil {
+			t.Fatalf("HandleMessage() error = %v", err)
+		}
+	}
+
+	if mockDB.saves != 1 {
+		t.Errorf("Expected order to be saved once, got %d saves", mockDB.saves)
+	}
+	if got := testutil.ToFloat64(app.Metrics.KafkaMessagesDeduplicated.WithLabelValues("orders")); got != 1 {
+		t.Errorf("Expected 1 deduplicated message, got %v", got)
+	}
+}
+
+func TestMessageHandler_HandleMessage_TooManyItems(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDLQService := &MockDLQService{}
+	app := &App{
+		Config:       &config.Config{Validation: config.ValidationConfig{MaxItemsPerOrder: 3}},
+		DB:           mockDB,
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   mockDLQService,
+	}
+	handler := NewMessageHandler(app)
+
+	data, err := json.Marshal(&model.Order{
+		OrderUID: "huge-order",
+		Items:    make([]model.Item, 4),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	if err := handler.HandleMessage(context.Background(), data); err == nil {
+		t.Fatal("Expected error for order exceeding item limit")
+	}
+
+	if mockDB.saves != 0 {
+		t.Errorf("Expected order not to be saved, got %d saves", mockDB.saves)
+	}
+	if len(mockDLQService.reasons) != 1 {
+		t.Fatalf("Expected 1 DLQ message, got %d", len(mockDLQService.reasons))
+	}
+	if !strings.HasPrefix(mockDLQService.reasons[0], dlq.ReasonValidationFailed+": ") {
+		t.Errorf("Expected validation_failed DLQ reason, got %q", mockDLQService.reasons[0])
+	}
+}
+
+func TestMessageHandler_HandleMessage_PostgresErrors(t *testing.T) {
+	retryConfig := &config.RetryConfig{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     10 * time.Millisecond,
+		Multiplier:   2,
+	}
+	data, err := json.Marshal(&model.Order{OrderUID: "concurrent-order"})
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	t.Run("serialization failure is retried", func(t *testing.T) {
+		mockDB := NewMockDB()
+		mockDB.saveErrs = []error{&pgconn.PgError{Code: "40001", Message: "could not serialize access"}}
+		mockDLQService := &MockDLQService{}
+		handler := NewMessageHandler(&App{
+			DB:           mockDB,
+			Cache:        NewMockCache(),
+			Validator:    &MockValidator{},
+			RetryService: retry.NewRetryService(retryConfig),
+			DLQService:   mockDLQService,
+		})
+
+		if err := handler.HandleMessage(context.Background(), data); err != nil {
+			t.Fatalf("Expected order to be saved on second attempt, got %v", err)
+		}
+		if _, ok := mockDB.orders["concurrent-order"]; !ok {
+			t.Error("Expected order to be saved")
+		}
+		if len(mockDLQService.reasons) != 0 {
+			t.Errorf("Expected no DLQ messages, got %v", mockDLQService.reasons)
+		}
+	})
+
+	t.Run("constraint violation goes to DLQ without retries", func(t *testing.T) {
+		mockDB := NewMockDB()
+		// Так ошибку возвращает db.SaveOrder
+		mockDB.saveErrs = []error{
+			apperrors.Permanent(&pgconn.PgError{Code: "23505", ConstraintName: "payment_pkey"}),
+			nil,
+		}
+		mockDLQService := &MockDLQService{}
+		handler := NewMessageHandler(&App{
+			DB:           mockDB,
+			Cache:        NewMockCache(),
+			Validator:    &MockValidator{},
+			RetryService: retry.NewRetryService(retryConfig),
+			DLQService:   mockDLQService,
+		})
+
+		if err := handler.HandleMessage(context.Background(), data); err == nil {
+			t.Fatal("Expected constraint violation error")
+		}
+		if len(mockDB.saveErrs) != 1 {
+			t.Errorf("Expected a single save attempt, got %d", 2-len(mockDB.saveErrs))
+		}
+		if len(mockDLQService.reasons) != 1 {
+			t.Fatalf("Expected 1 DLQ message, got %d", len(mockDLQService.reasons))
+		}
+		if !strings.HasPrefix(mockDLQService.reasons[0], dlq.ReasonConstraintViolation+": ") {
+			t.Errorf("Expected constraint_violation DLQ reason, got %q", mockDLQService.reasons[0])
+		}
+	})
+}
+
+func TestMessageHandler_HandleMessage_TraceID(t *testing.T) {
+	var buf bytes.Buffer
+	appLogger := logger.New(logger.Config{Level: "info", Format: "json"})
+	appLogger.SetOutput(&buf)
+
+	app := &App{
+		Logger:       appLogger,
+		DB:           NewMockDB(),
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+	}
+	handler := NewMessageHandler(app)
+
+	data, err := json.Marshal(&model.Order{OrderUID: "traced-order"})
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	ctx := kafka.ContextWithHeaders(context.Background(), kafka.Headers{kafka.HeaderTraceID: "trace-123"})
+	if err := handler.HandleMessage(ctx, data); err != nil {
+		t.Fatalf("HandleMessage() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatal("Expected handler to log the message")
+	}
+	for _, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		if record["trace_id"] != "trace-123" {
+			t.Errorf("Expected trace_id in log line %q", line)
+		}
+	}
+}
+
+func TestMessageHandler_HandleMessage_UnsupportedSchemaVersion(t *testing.T) {
+	mockDB := NewMockDB()
+	mockDLQService := &MockDLQService{}
+	app := &App{
+		DB:           mockDB,
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   mockDLQService,
+	}
+	handler := NewMessageHandler(app)
+
+	ctx := kafka.ContextWithHeaders(context.Background(), kafka.Headers{kafka.HeaderSchemaVersion: "99"})
+	if err := handler.HandleMessage(ctx, []byte(`{"order_uid":"future-order"}`)); err == nil {
+		t.Fatal("Expected error for unsupported schema version")
+	}
+
+	if mockDB.saves != 0 {
+		t.Errorf("Expected order not to be saved, got %d saves", mockDB.saves)
+	}
+	if len(mockDLQService.reasons) != 1 || !strings.HasPrefix(mockDLQService.reasons[0], dlq.ReasonParseError+": ") {
+		t.Errorf("Expected parse_error DLQ reason, got %v", mockDLQService.reasons)
+	}
+}
+
+func TestMessageHandler_HandleMessage_Sanitize(t *testing.T) {
+	mockDB := NewMockDB()
+	app := &App{
+		Config:       &config.Config{Validation: config.ValidationConfig{Sanitize: true}},
+		DB:           mockDB,
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+	}
+	handler := NewMessageHandler(app)
+
+	data, err := json.Marshal(&model.Order{
+		OrderUID: "dirty-order",
+		Delivery: model.Delivery{Name: "Ivan\x00 Ivanov "},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	if err := handler.HandleMessage(context.Background(), data); err != nil {
+		t.Fatalf("HandleMessage() error = %v", err)
+	}
+
+	saved := mockDB.orders["dirty-order"]
+	if saved == nil {
+		t.Fatal("Expected order to be saved")
+	}
+	if saved.Delivery.Name != "Ivan Ivanov" {
+		t.Errorf("Expected sanitized name, got %q", saved.Delivery.Name)
+	}
+}
+
+// newValidTestOrder возвращает заказ, проходящий валидацию
+func newValidTestOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: uid,
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      1817,
+			PaymentDT:   1637907727,
+			Bank:        "alpha",
+			GoodsTotal:  317,
+		},
+		Items: []model.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Now().Add(-time.Minute),
+		OofShard:        "1",
+	}
+}
+
+func TestMessageHandler_HandleMessage_DefaultCurrency(t *testing.T) {
+	order := newValidTestOrder("b563feb7b2b84b6test")
+	order.Payment.Currency = ""
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	newApp := func(defaultCurrency string) (*App, *MockDB, *MockDLQService) {
+		mockDB := NewMockDB()
+		mockDLQService := &MockDLQService{}
+		return &App{
+			Config:       &config.Config{Validation: config.ValidationConfig{DefaultCurrency: defaultCurrency}},
+			DB:           mockDB,
+			Cache:        NewMockCache(),
+			Validator:    validator.NewOrderValidator(),
+			RetryService: &MockRetryService{},
+			DLQService:   mockDLQService,
+		}, mockDB, mockDLQService
+	}
+
+	t.Run("auto-fill", func(t *testing.T) {
+		app, mockDB, mockDLQService := newApp("RUB")
+		if err := NewMessageHandler(app).HandleMessage(context.Background(), data); err != nil {
+			t.Fatalf("HandleMessage() error = %v", err)
+		}
+
+		saved := mockDB.orders[order.OrderUID]
+		if saved == nil {
+			t.Fatal("Expected order to be saved")
+		}
+		if saved.Payment.Currency != "RUB" {
+			t.Errorf("Expected default currency RUB, got %q", saved.Payment.Currency)
+		}
+		if len(mockDLQService.reasons) != 0 {
+			t.Errorf("Expected no DLQ messages, got %v", mockDLQService.reasons)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		app, mockDB, mockDLQService := newApp("")
+		if err := NewMessageHandler(app).HandleMessage(context.Background(), data); err == nil {
+			t.Fatal("Expected validation error for empty currency")
+		}
+		if _, ok := mockDB.orders[order.OrderUID]; ok {
+			t.Error("Expected order without currency not to be saved")
+		}
+		if len(mockDLQService.reasons) != 1 {
+			t.Errorf("Expected order to be sent to DLQ, got %v", mockDLQService.reasons)
+		}
+	})
+
+	t.Run("present currency kept", func(t *testing.T) {
+		app, mockDB, _ := newApp("RUB")
+		usd, err := json.Marshal(newValidTestOrder("b563feb7b2b84b6usd"))
+		if err != nil {
+			t.Fatalf("Failed to marshal order: %v", err)
+		}
+		if err := NewMessageHandler(app).HandleMessage(context.Background(), usd); err != nil {
+			t.Fatalf("HandleMessage() error = %v", err)
+		}
+		if got := mockDB.orders["b563feb7b2b84b6usd"].Payment.Currency; got != "USD" {
+			t.Errorf("Expected currency USD to be kept, got %q", got)
+		}
+	})
+}
+
+func TestMessageHandler_HandleMessage_PublishesProcessed(t *testing.T) {
+	outbound := kafka.NewMemoryProducer()
+	mockDLQService := &MockDLQService{}
+	app := &App{
+		Config:       &config.Config{Validation: config.ValidationConfig{MaxItemsPerOrder: 1}},
+		DB:           NewMockDB(),
+		Cache:        NewMockCache(),
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   mockDLQService,
+		Outbound:     outbound,
+	}
+	handler := NewMessageHandler(app)
+
+	for _, uid := range []string{"order-1", "order-2"} {
+		data, err := json.Marshal(&model.Order{OrderUID: uid})
+		if err != nil {
+			t.Fatalf("Failed to marshal order: %v", err)
+		}
+		ctx := kafka.ContextWithHeaders(context.Background(), kafka.Headers{
+			kafka.HeaderTraceID: "trace-" + uid,
+		})
+		if err := handler.HandleMessage(ctx, data); err != nil {
+			t.Fatalf("HandleMessage() error = %v", err)
+		}
+	}
+
+	// Заказ, ушедший в DLQ, событие не публикует
+	rejected, err := json.Marshal(&model.Order{OrderUID: "rejected", Items: make([]model.Item, 2)})
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+	if err := handler.HandleMessage(context.Background(), rejected); err == nil {
+		t.Fatal("Expected rejected order to fail")
+	}
+
+	messages := outbound.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 processed events, got %d", len(messages))
+	}
+	for i, uid := range []string{"order-1", "order-2"} {
+		if string(messages[i].Key) != uid {
+			t.Errorf("Expected event key %s, got %s", uid, messages[i].Key)
+		}
+		var event model.OrderProcessedEvent
+		if err := json.Unmarshal(messages[i].Value, &event); err != nil {
+			t.Fatalf("Failed to unmarshal event: %v", err)
+		}
+		if event.OrderUID != uid || event.Status != model.OrderStatusSaved || event.Timestamp.IsZero() {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		// Версия схемы события выставляется всегда, а не копируется из входящего сообщения
+		headers := messages[i].Headers
+		if headers[kafka.HeaderTraceID] != "trace-"+uid || headers[kafka.HeaderSchemaVersion] != model.OrderProcessedEventVersion {
+			t.Errorf("Unexpected event headers %v", headers)
+		}
+	}
+	if len(mockDLQService.reasons) != 1 {
+		t.Errorf("Expected 1 DLQ message, got %d", len(mockDLQService.reasons))
+	}
+}
+
+// concurrentWritesDB считает одновременные вызовы SaveOrder
+type concurrentWritesDB struct {
+	*MockDB
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	total     int
+}
+
+func (d *concurrentWritesDB) SaveOrder(ctx context.Context, order *model.Order) error {
+	d.mu.Lock()
+	d.active++
+	d.total++
+	if d.active > d.maxActive {
+		d.maxActive = d.active
+	}
+	d.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	d.mu.Lock()
+	d.active--
+	d.mu.Unlock()
+	return nil
+}
+
+func TestMessageHandler_HandleMessage_WriteConcurrencyLimit(t *testing.T) {
+	db := &concurrentWritesDB{MockDB: NewMockDB()}
+	orderCache := cache.NewOrderCache(100, time.Hour)
+	defer orderCache.Stop()
+
+	handler := NewMessageHandler(&App{
+		Config:       &config.Config{Database: config.DatabaseConfig{MaxConcurrentWrites: 3}},
+		DB:           db,
+		Cache:        orderCache,
+		Validator:    &MockValidator{},
+		RetryService: &MockRetryService{},
+		DLQService:   &MockDLQService{},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, _ := json.Marshal(&model.Order{OrderUID: fmt.Sprintf("order-%d", i)})
+			if err := handler.HandleMessage(context.Background(), data); err != nil {
+				t.Errorf("HandleMessage() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if db.total != 20 {
+		t.Errorf("Expected 20 writes, got %d", db.total)
+	}
+	if db.maxActive > 3 {
+		t.Errorf("Expected at most 3 concurrent writes, got %d", db.maxActive)
+	}
+	if db.maxActive < 2 {
+		t.Errorf("Expected writes to run concurrently up to the limit, got max %d", db.maxActive)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// pinger компонент, доступность которого можно проверить при старте
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// preflightCheck одна проверка самодиагностики
+type preflightCheck struct {
+	name   string
+	target interface{}
+}
+
+// Preflight проверяет, что компоненты приложения действительно работают:
+// БД отвечает, топики Kafka и DLQ существуют. Запись в DLQ не проверяется
+// Выполняет все проверки и возвращает одну ошибку со всеми проблемами сразу
+func (a *App) Preflight(ctx context.Context) error {
+	checks := []preflightCheck{
+		{name: "database", target: a.DB},
+		{name: "kafka", target: a.Consumer},
+		{name: "dlq_topic", target: a.DLQService},
+	}
+
+	var errs []error
+	for _, check := range checks {
+		// Компоненты без Ping (например, выключенный DLQ) не проверяются
+		p, ok := check.target.(pinger)
+		if !ok {
+			continue
+		}
+		if err := p.Ping(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", check.name, err))
+			continue
+		}
+		log.Printf("Preflight check %s passed", check.name)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("preflight failed: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"wbtest/internal/interfaces"
+)
+
+// pingDB репозиторий с управляемым результатом Ping
+type pingDB struct {
+	interfaces.OrderRepository
+	err error
+}
+
+func (p *pingDB) Ping(ctx context.Context) error { return p.err }
+
+// pingConsumer consumer с управляемым результатом Ping
+type pingConsumer struct {
+	interfaces.MessageConsumer
+	err error
+}
+
+func (p *pingConsumer) Ping(ctx context.Context) error { return p.err }
+
+// pingDLQ DLQ сервис с управляемым результатом Ping
+type pingDLQ struct {
+	MockDLQService
+	err error
+}
+
+func (p *pingDLQ) Ping(ctx context.Context) error { return p.err }
+
+func TestApp_Preflight(t *testing.T) {
+	dbErr := errors.New("connection refused")
+	kafkaErr := errors.New("unknown topic")
+	dlqErr := errors.New("dlq topic missing")
+
+	tests := []struct {
+		name     string
+		app      *App
+		expected []error
+		skipped  []string
+	}{
+		{
+			name: "all checks pass",
+			app: &App{
+				DB:         &pingDB{},
+				Consumer:   &pingConsumer{},
+				DLQService: &pingDLQ{},
+			},
+		},
+		{
+			name: "database fails",
+			app: &App{
+				DB:         &pingDB{err: dbErr},
+				Consumer:   &pingConsumer{},
+				DLQService: &pingDLQ{},
+			},
+			expected: []error{dbErr},
+			skipped:  []string{"kafka:", "dlq_topic:"},
+		},
+		{
+			name: "kafka and dlq fail together",
+			app: &App{
+				DB:         &pingDB{},
+				Consumer:   &pingConsumer{err: kafkaErr},
+				DLQService: &pingDLQ{err: dlqErr},
+			},
+			expected: []error{kafkaErr, dlqErr},
+			skipped:  []string{"database:"},
+		},
+		{
+			name: "all checks fail",
+			app: &App{
+				DB:         &pingDB{err: dbErr},
+				Consumer:   &pingConsumer{err: kafkaErr},
+				DLQService: &pingDLQ{err: dlqErr},
+			},
+			expected: []error{dbErr, kafkaErr, dlqErr},
+		},
+		{
+			name: "components without ping are skipped",
+			app: &App{
+				DB:         NewMockDB(),
+				DLQService: &MockDLQService{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.app.Preflight(context.Background())
+
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Fatalf("Preflight() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected preflight error")
+			}
+			for _, expected := range tt.expected {
+				if !errors.Is(err, expected) {
+					t.Errorf("Expected error to wrap %q, got %v", expected, err)
+				}
+			}
+			for _, name := range tt.skipped {
+				if strings.Contains(err.Error(), name) {
+					t.Errorf("Expected no %s failure in %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"wbtest/internal/lifecycle"
+)
+
+// flusher компонент, который дописывает начатые записи перед закрытием
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// newShutdownManager регистрирует компоненты в порядке остановки:
+// сначала HTTP перестает принимать запросы, затем consumer дочитывает Kafka,
+// закрывается DLQ и последней БД, которой пользуются все предыдущие
+// У каждого компонента свой таймаут, медленное закрытие БД не отнимает время у HTTP
+// stopConsumer отменяет контекст consumer, consumerDone закрывается после его остановки
+func (a *App) newShutdownManager(stopConsumer context.CancelFunc, consumerDone <-chan struct{}) *lifecycle.Manager {
+	cfg := a.Config.App
+	manager := lifecycle.New()
+
+	manager.RegisterWithTimeout(lifecycle.NewServiceWrapper("http", nil, a.stopHTTP),
+		cfg.ShutdownTimeout(cfg.HTTPShutdownTimeout))
+
+	manager.RegisterWithTimeout(lifecycle.NewServiceWrapper("kafka", nil, func(ctx context.Context) error {
+		stopConsumer()
+		// Close отправляет смещения обработанных сообщений, поэтому вызывается и по таймауту
+		defer a.closeKafka()
+		select {
+		case <-consumerDone:
+		case <-ctx.Done():
+			return fmt.Errorf("consumer did not drain: %w", ctx.Err())
+		}
+		return nil
+	}), cfg.ShutdownTimeout(cfg.KafkaShutdownTimeout))
+
+	// Начатые записи в DLQ дописываются до закрытия writer, иначе сообщение теряется
+	manager.RegisterWithTimeout(lifecycle.NewServiceWrapper("dlq", nil, func(ctx context.Context) error {
+		var err error
+		if f, ok := a.DLQService.(flusher); ok {
+			err = f.Flush(ctx)
+		}
+		a.closeDLQ()
+		return err
+	}), cfg.ShutdownTimeout(cfg.DLQShutdownTimeout))
+
+	manager.RegisterWithTimeout(lifecycle.NewServiceWrapper("db", nil, func(ctx context.Context) error {
+		a.closeDB()
+		return nil
+	}), cfg.ShutdownTimeout(cfg.DBShutdownTimeout))
+
+	return manager
+}
+
+// stopHTTP останавливает HTTP серверы и дожидается выполняющихся запросов
+// Сначала новые запросы начинают получать 503, начатые при этом выполняются до конца.
+// Listener закрывается через HTTP_DRAIN_DELAY: балансировщик успевает увидеть 503
+// и перестать слать запросы, а не получает отказ в соединении
+func (a *App) stopHTTP(ctx context.Context) error {
+	var errs []error
+
+	if a.InFlight != nil {
+		a.InFlight.Drain()
+	}
+
+	if delay := a.Config.App.HTTPDrainDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	if a.HTTPServer != nil {
+		if err := a.HTTPServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http server: %w", err))
+		}
+	}
+
+	if a.AdminServer != nil {
+		if err := a.AdminServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("admin server: %w", err))
+		}
+	}
+
+	if a.InFlight != nil {
+		if err := a.InFlight.Wait(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%d in-flight requests: %w", a.InFlight.Count(), err))
+		}
+	}
+
+	if a.Health != nil {
+		a.Health.Stop()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,33 @@
+// Package buildinfo хранит сведения о сборке, заданные через -ldflags
+//
+//	go build -ldflags "-X wbtest/internal/buildinfo.Version=1.2.0 \
+//	  -X wbtest/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X wbtest/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/service
+package buildinfo
+
+import "runtime"
+
+// Значения подставляются при сборке, без -ldflags остаются "dev"
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildTime = "dev"
+)
+
+// Info сведения о запущенной сборке
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get возвращает сведения о текущей сборке
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -1,17 +1,52 @@
 package cache
 
 import (
+	"log"
 	"sync"
 	"time"
+	"wbtest/internal/clock"
 	"wbtest/internal/interfaces"
+	"wbtest/internal/metrics"
 	"wbtest/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EvictionStrategy стратегия вытеснения при переполнении кеша
+type EvictionStrategy string
+
+const (
+	// EvictionLRU вытесняет запись, к которой дольше всего не обращались
+	EvictionLRU EvictionStrategy = "lru"
+	// EvictionLFU вытесняет запись с наименьшим числом обращений
+	EvictionLFU EvictionStrategy = "lfu"
+	// EvictionOldest вытесняет самую старую запись
+	EvictionOldest EvictionStrategy = "oldest"
+)
+
+// DefaultCleanupInterval период фоновой очистки просроченных записей по умолчанию
+const DefaultCleanupInterval = 5 * time.Minute
+
+// DefaultEvictionWarnRate частота вытеснений в секунду, выше которой кеш считается малым
+const DefaultEvictionWarnRate = 1.0
+
+const (
+	// evictionRateWindow окно, за которое считается частота вытеснений
+	evictionRateWindow = time.Minute
+	// evictionWarnInterval не чаще одного предупреждения о частых вытеснениях за интервал
+	evictionWarnInterval = time.Minute
 )
 
 type cacheEntry struct {
-	order      *model.Order
-	createdAt  time.Time
-	lastAccess time.Time
-	mu         sync.RWMutex // мелкогранулярная блокировка для каждого элемента
+	order     *model.Order
+	createdAt time.Time
+	// ttl время жизни записи, задается TTLPolicy при добавлении
+	ttl         time.Duration
+	lastAccess  time.Time
+	accessCount int64
+	// cacheOnly заказ записан только в кеш и в БД его нет, см. SetCacheOnly
+	cacheOnly bool
+	mu        sync.RWMutex // мелкогранулярная блокировка для каждого элемента
 }
 
 type OrderCache struct {
@@ -20,7 +55,17 @@ type OrderCache struct {
 	maxSize         int
 	ttl             time.Duration
 	cleanupInterval time.Duration
+	strategy        EvictionStrategy
 	stopCleanup     chan struct{}
+	clock           clock.Clock
+	// evictionWarnRate порог частоты вытеснений для предупреждения, <= 0 - выключено
+	evictionWarnRate float64
+	// ttlPolicy время жизни записи по заказу, nil - ttl для всех
+	ttlPolicy TTLPolicy
+	// metrics метрики очистки, nil - не собираются
+	metrics *metrics.Metrics
+	// logger итоги каждой очистки пишутся с уровнем debug
+	logger logrus.FieldLogger
 
 	// Метрики
 	stats struct {
@@ -29,16 +74,115 @@ type OrderCache struct {
 		misses      int64
 		evictions   int64
 		expirations int64
+
+		// Вытеснения в текущем и предыдущем окне evictionRateWindow
+		windowStart     time.Time
+		windowEvictions int64
+		prevEvictions   int64
+		lastWarn        time.Time
+	}
+}
+
+// TTLPolicy возвращает время жизни записи заказа в кеше
+// now - время добавления, ttl - время жизни кеша по умолчанию
+type TTLPolicy func(order *model.Order, now time.Time, ttl time.Duration) time.Duration
+
+// RecencyTTLPolicy хранит недавние заказы дольше старых
+// Заказ моложе recentAge получает ttl кеша, более старый - oldTTL, но не больше ttl.
+// Свежие заказы запрашивают чаще, поэтому они остаются в кеше, а старые освобождают место
+func RecencyTTLPolicy(recentAge, oldTTL time.Duration) TTLPolicy {
+	return func(order *model.Order, now time.Time, ttl time.Duration) time.Duration {
+		if order.DateCreated.IsZero() || now.Sub(order.DateCreated) < recentAge {
+			return ttl
+		}
+		if oldTTL < ttl {
+			return oldTTL
+		}
+		return ttl
+	}
+}
+
+// Option настройка OrderCache
+type Option func(*OrderCache)
+
+// WithEvictionStrategy задает стратегию вытеснения
+// Неизвестная стратегия заменяется на EvictionOldest
+func WithEvictionStrategy(strategy EvictionStrategy) Option {
+	return func(c *OrderCache) {
+		switch strategy {
+		case EvictionLRU, EvictionLFU, EvictionOldest:
+			c.strategy = strategy
+		default:
+			c.strategy = EvictionOldest
+		}
+	}
+}
+
+// WithEvictionWarnRate задает частоту вытеснений в секунду, при превышении которой
+// в лог пишется предупреждение, а GetStats отмечает EvictionRateHigh
+// Значение <= 0 отключает предупреждение
+func WithEvictionWarnRate(rate float64) Option {
+	return func(c *OrderCache) {
+		c.evictionWarnRate = rate
+	}
+}
+
+// WithClock задает источник времени для TTL и очистки, в тестах - clock.FakeClock
+func WithClock(c clock.Clock) Option {
+	return func(cache *OrderCache) {
+		cache.clock = c
+	}
+}
+
+// WithCleanupInterval задает период фоновой очистки просроченных записей
+// Значение <= 0 оставляет DefaultCleanupInterval
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(c *OrderCache) {
+		if interval > 0 {
+			c.cleanupInterval = interval
+		}
+	}
+}
+
+// WithMetrics включает метрики фоновой очистки
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(c *OrderCache) {
+		c.metrics = m
+	}
+}
+
+// WithLogger задает логгер для итогов очистки, nil оставляет стандартный logrus
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(c *OrderCache) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// WithTTLPolicy задает время жизни записи в зависимости от заказа
+func WithTTLPolicy(policy TTLPolicy) Option {
+	return func(c *OrderCache) {
+		c.ttlPolicy = policy
 	}
 }
 
-func NewOrderCache(maxSize int, ttl time.Duration) interfaces.OrderCache {
+func NewOrderCache(maxSize int, ttl time.Duration, opts ...Option) interfaces.OrderCache {
 	cache := &OrderCache{
 		orders:          make(map[string]*cacheEntry),
 		maxSize:         maxSize,
 		ttl:             ttl,
-		cleanupInterval: time.Minute * 5,
+		cleanupInterval: DefaultCleanupInterval,
+		strategy:        EvictionOldest,
 		stopCleanup:     make(chan struct{}),
+		clock:           clock.New(),
+		logger:          logrus.StandardLogger(),
+
+		evictionWarnRate: DefaultEvictionWarnRate,
+	}
+
+	for _, opt := range opts {
+		opt(cache)
 	}
 
 	go cache.startCleanup()
@@ -58,7 +202,7 @@ func (c *OrderCache) Get(orderUID string) (*model.Order, bool) {
 
 	// Проверяем TTL с мелкогранулярной блокировкой
 	entry.mu.RLock()
-	if time.Since(entry.createdAt) > c.ttl {
+	if entry.expired(c.clock.Now()) {
 		entry.mu.RUnlock()
 		c.Delete(orderUID)
 		c.incExpirations()
@@ -66,53 +210,158 @@ func (c *OrderCache) Get(orderUID string) (*model.Order, bool) {
 		return nil, false
 	}
 
-	// Обновляем время последнего доступа
-	entry.lastAccess = time.Now()
-	order := entry.order
 	entry.mu.RUnlock()
 
+	// Обновляем время последнего доступа и счетчик обращений
+	entry.mu.Lock()
+	entry.lastAccess = c.clock.Now()
+	entry.accessCount++
+	order := entry.order
+	entry.mu.Unlock()
+
 	c.incHits()
 	return order, true
 }
 
+// Peek возвращает заказ без учета в статистике попаданий и без обновления времени доступа
+// Нужен фоновым проверкам, которые не должны влиять на вытеснение и hit rate
+func (c *OrderCache) Peek(orderUID string) (*model.Order, bool) {
+	c.mu.RLock()
+	entry, exists := c.orders[orderUID]
+	c.mu.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	entry.mu.RLock()
+	defer entry.mu.RUnlock()
+	if entry.expired(c.clock.Now()) {
+		return nil, false
+	}
+	return entry.order, true
+}
+
+// Set сохраняет заказ, заменяя закешированный
 func (c *OrderCache) Set(order *model.Order) {
+	c.set(order, false)
+}
+
+// SetCacheOnly сохраняет заказ, который записан только в кеш (POST /order)
+// Сверка с БД не считает такой заказ расхождением и не удаляет его.
+// Запись заказа из Kafka через Set или SetIfNewer снимает отметку
+func (c *OrderCache) SetCacheOnly(order *model.Order) {
+	c.set(order, true)
+}
+
+func (c *OrderCache) set(order *model.Order, cacheOnly bool) {
 	if order == nil || order.OrderUID == "" {
 		return
 	}
 
-	now := time.Now()
-	newEntry := &cacheEntry{
-		order:      order,
-		createdAt:  now,
-		lastAccess: now,
-	}
+	newEntry := c.newEntry(order, c.clock.Now())
+	newEntry.cacheOnly = cacheOnly
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Если кеш полный, удаляем самый старый элемент
+	// Если кеш полный, вытесняем запись согласно стратегии
 	if len(c.orders) >= c.maxSize {
-		c.evictOldest()
+		c.evict()
 	}
 
 	c.orders[order.OrderUID] = newEntry
 }
 
+// SetIfNewer сохраняет заказ, если в кеше нет более нового по DateCreated
+// Устаревшее сообщение, обработанное позже нового, не затирает его в кеше.
+// Заказ с той же датой заменяет закешированный, чтобы повторная запись продлевала TTL
+func (c *OrderCache) SetIfNewer(order *model.Order) bool {
+	if order == nil || order.OrderUID == "" {
+		return false
+	}
+
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.orders[order.OrderUID]; exists {
+		entry.mu.RLock()
+		expired := entry.expired(now)
+		newer := entry.order.DateCreated.After(order.DateCreated)
+		entry.mu.RUnlock()
+
+		if newer && !expired {
+			return false
+		}
+	} else if len(c.orders) >= c.maxSize {
+		c.evict()
+	}
+
+	c.orders[order.OrderUID] = c.newEntry(order, now)
+	return true
+}
+
+// peekEntry возвращает заказ с временем записи и отметкой cacheOnly без учета в статистике
+func (c *OrderCache) peekEntry(orderUID string) (order *model.Order, createdAt time.Time, cacheOnly, ok bool) {
+	c.mu.RLock()
+	entry, exists := c.orders[orderUID]
+	c.mu.RUnlock()
+	if !exists {
+		return nil, time.Time{}, false, false
+	}
+
+	entry.mu.RLock()
+	defer entry.mu.RUnlock()
+	if entry.expired(c.clock.Now()) {
+		return nil, time.Time{}, false, false
+	}
+	return entry.order, entry.createdAt, entry.cacheOnly, true
+}
+
+// newEntry создает запись с временем жизни по ttlPolicy
+func (c *OrderCache) newEntry(order *model.Order, now time.Time) *cacheEntry {
+	ttl := c.ttl
+	if c.ttlPolicy != nil {
+		ttl = c.ttlPolicy(order, now, c.ttl)
+	}
+	return &cacheEntry{
+		order:      order,
+		createdAt:  now,
+		lastAccess: now,
+		ttl:        ttl,
+	}
+}
+
+// expired сообщает, что время жизни записи истекло, вызывающий держит entry.mu
+func (e *cacheEntry) expired(now time.Time) bool {
+	return now.Sub(e.createdAt) > e.ttl
+}
+
 func (c *OrderCache) LoadAll(orders []*model.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	// Очищаем кеш перед загрузкой
 	c.orders = make(map[string]*cacheEntry)
+	c.addLocked(orders)
+}
+
+// AddAll добавляет заказы в кеш без очистки, как LoadAll без вытеснения
+// Безопасен для одновременного вызова из нескольких горутин: загрузчики частей
+// не затирают данные друг друга
+func (c *OrderCache) AddAll(orders []*model.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addLocked(orders)
+}
 
-	now := time.Now()
+// addLocked добавляет заказы, вызывающий держит c.mu
+func (c *OrderCache) addLocked(orders []*model.Order) {
+	now := c.clock.Now()
 	for _, order := range orders {
 		if order != nil && order.OrderUID != "" {
-			c.orders[order.OrderUID] = &cacheEntry{
-				order:      order,
-				createdAt:  now,
-				lastAccess: now,
-			}
+			c.orders[order.OrderUID] = c.newEntry(order, now)
 		}
 	}
 }
@@ -123,12 +372,49 @@ func (c *OrderCache) Delete(orderUID string) {
 	delete(c.orders, orderUID)
 }
 
+// DeleteWhere удаляет из кеша все заказы, удовлетворяющие предикату
+// Возвращает количество удаленных записей
+func (c *OrderCache) DeleteWhere(pred func(*model.Order) bool) int {
+	if pred == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, entry := range c.orders {
+		entry.mu.RLock()
+		order := entry.order
+		entry.mu.RUnlock()
+
+		if pred(order) {
+			delete(c.orders, key)
+			removed++
+		}
+	}
+	return removed
+}
+
 func (c *OrderCache) Size() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return len(c.orders)
 }
 
+// Keys возвращает снимок UID заказов, находящихся в кеше
+// Блокировка на чтение держится только на время копирования ключей
+func (c *OrderCache) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.orders))
+	for key := range c.orders {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 func (c *OrderCache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -145,44 +431,92 @@ func (c *OrderCache) GetStats() interfaces.CacheStats {
 		hitRate = float64(c.stats.hits) / float64(total) * 100
 	}
 
+	size := c.Size()
+	evictionRate := c.evictionRate(c.clock.Now())
+
 	return interfaces.CacheStats{
-		Size:        c.Size(),
-		Hits:        c.stats.hits,
-		Misses:      c.stats.misses,
-		HitRate:     hitRate,
-		Evictions:   c.stats.evictions,
-		Expirations: c.stats.expirations,
+		Size:             size,
+		Hits:             c.stats.hits,
+		Misses:           c.stats.misses,
+		HitRate:          hitRate,
+		Evictions:        c.stats.evictions,
+		Expirations:      c.stats.expirations,
+		MaxSize:          c.maxSize,
+		Full:             size >= c.maxSize,
+		EvictionRate:     evictionRate,
+		EvictionRateHigh: c.evictionWarnRate > 0 && evictionRate > c.evictionWarnRate,
 	}
 }
 
-func (c *OrderCache) evictOldest() {
-	var oldestKey string
-	var oldestTime time.Time
+// evictionRate возвращает вытеснения в секунду за последние evictionRateWindow
+// Предыдущее окно учитывается с весом той доли, что еще попадает в интервал
+// Вызывается под блокировкой c.stats.mu
+func (c *OrderCache) evictionRate(now time.Time) float64 {
+	if c.stats.windowStart.IsZero() {
+		return 0
+	}
 
-	for key, entry := range c.orders {
-		entry.mu.RLock()
-		createdAt := entry.createdAt
-		entry.mu.RUnlock()
+	elapsed := now.Sub(c.stats.windowStart)
+	current, previous := c.stats.windowEvictions, c.stats.prevEvictions
+	switch {
+	case elapsed >= 2*evictionRateWindow:
+		return 0
+	case elapsed >= evictionRateWindow:
+		current, previous = 0, current
+		elapsed -= evictionRateWindow
+	}
+
+	weight := 1 - float64(elapsed)/float64(evictionRateWindow)
+	return (float64(current) + float64(previous)*weight) / evictionRateWindow.Seconds()
+}
+
+// evict удаляет одну запись согласно стратегии вытеснения
+// Вызывается под блокировкой c.mu
+func (c *OrderCache) evict() {
+	var victimKey string
+	var victim *cacheEntry
 
-		if oldestKey == "" || createdAt.Before(oldestTime) {
-			oldestKey = key
-			oldestTime = createdAt
+	for key, entry := range c.orders {
+		if victim == nil || c.evictsBefore(entry, victim) {
+			victimKey = key
+			victim = entry
 		}
 	}
 
-	if oldestKey != "" {
-		delete(c.orders, oldestKey)
+	if victimKey != "" {
+		delete(c.orders, victimKey)
 		c.incEvictions()
 	}
 }
 
-func (c *OrderCache) startCleanup() {
-	ticker := time.NewTicker(c.cleanupInterval)
-	defer ticker.Stop()
+// evictsBefore сообщает, должна ли запись a быть вытеснена раньше b
+func (c *OrderCache) evictsBefore(a, b *cacheEntry) bool {
+	a.mu.RLock()
+	aCreated, aAccess, aCount := a.createdAt, a.lastAccess, a.accessCount
+	a.mu.RUnlock()
+
+	b.mu.RLock()
+	bCreated, bAccess, bCount := b.createdAt, b.lastAccess, b.accessCount
+	b.mu.RUnlock()
+
+	switch c.strategy {
+	case EvictionLRU:
+		return aAccess.Before(bAccess)
+	case EvictionLFU:
+		// При равном числе обращений вытесняем давно не использованную запись
+		if aCount != bCount {
+			return aCount < bCount
+		}
+		return aAccess.Before(bAccess)
+	default:
+		return aCreated.Before(bCreated)
+	}
+}
 
+func (c *OrderCache) startCleanup() {
 	for {
 		select {
-		case <-ticker.C:
+		case <-c.clock.After(c.cleanupInterval):
 			c.cleanup()
 		case <-c.stopCleanup:
 			return
@@ -190,20 +524,22 @@ func (c *OrderCache) startCleanup() {
 	}
 }
 
+// cleanup удаляет просроченные записи и сообщает итог очистки в лог и метрики
+// Записи, вытесненные при переполнении, сюда не входят, их считает Evictions
 func (c *OrderCache) cleanup() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := time.Now()
+	now := c.clock.Now()
 	expiredKeys := make([]string, 0)
 
 	// Собираем ключи устаревших записей
 	for key, entry := range c.orders {
 		entry.mu.RLock()
-		createdAt := entry.createdAt
+		expired := entry.expired(now)
 		entry.mu.RUnlock()
 
-		if now.Sub(createdAt) > c.ttl {
+		if expired {
 			expiredKeys = append(expiredKeys, key)
 		}
 	}
@@ -213,6 +549,15 @@ func (c *OrderCache) cleanup() {
 		delete(c.orders, key)
 		c.incExpirations()
 	}
+
+	if c.metrics != nil {
+		c.metrics.CacheCleanupSweeps.Inc()
+		c.metrics.CacheLastSweepExpired.Set(float64(len(expiredKeys)))
+	}
+	c.logger.WithFields(logrus.Fields{
+		"expired": len(expiredKeys),
+		"size":    len(c.orders),
+	}).Debug("Cache cleanup sweep finished")
 }
 
 func (c *OrderCache) Stop() {
@@ -234,8 +579,33 @@ func (c *OrderCache) incMisses() {
 
 func (c *OrderCache) incEvictions() {
 	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+
 	c.stats.evictions++
-	c.stats.mu.Unlock()
+
+	// Сдвигаем окно подсчета частоты вытеснений
+	now := c.clock.Now()
+	elapsed := now.Sub(c.stats.windowStart)
+	switch {
+	case c.stats.windowStart.IsZero() || elapsed >= 2*evictionRateWindow:
+		c.stats.windowStart = now
+		c.stats.windowEvictions = 0
+		c.stats.prevEvictions = 0
+	case elapsed >= evictionRateWindow:
+		c.stats.windowStart = c.stats.windowStart.Add(evictionRateWindow)
+		c.stats.prevEvictions = c.stats.windowEvictions
+		c.stats.windowEvictions = 0
+	}
+	c.stats.windowEvictions++
+
+	if c.evictionWarnRate <= 0 || now.Sub(c.stats.lastWarn) < evictionWarnInterval {
+		return
+	}
+	if rate := c.evictionRate(now); rate > c.evictionWarnRate {
+		c.stats.lastWarn = now
+		log.Printf("[CACHE] Eviction rate %.2f/s exceeds %.2f/s, cache is full (%d orders), consider increasing CACHE_MAX_SIZE",
+			rate, c.evictionWarnRate, c.maxSize)
+	}
 }
 
 func (c *OrderCache) incExpirations() {
@@ -2,10 +2,16 @@ package cache
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"wbtest/internal/clock"
+	"wbtest/internal/metrics"
 	"wbtest/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestOrderCache_Get(t *testing.T) {
@@ -74,6 +80,45 @@ func TestOrderCache_Set(t *testing.T) {
 	}
 }
 
+func TestOrderCache_SetIfNewer(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+	cache := NewOrderCache(10, time.Hour, WithClock(fakeClock))
+	defer cache.(*OrderCache).Stop()
+
+	newer := time.Now()
+	older := newer.Add(-time.Minute)
+
+	if !cache.SetIfNewer(&model.Order{OrderUID: "test123", DateCreated: newer, TrackNumber: "NEW"}) {
+		t.Fatal("Expected order to be cached")
+	}
+
+	// Старый заказ не вытесняет новый
+	if cache.SetIfNewer(&model.Order{OrderUID: "test123", DateCreated: older, TrackNumber: "OLD"}) {
+		t.Error("Expected older order to be skipped")
+	}
+	if order, _ := cache.Get("test123"); order.TrackNumber != "NEW" {
+		t.Errorf("Expected newer order to stay cached, got %s", order.TrackNumber)
+	}
+
+	// Заказ с той же датой заменяет закешированный
+	if !cache.SetIfNewer(&model.Order{OrderUID: "test123", DateCreated: newer, TrackNumber: "SAME"}) {
+		t.Error("Expected order with equal date to be cached")
+	}
+	if order, _ := cache.Get("test123"); order.TrackNumber != "SAME" {
+		t.Errorf("Expected order with equal date to replace cached, got %s", order.TrackNumber)
+	}
+
+	// Просроченная запись не мешает сохранить старый заказ
+	fakeClock.Advance(2 * time.Hour)
+	if !cache.SetIfNewer(&model.Order{OrderUID: "test123", DateCreated: older, TrackNumber: "OLD"}) {
+		t.Error("Expected older order to replace expired entry")
+	}
+
+	if cache.SetIfNewer(nil) || cache.SetIfNewer(&model.Order{}) {
+		t.Error("Expected nil and empty orders to be skipped")
+	}
+}
+
 func TestOrderCache_Delete(t *testing.T) {
 	cache := NewOrderCache(10, time.Hour)
 	defer cache.(*OrderCache).Stop()
@@ -184,8 +229,107 @@ func TestOrderCache_Eviction(t *testing.T) {
 	}
 }
 
+func TestOrderCache_EvictionRate(t *testing.T) {
+	cache := NewOrderCache(10, time.Hour, WithEvictionWarnRate(1))
+	defer cache.(*OrderCache).Stop()
+
+	stats := cache.GetStats()
+	if stats.Full || stats.EvictionRate != 0 || stats.EvictionRateHigh {
+		t.Fatalf("Expected empty cache without evictions, got %+v", stats)
+	}
+
+	// Быстро вставляем заказов намного больше емкости
+	for i := 0; i < 1000; i++ {
+		cache.Set(&model.Order{OrderUID: fmt.Sprintf("test%d", i)})
+	}
+
+	stats = cache.GetStats()
+	if !stats.Full {
+		t.Error("Expected cache to be reported full")
+	}
+	if stats.MaxSize != 10 {
+		t.Errorf("Expected max size 10, got %d", stats.MaxSize)
+	}
+	if stats.Evictions != 990 {
+		t.Errorf("Expected 990 evictions, got %d", stats.Evictions)
+	}
+	if stats.EvictionRate <= 1 {
+		t.Errorf("Expected eviction rate above 1/s, got %.2f", stats.EvictionRate)
+	}
+	if !stats.EvictionRateHigh {
+		t.Error("Expected high eviction rate to be reported")
+	}
+}
+
+func TestOrderCache_EvictionStrategies(t *testing.T) {
+	// Один и тот же сценарий обращений:
+	// "hot" читается часто, но давно; "warm" и "cold" по разу, но позже
+	// LRU вытесняет давно не использованный "hot", LFU - редко используемый "warm"
+	tests := []struct {
+		name     string
+		strategy EvictionStrategy
+		evicted  string
+		survivor string
+	}{
+		{"lru", EvictionLRU, "hot", "warm"},
+		{"lfu", EvictionLFU, "warm", "hot"},
+		{"oldest", EvictionOldest, "hot", "warm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewOrderCache(3, time.Hour, WithEvictionStrategy(tt.strategy))
+			defer cache.(*OrderCache).Stop()
+
+			for _, uid := range []string{"hot", "warm", "cold"} {
+				cache.Set(&model.Order{OrderUID: uid})
+				time.Sleep(time.Millisecond)
+			}
+
+			for i := 0; i < 5; i++ {
+				cache.Get("hot")
+			}
+			time.Sleep(time.Millisecond)
+			cache.Get("warm")
+			time.Sleep(time.Millisecond)
+			cache.Get("cold")
+
+			cache.Set(&model.Order{OrderUID: "new"})
+
+			keys := cache.Keys()
+			if len(keys) != 3 {
+				t.Fatalf("Expected 3 entries after eviction, got %d", len(keys))
+			}
+
+			present := make(map[string]bool, len(keys))
+			for _, key := range keys {
+				present[key] = true
+			}
+			if present[tt.evicted] {
+				t.Errorf("Expected %s to be evicted", tt.evicted)
+			}
+			if !present[tt.survivor] {
+				t.Errorf("Expected %s to survive", tt.survivor)
+			}
+			if !present["new"] {
+				t.Error("Expected new entry to be present")
+			}
+		})
+	}
+}
+
+func TestOrderCache_UnknownEvictionStrategy(t *testing.T) {
+	cache := NewOrderCache(1, time.Hour, WithEvictionStrategy("random"))
+	defer cache.(*OrderCache).Stop()
+
+	if strategy := cache.(*OrderCache).strategy; strategy != EvictionOldest {
+		t.Errorf("Expected fallback to %s, got %s", EvictionOldest, strategy)
+	}
+}
+
 func TestOrderCache_TTL(t *testing.T) {
-	cache := NewOrderCache(10, time.Millisecond*100) // TTL 100ms
+	fakeClock := clock.NewFake(time.Now())
+	cache := NewOrderCache(10, time.Millisecond*100, WithClock(fakeClock)) // TTL 100ms
 	defer cache.(*OrderCache).Stop()
 
 	// Добавляем заказ
@@ -204,8 +348,8 @@ func TestOrderCache_TTL(t *testing.T) {
 		t.Error("Expected order to be non-nil immediately after setting")
 	}
 
-	// Ждем, пока заказ истечет
-	time.Sleep(time.Millisecond * 150)
+	// Переводим часы за TTL
+	fakeClock.Advance(time.Millisecond * 150)
 
 	// Проверяем, что заказ больше не доступен
 	order, exists = cache.Get("test123")
@@ -251,3 +395,212 @@ func TestOrderCache_GetStats(t *testing.T) {
 		t.Errorf("Expected hit rate ~66.67%%, got %.2f%%", stats.HitRate)
 	}
 }
+
+func TestOrderCache_Keys(t *testing.T) {
+	cache := NewOrderCache(10, time.Hour)
+	defer cache.(*OrderCache).Stop()
+
+	// Пустой кеш возвращает пустой снимок
+	if keys := cache.Keys(); len(keys) != 0 {
+		t.Errorf("Expected no keys in empty cache, got %v", keys)
+	}
+
+	expected := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		uid := fmt.Sprintf("test%d", i)
+		cache.Set(&model.Order{OrderUID: uid})
+		expected[uid] = true
+	}
+
+	keys := cache.Keys()
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %d", len(expected), len(keys))
+	}
+	for _, key := range keys {
+		if !expected[key] {
+			t.Errorf("Unexpected key %s", key)
+		}
+		delete(expected, key)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Missing keys: %v", expected)
+	}
+}
+
+func TestOrderCache_DeleteWhere(t *testing.T) {
+	cache := NewOrderCache(10, time.Hour)
+	defer cache.(*OrderCache).Stop()
+
+	cache.Set(&model.Order{OrderUID: "order1", CustomerID: "alice"})
+	cache.Set(&model.Order{OrderUID: "order2", CustomerID: "bob"})
+	cache.Set(&model.Order{OrderUID: "order3", CustomerID: "alice"})
+
+	removed := cache.DeleteWhere(func(order *model.Order) bool {
+		return order.CustomerID == "alice"
+	})
+
+	if removed != 2 {
+		t.Errorf("Expected 2 removed orders, got %d", removed)
+	}
+	if cache.Size() != 1 {
+		t.Errorf("Expected cache size 1, got %d", cache.Size())
+	}
+	if _, exists := cache.Get("order2"); !exists {
+		t.Error("Expected order of other customer to remain in cache")
+	}
+
+	// nil предикат ничего не удаляет
+	if removed := cache.DeleteWhere(nil); removed != 0 {
+		t.Errorf("Expected 0 removed orders for nil predicate, got %d", removed)
+	}
+}
+
+func TestOrderCache_DeleteWhere_Concurrent(t *testing.T) {
+	cache := NewOrderCache(1000, time.Hour)
+	defer cache.(*OrderCache).Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Set(&model.Order{OrderUID: fmt.Sprintf("order-%d-%d", n, j), CustomerID: "alice"})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.DeleteWhere(func(order *model.Order) bool {
+					return order.CustomerID == "alice"
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	cache.DeleteWhere(func(order *model.Order) bool {
+		return order.CustomerID == "alice"
+	})
+	if cache.Size() != 0 {
+		t.Errorf("Expected empty cache, got %d", cache.Size())
+	}
+}
+
+func TestOrderCache_CleanupExpired(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+	cache := NewOrderCache(10, time.Minute, WithClock(fakeClock))
+	defer cache.(*OrderCache).Stop()
+
+	cache.Set(&model.Order{OrderUID: "test123"})
+
+	// Ждем, пока фоновая очистка начнет ждать интервал
+	deadline := time.Now().Add(time.Second)
+	for fakeClock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Cleanup goroutine did not start waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Интервал очистки 5 минут, TTL к этому времени истек
+	fakeClock.Advance(5 * time.Minute)
+
+	deadline = time.Now().Add(time.Second)
+	for cache.Size() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected expired order to be cleaned up, size %d", cache.Size())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats := cache.GetStats(); stats.Expirations != 1 {
+		t.Errorf("Expected 1 expiration, got %d", stats.Expirations)
+	}
+}
+
+func TestOrderCache_CleanupSweepMetrics(t *testing.T) {
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	fakeClock := clock.NewFake(time.Now())
+	cache := NewOrderCache(10, 20*time.Millisecond,
+		WithClock(fakeClock),
+		WithCleanupInterval(10*time.Millisecond),
+		WithMetrics(m),
+	)
+	defer cache.(*OrderCache).Stop()
+
+	cache.Set(&model.Order{OrderUID: "order-1"})
+	cache.Set(&model.Order{OrderUID: "order-2"})
+
+	// sweep сдвигает время и ждет want завершенных очисток
+	sweep := func(advance time.Duration, want float64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for fakeClock.Waiters() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Cleanup goroutine did not start waiting")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		fakeClock.Advance(advance)
+		for testutil.ToFloat64(m.CacheCleanupSweeps) < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %v cleanup sweeps, got %v", want, testutil.ToFloat64(m.CacheCleanupSweeps))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// TTL еще не истек
+	sweep(10*time.Millisecond, 1)
+	if got := testutil.ToFloat64(m.CacheLastSweepExpired); got != 0 {
+		t.Errorf("Expected first sweep to expire nothing, got %v", got)
+	}
+
+	sweep(15*time.Millisecond, 2)
+	if got := testutil.ToFloat64(m.CacheLastSweepExpired); got != 2 {
+		t.Errorf("Expected last sweep to expire 2 entries, got %v", got)
+	}
+	if cache.Size() != 0 {
+		t.Errorf("Expected empty cache after sweep, got %d", cache.Size())
+	}
+}
+
+func TestOrderCache_RecencyTTLPolicy(t *testing.T) {
+	now := time.Now()
+	fakeClock := clock.NewFake(now)
+	cache := NewOrderCache(10, time.Hour,
+		WithClock(fakeClock),
+		WithTTLPolicy(RecencyTTLPolicy(24*time.Hour, 10*time.Minute)))
+	defer cache.(*OrderCache).Stop()
+
+	cache.Set(&model.Order{OrderUID: "recent", DateCreated: now.Add(-time.Hour)})
+	cache.Set(&model.Order{OrderUID: "old", DateCreated: now.AddDate(0, 0, -30)})
+	cache.Set(&model.Order{OrderUID: "undated"})
+
+	fakeClock.Advance(15 * time.Minute)
+
+	if _, ok := cache.Get("old"); ok {
+		t.Error("Expected old order to expire after its shorter TTL")
+	}
+	if _, ok := cache.Get("recent"); !ok {
+		t.Error("Expected recent order to stay cached")
+	}
+	if _, ok := cache.Get("undated"); !ok {
+		t.Error("Expected order without date to use default TTL")
+	}
+
+	fakeClock.Advance(time.Hour)
+	if _, ok := cache.Get("recent"); ok {
+		t.Error("Expected recent order to expire after default TTL")
+	}
+}
+
+func TestRecencyTTLPolicy_CappedByDefault(t *testing.T) {
+	now := time.Now()
+	policy := RecencyTTLPolicy(time.Hour, 2*time.Hour)
+
+	old := &model.Order{DateCreated: now.Add(-48 * time.Hour)}
+	if got := policy(old, now, 30*time.Minute); got != 30*time.Minute {
+		t.Errorf("Expected TTL capped at default 30m, got %v", got)
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"wbtest/internal/interfaces"
+)
+
+// DefaultStatsHistorySize число снимков в истории статистики по умолчанию
+const DefaultStatsHistorySize = 60
+
+// StatsSample снимок статистики кеша в момент Time
+type StatsSample struct {
+	Time      time.Time `json:"time"`
+	Size      int       `json:"size"`
+	Hits      int64     `json:"hits"`
+	Misses    int64     `json:"misses"`
+	HitRate   float64   `json:"hit_rate"`
+	Evictions int64     `json:"evictions"`
+}
+
+// StatsHistory последние снимки статистики кеша в кольцевом буфере
+// GetStats показывает только текущее состояние, история показывает динамику
+// размера и hit rate без Prometheus. Старые снимки вытесняются новыми
+type StatsHistory struct {
+	cache interfaces.OrderCache
+	stop  chan struct{}
+
+	mu      sync.Mutex
+	samples []StatsSample
+	// next позиция следующего снимка, count число заполненных позиций
+	next  int
+	count int
+}
+
+// NewStatsHistory создает историю статистики cache на size снимков
+// Значение size <= 0 заменяется на DefaultStatsHistorySize
+func NewStatsHistory(cache interfaces.OrderCache, size int) *StatsHistory {
+	if size <= 0 {
+		size = DefaultStatsHistorySize
+	}
+	return &StatsHistory{
+		cache:   cache,
+		stop:    make(chan struct{}),
+		samples: make([]StatsSample, size),
+	}
+}
+
+// Start снимает статистику каждые interval до вызова Stop
+func (h *StatsHistory) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.Sample()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает снятие статистики
+func (h *StatsHistory) Stop() {
+	close(h.stop)
+}
+
+// Sample добавляет снимок текущей статистики кеша
+func (h *StatsHistory) Sample() {
+	stats := h.cache.GetStats()
+	sample := StatsSample{
+		Time:      time.Now().UTC(),
+		Size:      stats.Size,
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		HitRate:   stats.HitRate,
+		Evictions: stats.Evictions,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.count < len(h.samples) {
+		h.count++
+	}
+}
+
+// Samples возвращает снимки от старых к новым
+func (h *StatsHistory) Samples() []StatsSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	samples := make([]StatsSample, 0, h.count)
+	start := (h.next - h.count + len(h.samples)) % len(h.samples)
+	for i := 0; i < h.count; i++ {
+		samples = append(samples, h.samples[(start+i)%len(h.samples)])
+	}
+	return samples
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"wbtest/internal/interfaces"
+	"wbtest/internal/model"
+)
+
+// OrderLoader источник заказов для загрузки кеша, его реализует interfaces.OrderRepository
+type OrderLoader interface {
+	LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error)
+}
+
+// Load заменяет содержимое кеша заказами из репозитория
+// При workers > 1 заказы читаются workers параллельными запросами, каждый
+// загружает свою часть (interfaces.Partition) и сразу добавляет ее в кеш
+// При ошибке любой части остальные запросы отменяются, кеш остается пустым
+// Возвращает число загруженных заказов
+func Load(ctx context.Context, repo OrderLoader, c *OrderCache, workers int) (int, error) {
+	if workers <= 1 {
+		orders, err := repo.LoadAllOrders(ctx)
+		if err != nil {
+			c.Clear()
+			return 0, err
+		}
+		c.LoadAll(orders)
+		return len(orders), nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.Clear()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		loaded   int
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			orders, err := repo.LoadAllOrders(ctx, interfaces.Partition(index, workers))
+			if err == nil {
+				c.AddAll(orders)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("partition %d/%d: %w", index, workers, err)
+					cancel()
+				}
+				return
+			}
+			loaded += len(orders)
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		c.Clear()
+		return 0, firstErr
+	}
+	return loaded, nil
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"wbtest/internal/interfaces"
+	"wbtest/internal/model"
+)
+
+// partitionedRepo отдает заказы с учетом Partition
+// latency имитирует время чтения одного заказа из БД
+type partitionedRepo struct {
+	orders  []*model.Order
+	latency time.Duration
+	// failPartition номер части, запрос которой завершится ошибкой, -1 - без ошибок
+	failPartition int
+}
+
+func newPartitionedRepo(count int, latency time.Duration) *partitionedRepo {
+	orders := make([]*model.Order, count)
+	for i := range orders {
+		orders[i] = &model.Order{OrderUID: fmt.Sprintf("order-%d", i)}
+	}
+	return &partitionedRepo{orders: orders, latency: latency, failPartition: -1}
+}
+
+func (r *partitionedRepo) LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	if options.PartitionCount > 1 && options.PartitionIndex == r.failPartition {
+		return nil, errors.New("connection reset")
+	}
+
+	var orders []*model.Order
+	for _, order := range r.orders {
+		if options.InPartition(order.OrderUID) {
+			orders = append(orders, order)
+		}
+	}
+	time.Sleep(time.Duration(len(orders)) * r.latency)
+	return orders, nil
+}
+
+func TestLoad(t *testing.T) {
+	for _, workers := range []int{0, 1, 4, 16} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			repo := newPartitionedRepo(1000, 0)
+			cache := NewOrderCache(2000, time.Hour).(*OrderCache)
+			defer cache.Stop()
+			cache.Set(&model.Order{OrderUID: "stale"})
+
+			loaded, err := Load(context.Background(), repo, cache, workers)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if loaded != 1000 || cache.Size() != 1000 {
+				t.Errorf("Expected 1000 orders loaded and cached, got loaded=%d size=%d", loaded, cache.Size())
+			}
+			if _, ok := cache.Get("stale"); ok {
+				t.Error("Expected previous cache contents to be replaced")
+			}
+			for _, order := range repo.orders {
+				if _, ok := cache.Get(order.OrderUID); !ok {
+					t.Fatalf("Order %s missing from cache", order.OrderUID)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_PartitionError(t *testing.T) {
+	repo := newPartitionedRepo(100, 0)
+	repo.failPartition = 2
+	cache := NewOrderCache(200, time.Hour).(*OrderCache)
+	defer cache.Stop()
+
+	loaded, err := Load(context.Background(), repo, cache, 4)
+	if err == nil {
+		t.Fatal("Expected error from failed partition")
+	}
+	if loaded != 0 || cache.Size() != 0 {
+		t.Errorf("Expected empty cache after failed load, got loaded=%d size=%d", loaded, cache.Size())
+	}
+}
+
+func TestOrderCache_AddAllConcurrent(t *testing.T) {
+	cache := NewOrderCache(10000, time.Hour).(*OrderCache)
+	defer cache.Stop()
+
+	done := make(chan struct{})
+	for w := 0; w < 8; w++ {
+		go func(w int) {
+			defer func() { done <- struct{}{} }()
+			orders := make([]*model.Order, 100)
+			for i := range orders {
+				orders[i] = &model.Order{OrderUID: fmt.Sprintf("w%d-%d", w, i)}
+			}
+			cache.AddAll(orders)
+		}(w)
+	}
+	for w := 0; w < 8; w++ {
+		<-done
+	}
+
+	if cache.Size() != 800 {
+		t.Errorf("Expected 800 orders after concurrent AddAll, got %d", cache.Size())
+	}
+}
+
+func benchmarkLoad(b *testing.B, workers int) {
+	repo := newPartitionedRepo(2000, time.Microsecond)
+	cache := NewOrderCache(4000, time.Hour).(*OrderCache)
+	defer cache.Stop()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Load(context.Background(), repo, cache, workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoad_Serial(b *testing.B) {
+	benchmarkLoad(b, 1)
+}
+
+func BenchmarkLoad_Parallel(b *testing.B) {
+	benchmarkLoad(b, 8)
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/metrics"
+	"wbtest/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultReconcileSampleRate доля заказов кеша, сверяемых с БД за проход, по умолчанию
+const DefaultReconcileSampleRate = 0.1
+
+// Виды расхождений кеша с БД, метка kind в cache_reconcile_mismatches_total
+const (
+	// MismatchStale заказ в кеше отличается от заказа в БД
+	MismatchStale = "stale"
+	// MismatchMissing заказ есть в кеше, но удален из БД
+	MismatchMissing = "missing"
+)
+
+// OrderGetter источник заказов для сверки, его реализует interfaces.OrderRepository
+type OrderGetter interface {
+	GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error)
+}
+
+// ReconcileResult итоги одного прохода сверки
+type ReconcileResult struct {
+	Checked int
+	Stale   int
+	Missing int
+	Healed  int
+	// Errors заказы, которые не удалось прочитать из БД, они не считаются расхождением
+	Errors int
+}
+
+// Reconciler периодически сверяет случайную часть кеша с БД
+// Кеш обновляется только из Kafka и HTTP, поэтому изменения в БД в обход сервиса
+// и потерянные обновления иначе остаются незамеченными до истечения TTL.
+// Расхождения пишутся в лог и метрики, с WithHealing запись кеша исправляется по БД.
+// Не сверяются заказы, записанные только в кеш (POST /order), и записи моложе grace:
+// запись в БД по ним может быть еще не завершена
+type Reconciler struct {
+	cache      *OrderCache
+	repo       OrderGetter
+	sampleRate float64
+	heal       bool
+	// grace записи кеша моложе grace не сверяются, Start выставляет интервал сверки
+	grace   time.Duration
+	metrics *metrics.Metrics
+	logger  logrus.FieldLogger
+	stop    chan struct{}
+}
+
+// NewReconciler создает сверку кеша c с репозиторием repo
+// sampleRate - доля заказов кеша за проход, значения вне (0, 1] заменяются на DefaultReconcileSampleRate
+func NewReconciler(c *OrderCache, repo OrderGetter, sampleRate float64) *Reconciler {
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = DefaultReconcileSampleRate
+	}
+	return &Reconciler{
+		cache:      c,
+		repo:       repo,
+		sampleRate: sampleRate,
+		logger:     logrus.StandardLogger(),
+		stop:       make(chan struct{}),
+	}
+}
+
+// WithHealing включает исправление расхождений: устаревший заказ заменяется заказом из БД
+// через SetIfNewer, удаленный из БД убирается из кеша
+func (r *Reconciler) WithHealing(enabled bool) *Reconciler {
+	r.heal = enabled
+	return r
+}
+
+// WithMetrics включает метрики сверки, nil - не собираются
+func (r *Reconciler) WithMetrics(m *metrics.Metrics) *Reconciler {
+	r.metrics = m
+	return r
+}
+
+// WithLogger задает логгер расхождений
+func (r *Reconciler) WithLogger(logger logrus.FieldLogger) *Reconciler {
+	r.logger = logger
+	return r
+}
+
+// WithGracePeriod задает возраст записи кеша, с которого она сверяется с БД
+func (r *Reconciler) WithGracePeriod(grace time.Duration) *Reconciler {
+	r.grace = grace
+	return r
+}
+
+// Start запускает сверку каждые interval до вызова Stop
+// Проход ограничен interval, чтобы медленная БД не накапливала проходы.
+// Если WithGracePeriod не задан, записи моложе interval не сверяются
+func (r *Reconciler) Start(interval time.Duration) {
+	if r.grace <= 0 {
+		r.grace = interval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				result := r.Reconcile(ctx)
+				cancel()
+				if result.Stale > 0 || result.Missing > 0 || result.Errors > 0 {
+					r.logger.Warnf("Cache reconciliation: checked=%d stale=%d missing=%d healed=%d errors=%d",
+						result.Checked, result.Stale, result.Missing, result.Healed, result.Errors)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает сверку
+func (r *Reconciler) Stop() {
+	close(r.stop)
+}
+
+// Reconcile выполняет один проход сверки
+func (r *Reconciler) Reconcile(ctx context.Context) ReconcileResult {
+	var result ReconcileResult
+
+	for _, uid := range r.cache.Keys() {
+		if ctx.Err() != nil {
+			break
+		}
+		if r.sampleRate < 1 && rand.Float64() >= r.sampleRate {
+			continue
+		}
+		cached, createdAt, cacheOnly, ok := r.cache.peekEntry(uid)
+		if !ok || cacheOnly || r.cache.clock.Now().Sub(createdAt) < r.grace {
+			continue
+		}
+
+		stored, err := r.repo.GetOrderByUID(ctx, uid)
+		if err != nil && !errors.Is(err, apperrors.ErrOrderNotFound) {
+			result.Errors++
+			r.logger.Debugf("Cache reconciliation: failed to load order %s: %v", uid, err)
+			continue
+		}
+		result.Checked++
+		r.inc(func(m *metrics.Metrics) { m.CacheReconcileChecked.Inc() })
+
+		switch {
+		case stored == nil:
+			result.Missing++
+			r.mismatch(MismatchMissing, uid)
+			if r.heal {
+				r.cache.Delete(uid)
+				result.Healed++
+				r.inc(func(m *metrics.Metrics) { m.CacheReconcileHealed.Inc() })
+			}
+		case !ordersEqual(cached, stored):
+			result.Stale++
+			r.mismatch(MismatchStale, uid)
+			if r.heal {
+				// Заказ мог обновиться в кеше, пока читали БД, более новый не затираем
+				if r.cache.SetIfNewer(stored) {
+					result.Healed++
+					r.inc(func(m *metrics.Metrics) { m.CacheReconcileHealed.Inc() })
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// mismatch учитывает расхождение вида kind по заказу uid
+func (r *Reconciler) mismatch(kind, uid string) {
+	r.logger.WithField("kind", kind).Warnf("Cache reconciliation: cached order %s does not match database", uid)
+	r.inc(func(m *metrics.Metrics) { m.CacheReconcileMismatches.WithLabelValues(kind).Inc() })
+}
+
+// inc обновляет метрики, если они включены
+func (r *Reconciler) inc(update func(m *metrics.Metrics)) {
+	if r.metrics != nil {
+		update(r.metrics)
+	}
+}
+
+// ordersEqual сравнивает заказы по содержимому
+// DateCreated сравнивается как момент времени с точностью БД до микросекунды,
+// пустой и nil список товаров считаются одинаковыми
+func ordersEqual(a, b *model.Order) bool {
+	// JSON пишет date_created в формате JSON_TIME_FORMAT, он может быть грубее БД
+	if !a.DateCreated.Truncate(time.Microsecond).Equal(b.DateCreated.Truncate(time.Microsecond)) {
+		return false
+	}
+	return orderJSON(normalizeOrder(*a)) == orderJSON(normalizeOrder(*b))
+}
+
+// normalizeOrder приводит копию заказа к виду, в котором ее можно сравнить побайтно
+// DateCreated сравнивается отдельно и обнуляется
+func normalizeOrder(order model.Order) model.Order {
+	order.DateCreated = time.Time{}
+	if order.Items == nil {
+		order.Items = []model.Item{}
+	}
+	return order
+}
+
+// orderJSON возвращает JSON заказа, в заказе нет типов, на которых Marshal может упасть
+func orderJSON(order model.Order) string {
+	data, _ := json.Marshal(order)
+	return string(data)
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wbtest/internal/clock"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/metrics"
+	"wbtest/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// mapRepo отдает заказы из map, failUID - заказ, чтение которого завершается ошибкой
+type mapRepo struct {
+	orders  map[string]*model.Order
+	failUID string
+}
+
+func (r *mapRepo) GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error) {
+	if orderUID == r.failUID {
+		return nil, errors.New("connection reset")
+	}
+	order, ok := r.orders[orderUID]
+	if !ok {
+		return nil, apperrors.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func TestReconciler_DetectsAndHeals(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	order := func(uid, track string, at time.Time) *model.Order {
+		return &model.Order{OrderUID: uid, TrackNumber: track, DateCreated: at}
+	}
+
+	repo := &mapRepo{
+		orders: map[string]*model.Order{
+			"in-sync": order("in-sync", "TRACK1", created),
+			// Тот же момент в другой зоне - не расхождение
+			"other-zone": order("other-zone", "TRACK2", created.In(time.FixedZone("MSK", 3*3600))),
+			"diverged":   order("diverged", "TRACK-UPDATED", created),
+			"broken":     order("broken", "TRACK4", created),
+		},
+		failUID: "broken",
+	}
+
+	for _, healing := range []bool{false, true} {
+		m := metrics.NewWithRegistry(prometheus.NewRegistry())
+		c := NewOrderCache(10, time.Hour).(*OrderCache)
+		c.Set(order("in-sync", "TRACK1", created))
+		c.Set(order("other-zone", "TRACK2", created))
+		c.Set(order("diverged", "TRACK3", created))
+		c.Set(order("deleted", "TRACK5", created))
+		c.Set(order("broken", "TRACK4", created))
+
+		result := NewReconciler(c, repo, 1).WithHealing(healing).WithMetrics(m).Reconcile(context.Background())
+
+		if result.Checked != 4 || result.Stale != 1 || result.Missing != 1 || result.Errors != 1 {
+			t.Errorf("healing=%v: unexpected result %+v", healing, result)
+		}
+		if got := testutil.ToFloat64(m.CacheReconcileMismatches.WithLabelValues(MismatchStale)); got != 1 {
+			t.Errorf("healing=%v: expected 1 stale mismatch in metrics, got %v", healing, got)
+		}
+		if got := testutil.ToFloat64(m.CacheReconcileMismatches.WithLabelValues(MismatchMissing)); got != 1 {
+			t.Errorf("healing=%v: expected 1 missing mismatch in metrics, got %v", healing, got)
+		}
+		// Сверка не влияет на hit rate
+		if stats := c.GetStats(); stats.Hits != 0 || stats.Misses != 0 {
+			t.Errorf("healing=%v: expected reconciliation not to touch cache stats, got %+v", healing, stats)
+		}
+
+		cached, _ := c.Peek("diverged")
+		_, deletedCached := c.Peek("deleted")
+		if !healing {
+			if result.Healed != 0 || cached.TrackNumber != "TRACK3" || !deletedCached {
+				t.Errorf("Expected cache untouched without healing, result %+v", result)
+			}
+			c.Stop()
+			continue
+		}
+
+		if result.Healed != 2 || testutil.ToFloat64(m.CacheReconcileHealed) != 2 {
+			t.Errorf("Expected 2 healed entries, got %+v", result)
+		}
+		if cached.TrackNumber != "TRACK-UPDATED" {
+			t.Errorf("Expected diverged entry refreshed from DB, got %s", cached.TrackNumber)
+		}
+		if deletedCached {
+			t.Error("Expected order deleted from DB to be removed from cache")
+		}
+
+		// После исправления расхождений нет
+		again := NewReconciler(c, repo, 1).Reconcile(context.Background())
+		if again.Stale != 0 || again.Missing != 0 {
+			t.Errorf("Expected no mismatches after healing, got %+v", again)
+		}
+		c.Stop()
+	}
+}
+
+func TestReconciler_SkipsFreshAndCacheOnlyEntries(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+	c := NewOrderCache(10, time.Hour, WithClock(fakeClock)).(*OrderCache)
+	defer c.Stop()
+
+	// Ни одного заказа нет в БД
+	repo := &mapRepo{orders: map[string]*model.Order{}}
+	reconciler := NewReconciler(c, repo, 1).WithHealing(true).WithGracePeriod(time.Minute)
+
+	c.SetCacheOnly(&model.Order{OrderUID: "http-created"})
+	fakeClock.Advance(2 * time.Minute)
+	c.Set(&model.Order{OrderUID: "fresh"})
+
+	result := reconciler.Reconcile(context.Background())
+	if result.Checked != 0 || result.Missing != 0 {
+		t.Errorf("Expected fresh and cache-only entries to be skipped, got %+v", result)
+	}
+	for _, uid := range []string{"http-created", "fresh"} {
+		if _, ok := c.Peek(uid); !ok {
+			t.Errorf("Expected %s to stay in cache", uid)
+		}
+	}
+
+	// Запись старше grace сверяется, заказ только из кеша остается
+	fakeClock.Advance(2 * time.Minute)
+	result = reconciler.Reconcile(context.Background())
+	if result.Checked != 1 || result.Missing != 1 || result.Healed != 1 {
+		t.Errorf("Expected only the aged entry to be reconciled, got %+v", result)
+	}
+	if _, ok := c.Peek("http-created"); !ok {
+		t.Error("Expected cache-only order to survive healing")
+	}
+}
+
+func TestReconciler_HealKeepsNewerCachedOrder(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	repo := &mapRepo{orders: map[string]*model.Order{
+		"updated": {OrderUID: "updated", TrackNumber: "TRACK-DB", DateCreated: created},
+	}}
+	c := NewOrderCache(10, time.Hour).(*OrderCache)
+	defer c.Stop()
+
+	// Кеш уже получил более новую версию, чем прочитанная из БД
+	c.Set(&model.Order{OrderUID: "updated", TrackNumber: "TRACK-NEW", DateCreated: created.Add(time.Minute)})
+
+	result := NewReconciler(c, repo, 1).WithHealing(true).Reconcile(context.Background())
+	if result.Stale != 1 || result.Healed != 0 {
+		t.Errorf("Expected stale entry not to be healed, got %+v", result)
+	}
+	if cached, _ := c.Peek("updated"); cached.TrackNumber != "TRACK-NEW" {
+		t.Errorf("Expected newer cached order to be kept, got %s", cached.TrackNumber)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"wbtest/internal/clock"
 )
 
 // State состояние circuit breaker
@@ -62,6 +64,7 @@ type CircuitBreaker struct {
 	nextAttempt   time.Time
 	mutex         sync.RWMutex
 	onStateChange func(from, to State)
+	clock         clock.Clock
 }
 
 // New создает новый circuit breaker
@@ -82,9 +85,16 @@ func New(config Config) *CircuitBreaker {
 	return &CircuitBreaker{
 		config: config,
 		state:  StateClosed,
+		clock:  clock.New(),
 	}
 }
 
+// WithClock задает источник времени для таймаута открытого состояния
+func (cb *CircuitBreaker) WithClock(c clock.Clock) *CircuitBreaker {
+	cb.clock = c
+	return cb
+}
+
 // WithStateChangeCallback устанавливает callback для изменения состояния
 func (cb *CircuitBreaker) WithStateChangeCallback(callback func(from, to State)) *CircuitBreaker {
 	cb.onStateChange = callback
@@ -128,6 +138,36 @@ func (cb *CircuitBreaker) ExecuteAsync(ctx context.Context, fn func() (interface
 	return resultChan
 }
 
+// ExecuteTyped выполняет функцию через circuit breaker без приведения типов
+// Методы в Go не могут иметь параметров типа, поэтому это функция пакета
+// Если breaker не пропустил вызов, возвращается нулевое значение T и CircuitBreakerError
+func ExecuteTyped[T any](ctx context.Context, cb *CircuitBreaker, fn func() (T, error)) (T, error) {
+	var result T
+	_, err := cb.Execute(ctx, func() (interface{}, error) {
+		var fnErr error
+		result, fnErr = fn()
+		return nil, fnErr
+	})
+	return result, err
+}
+
+// ExecuteAsyncTyped выполняет функцию асинхронно, результат приходит в канал
+func ExecuteAsyncTyped[T any](ctx context.Context, cb *CircuitBreaker, fn func() (T, error)) <-chan TypedResult[T] {
+	resultChan := make(chan TypedResult[T], 1)
+
+	go func() {
+		defer close(resultChan)
+
+		result, err := ExecuteTyped(ctx, cb, fn)
+		resultChan <- TypedResult[T]{
+			Data: result,
+			Err:  err,
+		}
+	}()
+
+	return resultChan
+}
+
 // GetState возвращает текущее состояние
 func (cb *CircuitBreaker) GetState() State {
 	cb.mutex.RLock()
@@ -179,7 +219,7 @@ func (cb *CircuitBreaker) checkAndUpdateState() (bool, bool) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.clock.Now()
 	stateChanged := false
 
 	switch cb.state {
@@ -225,13 +265,13 @@ func (cb *CircuitBreaker) recordResult(success bool, stateChanged bool) {
 			cb.failureCount = 0 // Сбрасываем счетчик ошибок при успехе
 		} else {
 			cb.failureCount++
-			cb.lastFailTime = time.Now()
+			cb.lastFailTime = cb.clock.Now()
 
 			// Проверяем нужно ли открыть circuit breaker
 			if cb.failureCount >= cb.config.FailureThreshold {
 				oldState := cb.state
 				cb.state = StateOpen
-				cb.nextAttempt = time.Now().Add(cb.config.Timeout)
+				cb.nextAttempt = cb.clock.Now().Add(cb.config.Timeout)
 
 				if cb.onStateChange != nil {
 					cb.onStateChange(oldState, cb.state)
@@ -260,9 +300,9 @@ func (cb *CircuitBreaker) recordResult(success bool, stateChanged bool) {
 			// При ошибке в полуоткрытом состоянии снова открываем
 			oldState := cb.state
 			cb.state = StateOpen
-			cb.nextAttempt = time.Now().Add(cb.config.Timeout)
+			cb.nextAttempt = cb.clock.Now().Add(cb.config.Timeout)
 			cb.failureCount++
-			cb.lastFailTime = time.Now()
+			cb.lastFailTime = cb.clock.Now()
 
 			if cb.onStateChange != nil {
 				cb.onStateChange(oldState, cb.state)
@@ -292,6 +332,12 @@ type Result struct {
 	Err  error
 }
 
+// TypedResult результат асинхронного выполнения с конкретным типом данных
+type TypedResult[T any] struct {
+	Data T
+	Err  error
+}
+
 // CircuitBreakerError ошибка circuit breaker
 type CircuitBreakerError struct {
 	State State
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"wbtest/internal/clock"
 )
 
 func TestCircuitBreaker_Execute_Success(t *testing.T) {
@@ -93,15 +95,16 @@ func TestCircuitBreaker_Execute_HalfOpenState(t *testing.T) {
 		Timeout:          50 * time.Millisecond,
 		MaxRequests:      3,
 	}
-	cb := New(config)
+	fakeClock := clock.NewFake(time.Now())
+	cb := New(config).WithClock(fakeClock)
 
 	// Открываем circuit breaker
 	cb.Execute(context.Background(), func() (interface{}, error) {
 		return nil, errors.New("test error")
 	})
 
-	// Ждем timeout
-	time.Sleep(100 * time.Millisecond)
+	// Переводим часы за timeout
+	fakeClock.Advance(100 * time.Millisecond)
 
 	// Проверяем что состояние изменилось на полуоткрытое при попытке выполнения
 	// (состояние меняется только при вызове CanExecute)
@@ -142,15 +145,16 @@ func TestCircuitBreaker_Execute_HalfOpenFailure(t *testing.T) {
 		Timeout:          50 * time.Millisecond,
 		MaxRequests:      2,
 	}
-	cb := New(config)
+	fakeClock := clock.NewFake(time.Now())
+	cb := New(config).WithClock(fakeClock)
 
 	// Открываем circuit breaker
 	cb.Execute(context.Background(), func() (interface{}, error) {
 		return nil, errors.New("test error")
 	})
 
-	// Ждем timeout
-	time.Sleep(100 * time.Millisecond)
+	// Переводим часы за timeout
+	fakeClock.Advance(100 * time.Millisecond)
 
 	// Выполняем неудачный запрос в полуоткрытом состоянии
 	_, err := cb.Execute(context.Background(), func() (interface{}, error) {
@@ -351,3 +355,64 @@ type StateChange struct {
 	From State
 	To   State
 }
+
+type typedPayload struct {
+	ID    string
+	Count int
+}
+
+func TestExecuteTyped(t *testing.T) {
+	cb := New(Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		MaxRequests:      1,
+	})
+
+	payload, err := ExecuteTyped(context.Background(), cb, func() (typedPayload, error) {
+		return typedPayload{ID: "order-1", Count: 3}, nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if payload.ID != "order-1" || payload.Count != 3 {
+		t.Errorf("Expected payload {order-1 3}, got %+v", payload)
+	}
+
+	// Ошибка открывает breaker, следующий вызов получает нулевое значение
+	if _, err := ExecuteTyped(context.Background(), cb, func() (typedPayload, error) {
+		return typedPayload{}, errors.New("test error")
+	}); err == nil {
+		t.Fatal("Expected error from failing function")
+	}
+
+	called := false
+	payload, err = ExecuteTyped(context.Background(), cb, func() (typedPayload, error) {
+		called = true
+		return typedPayload{ID: "order-2"}, nil
+	})
+	if !IsCircuitBreakerOpen(err) {
+		t.Errorf("Expected circuit breaker open error, got %v", err)
+	}
+	if called {
+		t.Error("Expected function not to be called while breaker is open")
+	}
+	if payload != (typedPayload{}) {
+		t.Errorf("Expected zero payload, got %+v", payload)
+	}
+}
+
+func TestExecuteAsyncTyped(t *testing.T) {
+	cb := New(DefaultConfig())
+
+	result := <-ExecuteAsyncTyped(context.Background(), cb, func() (*typedPayload, error) {
+		return &typedPayload{ID: "order-1", Count: 1}, nil
+	})
+
+	if result.Err != nil {
+		t.Fatalf("Expected no error, got %v", result.Err)
+	}
+	if result.Data == nil || result.Data.ID != "order-1" {
+		t.Errorf("Expected payload order-1, got %+v", result.Data)
+	}
+}
@@ -0,0 +1,95 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock источник времени
+// Компоненты с таймаутами получают его снаружи, чтобы тесты могли управлять временем
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real системные часы
+type Real struct{}
+
+// New возвращает системные часы
+func New() Clock {
+	return Real{}
+}
+
+// Now возвращает текущее время
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// After возвращает канал, в который придет время через d
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// waiter ожидание FakeClock.After
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// FakeClock часы для тестов, время идет только при вызове Advance
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+// NewFake создает часы, остановленные на now
+func NewFake(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now возвращает текущее время часов
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After возвращает канал, который сработает, когда Advance переведет часы на d вперед
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, waiter{deadline: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance переводит часы вперед и срабатывает наступившие After
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.deadline.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+}
+
+// Waiters возвращает число незавершенных After
+// Тест может дождаться, пока компонент начнет ждать, и только потом двигать часы
+func (f *FakeClock) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
@@ -0,0 +1,50 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock_Advance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFake(start)
+
+	ch := clock.After(time.Minute)
+	if clock.Waiters() != 1 {
+		t.Fatalf("Expected 1 waiter, got %d", clock.Waiters())
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After fired before deadline")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case fired := <-ch:
+		if !fired.Equal(start.Add(time.Minute)) {
+			t.Errorf("Expected fire time %v, got %v", start.Add(time.Minute), fired)
+		}
+	default:
+		t.Fatal("Expected After to fire at deadline")
+	}
+
+	if clock.Waiters() != 0 {
+		t.Errorf("Expected no waiters, got %d", clock.Waiters())
+	}
+	if !clock.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected now %v, got %v", start.Add(time.Minute), clock.Now())
+	}
+}
+
+func TestFakeClock_AfterNonPositive(t *testing.T) {
+	clock := NewFake(time.Now())
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("Expected After(0) to fire immediately")
+	}
+}
@@ -1,13 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"wbtest/internal/logger"
+	"wbtest/internal/model"
 
 	"github.com/joho/godotenv"
 )
@@ -24,6 +27,7 @@ type Config struct {
 	DLQ        DLQConfig
 	Logger     logger.Config
 	Metrics    MetricsConfig
+	RateLimit  RateLimitConfig
 }
 
 type DatabaseConfig struct {
@@ -33,9 +37,25 @@ type DatabaseConfig struct {
 	Password        string
 	Database        string
 	SSLMode         string
+	SSLRootCert     string // CA сертификат, обязателен для verify-ca и verify-full
+	SSLCert         string // клиентский сертификат
+	SSLKey          string // ключ клиентского сертификата
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+	SoftDelete      bool // помечать заказы удаленными вместо удаления строк
+	// Предел одновременных записей заказов из Kafka, 0 - без ограничения
+	// Оставляет часть пула соединений для чтения из HTTP API
+	MaxConcurrentWrites int
+	// Запросы дольше порога пишутся в лог, 0 - не логировать
+	SlowQueryThreshold time.Duration
+	// statement_timeout подключений: Postgres сам прерывает более долгие запросы, 0 - настройка сервера
+	StatementTimeout time.Duration
+	// Проверка подключения на старте: число попыток (0 - без проверки, подключение при первом запросе)
+	// и задержки экспоненциального backoff между ними
+	ConnectMaxAttempts  int
+	ConnectInitialDelay time.Duration
+	ConnectMaxDelay     time.Duration
 }
 
 type KafkaConfig struct {
@@ -45,8 +65,28 @@ type KafkaConfig struct {
 	AutoOffsetReset  string
 	EnableAutoCommit bool
 	SessionTimeoutMs int
-	BatchSize        int
-	BatchTimeout     time.Duration
+	// Частота heartbeat участника группы, должна быть меньше SessionTimeoutMs
+	HeartbeatIntervalMs int
+	// Стратегия распределения партиций в группе: range или round-robin
+	GroupBalancer string
+	BatchSize     int
+	BatchTimeout  time.Duration
+	// Пауза чтения при медленной записи в БД, 0 - выключено
+	BackpressureThreshold time.Duration
+	BackpressureCooldown  time.Duration
+	// Топик compacted: сообщение заменяет заказ целиком, если его offset не меньше сохраненного
+	Compacted bool
+	// Экспоненциальная задержка между попытками чтения при недоступности брокеров
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
+	// Сжатие сообщений producer и DLQ: none, gzip, snappy, lz4, zstd
+	Compression string
+	// Окно, в котором повторная доставка того же сообщения пропускается, 0 - выключено
+	DedupWindow time.Duration
+	// Топик для событий о сохраненных заказах, пусто - не публиковать
+	OutboundTopic string
+	// Число партиций, обрабатываемых одновременно, 0 - все партиции параллельно
+	ConsumerConcurrency int
 }
 
 type HTTPConfig struct {
@@ -54,12 +94,61 @@ type HTTPConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	AdminEnabled bool
+	MaxBodyBytes int64
+	// max-age для ответов с заказом, 0 - без кеширования
+	OrderCacheMaxAge time.Duration
+	// Таймаут обработки одного запроса, 0 - без ограничения
+	RequestTimeout time.Duration
+	// Таймаут чтения заказа из БД при промахе кеша в GET /order, 0 - без ограничения
+	DBReadTimeout time.Duration
+	// Минимальный интервал между вызовами /admin/cache/reload
+	CacheReloadInterval time.Duration
+	// Время кеширования /orders/stats, 0 - без кеширования
+	StatsCacheTTL time.Duration
+	// JSON ответы с отступами по умолчанию, для отладки
+	PrettyJSON bool
+	// Максимум строк в ответах списков, больше - ответ обрезается, 0 - значение по умолчанию
+	MaxListRows int
+	// Ключи API для записи и /admin/*, пусто - без аутентификации
+	APIKeys []string
+	// Требовать ключ и для чтения, /health остается открытым
+	AuthProtectReads bool
+	// Режим обслуживания при старте: изменяющие запросы получают 503, чтение работает
+	MaintenanceMode bool
+	// Требовать версию схемы в POST /order, без нее заказ разбирается как v1
+	RequireSchemaVersion bool
+	// Период фоновых проверок зависимостей для /health, 0 - проверка на каждый запрос
+	HealthCheckInterval time.Duration
+	// Допустимый возраст результата фоновых проверок, 0 - три интервала
+	HealthMaxStaleness time.Duration
 }
 
 type CacheConfig struct {
-	MaxSize         int
-	TTLMinutes      int
-	CleanupInterval time.Duration
+	MaxSize          int
+	TTLMinutes       int
+	CleanupInterval  time.Duration
+	EvictionStrategy string
+	// Частота вытеснений в секунду, выше которой кеш считается малым, 0 - не проверять
+	EvictionWarnRate float64
+	// Число параллельных запросов при загрузке кеша на старте, 0 и 1 - один запрос
+	LoadWorkers int
+	// Заказы старше RecentOrderAge хранятся OldOrderTTL вместо TTL кеша, 0 - одинаковый TTL
+	RecentOrderAge time.Duration
+	OldOrderTTL    time.Duration
+	// Повторы загрузки кеша на старте, пока БД недоступна: число попыток (0 и 1 - без повторов)
+	// и задержки экспоненциального backoff между ними
+	WarmMaxAttempts  int
+	WarmInitialDelay time.Duration
+	WarmMaxDelay     time.Duration
+	// Снимки статистики для /stats/history: период (0 - история выключена) и число хранимых снимков
+	StatsHistoryInterval time.Duration
+	StatsHistorySize     int
+	// Сверка кеша с БД: период (0 - выключена), доля заказов за проход
+	// и исправление расхождений по данным БД
+	ReconcileInterval   time.Duration
+	ReconcileSampleRate float64
+	ReconcileHeal       bool
 }
 
 type AppConfig struct {
@@ -68,6 +157,19 @@ type AppConfig struct {
 	Environment             string
 	DatabaseLoadTimeout     time.Duration
 	ShutdownWaitTimeout     time.Duration
+	// Время на проверку БД и Kafka при старте, 0 - не проверять
+	PreflightTimeout time.Duration
+	// Таймауты остановки компонентов, у каждого свой дедлайн
+	// 0 - используется GracefulShutdownTimeout
+	HTTPShutdownTimeout  time.Duration
+	KafkaShutdownTimeout time.Duration
+	DLQShutdownTimeout   time.Duration
+	DBShutdownTimeout    time.Duration
+	// Пауза между переводом HTTP в режим drain и закрытием listener, 0 - без паузы
+	// За это время балансировщик успевает увидеть 503 и убрать инстанс
+	HTTPDrainDelay time.Duration
+	// Формат date_created в JSON заказа: rfc3339 или rfc3339nano
+	JSONTimeFormat string
 }
 
 type GeneratorConfig struct {
@@ -76,6 +178,10 @@ type GeneratorConfig struct {
 	MinPrice         int
 	MaxPrice         int
 	MaxSale          int
+	// Скорость отправки в Kafka, заказов в секунду, 0 - без ограничения
+	RatePerSecond int
+	// Число одновременных отправок в Kafka
+	Concurrency int
 }
 
 type ValidationConfig struct {
@@ -86,6 +192,25 @@ type ValidationConfig struct {
 	MaxPaymentAmount     int
 	MaxItemsPerOrder     int
 	MaxItemPrice         int
+	// Трек-номер каждого товара должен совпадать с трек-номером заказа
+	ItemTrackNumberMatch bool
+	// Допустимые значения entry, пустой список - любое значение
+	AllowedEntries []string
+	// Насколько date_created может опережать текущее время
+	MaxFutureSkew time.Duration
+	// Очищать строки заказа из Kafka перед сохранением
+	Sanitize bool
+	// Предельная длина строк без собственного ограничения
+	SanitizeMaxLength int
+	// Допустимые статусы товаров, например "200-299,404", пустая строка - любые
+	ItemStatuses string
+	// Валюта для заказов из Kafka без payment.currency, пусто - такие заказы отклоняются
+	DefaultCurrency string
+	// Формат UID заказа: пусто - только длина, uuid или regex с OrderUIDPattern
+	OrderUIDFormat  string
+	OrderUIDPattern string
+	// Отклонять заказы с полями, которых нет в модели, вместо того чтобы молча их отбрасывать
+	StrictJSON bool
 }
 
 type RetryConfig struct {
@@ -93,12 +218,23 @@ type RetryConfig struct {
 	InitialDelay time.Duration
 	MaxDelay     time.Duration
 	Multiplier   float64
+	// Общее время на все попытки с учетом задержек, 0 - без ограничения
+	MaxElapsed time.Duration
+	// Бюджет повторов: после BreakerFailureThreshold неудачных операций подряд
+	// повторы отключаются на BreakerCooldown, 0 - выключено
+	BreakerFailureThreshold int
+	BreakerCooldown         time.Duration
 }
 
 type DLQConfig struct {
-	Enabled    bool
-	Topic      string
-	MaxRetries int
+	Enabled        bool
+	Topic          string
+	MaxRetries     int
+	ParkingTopic   string
+	RetryBackoff   time.Duration
+	RequeueEnabled bool
+	// CorruptTopic топик для сообщений DLQ, которые не удалось разобрать, пусто - такие сообщения отбрасываются
+	CorruptTopic string
 }
 
 func Load() (*Config, error) {
@@ -107,84 +243,184 @@ func Load() (*Config, error) {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
+	// В строгом режиме нераспознанные значения переменных - ошибка, а не значение по умолчанию
+	env := &envReader{strict: getEnvAsBool("CONFIG_STRICT", false)}
+
 	cfg := &Config{
 		Database: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "127.0.0.1"),
-			Port:            getEnvAsInt("DB_PORT", 5432),
+			Port:            env.asInt("DB_PORT", 5432),
 			User:            getEnv("DB_USER", "orders_user"),
 			Password:        getEnv("DB_PASSWORD", "orders_pass"),
 			Database:        getEnv("DB_NAME", "orders_db"),
 			SSLMode:         getEnv("DB_SSLMODE", "disable"),
-			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
-			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
-			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			SSLRootCert:     getEnv("DB_SSLROOTCERT", ""),
+			SSLCert:         getEnv("DB_SSLCERT", ""),
+			SSLKey:          getEnv("DB_SSLKEY", ""),
+			MaxOpenConns:    env.asInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    env.asInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: env.asDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			SoftDelete:      env.asBool("DB_SOFT_DELETE", false),
+
+			MaxConcurrentWrites: env.asInt("DB_MAX_CONCURRENT_WRITES", 10),
+			SlowQueryThreshold:  env.asDuration("DB_SLOW_QUERY_THRESHOLD", 500*time.Millisecond),
+			StatementTimeout:    env.asDuration("DB_STATEMENT_TIMEOUT", 0),
+			ConnectMaxAttempts:  env.asInt("DB_CONNECT_MAX_ATTEMPTS", 0),
+			ConnectInitialDelay: env.asDuration("DB_CONNECT_INITIAL_DELAY", time.Second),
+			ConnectMaxDelay:     env.asDuration("DB_CONNECT_MAX_DELAY", 10*time.Second),
 		},
 		Kafka: KafkaConfig{
-			Brokers:          strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
-			Topic:            getEnv("KAFKA_TOPIC", "orders"),
-			GroupID:          getEnv("KAFKA_GROUP_ID", "order-service"),
-			AutoOffsetReset:  getEnv("KAFKA_AUTO_OFFSET_RESET", "earliest"),
-			EnableAutoCommit: getEnvAsBool("KAFKA_ENABLE_AUTO_COMMIT", true),
-			SessionTimeoutMs: getEnvAsInt("KAFKA_SESSION_TIMEOUT_MS", 30000),
-			BatchSize:        getEnvAsInt("KAFKA_BATCH_SIZE", 100),
-			BatchTimeout:     getEnvAsDuration("KAFKA_BATCH_TIMEOUT", 100*time.Millisecond),
+			Brokers:               strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
+			Topic:                 getEnv("KAFKA_TOPIC", "orders"),
+			GroupID:               getEnv("KAFKA_GROUP_ID", "order-service"),
+			AutoOffsetReset:       getEnv("KAFKA_AUTO_OFFSET_RESET", "earliest"),
+			EnableAutoCommit:      env.asBool("KAFKA_ENABLE_AUTO_COMMIT", true),
+			SessionTimeoutMs:      env.asInt("KAFKA_SESSION_TIMEOUT_MS", 30000),
+			HeartbeatIntervalMs:   env.asInt("KAFKA_HEARTBEAT_INTERVAL_MS", 3000),
+			GroupBalancer:         getEnv("KAFKA_GROUP_BALANCER", "range"),
+			BatchSize:             env.asInt("KAFKA_BATCH_SIZE", 100),
+			BatchTimeout:          env.asDuration("KAFKA_BATCH_TIMEOUT", 100*time.Millisecond),
+			BackpressureThreshold: env.asDuration("KAFKA_BACKPRESSURE_THRESHOLD", 0),
+			BackpressureCooldown:  env.asDuration("KAFKA_BACKPRESSURE_COOLDOWN", 5*time.Second),
+			Compacted:             env.asBool("KAFKA_COMPACTED", false),
+			ReconnectMinBackoff:   env.asDuration("KAFKA_RECONNECT_MIN_BACKOFF", 100*time.Millisecond),
+			ReconnectMaxBackoff:   env.asDuration("KAFKA_RECONNECT_MAX_BACKOFF", 10*time.Second),
+			DedupWindow:           env.asDuration("KAFKA_DEDUP_WINDOW", 5*time.Minute),
+			Compression:           getEnv("KAFKA_COMPRESSION", "none"),
+			OutboundTopic:         getEnv("OUTBOUND_TOPIC", ""),
+			ConsumerConcurrency:   env.asInt("KAFKA_CONSUMER_CONCURRENCY", 0),
 		},
 		HTTP: HTTPConfig{
-			Port:         getEnvAsInt("HTTP_PORT", 8082),
-			ReadTimeout:  getEnvAsDuration("HTTP_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getEnvAsDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
-			IdleTimeout:  getEnvAsDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+			Port:                 env.asInt("HTTP_PORT", 8082),
+			ReadTimeout:          env.asDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:         env.asDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:          env.asDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+			AdminEnabled:         env.asBool("HTTP_ADMIN_ENABLED", false),
+			MaxBodyBytes:         int64(env.asInt("HTTP_MAX_BODY_BYTES", 1<<20)),
+			OrderCacheMaxAge:     env.asDuration("HTTP_ORDER_CACHE_MAX_AGE", 5*time.Minute),
+			RequestTimeout:       env.asDuration("HTTP_REQUEST_TIMEOUT", 10*time.Second),
+			DBReadTimeout:        env.asDuration("HTTP_DB_READ_TIMEOUT", 3*time.Second),
+			CacheReloadInterval:  env.asDuration("HTTP_CACHE_RELOAD_INTERVAL", time.Minute),
+			StatsCacheTTL:        env.asDuration("HTTP_STATS_CACHE_TTL", 30*time.Second),
+			PrettyJSON:           env.asBool("HTTP_PRETTY_JSON", false),
+			MaxListRows:          env.asInt("HTTP_MAX_LIST_ROWS", 1000),
+			APIKeys:              getEnvAsList("HTTP_API_KEYS"),
+			AuthProtectReads:     env.asBool("HTTP_AUTH_PROTECT_READS", false),
+			MaintenanceMode:      env.asBool("HTTP_MAINTENANCE_MODE", false),
+			RequireSchemaVersion: env.asBool("HTTP_REQUIRE_SCHEMA_VERSION", false),
+			HealthCheckInterval:  env.asDuration("HTTP_HEALTH_CHECK_INTERVAL", 0),
+			HealthMaxStaleness:   env.asDuration("HTTP_HEALTH_MAX_STALENESS", 0),
 		},
 		Cache: CacheConfig{
-			MaxSize:         getEnvAsInt("CACHE_MAX_SIZE", 1000),
-			TTLMinutes:      getEnvAsInt("CACHE_TTL_MINUTES", 60),
-			CleanupInterval: getEnvAsDuration("CACHE_CLEANUP_INTERVAL", 5*time.Minute),
+			MaxSize:         env.asInt("CACHE_MAX_SIZE", 1000),
+			TTLMinutes:      env.asInt("CACHE_TTL_MINUTES", 60),
+			CleanupInterval: env.asDuration("CACHE_CLEANUP_INTERVAL", 5*time.Minute),
+			// lru | lfu | oldest
+			EvictionStrategy: getEnv("CACHE_EVICTION_STRATEGY", "oldest"),
+			EvictionWarnRate: env.asFloat("CACHE_EVICTION_WARN_RATE", 1.0),
+			LoadWorkers:      env.asInt("CACHE_LOAD_WORKERS", 4),
+			RecentOrderAge:   env.asDuration("CACHE_RECENT_ORDER_AGE", 0),
+			OldOrderTTL:      env.asDuration("CACHE_OLD_ORDER_TTL", 10*time.Minute),
+			WarmMaxAttempts:  env.asInt("CACHE_WARM_MAX_ATTEMPTS", 3),
+			WarmInitialDelay: env.asDuration("CACHE_WARM_INITIAL_DELAY", time.Second),
+			WarmMaxDelay:     env.asDuration("CACHE_WARM_MAX_DELAY", 5*time.Second),
+
+			StatsHistoryInterval: env.asDuration("CACHE_STATS_HISTORY_INTERVAL", 0),
+			StatsHistorySize:     env.asInt("CACHE_STATS_HISTORY_SIZE", 60),
+			ReconcileInterval:    env.asDuration("CACHE_RECONCILE_INTERVAL", 0),
+			ReconcileSampleRate:  env.asFloat("CACHE_RECONCILE_SAMPLE_RATE", 0.1),
+			ReconcileHeal:        env.asBool("CACHE_RECONCILE_HEAL", false),
 		},
 		App: AppConfig{
-			GracefulShutdownTimeout: getEnvAsDuration("GRACEFUL_SHUTDOWN_TIMEOUT", 30*time.Second),
+			GracefulShutdownTimeout: env.asDuration("GRACEFUL_SHUTDOWN_TIMEOUT", 30*time.Second),
 			LogLevel:                getEnv("LOG_LEVEL", "info"),
 			Environment:             getEnv("ENVIRONMENT", "development"),
-			DatabaseLoadTimeout:     getEnvAsDuration("DB_LOAD_TIMEOUT", 10*time.Second),
-			ShutdownWaitTimeout:     getEnvAsDuration("SHUTDOWN_WAIT_TIMEOUT", 5*time.Second),
+			DatabaseLoadTimeout:     env.asDuration("DB_LOAD_TIMEOUT", 10*time.Second),
+			ShutdownWaitTimeout:     env.asDuration("SHUTDOWN_WAIT_TIMEOUT", 5*time.Second),
+			PreflightTimeout:        env.asDuration("PREFLIGHT_TIMEOUT", 0),
+			HTTPShutdownTimeout:     env.asDuration("HTTP_SHUTDOWN_TIMEOUT", 15*time.Second),
+			DLQShutdownTimeout:      env.asDuration("DLQ_SHUTDOWN_TIMEOUT", 5*time.Second),
+			DBShutdownTimeout:       env.asDuration("DB_SHUTDOWN_TIMEOUT", 5*time.Second),
+			HTTPDrainDelay:          env.asDuration("HTTP_DRAIN_DELAY", 0),
+			JSONTimeFormat:          getEnv("JSON_TIME_FORMAT", model.DefaultTimeFormat),
 		},
 		Generator: GeneratorConfig{
-			MaxOrdersCount:   getEnvAsInt("GENERATOR_MAX_ORDERS", 10000),
-			MaxItemsPerOrder: getEnvAsInt("GENERATOR_MAX_ITEMS_PER_ORDER", 5),
-			MinPrice:         getEnvAsInt("GENERATOR_MIN_PRICE", 50),
-			MaxPrice:         getEnvAsInt("GENERATOR_MAX_PRICE", 5000),
-			MaxSale:          getEnvAsInt("GENERATOR_MAX_SALE", 50),
+			MaxOrdersCount:   env.asInt("GENERATOR_MAX_ORDERS", 10000),
+			MaxItemsPerOrder: env.asInt("GENERATOR_MAX_ITEMS_PER_ORDER", 5),
+			MinPrice:         env.asInt("GENERATOR_MIN_PRICE", 50),
+			MaxPrice:         env.asInt("GENERATOR_MAX_PRICE", 5000),
+			MaxSale:          env.asInt("GENERATOR_MAX_SALE", 50),
+			RatePerSecond:    env.asInt("GENERATOR_RATE", 100),
+			Concurrency:      env.asInt("GENERATOR_CONCURRENCY", 4),
 		},
 		Validation: ValidationConfig{
-			OrderUIDMinLength:    getEnvAsInt("VALIDATION_ORDER_UID_MIN_LENGTH", 10),
-			OrderUIDMaxLength:    getEnvAsInt("VALIDATION_ORDER_UID_MAX_LENGTH", 50),
-			TrackNumberMinLength: getEnvAsInt("VALIDATION_TRACK_NUMBER_MIN_LENGTH", 5),
-			TrackNumberMaxLength: getEnvAsInt("VALIDATION_TRACK_NUMBER_MAX_LENGTH", 20),
-			MaxPaymentAmount:     getEnvAsInt("VALIDATION_MAX_PAYMENT_AMOUNT", 1000000),
-			MaxItemsPerOrder:     getEnvAsInt("VALIDATION_MAX_ITEMS_PER_ORDER", 100),
-			MaxItemPrice:         getEnvAsInt("VALIDATION_MAX_ITEM_PRICE", 100000),
+			OrderUIDMinLength:    env.asInt("VALIDATION_ORDER_UID_MIN_LENGTH", 10),
+			OrderUIDMaxLength:    env.asInt("VALIDATION_ORDER_UID_MAX_LENGTH", 50),
+			TrackNumberMinLength: env.asInt("VALIDATION_TRACK_NUMBER_MIN_LENGTH", 5),
+			TrackNumberMaxLength: env.asInt("VALIDATION_TRACK_NUMBER_MAX_LENGTH", 20),
+			MaxPaymentAmount:     env.asInt("VALIDATION_MAX_PAYMENT_AMOUNT", 1000000),
+			MaxItemsPerOrder:     env.asInt("VALIDATION_MAX_ITEMS_PER_ORDER", 100),
+			MaxItemPrice:         env.asInt("VALIDATION_MAX_ITEM_PRICE", 100000),
+			ItemTrackNumberMatch: env.asBool("VALIDATION_ITEM_TRACK_NUMBER_MATCH", false),
+			AllowedEntries:       getEnvAsList("VALIDATION_ALLOWED_ENTRIES"),
+			MaxFutureSkew:        env.asDuration("VALIDATION_MAX_FUTURE_SKEW", 5*time.Minute),
+			Sanitize:             env.asBool("VALIDATION_SANITIZE", false),
+			SanitizeMaxLength:    env.asInt("VALIDATION_SANITIZE_MAX_LENGTH", 255),
+			ItemStatuses:         getEnv("VALIDATION_ITEM_STATUSES", ""),
+			DefaultCurrency:      getEnv("DEFAULT_CURRENCY", ""),
+			OrderUIDFormat:       getEnv("VALIDATION_ORDER_UID_FORMAT", ""),
+			OrderUIDPattern:      getEnv("VALIDATION_ORDER_UID_PATTERN", ""),
+			StrictJSON:           env.asBool("VALIDATION_STRICT_JSON", false),
 		},
 		Retry: RetryConfig{
-			MaxAttempts:  getEnvAsInt("RETRY_MAX_ATTEMPTS", 3),
-			InitialDelay: getEnvAsDuration("RETRY_INITIAL_DELAY", 1*time.Second),
-			MaxDelay:     getEnvAsDuration("RETRY_MAX_DELAY", 30*time.Second),
-			Multiplier:   getEnvAsFloat("RETRY_MULTIPLIER", 2.0),
+			MaxAttempts:             env.asInt("RETRY_MAX_ATTEMPTS", 3),
+			InitialDelay:            env.asDuration("RETRY_INITIAL_DELAY", 1*time.Second),
+			MaxDelay:                env.asDuration("RETRY_MAX_DELAY", 30*time.Second),
+			Multiplier:              env.asFloat("RETRY_MULTIPLIER", 2.0),
+			MaxElapsed:              env.asDuration("RETRY_MAX_ELAPSED", 0),
+			BreakerFailureThreshold: env.asInt("RETRY_BREAKER_FAILURE_THRESHOLD", 5),
+			BreakerCooldown:         env.asDuration("RETRY_BREAKER_COOLDOWN", 30*time.Second),
 		},
 		DLQ: DLQConfig{
-			Enabled:    getEnvAsBool("DLQ_ENABLED", true),
-			Topic:      getEnv("DLQ_TOPIC", "orders-dlq"),
-			MaxRetries: getEnvAsInt("DLQ_MAX_RETRIES", 3),
+			Enabled:      env.asBool("DLQ_ENABLED", true),
+			Topic:        getEnv("DLQ_TOPIC", "orders-dlq"),
+			MaxRetries:   env.asInt("DLQ_MAX_RETRIES", 3),
+			ParkingTopic: getEnv("DLQ_PARKING_TOPIC", "orders-dlq-parked"),
+			RetryBackoff: env.asDuration("DLQ_RETRY_BACKOFF", 1*time.Second),
+			// Повторная отправка в основной топик; номер попытки идет с сообщением
+			// в заголовке retry-count, после DLQ_MAX_RETRIES сообщение паркуется
+			RequeueEnabled: env.asBool("DLQ_REQUEUE_ENABLED", false),
+			CorruptTopic:   getEnv("DLQ_CORRUPT_TOPIC", "orders-dlq-corrupt"),
 		},
 		Logger: logger.Config{
 			Level:  getEnv("LOG_LEVEL", "info"),
 			Format: getEnv("LOG_FORMAT", "json"),
+			// LOG_LEVEL_KAFKA=debug включает debug только для компонента kafka
+			ComponentLevels: getEnvByPrefix("LOG_LEVEL_"),
 		},
 		Metrics: MetricsConfig{
-			Enabled: getEnvAsBool("METRICS_ENABLED", true),
-			Port:    getEnvAsInt("METRICS_PORT", 9090),
+			Enabled: env.asBool("METRICS_ENABLED", true),
+			Port:    env.asInt("METRICS_PORT", 9090),
 			Path:    getEnv("METRICS_PATH", "/metrics"),
+
+			PprofEnabled: env.asBool("PPROF_ENABLED", false),
+		},
+		RateLimit: RateLimitConfig{
+			Requests:   env.asInt("RATE_LIMIT_REQUESTS", 0),
+			Window:     env.asDuration("RATE_LIMIT_WINDOW", time.Second),
+			Burst:      env.asInt("RATE_LIMIT_BURST", 0),
+			AllowCIDRs: getEnvAsList("RATE_LIMIT_ALLOW_CIDRS"),
+			DenyCIDRs:  getEnvAsList("RATE_LIMIT_DENY_CIDRS"),
 		},
 	}
 
+	// Раньше время на остановку consumer задавал SHUTDOWN_WAIT_TIMEOUT, он остается значением по умолчанию
+	cfg.App.KafkaShutdownTimeout = env.asDuration("KAFKA_SHUTDOWN_TIMEOUT", cfg.App.ShutdownWaitTimeout)
+
+	if err := env.err(); err != nil {
+		return nil, err
+	}
+
 	// Валидируем конфигурацию
 	validator := NewValidator()
 	if err := validator.Validate(cfg); err != nil {
@@ -194,10 +430,31 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// ShutdownTimeout возвращает таймаут остановки компонента
+// Незаданный таймаут заменяется общим GracefulShutdownTimeout
+func (c AppConfig) ShutdownTimeout(component time.Duration) time.Duration {
+	if component > 0 {
+		return component
+	}
+	return c.GracefulShutdownTimeout
+}
+
 func (c *Config) DatabaseURL() string {
-	return "postgresql://" + c.Database.User + ":" + c.Database.Password + "@" +
+	dsn := "postgresql://" + c.Database.User + ":" + c.Database.Password + "@" +
 		c.Database.Host + ":" + strconv.Itoa(c.Database.Port) + "/" +
 		c.Database.Database + "?sslmode=" + c.Database.SSLMode
+
+	// Пути к сертификатам добавляются только если заданы
+	if c.Database.SSLRootCert != "" {
+		dsn += "&sslrootcert=" + url.QueryEscape(c.Database.SSLRootCert)
+	}
+	if c.Database.SSLCert != "" {
+		dsn += "&sslcert=" + url.QueryEscape(c.Database.SSLCert)
+	}
+	if c.Database.SSLKey != "" {
+		dsn += "&sslkey=" + url.QueryEscape(c.Database.SSLKey)
+	}
+	return dsn
 }
 
 func getEnv(key, defaultValue string) string {
@@ -207,6 +464,32 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvByPrefix собирает переменные с префиксом prefix
+// Ключ - остаток имени в нижнем регистре, пустые значения отбрасываются
+func getEnvByPrefix(prefix string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range os.Environ() {
+		key, value, _ := strings.Cut(pair, "=")
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" || value == "" {
+			continue
+		}
+		values[strings.ToLower(name)] = value
+	}
+	return values
+}
+
+// getEnvAsList разбирает список через запятую, пустые элементы отбрасываются
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -243,9 +526,83 @@ func getEnvAsFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// envReader читает типизированные переменные окружения и
+// в строгом режиме собирает ошибки разбора
+type envReader struct {
+	strict bool
+	errors []string
+}
+
+func (e *envReader) asInt(key string, defaultValue int) int {
+	e.check(key, func(value string) error {
+		_, err := strconv.Atoi(value)
+		return err
+	})
+	return getEnvAsInt(key, defaultValue)
+}
+
+func (e *envReader) asBool(key string, defaultValue bool) bool {
+	e.check(key, func(value string) error {
+		_, err := strconv.ParseBool(value)
+		return err
+	})
+	return getEnvAsBool(key, defaultValue)
+}
+
+func (e *envReader) asDuration(key string, defaultValue time.Duration) time.Duration {
+	e.check(key, func(value string) error {
+		_, err := time.ParseDuration(value)
+		return err
+	})
+	return getEnvAsDuration(key, defaultValue)
+}
+
+func (e *envReader) asFloat(key string, defaultValue float64) float64 {
+	e.check(key, func(value string) error {
+		_, err := strconv.ParseFloat(value, 64)
+		return err
+	})
+	return getEnvAsFloat(key, defaultValue)
+}
+
+// check запоминает ошибку разбора, если переменная задана и не разбирается
+func (e *envReader) check(key string, parse func(string) error) {
+	if !e.strict {
+		return
+	}
+	if value := os.Getenv(key); value != "" {
+		if err := parse(value); err != nil {
+			e.errors = append(e.errors, fmt.Sprintf("%s=%q", key, value))
+		}
+	}
+}
+
+// err возвращает ошибку со списком некорректных переменных
+func (e *envReader) err() error {
+	if len(e.errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid environment variables: %s", strings.Join(e.errors, ", "))
+}
+
 // MetricsConfig конфигурация метрик
 type MetricsConfig struct {
 	Enabled bool
 	Port    int
 	Path    string
+	// pprof на отдельном служебном сервере METRICS_PORT, на порт API не попадает
+	PprofEnabled bool
+}
+
+// RateLimitConfig ограничение частоты запросов к API по IP клиента
+type RateLimitConfig struct {
+	// Requests запросов за Window с одного IP, 0 - ограничение выключено
+	Requests int
+	Window   time.Duration
+	// Burst запас запросов сверх Requests, 0 - равен Requests
+	Burst int
+	// AllowCIDRs подсети без ограничения: проверки здоровья, доверенные прокси
+	AllowCIDRs []string
+	// DenyCIDRs подсети, запросы с которых всегда отклоняются с 403
+	DenyCIDRs []string
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -11,8 +12,13 @@ import (
 
 	apperrors "wbtest/internal/errors"
 	"wbtest/internal/logger"
+	"wbtest/internal/model"
+	"wbtest/internal/ratelimit"
 )
 
+// maxCacheLoadWorkers предел параллельных запросов при загрузке кеша
+const maxCacheLoadWorkers = 64
+
 // Validator валидирует конфигурацию приложения
 type Validator struct{}
 
@@ -34,6 +40,10 @@ func (v *Validator) Validate(cfg *Config) error {
 		errors = append(errors, fmt.Sprintf("Kafka: %v", err))
 	}
 
+	if err := v.validateKafkaForEnvironment(&cfg.Kafka, cfg.App.Environment); err != nil {
+		errors = append(errors, fmt.Sprintf("Kafka: %v", err))
+	}
+
 	if err := v.validateCache(&cfg.Cache); err != nil {
 		errors = append(errors, fmt.Sprintf("Cache: %v", err))
 	}
@@ -58,6 +68,27 @@ func (v *Validator) Validate(cfg *Config) error {
 		errors = append(errors, fmt.Sprintf("Metrics: %v", err))
 	}
 
+	if err := v.validateRateLimit(&cfg.RateLimit); err != nil {
+		errors = append(errors, fmt.Sprintf("RateLimit: %v", err))
+	}
+
+	if err := v.validateShutdown(&cfg.App); err != nil {
+		errors = append(errors, fmt.Sprintf("App: %v", err))
+	}
+
+	if err := v.validateValidation(&cfg.Validation); err != nil {
+		errors = append(errors, fmt.Sprintf("Validation: %v", err))
+	}
+
+	if _, err := model.TimeLayout(cfg.App.JSONTimeFormat); err != nil {
+		errors = append(errors, fmt.Sprintf("App: json_time_format: %v", err))
+	}
+
+	// Метрики и pprof отдает отдельный сервер, он не может занять порт API
+	if (cfg.Metrics.Enabled || cfg.Metrics.PprofEnabled) && cfg.Metrics.Port == cfg.HTTP.Port {
+		errors = append(errors, "Metrics: port must differ from HTTP port")
+	}
+
 	if len(errors) > 0 {
 		return apperrors.NewWithCode(
 			apperrors.ErrorTypeValidation,
@@ -110,6 +141,30 @@ func (v *Validator) validateDatabase(cfg *DatabaseConfig) error {
 		errors = append(errors, "max_idle_conns cannot be greater than max_open_conns")
 	}
 
+	// Предел записей не меньше пула не оставляет соединений для чтения
+	if cfg.MaxConcurrentWrites < 0 {
+		errors = append(errors, "max_concurrent_writes cannot be negative")
+	} else if cfg.MaxConcurrentWrites > 0 && cfg.MaxConcurrentWrites >= cfg.MaxOpenConns {
+		errors = append(errors, "max_concurrent_writes must be less than max_open_conns")
+	}
+
+	if cfg.SlowQueryThreshold < 0 {
+		errors = append(errors, "slow_query_threshold cannot be negative")
+	}
+
+	if cfg.StatementTimeout < 0 {
+		errors = append(errors, "statement_timeout cannot be negative")
+	}
+
+	if cfg.ConnectMaxAttempts < 0 {
+		errors = append(errors, "connect_max_attempts cannot be negative")
+	}
+	if cfg.ConnectInitialDelay < 0 || cfg.ConnectMaxDelay < 0 {
+		errors = append(errors, "connect delays cannot be negative")
+	}
+
+	errors = append(errors, validateDatabaseSSL(cfg)...)
+
 	if len(errors) > 0 {
 		return fmt.Errorf(strings.Join(errors, "; "))
 	}
@@ -117,6 +172,42 @@ func (v *Validator) validateDatabase(cfg *DatabaseConfig) error {
 	return nil
 }
 
+// validateDatabaseSSL проверяет режим SSL и наличие файлов сертификатов
+func validateDatabaseSSL(cfg *DatabaseConfig) []string {
+	var errors []string
+
+	switch cfg.SSLMode {
+	case "", "disable", "allow", "prefer", "require":
+	case "verify-ca", "verify-full":
+		// Без CA сертификата проверить сервер невозможно
+		if cfg.SSLRootCert == "" {
+			errors = append(errors, fmt.Sprintf("sslrootcert is required for sslmode %s", cfg.SSLMode))
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("invalid sslmode: %s", cfg.SSLMode))
+	}
+
+	if (cfg.SSLCert == "") != (cfg.SSLKey == "") {
+		errors = append(errors, "sslcert and sslkey must be set together")
+	}
+
+	files := []struct{ name, path string }{
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			errors = append(errors, fmt.Sprintf("%s file is not accessible: %s", file.name, file.path))
+		}
+	}
+
+	return errors
+}
+
 // validateKafka валидирует конфигурацию Kafka
 func (v *Validator) validateKafka(cfg *KafkaConfig) error {
 	var errors []string
@@ -125,11 +216,19 @@ func (v *Validator) validateKafka(cfg *KafkaConfig) error {
 		errors = append(errors, "at least one broker is required")
 	}
 
-	// Проверяем формат каждого брокера
+	// Проверяем формат каждого брокера и отсутствие повторов
+	seen := make(map[string]int, len(cfg.Brokers))
 	for i, broker := range cfg.Brokers {
 		if err := v.validateHostPort(broker); err != nil {
 			errors = append(errors, fmt.Sprintf("broker %d (%s): %v", i, broker, err))
 		}
+
+		key := strings.ToLower(strings.TrimSpace(broker))
+		if first, ok := seen[key]; ok {
+			errors = append(errors, fmt.Sprintf("broker %d (%s) duplicates broker %d", i, broker, first))
+			continue
+		}
+		seen[key] = i
 	}
 
 	if cfg.Topic == "" {
@@ -144,10 +243,70 @@ func (v *Validator) validateKafka(cfg *KafkaConfig) error {
 		errors = append(errors, "batch_size must be greater than 0")
 	}
 
+	if cfg.SessionTimeoutMs < 0 {
+		errors = append(errors, "session_timeout_ms cannot be negative")
+	}
+
+	if cfg.HeartbeatIntervalMs < 0 {
+		errors = append(errors, "heartbeat_interval_ms cannot be negative")
+	}
+
+	if cfg.SessionTimeoutMs > 0 && cfg.HeartbeatIntervalMs >= cfg.SessionTimeoutMs {
+		errors = append(errors, "heartbeat_interval_ms must be less than session_timeout_ms")
+	}
+
 	if cfg.BatchTimeout <= 0 {
 		errors = append(errors, "batch_timeout must be greater than 0")
 	}
 
+	if cfg.BackpressureThreshold < 0 {
+		errors = append(errors, "backpressure_threshold cannot be negative")
+	}
+
+	if cfg.ConsumerConcurrency < 0 {
+		errors = append(errors, "consumer_concurrency cannot be negative")
+	}
+
+	switch cfg.Compression {
+	case "", "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		errors = append(errors, fmt.Sprintf("compression must be one of none, gzip, snappy, lz4, zstd, got %q", cfg.Compression))
+	}
+
+	if cfg.DedupWindow < 0 {
+		errors = append(errors, "dedup_window cannot be negative")
+	}
+
+	if cfg.BackpressureThreshold > 0 && cfg.BackpressureCooldown <= 0 {
+		errors = append(errors, "backpressure_cooldown must be greater than 0 when backpressure is enabled")
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf(strings.Join(errors, "; "))
+	}
+
+	return nil
+}
+
+// validateKafkaForEnvironment запрещает локальные брокеры в production
+func (v *Validator) validateKafkaForEnvironment(cfg *KafkaConfig, environment string) error {
+	if !strings.EqualFold(environment, "production") {
+		return nil
+	}
+
+	var errors []string
+
+	for i, broker := range cfg.Brokers {
+		host, _, err := net.SplitHostPort(broker)
+		if err != nil {
+			// Формат проверяется в validateKafka
+			continue
+		}
+		if isLocalHost(host) {
+			errors = append(errors, fmt.Sprintf("broker %d (%s) points to localhost in production", i, broker))
+		}
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf(strings.Join(errors, "; "))
 	}
@@ -155,6 +314,15 @@ func (v *Validator) validateKafka(cfg *KafkaConfig) error {
 	return nil
 }
 
+// isLocalHost проверяет, указывает ли хост на локальную машину
+func isLocalHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
 // validateCache валидирует конфигурацию кеша
 func (v *Validator) validateCache(cfg *CacheConfig) error {
 	var errors []string
@@ -171,6 +339,48 @@ func (v *Validator) validateCache(cfg *CacheConfig) error {
 		errors = append(errors, "cleanup_interval must be greater than 0")
 	}
 
+	// Каждый загрузчик занимает соединение из пула БД
+	if cfg.LoadWorkers < 0 || cfg.LoadWorkers > maxCacheLoadWorkers {
+		errors = append(errors, fmt.Sprintf("load_workers must be between 0 and %d", maxCacheLoadWorkers))
+	}
+
+	if cfg.RecentOrderAge < 0 {
+		errors = append(errors, "recent_order_age cannot be negative")
+	}
+	if cfg.RecentOrderAge > 0 && cfg.OldOrderTTL <= 0 {
+		errors = append(errors, "old_order_ttl must be greater than 0 when recent_order_age is set")
+	}
+
+	if cfg.WarmMaxAttempts < 0 {
+		errors = append(errors, "warm_max_attempts cannot be negative")
+	}
+	if cfg.WarmInitialDelay < 0 || cfg.WarmMaxDelay < 0 {
+		errors = append(errors, "warm delays cannot be negative")
+	}
+
+	if cfg.StatsHistoryInterval < 0 {
+		errors = append(errors, "stats_history_interval cannot be negative")
+	}
+	if cfg.StatsHistoryInterval > 0 && cfg.StatsHistorySize <= 0 {
+		errors = append(errors, "stats_history_size must be greater than 0 when stats_history_interval is set")
+	}
+
+	if cfg.ReconcileInterval < 0 {
+		errors = append(errors, "reconcile_interval cannot be negative")
+	}
+	if cfg.ReconcileInterval > 0 && (cfg.ReconcileSampleRate <= 0 || cfg.ReconcileSampleRate > 1) {
+		errors = append(errors, "reconcile_sample_rate must be in (0, 1] when reconcile_interval is set")
+	}
+
+	validStrategies := map[string]bool{
+		"lru": true, "lfu": true, "oldest": true,
+	}
+
+	// Пустое значение означает стратегию по умолчанию
+	if cfg.EvictionStrategy != "" && !validStrategies[cfg.EvictionStrategy] {
+		errors = append(errors, fmt.Sprintf("invalid eviction strategy '%s', valid strategies: lru, lfu, oldest", cfg.EvictionStrategy))
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf(strings.Join(errors, "; "))
 	}
@@ -198,6 +408,40 @@ func (v *Validator) validateHTTP(cfg *HTTPConfig) error {
 		errors = append(errors, "idle_timeout must be greater than 0")
 	}
 
+	if cfg.MaxBodyBytes < 0 {
+		errors = append(errors, "max_body_bytes cannot be negative")
+	}
+
+	if cfg.MaxListRows < 0 {
+		errors = append(errors, "max_list_rows cannot be negative")
+	}
+
+	if cfg.DBReadTimeout < 0 {
+		errors = append(errors, "db_read_timeout cannot be negative")
+	}
+
+	if cfg.HealthCheckInterval < 0 {
+		errors = append(errors, "health_check_interval cannot be negative")
+	}
+
+	if cfg.HealthMaxStaleness < 0 {
+		errors = append(errors, "health_max_staleness cannot be negative")
+	}
+
+	// Результат старше интервала устаревает до следующей фоновой проверки
+	if cfg.HealthMaxStaleness > 0 && cfg.HealthMaxStaleness < cfg.HealthCheckInterval {
+		errors = append(errors, "health_max_staleness must not be less than health_check_interval")
+	}
+
+	// Иначе таймаут запроса срабатывает раньше и медленная БД неотличима от прочих таймаутов
+	if cfg.DBReadTimeout > 0 && cfg.RequestTimeout > 0 && cfg.DBReadTimeout >= cfg.RequestTimeout {
+		errors = append(errors, "db_read_timeout must be less than request_timeout")
+	}
+
+	if cfg.AuthProtectReads && len(cfg.APIKeys) == 0 {
+		errors = append(errors, "auth_protect_reads requires api_keys")
+	}
+
 	// Проверяем что read_timeout и write_timeout разумные
 	if cfg.ReadTimeout > 5*time.Minute {
 		errors = append(errors, "read_timeout should not exceed 5 minutes")
@@ -242,6 +486,18 @@ func (v *Validator) validateRetry(cfg *RetryConfig) error {
 		errors = append(errors, "initial_delay cannot be greater than max_delay")
 	}
 
+	if cfg.MaxElapsed < 0 {
+		errors = append(errors, "max_elapsed cannot be negative")
+	}
+
+	if cfg.BreakerFailureThreshold < 0 {
+		errors = append(errors, "breaker_failure_threshold cannot be negative")
+	}
+
+	if cfg.BreakerFailureThreshold > 0 && cfg.BreakerCooldown <= 0 {
+		errors = append(errors, "breaker_cooldown must be greater than 0 when breaker is enabled")
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf(strings.Join(errors, "; "))
 	}
@@ -261,6 +517,14 @@ func (v *Validator) validateDLQ(cfg *DLQConfig) error {
 		errors = append(errors, "max_retries must be greater than 0")
 	}
 
+	if cfg.RetryBackoff < 0 {
+		errors = append(errors, "retry_backoff cannot be negative")
+	}
+
+	if cfg.ParkingTopic != "" && cfg.ParkingTopic == cfg.Topic {
+		errors = append(errors, "parking_topic must differ from topic")
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf(strings.Join(errors, "; "))
 	}
@@ -280,6 +544,12 @@ func (v *Validator) validateLogger(cfg *logger.Config) error {
 		errors = append(errors, fmt.Sprintf("invalid log level '%s', valid levels: debug, info, warn, error, fatal, panic", cfg.Level))
 	}
 
+	for component, level := range cfg.ComponentLevels {
+		if !validLevels[strings.ToLower(level)] {
+			errors = append(errors, fmt.Sprintf("invalid log level '%s' for component %s (LOG_LEVEL_%s)", level, component, strings.ToUpper(component)))
+		}
+	}
+
 	validFormats := map[string]bool{
 		"json": true, "text": true,
 	}
@@ -325,6 +595,114 @@ func (v *Validator) validateMetrics(cfg *MetricsConfig) error {
 	return nil
 }
 
+// validateRateLimit валидирует ограничение частоты запросов
+// Списки подсетей без лимита не применяются, поэтому требуют RATE_LIMIT_REQUESTS
+func (v *Validator) validateRateLimit(cfg *RateLimitConfig) error {
+	var errors []string
+
+	if cfg.Requests < 0 {
+		errors = append(errors, "requests cannot be negative")
+	}
+
+	if cfg.Burst < 0 {
+		errors = append(errors, "burst cannot be negative")
+	}
+
+	if cfg.Requests > 0 && cfg.Window <= 0 {
+		errors = append(errors, "window must be positive")
+	}
+
+	if cfg.Requests == 0 && (len(cfg.AllowCIDRs) > 0 || len(cfg.DenyCIDRs) > 0) {
+		errors = append(errors, "allow_cidrs and deny_cidrs require requests > 0")
+	}
+
+	if _, err := ratelimit.ParseIPList(cfg.AllowCIDRs); err != nil {
+		errors = append(errors, fmt.Sprintf("allow_cidrs: %v", err))
+	}
+
+	if _, err := ratelimit.ParseIPList(cfg.DenyCIDRs); err != nil {
+		errors = append(errors, fmt.Sprintf("deny_cidrs: %v", err))
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf(strings.Join(errors, "; "))
+	}
+
+	return nil
+}
+
+// validateShutdown валидирует таймауты остановки
+// 0 допустим и означает общий GracefulShutdownTimeout
+func (v *Validator) validateShutdown(cfg *AppConfig) error {
+	var errors []string
+
+	if cfg.GracefulShutdownTimeout < 0 {
+		errors = append(errors, "graceful_shutdown_timeout cannot be negative")
+	}
+
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http_shutdown_timeout", cfg.HTTPShutdownTimeout},
+		{"kafka_shutdown_timeout", cfg.KafkaShutdownTimeout},
+		{"dlq_shutdown_timeout", cfg.DLQShutdownTimeout},
+		{"db_shutdown_timeout", cfg.DBShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			errors = append(errors, fmt.Sprintf("%s cannot be negative", timeout.name))
+		}
+	}
+
+	// Пауза drain входит в таймаут остановки HTTP, иначе на ожидание запросов не останется времени
+	if cfg.HTTPDrainDelay < 0 {
+		errors = append(errors, "http_drain_delay cannot be negative")
+	} else if httpTimeout := cfg.ShutdownTimeout(cfg.HTTPShutdownTimeout); cfg.HTTPDrainDelay > 0 && cfg.HTTPDrainDelay >= httpTimeout {
+		errors = append(errors, fmt.Sprintf("http_drain_delay (%v) must be less than http shutdown timeout (%v)",
+			cfg.HTTPDrainDelay, httpTimeout))
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf(strings.Join(errors, "; "))
+	}
+
+	return nil
+}
+
+// currencyRegex код валюты ISO 4217
+var currencyRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// validateValidation валидирует настройки проверки заказов
+func (v *Validator) validateValidation(cfg *ValidationConfig) error {
+	var errors []string
+
+	if cfg.DefaultCurrency != "" && !currencyRegex.MatchString(cfg.DefaultCurrency) {
+		errors = append(errors, fmt.Sprintf("default_currency must be a 3-letter uppercase code, got %q", cfg.DefaultCurrency))
+	}
+
+	switch cfg.OrderUIDFormat {
+	case "", "uuid":
+		if cfg.OrderUIDPattern != "" {
+			errors = append(errors, "order_uid_pattern requires order_uid_format=regex")
+		}
+	case "regex":
+		if cfg.OrderUIDPattern == "" {
+			errors = append(errors, "order_uid_pattern is required for order_uid_format=regex")
+		} else if _, err := regexp.Compile(cfg.OrderUIDPattern); err != nil {
+			errors = append(errors, fmt.Sprintf("order_uid_pattern is not a valid regular expression: %v", err))
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("order_uid_format must be uuid or regex, got %q", cfg.OrderUIDFormat))
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf(strings.Join(errors, "; "))
+	}
+
+	return nil
+}
+
 // validateHostPort валидирует формат host:port
 func (v *Validator) validateHostPort(addr string) error {
 	host, portStr, err := net.SplitHostPort(addr)
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -329,6 +332,59 @@ func TestValidator_validateDatabase(t *testing.T) {
 	}
 }
 
+func TestValidator_validateDatabase_SSL(t *testing.T) {
+	validator := NewValidator()
+
+	certPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(certPath, []byte("cert"), 0o600); err != nil {
+		t.Fatalf("Failed to write cert: %v", err)
+	}
+
+	base := DatabaseConfig{
+		Host:            "localhost",
+		Port:            5432,
+		User:            "test_user",
+		Password:        "test_pass",
+		Database:        "test_db",
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 5 * time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*DatabaseConfig)
+		wantErr bool
+	}{
+		{"require without certs", func(c *DatabaseConfig) { c.SSLMode = "require" }, false},
+		{"verify-full with existing root cert", func(c *DatabaseConfig) {
+			c.SSLMode = "verify-full"
+			c.SSLRootCert = certPath
+		}, false},
+		{"verify-full without root cert", func(c *DatabaseConfig) { c.SSLMode = "verify-full" }, true},
+		{"verify-ca with missing root cert file", func(c *DatabaseConfig) {
+			c.SSLMode = "verify-ca"
+			c.SSLRootCert = certPath + ".missing"
+		}, true},
+		{"client cert without key", func(c *DatabaseConfig) {
+			c.SSLMode = "require"
+			c.SSLCert = certPath
+		}, true},
+		{"invalid sslmode", func(c *DatabaseConfig) { c.SSLMode = "sometimes" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.mutate(&cfg)
+			err := validator.validateDatabase(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateDatabase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidator_validateKafka(t *testing.T) {
 	validator := NewValidator()
 
@@ -370,6 +426,17 @@ func TestValidator_validateKafka(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "duplicate brokers",
+			config: KafkaConfig{
+				Brokers:      []string{"broker1:9092", "broker2:9092", "broker1:9092"},
+				Topic:        "test-topic",
+				GroupID:      "test-group",
+				BatchSize:    100,
+				BatchTimeout: 100 * time.Millisecond,
+			},
+			wantErr: true,
+		},
 		{
 			name: "empty topic",
 			config: KafkaConfig{
@@ -393,6 +460,53 @@ func TestValidator_validateKafka(t *testing.T) {
 	}
 }
 
+func TestValidator_validateKafkaForEnvironment(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name        string
+		brokers     []string
+		environment string
+		wantErr     bool
+	}{
+		{"localhost in development", []string{"localhost:9092"}, "development", false},
+		{"localhost in production", []string{"kafka-1:9092", "localhost:9092"}, "production", true},
+		{"loopback ip in production", []string{"127.0.0.1:9092"}, "Production", true},
+		{"remote brokers in production", []string{"kafka-1:9092", "kafka-2:9092"}, "production", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.validateKafkaForEnvironment(&KafkaConfig{Brokers: tt.brokers}, tt.environment)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateKafkaForEnvironment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_validateKafka_DuplicateBrokersAggregated(t *testing.T) {
+	validator := NewValidator()
+
+	err := validator.validateKafka(&KafkaConfig{
+		Brokers:      []string{"broker1:9092", "broker1:9092", "invalid-broker"},
+		Topic:        "test-topic",
+		GroupID:      "test-group",
+		BatchSize:    100,
+		BatchTimeout: 100 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("Expected error for duplicate and invalid brokers")
+	}
+
+	// Ошибки собираются вместе, а не по первой
+	for _, part := range []string{"duplicates broker 0", "broker 2 (invalid-broker)"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("Expected error to contain %q, got %v", part, err)
+		}
+	}
+}
+
 func TestValidator_validateMetrics(t *testing.T) {
 	validator := NewValidator()
 
@@ -458,6 +572,178 @@ func TestValidator_validateMetrics(t *testing.T) {
 	}
 }
 
+func TestValidator_validateRateLimit(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name    string
+		config  RateLimitConfig
+		wantErr bool
+	}{
+		{
+			name:    "disabled",
+			config:  RateLimitConfig{},
+			wantErr: false,
+		},
+		{
+			name: "valid with networks",
+			config: RateLimitConfig{
+				Requests:   100,
+				Window:     time.Second,
+				AllowCIDRs: []string{"10.0.0.0/8", "127.0.0.1"},
+				DenyCIDRs:  []string{"203.0.113.0/24"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "negative requests",
+			config:  RateLimitConfig{Requests: -1, Window: time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "zero window",
+			config:  RateLimitConfig{Requests: 100},
+			wantErr: true,
+		},
+		{
+			name:    "networks without limit",
+			config:  RateLimitConfig{DenyCIDRs: []string{"203.0.113.0/24"}},
+			wantErr: true,
+		},
+		{
+			name: "invalid CIDR",
+			config: RateLimitConfig{
+				Requests:   100,
+				Window:     time.Second,
+				AllowCIDRs: []string{"10.0.0.0/33"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.validateRateLimit(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRateLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_validateShutdown(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name    string
+		config  AppConfig
+		wantErr bool
+	}{
+		{
+			name: "valid per-component timeouts",
+			config: AppConfig{
+				GracefulShutdownTimeout: 30 * time.Second,
+				HTTPShutdownTimeout:     15 * time.Second,
+				KafkaShutdownTimeout:    5 * time.Second,
+				DLQShutdownTimeout:      5 * time.Second,
+				DBShutdownTimeout:       5 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "unset timeouts fall back to total",
+			config:  AppConfig{GracefulShutdownTimeout: 30 * time.Second},
+			wantErr: false,
+		},
+		{
+			name: "negative component timeout",
+			config: AppConfig{
+				GracefulShutdownTimeout: 30 * time.Second,
+				DBShutdownTimeout:       -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "negative graceful timeout",
+			config:  AppConfig{GracefulShutdownTimeout: -time.Second},
+			wantErr: true,
+		},
+		{
+			name: "drain delay within http timeout",
+			config: AppConfig{
+				GracefulShutdownTimeout: 30 * time.Second,
+				HTTPShutdownTimeout:     15 * time.Second,
+				HTTPDrainDelay:          5 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name: "drain delay exceeds http timeout",
+			config: AppConfig{
+				GracefulShutdownTimeout: 30 * time.Second,
+				HTTPShutdownTimeout:     5 * time.Second,
+				HTTPDrainDelay:          5 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "negative drain delay",
+			config:  AppConfig{GracefulShutdownTimeout: 30 * time.Second, HTTPDrainDelay: -time.Second},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.validateShutdown(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateShutdown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_validateValidation(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name    string
+		config  ValidationConfig
+		wantErr bool
+	}{
+		{"no default currency", ValidationConfig{}, false},
+		{"valid default currency", ValidationConfig{DefaultCurrency: "RUB"}, false},
+		{"lowercase default currency", ValidationConfig{DefaultCurrency: "rub"}, true},
+		{"long default currency", ValidationConfig{DefaultCurrency: "RUBL"}, true},
+		{"uuid order uid format", ValidationConfig{OrderUIDFormat: "uuid"}, false},
+		{"regex order uid format", ValidationConfig{OrderUIDFormat: "regex", OrderUIDPattern: `ORD-[0-9]+`}, false},
+		{"regex format without pattern", ValidationConfig{OrderUIDFormat: "regex"}, true},
+		{"invalid order uid pattern", ValidationConfig{OrderUIDFormat: "regex", OrderUIDPattern: `ORD-[`}, true},
+		{"pattern without regex format", ValidationConfig{OrderUIDPattern: `ORD-[0-9]+`}, true},
+		{"unknown order uid format", ValidationConfig{OrderUIDFormat: "ulid"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.validateValidation(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateValidation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppConfig_ShutdownTimeout(t *testing.T) {
+	cfg := AppConfig{GracefulShutdownTimeout: 30 * time.Second}
+
+	if got := cfg.ShutdownTimeout(5 * time.Second); got != 5*time.Second {
+		t.Errorf("Expected component timeout 5s, got %v", got)
+	}
+	if got := cfg.ShutdownTimeout(0); got != 30*time.Second {
+		t.Errorf("Expected fallback to graceful timeout 30s, got %v", got)
+	}
+}
+
 func TestValidator_validateHostPort(t *testing.T) {
 	validator := NewValidator()
 
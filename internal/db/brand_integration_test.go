@@ -0,0 +1,112 @@
+//go:build integration
+// +build integration
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"wbtest/internal/config"
+	"wbtest/internal/model"
+)
+
+// TestCountOrdersByBrand проверяет подсчет заказов по бренду на заранее сохраненных заказах
+func TestCountOrdersByBrand(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dbConn, err := New(cfg.DatabaseURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Уникальные бренды, чтобы не пересекаться с данными других тестов
+	suffix := time.Now().UnixNano()
+	brand := fmt.Sprintf("brand-%d", suffix)
+	other := fmt.Sprintf("other-%d", suffix)
+
+	seed := []struct {
+		uid    string
+		brands []string
+	}{
+		{fmt.Sprintf("brand-a-%d", suffix), []string{brand}},
+		{fmt.Sprintf("brand-b-%d", suffix), []string{brand, brand, other}},
+		{fmt.Sprintf("brand-c-%d", suffix), []string{other}},
+		{fmt.Sprintf("brand-d-%d", suffix), []string{brand}},
+	}
+	for _, s := range seed {
+		uid := s.uid
+		order := &model.Order{
+			OrderUID:    uid,
+			TrackNumber: "TRACK-" + uid,
+			Entry:       "WBIL",
+			Payment: model.Payment{
+				Transaction: uid,
+				Currency:    "USD",
+				Provider:    "wbpay",
+				Amount:      1000,
+				PaymentDT:   1637907727,
+				Bank:        "alpha",
+				GoodsTotal:  1000,
+			},
+			Locale:          "en",
+			CustomerID:      "test",
+			DeliveryService: "meest",
+			SmID:            99,
+			DateCreated:     time.Now().UTC(),
+		}
+		for i, b := range s.brands {
+			order.Items = append(order.Items, model.Item{
+				ChrtID:      i + 1,
+				TrackNumber: order.TrackNumber,
+				Price:       500,
+				Rid:         fmt.Sprintf("%s-%d", uid, i),
+				Name:        "Item",
+				TotalPrice:  500,
+				NmID:        i + 1,
+				Brand:       b,
+				Status:      202,
+			})
+		}
+		defer func() {
+			_, _ = dbConn.DB.Exec(context.Background(), "DELETE FROM orders WHERE order_uid = $1", uid)
+		}()
+
+		if err := dbConn.SaveOrder(ctx, order); err != nil {
+			t.Fatalf("SaveOrder %s failed: %v", uid, err)
+		}
+	}
+
+	// Удаленный заказ не учитывается
+	if _, err := dbConn.DB.Exec(ctx, "UPDATE orders SET deleted_at = NOW() WHERE order_uid = $1", seed[3].uid); err != nil {
+		t.Fatalf("Failed to soft delete order: %v", err)
+	}
+
+	count, err := dbConn.CountOrdersByBrand(ctx, brand, 10)
+	if err != nil {
+		t.Fatalf("CountOrdersByBrand failed: %v", err)
+	}
+	if count.Orders != 2 {
+		t.Errorf("Expected 2 orders with brand %s, got %d", brand, count.Orders)
+	}
+	if len(count.SampleOrderUIDs) != 2 || count.SampleOrderUIDs[0] != seed[0].uid || count.SampleOrderUIDs[1] != seed[1].uid {
+		t.Errorf("Expected sample [%s %s], got %v", seed[0].uid, seed[1].uid, count.SampleOrderUIDs)
+	}
+
+	count, err = dbConn.CountOrdersByBrand(ctx, other, 1)
+	if err != nil {
+		t.Fatalf("CountOrdersByBrand failed: %v", err)
+	}
+	if count.Orders != 2 || len(count.SampleOrderUIDs) != 1 {
+		t.Errorf("Expected 2 orders and 1 sample for brand %s, got %+v", other, count)
+	}
+}
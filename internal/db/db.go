@@ -4,26 +4,122 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
+	"wbtest/internal/clock"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/metrics"
 	"wbtest/internal/model"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
 )
 
+// pool методы пула подключений, которые использует DB
+// Совпадает с частью pgxmock.PgxPoolIface, поэтому в тестах пул подменяется моком
+type pool interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Ping(ctx context.Context) error
+	Close()
+}
+
 // DB подключение к БД
 type DB struct {
-	pool *pgxpool.Pool
+	pool pool
 	// DB экспортированное поле для доступа к подключению (для миграций)
 	DB *pgxpool.Pool
+	// softDelete помечать заказы удаленными вместо удаления строк
+	softDelete bool
+
+	// Замер длительности запросов, см. timeQuery
+	clock              clock.Clock
+	logger             logrus.FieldLogger
+	metrics            *metrics.Metrics
+	slowQueryThreshold time.Duration
 }
 
 // New создает подключение к БД
-func New(connStr string) (*DB, error) {
-	pool, err := pgxpool.New(context.Background(), connStr)
+// С WithConnectRetry подключение проверяется сразу, иначе при первом запросе
+func New(connStr string, opts ...Option) (*DB, error) {
+	cfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	o := &options{pool: cfg}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
 		return nil, err
 	}
-	return &DB{pool: pool, DB: pool}, nil
+	if o.connectRetry.MaxAttempts > 0 {
+		if err := pingWithRetry(pool, o.connectRetry); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("database is not reachable: %w", err)
+		}
+	}
+	return NewWithPool(pool), nil
+}
+
+// NewWithPool создает DB поверх уже открытого пула
+// Пул закрывается вместе с DB в Close
+func NewWithPool(pool *pgxpool.Pool) *DB {
+	db := newWithPool(pool)
+	db.DB = pool
+	return db
+}
+
+// newWithPool создает DB поверх любой реализации пула, в тестах - pgxmock
+func newWithPool(p pool) *DB {
+	return &DB{pool: p, clock: clock.New(), logger: logrus.StandardLogger()}
+}
+
+// WithSoftDelete включает мягкое удаление заказов
+// DeleteOrder будет выставлять deleted_at вместо удаления строк
+func (db *DB) WithSoftDelete(enabled bool) *DB {
+	db.softDelete = enabled
+	return db
+}
+
+// deletedFilter возвращает условие отбора по deleted_at
+func deletedFilter(opts []interfaces.QueryOption) string {
+	if interfaces.ApplyQueryOptions(opts...).IncludeDeleted {
+		return "TRUE"
+	}
+	return "o.deleted_at IS NULL"
+}
+
+// partitionFilter возвращает условие отбора части заказов по хешу order_uid
+// hashtext приводится к bigint, иначе abs переполняется на минимальном int4
+func partitionFilter(opts []interfaces.QueryOption) string {
+	options := interfaces.ApplyQueryOptions(opts...)
+	if options.PartitionCount <= 1 {
+		return "TRUE"
+	}
+	return fmt.Sprintf("abs(hashtext(o.order_uid)::bigint) %% %d = %d", options.PartitionCount, options.PartitionIndex)
+}
+
+// Ping проверяет, что БД доступна
+// pgxpool подключается лениво, поэтому без Ping ошибка видна только на первом запросе
+func (db *DB) Ping(ctx context.Context) error {
+	return db.pool.Ping(ctx)
+}
+
+// ServerVersion возвращает версию сервера PostgreSQL
+func (db *DB) ServerVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := db.pool.QueryRow(ctx, "SELECT version()").Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to get server version: %w", err)
+	}
+	return version, nil
 }
 
 // Close закрывает подключение
@@ -32,11 +128,15 @@ func (db *DB) Close() {
 }
 
 // LoadAllOrders загружает все заказы
-func (db *DB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
+// Мягко удаленные заказы возвращаются только с опцией IncludeDeleted,
+// с опцией Partition загружается только одна часть заказов
+func (db *DB) LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	defer db.timeQuery("load_all_orders")()
+
 	query := `
 	SELECT 
 	  o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
-	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created::text, o.oof_shard,
+	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
 	  row_to_json(d.*),
 	  row_to_json(p.*),
 	  COALESCE(json_agg(i.*) FILTER (WHERE i.id IS NOT NULL), '[]')
@@ -44,6 +144,7 @@ func (db *DB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
 	JOIN delivery d ON d.order_uid = o.order_uid
 	JOIN payment p ON p.order_uid = o.order_uid
 	LEFT JOIN items i ON i.order_uid = o.order_uid
+	WHERE ` + deletedFilter(opts) + ` AND ` + partitionFilter(opts) + `
 	GROUP BY o.order_uid, d.*, p.*
 	`
 
@@ -56,21 +157,18 @@ func (db *DB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
 	var orders []*model.Order
 	for rows.Next() {
 		var o model.Order
-		var dateCreated time.Time
 		var deliveryJSON, paymentJSON []byte
 		var itemsJSON []byte
 
 		err := rows.Scan(
 			&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
-			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &dateCreated, &o.OofShard,
+			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
 			&deliveryJSON, &paymentJSON, &itemsJSON,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		o.DateCreated = dateCreated
-
 		// Парсим JSON данные для связанных сущностей
 		if err := json.Unmarshal(deliveryJSON, &o.Delivery); err != nil {
 			return nil, err
@@ -94,11 +192,15 @@ func (db *DB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
 }
 
 // GetOrderByUID загружает заказ по UID
-func (db *DB) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+// Мягко удаленный заказ возвращается только с опцией IncludeDeleted
+// Если заказа нет, возвращается apperrors.ErrOrderNotFound
+func (db *DB) GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error) {
+	defer db.timeQuery("get_order_by_uid")()
+
 	query := `
 	SELECT 
 	  o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
-	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created::text, o.oof_shard,
+	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
 	  row_to_json(d.*),
 	  row_to_json(p.*),
 	  COALESCE(json_agg(i.*) FILTER (WHERE i.id IS NOT NULL), '[]')
@@ -106,29 +208,26 @@ func (db *DB) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order,
 	JOIN delivery d ON d.order_uid = o.order_uid
 	JOIN payment p ON p.order_uid = o.order_uid
 	LEFT JOIN items i ON i.order_uid = o.order_uid
-	WHERE o.order_uid = $1
+	WHERE o.order_uid = $1 AND ` + deletedFilter(opts) + `
 	GROUP BY o.order_uid, d.*, p.*
 	`
 
 	var order model.Order
 	var deliveryJSON, paymentJSON []byte
 	var itemsJSON []byte
-	var dateCreatedStr string
 
 	err := db.pool.QueryRow(ctx, query, orderUID).Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &dateCreatedStr, &order.OofShard,
+		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
 		&deliveryJSON, &paymentJSON, &itemsJSON,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.ErrOrderNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Парсим дату создания
-	if dateCreated, err := time.Parse(time.RFC3339, dateCreatedStr); err == nil {
-		order.DateCreated = dateCreated
-	}
-
 	// Парсим JSON поля
 	if err := json.Unmarshal(deliveryJSON, &order.Delivery); err != nil {
 		return nil, err
@@ -143,8 +242,337 @@ func (db *DB) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order,
 	return &order, nil
 }
 
+// GetOrderByTrackNumber загружает заказы по трек-номеру
+// Трек-номер не уникален, поэтому возвращается список
+func (db *DB) GetOrderByTrackNumber(ctx context.Context, trackNumber string) ([]*model.Order, error) {
+	defer db.timeQuery("get_order_by_track_number")()
+
+	query := `
+	SELECT 
+	  o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
+	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+	  row_to_json(d.*),
+	  row_to_json(p.*),
+	  COALESCE(json_agg(i.*) FILTER (WHERE i.id IS NOT NULL), '[]')
+	FROM orders o
+	JOIN delivery d ON d.order_uid = o.order_uid
+	JOIN payment p ON p.order_uid = o.order_uid
+	LEFT JOIN items i ON i.order_uid = o.order_uid
+	WHERE o.track_number = $1 AND o.deleted_at IS NULL
+	GROUP BY o.order_uid, d.*, p.*
+	ORDER BY o.date_created DESC
+	`
+
+	rows, err := db.pool.Query(ctx, query, trackNumber)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrders(rows)
+}
+
+// FindOrders загружает заказы по sm_id и oof_shard
+// Без опций BySmID и ByOofShard возвращает все заказы, поэтому вызывающий
+// должен ограничивать выборку опцией Page
+func (db *DB) FindOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	defer db.timeQuery("find_orders")()
+
+	where, args := findFilter(opts)
+	query := `
+	SELECT 
+	  o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
+	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+	  row_to_json(d.*),
+	  row_to_json(p.*),
+	  COALESCE(json_agg(i.*) FILTER (WHERE i.id IS NOT NULL), '[]')
+	FROM orders o
+	JOIN delivery d ON d.order_uid = o.order_uid
+	JOIN payment p ON p.order_uid = o.order_uid
+	LEFT JOIN items i ON i.order_uid = o.order_uid
+	WHERE ` + where + `
+	GROUP BY o.order_uid, d.*, p.*
+	ORDER BY o.date_created DESC, o.order_uid
+	` + pageClause(opts)
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrders(rows)
+}
+
+// LoadOrdersAfter загружает пачку заказов после afterUID в порядке order_uid
+// Пачки читаются по первичному ключу, поэтому каждая следующая не дороже первой
+func (db *DB) LoadOrdersAfter(ctx context.Context, afterUID string, limit int, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	defer db.timeQuery("load_orders_after")()
+
+	where, args := findFilter(opts)
+	args = append(args, afterUID, limit)
+	query := `
+	SELECT 
+	  o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
+	  o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+	  row_to_json(d.*),
+	  row_to_json(p.*),
+	  COALESCE(json_agg(i.*) FILTER (WHERE i.id IS NOT NULL), '[]')
+	FROM orders o
+	JOIN delivery d ON d.order_uid = o.order_uid
+	JOIN payment p ON p.order_uid = o.order_uid
+	LEFT JOIN items i ON i.order_uid = o.order_uid
+	WHERE ` + where + fmt.Sprintf(` AND o.order_uid > $%d
+	GROUP BY o.order_uid, d.*, p.*
+	ORDER BY o.order_uid
+	LIMIT $%d`, len(args)-1, len(args))
+
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanOrders(rows)
+}
+
+// findFilter возвращает условие FindOrders и его параметры
+func findFilter(opts []interfaces.QueryOption) (string, []any) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	where := deletedFilter(opts)
+	var args []any
+	if options.SmID != 0 {
+		args = append(args, options.SmID)
+		where += fmt.Sprintf(" AND o.sm_id = $%d", len(args))
+	}
+	if options.OofShard != "" {
+		args = append(args, options.OofShard)
+		where += fmt.Sprintf(" AND o.oof_shard = $%d", len(args))
+	}
+	if !options.CreatedFrom.IsZero() {
+		args = append(args, options.CreatedFrom.UTC())
+		where += fmt.Sprintf(" AND o.date_created >= $%d", len(args))
+	}
+	if !options.CreatedTo.IsZero() {
+		args = append(args, options.CreatedTo.UTC())
+		where += fmt.Sprintf(" AND o.date_created < $%d", len(args))
+	}
+	return where, args
+}
+
+// pageClause возвращает LIMIT и OFFSET для опции Page
+func pageClause(opts []interfaces.QueryOption) string {
+	options := interfaces.ApplyQueryOptions(opts...)
+	var clause string
+	if options.Limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", options.Limit)
+	}
+	if options.Offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", options.Offset)
+	}
+	return clause
+}
+
+// scanOrders читает заказы из результата запроса и закрывает его
+// Колонки: поля orders, затем delivery, payment и items в JSON
+func scanOrders(rows pgx.Rows) ([]*model.Order, error) {
+	defer rows.Close()
+
+	orders := make([]*model.Order, 0)
+	for rows.Next() {
+		var o model.Order
+		var deliveryJSON, paymentJSON []byte
+		var itemsJSON []byte
+
+		err := rows.Scan(
+			&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
+			&deliveryJSON, &paymentJSON, &itemsJSON,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Парсим JSON данные для связанных сущностей
+		if err := json.Unmarshal(deliveryJSON, &o.Delivery); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(paymentJSON, &o.Payment); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(itemsJSON, &o.Items); err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, &o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// DeleteOrder удаляет заказ
+// В режиме soft delete строки остаются, заказ помечается deleted_at
+func (db *DB) DeleteOrder(ctx context.Context, orderUID string) error {
+	defer db.timeQuery("delete_order")()
+
+	query := `DELETE FROM orders WHERE order_uid = $1`
+	if db.softDelete {
+		query = `UPDATE orders SET deleted_at = NOW() WHERE order_uid = $1 AND deleted_at IS NULL`
+	}
+
+	tag, err := db.pool.Exec(ctx, query, orderUID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.ErrOrderNotFound
+	}
+	return nil
+}
+
+// RestoreOrder восстанавливает мягко удаленный заказ
+func (db *DB) RestoreOrder(ctx context.Context, orderUID string) error {
+	defer db.timeQuery("restore_order")()
+
+	tag, err := db.pool.Exec(ctx,
+		`UPDATE orders SET deleted_at = NULL WHERE order_uid = $1 AND deleted_at IS NOT NULL`,
+		orderUID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.ErrOrderNotFound
+	}
+	return nil
+}
+
+// GetOrderStats считает агрегированную статистику по заказам
+// Мягко удаленные заказы не учитываются
+func (db *DB) GetOrderStats(ctx context.Context, since time.Time, topServices int) (*model.OrderStats, error) {
+	defer db.timeQuery("get_order_stats")()
+
+	stats := &model.OrderStats{
+		OrdersPerDay:        make([]model.DailyOrderCount, 0),
+		TopDeliveryServices: make([]model.DeliveryServiceCount, 0),
+	}
+
+	err := db.pool.QueryRow(ctx, `
+	SELECT COUNT(*), COALESCE(SUM(p.amount), 0)
+	FROM orders o
+	LEFT JOIN payment p ON p.order_uid = o.order_uid
+	WHERE o.deleted_at IS NULL
+	`).Scan(&stats.TotalOrders, &stats.TotalRevenue)
+	if err != nil {
+		return nil, fmt.Errorf("count orders: %w", err)
+	}
+
+	rows, err := db.pool.Query(ctx, `
+	SELECT date_trunc('day', o.date_created) AS day, COUNT(*)
+	FROM orders o
+	WHERE o.deleted_at IS NULL AND o.date_created >= $1
+	GROUP BY day
+	ORDER BY day
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("count orders per day: %w", err)
+	}
+	for rows.Next() {
+		var day time.Time
+		var count int64
+		if err := rows.Scan(&day, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.OrdersPerDay = append(stats.OrdersPerDay, model.DailyOrderCount{Day: day.Format("2006-01-02"), Count: count})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.pool.Query(ctx, `
+	SELECT o.delivery_service, COUNT(*) AS orders
+	FROM orders o
+	WHERE o.deleted_at IS NULL
+	GROUP BY o.delivery_service
+	ORDER BY orders DESC, o.delivery_service
+	LIMIT $1
+	`, topServices)
+	if err != nil {
+		return nil, fmt.Errorf("count orders per delivery service: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var service model.DeliveryServiceCount
+		if err := rows.Scan(&service.Service, &service.Count); err != nil {
+			return nil, err
+		}
+		stats.TopDeliveryServices = append(stats.TopDeliveryServices, service)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// CountOrdersByBrand считает заказы, в которых есть товар бренда brand
+// Заказ с несколькими товарами бренда считается один раз, мягко удаленные не учитываются
+// Поиск по items.brand использует индекс idx_items_brand из миграции 007
+func (db *DB) CountOrdersByBrand(ctx context.Context, brand string, sampleSize int) (*model.BrandCount, error) {
+	defer db.timeQuery("count_orders_by_brand")()
+
+	count := &model.BrandCount{Brand: brand, SampleOrderUIDs: make([]string, 0)}
+
+	err := db.pool.QueryRow(ctx, `
+	SELECT COUNT(DISTINCT o.order_uid)
+	FROM items i
+	JOIN orders o ON o.order_uid = i.order_uid
+	WHERE i.brand = $1 AND o.deleted_at IS NULL
+	`, brand).Scan(&count.Orders)
+	if err != nil {
+		return nil, fmt.Errorf("count orders by brand: %w", err)
+	}
+	if sampleSize <= 0 || count.Orders == 0 {
+		return count, nil
+	}
+
+	rows, err := db.pool.Query(ctx, `
+	SELECT DISTINCT o.order_uid
+	FROM items i
+	JOIN orders o ON o.order_uid = i.order_uid
+	WHERE i.brand = $1 AND o.deleted_at IS NULL
+	ORDER BY o.order_uid
+	LIMIT $2
+	`, brand, sampleSize)
+	if err != nil {
+		return nil, fmt.Errorf("sample orders by brand: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		count.SampleOrderUIDs = append(count.SampleOrderUIDs, uid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return count, nil
+}
+
 // SaveOrder сохраняет заказ в БД
+// Ошибки Postgres классифицируются: сбой сериализации и deadlock можно повторить,
+// нарушение ограничений помечается постоянной ошибкой, см. classifyError
 func (db *DB) SaveOrder(ctx context.Context, order *model.Order) error {
+	defer db.timeQuery("save_order")()
+
+	return classifyError(db.saveOrder(ctx, order))
+}
+
+// saveOrder сохраняет заказ в одной транзакции
+// Ошибка commit возвращается: сбой сериализации часто приходит именно на нем
+func (db *DB) saveOrder(ctx context.Context, order *model.Order) (err error) {
 	// Небольшие проверки входных данных чтобы не писать мусор
 	if order == nil {
 		return errors.New("order is nil")
@@ -160,11 +588,33 @@ func (db *DB) SaveOrder(ctx context.Context, order *model.Order) error {
 	defer func() {
 		if err != nil {
 			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
+			return
 		}
+		err = tx.Commit(ctx)
 	}()
 
+	// date_created - TIMESTAMP без часового пояса, pgx отбрасывает смещение, поэтому пишем UTC.
+	// При чтении pgx возвращает такое время в UTC, и заказ загружается с тем же моментом.
+	// Повторно пришедший мягко удаленный заказ заменяется целиком, как в upsertOrder:
+	// иначе вернулись бы старые поля заказа, а товары записались бы второй раз
+	tag, err := tx.Exec(ctx, `
+		UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+			customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10,
+			oof_shard = $11, deleted_at = NULL
+		WHERE order_uid = $1 AND deleted_at IS NOT NULL`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated.UTC(), order.OofShard)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() > 0 {
+		if err = deleteOrderDetails(ctx, tx, order.OrderUID); err != nil {
+			return err
+		}
+		return insertOrderDetails(ctx, tx, order)
+	}
+
 	// Сохраняем основную информацию о заказе
 	_, err = tx.Exec(ctx, `
 		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, 
@@ -172,13 +622,104 @@ func (db *DB) SaveOrder(ctx context.Context, order *model.Order) error {
 		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) 
 		ON CONFLICT (order_uid) DO NOTHING`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard)
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated.UTC(), order.OofShard)
 	if err != nil {
 		return err
 	}
 
+	return insertOrderDetails(ctx, tx, order)
+}
+
+// UpsertOrder сохраняет заказ из compacted топика, где каждое сообщение - полная версия заказа
+// Версия - offset сообщения Kafka: сообщения одного заказа лежат в одной партиции,
+// поэтому больший offset означает более позднюю версию. Дата заказа приходит от клиента
+// и для упорядочивания версий не годится.
+// Если сохранена версия не старше, заказ заменяется целиком: доставка, оплата и товары
+// удаляются и записываются заново в той же транзакции. Возвращает false, если
+// сохраненная версия новее и заказ не изменен
+func (db *DB) UpsertOrder(ctx context.Context, order *model.Order, version int64) (bool, error) {
+	defer db.timeQuery("upsert_order")()
+
+	saved, err := db.upsertOrder(ctx, order, version)
+	return saved, classifyError(err)
+}
+
+// upsertOrder заменяет заказ в одной транзакции, если version не меньше сохраненной
+func (db *DB) upsertOrder(ctx context.Context, order *model.Order, version int64) (saved bool, err error) {
+	if order == nil {
+		return false, errors.New("order is nil")
+	}
+	if order.OrderUID == "" {
+		return false, errors.New("order uid is empty")
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+			return
+		}
+		err = tx.Commit(ctx)
+	}()
+
+	// Строка без kafka_offset записана до включения compacted режима и считается старше.
+	// Та же версия перезаписывается: повторная доставка сообщения дает тот же результат.
+	// Новая версия мягко удаленного заказа снимает отметку deleted_at
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, kafka_offset)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+		ON CONFLICT (order_uid) DO UPDATE SET
+			track_number = EXCLUDED.track_number,
+			entry = EXCLUDED.entry,
+			locale = EXCLUDED.locale,
+			internal_signature = EXCLUDED.internal_signature,
+			customer_id = EXCLUDED.customer_id,
+			delivery_service = EXCLUDED.delivery_service,
+			shardkey = EXCLUDED.shardkey,
+			sm_id = EXCLUDED.sm_id,
+			date_created = EXCLUDED.date_created,
+			oof_shard = EXCLUDED.oof_shard,
+			kafka_offset = EXCLUDED.kafka_offset,
+			deleted_at = NULL
+		WHERE orders.kafka_offset IS NULL OR orders.kafka_offset <= EXCLUDED.kafka_offset`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated.UTC(),
+		order.OofShard, version)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err = deleteOrderDetails(ctx, tx, order.OrderUID); err != nil {
+		return false, err
+	}
+
+	if err = insertOrderDetails(ctx, tx, order); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteOrderDetails удаляет товары, оплату и доставку заказа перед повторной записью
+func deleteOrderDetails(ctx context.Context, tx pgx.Tx, orderUID string) error {
+	for _, table := range []string{"items", "payment", "delivery"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE order_uid = $1`, orderUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertOrderDetails записывает доставку, оплату и товары заказа
+func insertOrderDetails(ctx context.Context, tx pgx.Tx, order *model.Order) error {
 	// Сохраняем информацию о доставке
-	_, err = tx.Exec(ctx, `
+	_, err := tx.Exec(ctx, `
 		INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email) 
 		VALUES ($1,$2,$3,$4,$5,$6,$7,$8) 
 		ON CONFLICT (order_uid) DO NOTHING`,
@@ -189,11 +730,13 @@ func (db *DB) SaveOrder(ctx context.Context, order *model.Order) error {
 	}
 
 	// Сохраняем информацию об оплате
+	// У заказа одна оплата (миграция 005): повторная доставка заказа ничего не меняет,
+	// а transaction, уже занятый другим заказом, - нарушение ограничения и уходит в DLQ
 	_, err = tx.Exec(ctx, `
 		INSERT INTO payment (transaction, order_uid, request_id, currency, provider, 
 			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) 
 		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) 
-		ON CONFLICT (transaction) DO NOTHING`,
+		ON CONFLICT (order_uid) DO NOTHING`,
 		order.Payment.Transaction, order.OrderUID, order.Payment.RequestID, order.Payment.Currency,
 		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
 		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
@@ -202,7 +745,7 @@ func (db *DB) SaveOrder(ctx context.Context, order *model.Order) error {
 	}
 
 	// Сохраняем товары заказа
-	for _, item := range order.Items {
+	for i, item := range order.Items {
 		_, err = tx.Exec(ctx, `
 			INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, 
 				sale, size, total_price, nm_id, brand, status) 
@@ -210,7 +753,7 @@ func (db *DB) SaveOrder(ctx context.Context, order *model.Order) error {
 			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid,
 			item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
 		if err != nil {
-			return err
+			return &ItemInsertError{Index: i, Rid: item.Rid, Kind: itemErrorKind(err), Err: err}
 		}
 	}
 
@@ -2,10 +2,19 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/interfaces"
 	"wbtest/internal/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v3"
 )
 
 // MockDB для тестирования
@@ -451,3 +460,374 @@ func TestOrderRepository_Close(t *testing.T) {
 	// Тест на то, что Close не падает (Close() не возвращает error)
 	repo.Close()
 }
+
+func TestPartitionFilter(t *testing.T) {
+	if got := partitionFilter(nil); got != "TRUE" {
+		t.Errorf("Expected no filter without partition, got %q", got)
+	}
+
+	got := partitionFilter([]interfaces.QueryOption{interfaces.Partition(2, 4)})
+	if want := "abs(hashtext(o.order_uid)::bigint) % 4 = 2"; got != want {
+		t.Errorf("partitionFilter() = %q, want %q", got, want)
+	}
+}
+
+func TestFindFilter(t *testing.T) {
+	where, args := findFilter(nil)
+	if where != "o.deleted_at IS NULL" || len(args) != 0 {
+		t.Errorf("findFilter(nil) = %q %v, want only deleted filter", where, args)
+	}
+
+	where, args = findFilter([]interfaces.QueryOption{interfaces.BySmID(99), interfaces.ByOofShard("1")})
+	if want := "o.deleted_at IS NULL AND o.sm_id = $1 AND o.oof_shard = $2"; where != want {
+		t.Errorf("findFilter() = %q, want %q", where, want)
+	}
+	if len(args) != 2 || args[0] != 99 || args[1] != "1" {
+		t.Errorf("findFilter() args = %v, want [99 1]", args)
+	}
+
+	where, args = findFilter([]interfaces.QueryOption{interfaces.ByOofShard("2")})
+	if want := "o.deleted_at IS NULL AND o.oof_shard = $1"; where != want || len(args) != 1 {
+		t.Errorf("findFilter() = %q %v, want %q", where, args, want)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	where, args = findFilter([]interfaces.QueryOption{interfaces.CreatedBetween(from, to)})
+	if want := "o.deleted_at IS NULL AND o.date_created >= $1 AND o.date_created < $2"; where != want {
+		t.Errorf("findFilter() = %q, want %q", where, want)
+	}
+	if len(args) != 2 || args[0] != from || args[1] != to {
+		t.Errorf("findFilter() args = %v, want [%v %v]", args, from, to)
+	}
+
+	where, args = findFilter([]interfaces.QueryOption{interfaces.CreatedBetween(time.Time{}, to)})
+	if want := "o.deleted_at IS NULL AND o.date_created < $1"; where != want || len(args) != 1 {
+		t.Errorf("findFilter() = %q %v, want %q", where, args, want)
+	}
+}
+
+func TestPageClause(t *testing.T) {
+	if got := pageClause(nil); got != "" {
+		t.Errorf("Expected no page clause, got %q", got)
+	}
+	got := pageClause([]interfaces.QueryOption{interfaces.Page(50, 100)})
+	if want := " LIMIT 50 OFFSET 100"; got != want {
+		t.Errorf("pageClause() = %q, want %q", got, want)
+	}
+}
+
+func newPgxmockDB(t *testing.T) (*DB, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("Failed to create pgxmock pool: %v", err)
+	}
+	t.Cleanup(mock.Close)
+	return newWithPool(mock), mock
+}
+
+// anyArgs возвращает n аргументов, совпадающих с любым значением
+func anyArgs(n int) []interface{} {
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = pgxmock.AnyArg()
+	}
+	return args
+}
+
+func TestDB_SaveOrder_Statements(t *testing.T) {
+	db, mock := newPgxmockDB(t)
+
+	order := &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Delivery:    model.Delivery{Name: "Test Testov"},
+		Payment:     model.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Amount: 1817},
+		Items: []model.Item{
+			{ChrtID: 9934930, Price: 453, Name: "Mascaras"},
+			{ChrtID: 9934931, Price: 100, Name: "Brush"},
+		},
+	}
+
+	mock.ExpectBegin()
+	// Мягко удаленного заказа с таким номером нет, заказ вставляется
+	mock.ExpectExec(`UPDATE orders SET .* deleted_at = NULL\s+WHERE order_uid = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(anyArgs(11)...).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectExec(`INSERT INTO orders .* ON CONFLICT \(order_uid\) DO NOTHING`).
+		WithArgs(order.OrderUID, order.TrackNumber, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO delivery").
+		WithArgs(order.OrderUID, "Test Testov", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO payment").
+		WithArgs("b563feb7b2b84b6test", order.OrderUID, pgxmock.AnyArg(), "USD", pgxmock.AnyArg(),
+			1817, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	for _, item := range order.Items {
+		mock.ExpectExec("INSERT INTO items").
+			WithArgs(order.OrderUID, item.ChrtID, pgxmock.AnyArg(), item.Price, pgxmock.AnyArg(), item.Name,
+				pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	}
+	mock.ExpectCommit()
+
+	if err := db.SaveOrder(context.Background(), order); err != nil {
+		t.Fatalf("SaveOrder() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDB_SaveOrder_ReplacesSoftDeletedOrder(t *testing.T) {
+	db, mock := newPgxmockDB(t)
+
+	order := &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMNEWTRACK",
+		Items:       []model.Item{{ChrtID: 9934930}, {ChrtID: 9934931}},
+	}
+
+	// Заказ восстанавливается с новыми полями, товары старой версии удаляются
+	// до вставки, поэтому в заказе остаются только два новых товара
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE orders SET .* deleted_at = NULL\s+WHERE order_uid = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(append([]interface{}{order.OrderUID, "WBILMNEWTRACK"}, anyArgs(9)...)...).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("DELETE FROM items").WithArgs(order.OrderUID).WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectExec("DELETE FROM payment").WithArgs(order.OrderUID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DELETE FROM delivery").WithArgs(order.OrderUID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("INSERT INTO delivery").WithArgs(anyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO payment").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	for _, item := range order.Items {
+		mock.ExpectExec("INSERT INTO items").
+			WithArgs(append([]interface{}{order.OrderUID, item.ChrtID}, anyArgs(10)...)...).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	}
+	mock.ExpectCommit()
+
+	if err := db.SaveOrder(context.Background(), order); err != nil {
+		t.Fatalf("SaveOrder() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDB_SaveOrder_RollbackOnError(t *testing.T) {
+	db, mock := newPgxmockDB(t)
+
+	order := &model.Order{OrderUID: "b563feb7b2b84b6test", Items: []model.Item{{ChrtID: 1}}}
+	insertErr := errors.New("connection reset")
+
+	// Аргументы не проверяются: важен только откат транзакции после ошибки
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectExec("INSERT INTO orders").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO delivery").WithArgs(anyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO payment").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO items").WithArgs(anyArgs(12)...).WillReturnError(insertErr)
+	mock.ExpectRollback()
+
+	if err := db.SaveOrder(context.Background(), order); !errors.Is(err, insertErr) {
+		t.Fatalf("Expected insert error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDB_UpsertOrder_ReplacesOrder(t *testing.T) {
+	db, mock := newPgxmockDB(t)
+
+	order := &model.Order{OrderUID: "b563feb7b2b84b6test", Items: []model.Item{{ChrtID: 1}}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO orders .* ON CONFLICT \(order_uid\) DO UPDATE SET .* deleted_at = NULL\s+WHERE orders.kafka_offset IS NULL OR orders.kafka_offset <= EXCLUDED.kafka_offset`).
+		WithArgs(append(anyArgs(11), int64(42))...).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("DELETE FROM items").WithArgs(order.OrderUID).WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectExec("DELETE FROM payment").WithArgs(order.OrderUID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DELETE FROM delivery").WithArgs(order.OrderUID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("INSERT INTO delivery").WithArgs(anyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO payment").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO items").WithArgs(anyArgs(12)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	saved, err := db.UpsertOrder(context.Background(), order, 42)
+	if err != nil {
+		t.Fatalf("UpsertOrder() error = %v", err)
+	}
+	if !saved {
+		t.Error("Expected order to be saved")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDB_UpsertOrder_SkipsOlderVersion(t *testing.T) {
+	db, mock := newPgxmockDB(t)
+
+	order := &model.Order{OrderUID: "b563feb7b2b84b6test", Items: []model.Item{{ChrtID: 1}}}
+
+	// Сохранена версия с большим offset: строка не обновлена, остальные таблицы не трогаем
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WithArgs(anyArgs(12)...).WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectCommit()
+
+	saved, err := db.UpsertOrder(context.Background(), order, 7)
+	if err != nil {
+		t.Fatalf("UpsertOrder() error = %v", err)
+	}
+	if saved {
+		t.Error("Expected older version to be skipped")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDB_SaveOrder_ItemInsertError(t *testing.T) {
+	order := &model.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Items: []model.Item{
+			{ChrtID: 1, Rid: "rid-0"},
+			{ChrtID: 2, Rid: "rid-1"},
+			{ChrtID: 3, Rid: "rid-2"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		insertErr error
+		kind      ItemErrorKind
+		permanent bool
+	}{
+		{
+			name:      "constraint violation",
+			insertErr: &pgconn.PgError{Code: "23514", ConstraintName: "items_price_check"},
+			kind:      ItemErrorConstraint,
+			permanent: true,
+		},
+		{
+			name:      "connection lost",
+			insertErr: fmt.Errorf("write failed: %w", io.ErrUnexpectedEOF),
+			kind:      ItemErrorConnection,
+		},
+		{
+			name:      "serialization failure",
+			insertErr: &pgconn.PgError{Code: "40001"},
+			kind:      ItemErrorDatabase,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newPgxmockDB(t)
+
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE orders").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			mock.ExpectExec("INSERT INTO orders").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mock.ExpectExec("INSERT INTO delivery").WithArgs(anyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mock.ExpectExec("INSERT INTO payment").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mock.ExpectExec("INSERT INTO items").WithArgs(anyArgs(12)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mock.ExpectExec("INSERT INTO items").WithArgs(anyArgs(12)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mock.ExpectExec("INSERT INTO items").WithArgs(anyArgs(12)...).WillReturnError(tt.insertErr)
+			mock.ExpectRollback()
+
+			err := db.SaveOrder(context.Background(), order)
+
+			var itemErr *ItemInsertError
+			if !errors.As(err, &itemErr) {
+				t.Fatalf("Expected ItemInsertError, got %v", err)
+			}
+			if itemErr.Index != 2 || itemErr.Rid != "rid-2" {
+				t.Errorf("Expected items[2] with rid-2, got items[%d] with %q", itemErr.Index, itemErr.Rid)
+			}
+			if itemErr.Kind != tt.kind {
+				t.Errorf("Kind = %q, want %q", itemErr.Kind, tt.kind)
+			}
+			if !errors.Is(err, tt.insertErr) {
+				t.Error("Expected error to wrap insert error")
+			}
+			if !strings.Contains(err.Error(), `items[2] (rid "rid-2")`) {
+				t.Errorf("Expected message to name the item, got %q", err.Error())
+			}
+			if got := apperrors.IsPermanent(err); got != tt.permanent {
+				t.Errorf("IsPermanent() = %v, want %v", got, tt.permanent)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+// capturedArg совпадает с любым аргументом и запоминает его значение
+type capturedArg struct {
+	value interface{}
+}
+
+func (a *capturedArg) Match(v interface{}) bool {
+	a.value = v
+	return true
+}
+
+func TestDB_DateCreatedRoundTrip(t *testing.T) {
+	db, mock := newPgxmockDB(t)
+
+	created := time.Date(2021, 11, 26, 9, 22, 19, 123456000, time.FixedZone("MSK", 3*60*60))
+	order := &model.Order{OrderUID: "b563feb7b2b84b6test", DateCreated: created}
+
+	saved := &capturedArg{}
+	args := anyArgs(11)
+	args[9] = saved
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectExec("INSERT INTO orders").WithArgs(args...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO delivery").WithArgs(anyArgs(8)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO payment").WithArgs(anyArgs(11)...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	if err := db.SaveOrder(context.Background(), order); err != nil {
+		t.Fatalf("SaveOrder() error = %v", err)
+	}
+
+	// TIMESTAMP без часового пояса хранит время как есть, поэтому записываться должно UTC
+	savedTime, ok := saved.value.(time.Time)
+	if !ok {
+		t.Fatalf("date_created saved as %T, want time.Time", saved.value)
+	}
+	if savedTime.Location() != time.UTC || !savedTime.Equal(created) {
+		t.Fatalf("date_created saved as %v, want %v in UTC", savedTime, created)
+	}
+
+	// Колонка читается как время, а не как текст
+	mock.ExpectQuery("SELECT").WithArgs(order.OrderUID).WillReturnRows(
+		pgxmock.NewRows([]string{
+			"order_uid", "track_number", "entry", "locale", "internal_signature",
+			"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+			"delivery", "payment", "items",
+		}).AddRow(
+			order.OrderUID, "", "", "", "", "", "", "", 0, savedTime, "",
+			[]byte(`{}`), []byte(`{}`), []byte(`[]`),
+		),
+	)
+
+	loaded, err := db.GetOrderByUID(context.Background(), order.OrderUID)
+	if err != nil {
+		t.Fatalf("GetOrderByUID() error = %v", err)
+	}
+	if !loaded.DateCreated.Equal(created) {
+		t.Errorf("DateCreated = %v, want %v", loaded.DateCreated, created)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet expectations: %v", err)
+	}
+}
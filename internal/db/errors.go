@@ -0,0 +1,100 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	apperrors "wbtest/internal/errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды ошибок Postgres, см. https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	// pgIntegrityViolationClass класс 23: unique, foreign key, not null, check
+	pgIntegrityViolationClass = "23"
+	// pgInvalidAuthorizationClass класс 28: неверный пароль или нет доступа
+	pgInvalidAuthorizationClass = "28"
+	pgInvalidCatalogName        = "3D000"
+)
+
+// IsRetryable сообщает, что транзакция прервана конкурентной записью
+// и может успешно выполниться при повторе
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected
+}
+
+// IsConstraintViolation сообщает, что запись нарушает ограничение целостности
+func IsConstraintViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, pgIntegrityViolationClass)
+}
+
+// ItemErrorKind причина ошибки вставки товара заказа
+type ItemErrorKind string
+
+const (
+	// ItemErrorConstraint товар нарушает ограничение целостности, повтор не поможет
+	ItemErrorConstraint ItemErrorKind = "constraint violation"
+	// ItemErrorConnection соединение с БД прервано или истек таймаут, повтор может пройти
+	ItemErrorConnection ItemErrorKind = "connection error"
+	// ItemErrorDatabase прочие ошибки Postgres, например сбой сериализации
+	ItemErrorDatabase ItemErrorKind = "database error"
+)
+
+// ItemInsertError ошибка вставки одного товара заказа
+// Транзакция откатывается целиком, ошибка показывает какой товар и почему не записан
+type ItemInsertError struct {
+	// Index позиция товара в order.Items
+	Index int
+	Rid   string
+	Kind  ItemErrorKind
+	Err   error
+}
+
+func (e *ItemInsertError) Error() string {
+	return fmt.Sprintf("insert items[%d] (rid %q): %s: %v", e.Index, e.Rid, e.Kind, e.Err)
+}
+
+func (e *ItemInsertError) Unwrap() error {
+	return e.Err
+}
+
+// itemErrorKind определяет причину ошибки вставки товара
+func itemErrorKind(err error) ItemErrorKind {
+	if IsConstraintViolation(err) {
+		return ItemErrorConstraint
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return ItemErrorDatabase
+	}
+	var netErr net.Error
+	if pgconn.Timeout(err) || pgconn.SafeToRetry(err) || errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return ItemErrorConnection
+	}
+	return ItemErrorDatabase
+}
+
+// classifyError помечает нарушение ограничений постоянной ошибкой:
+// повтор записи тех же данных снова упадет, сообщение должно уйти в DLQ
+// Остальные ошибки, включая сбой сериализации, возвращаются как есть и повторяются
+func classifyError(err error) error {
+	if !IsConstraintViolation(err) {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	errors.As(err, &pgErr)
+	return apperrors.Permanent(fmt.Errorf("constraint %s violated: %w", pgErr.ConstraintName, err))
+}
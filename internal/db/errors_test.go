@@ -0,0 +1,60 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	apperrors "wbtest/internal/errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", fmt.Errorf("insert items: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"plain error", errors.New("connection refused"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.expected {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Run("constraint violation is permanent", func(t *testing.T) {
+		pgErr := &pgconn.PgError{Code: "23505", ConstraintName: "payment_pkey"}
+		err := classifyError(pgErr)
+
+		if !apperrors.IsPermanent(err) {
+			t.Errorf("Expected permanent error, got %v", err)
+		}
+		if !errors.Is(err, pgErr) {
+			t.Error("Expected classified error to wrap pg error")
+		}
+	})
+
+	t.Run("serialization failure stays retryable", func(t *testing.T) {
+		err := classifyError(&pgconn.PgError{Code: "40001"})
+		if apperrors.IsPermanent(err) {
+			t.Error("Expected serialization failure not to be permanent")
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		if err := classifyError(nil); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	})
+}
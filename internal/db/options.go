@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"wbtest/internal/config"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/retry"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// connectPingTimeout ограничение одной проверки подключения в New
+const connectPingTimeout = 5 * time.Second
+
+// Option настраивает подключение к БД до создания пула
+type Option func(*options)
+
+// options настройки New: конфигурация пула и проверка подключения
+type options struct {
+	pool *pgxpool.Config
+	// connectRetry повторы Ping в New, MaxAttempts 0 - подключение не проверяется
+	connectRetry config.RetryConfig
+}
+
+// WithStatementTimeout задает statement_timeout каждому подключению пула
+// Postgres сам прерывает запросы дольше timeout, даже если клиент не отменил контекст
+// Значение <= 0 оставляет настройку сервера
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout <= 0 {
+			return
+		}
+		o.pool.AfterConnect = statementTimeoutHook(o.pool.AfterConnect, timeout)
+	}
+}
+
+// statementTimeoutHook возвращает AfterConnect: после next выполняет SET statement_timeout
+// Подключение параметризовано, чтобы хук проверялся на pgxmock вместо *pgx.Conn
+func statementTimeoutHook[C execer](next func(context.Context, C) error, timeout time.Duration) func(context.Context, C) error {
+	return func(ctx context.Context, conn C) error {
+		if next != nil {
+			if err := next(ctx, conn); err != nil {
+				return err
+			}
+		}
+		return setStatementTimeout(ctx, conn, timeout)
+	}
+}
+
+// WithConnectRetry проверяет подключение в New: Ping повторяется до attempts раз
+// с экспоненциальной задержкой от initialDelay до maxDelay, и только потом New возвращает ошибку
+// Без опции pgxpool подключается лениво, и недоступная БД видна только на первом запросе
+// attempts <= 0 оставляет ленивое подключение
+func WithConnectRetry(attempts int, initialDelay, maxDelay time.Duration) Option {
+	return func(o *options) {
+		o.connectRetry = config.RetryConfig{
+			MaxAttempts:  max(attempts, 0),
+			InitialDelay: initialDelay,
+			MaxDelay:     maxDelay,
+			Multiplier:   2,
+		}
+	}
+}
+
+// pinger проверяет подключение, *pgxpool.Pool
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// pingWithRetry проверяет подключение с повторами по cfg
+// Неверные учетные данные или имя БД не исправятся повтором, на них попытки прекращаются
+func pingWithRetry(p pinger, cfg config.RetryConfig) error {
+	attempt := 0
+	return retry.NewRetryService(&cfg).ExecuteWithRetry(func() error {
+		attempt++
+		ctx, cancel := context.WithTimeout(context.Background(), connectPingTimeout)
+		defer cancel()
+
+		err := p.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if isFatalConnectError(err) {
+			return apperrors.Permanent(err)
+		}
+		logrus.Warnf("Database ping attempt %d/%d failed: %v", attempt, cfg.MaxAttempts, err)
+		return err
+	})
+}
+
+// isFatalConnectError сообщает, что сервер отклонил подключение из-за настроек клиента:
+// класс 28 - ошибка авторизации, 3D000 - базы данных нет
+func isFatalConnectError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, pgInvalidAuthorizationClass) || pgErr.Code == pgInvalidCatalogName
+}
+
+// execer выполняет команду без результата, *pgx.Conn или pgxmock в тестах
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// setStatementTimeout выполняет SET statement_timeout для подключения
+// SET не принимает параметры запроса, поэтому значение в миллисекундах подставляется в текст
+func setStatementTimeout(ctx context.Context, conn execer, timeout time.Duration) error {
+	if _, err := conn.Exec(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set statement_timeout: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build integration
+// +build integration
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"wbtest/internal/config"
+	"wbtest/internal/model"
+)
+
+// TestPaymentUniqueOrderUID проверяет ограничение payment_order_uid_key из миграции 005
+func TestPaymentUniqueOrderUID(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dbConn, err := New(cfg.DatabaseURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	uid := fmt.Sprintf("payment-unique-%d", time.Now().UnixNano())
+	order := &model.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + uid,
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    "Test User",
+			Phone:   "+1234567890",
+			Email:   "test@example.com",
+			City:    "Test City",
+			Address: "Test Address",
+			Region:  "Test Region",
+			Zip:     "12345",
+		},
+		Payment: model.Payment{
+			Transaction: uid,
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      1000,
+			PaymentDT:   1637907727,
+			Bank:        "alpha",
+			GoodsTotal:  1000,
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Now().UTC(),
+		OofShard:        "1",
+	}
+	defer func() {
+		_, _ = dbConn.DB.Exec(context.Background(), "DELETE FROM orders WHERE order_uid = $1", uid)
+	}()
+
+	if err := dbConn.SaveOrder(ctx, order); err != nil {
+		t.Fatalf("SaveOrder failed: %v", err)
+	}
+
+	// Повторная доставка того же заказа идемпотентна
+	if err := dbConn.SaveOrder(ctx, order); err != nil {
+		t.Fatalf("SaveOrder redelivery failed: %v", err)
+	}
+
+	// Вторая оплата для того же заказа отклоняется базой
+	_, err = dbConn.DB.Exec(ctx, `
+		INSERT INTO payment (transaction, order_uid, currency, provider, amount, payment_dt, bank, goods_total)
+		VALUES ($1, $2, 'USD', 'wbpay', 1000, 1637907727, 'alpha', 1000)`,
+		uid+"-second", uid)
+	if err == nil {
+		t.Fatal("Expected unique violation for second payment")
+	}
+	if !IsConstraintViolation(err) {
+		t.Errorf("Expected constraint violation, got %v", err)
+	}
+}
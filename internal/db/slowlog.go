@@ -0,0 +1,65 @@
+package db
+
+import (
+	"time"
+
+	"wbtest/internal/clock"
+	"wbtest/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithSlowQueryLog включает предупреждения в лог о запросах дольше threshold
+// threshold <= 0 выключает их, logger nil оставляет стандартный logrus
+func (db *DB) WithSlowQueryLog(threshold time.Duration, logger logrus.FieldLogger) *DB {
+	db.slowQueryThreshold = threshold
+	if logger != nil {
+		db.logger = logger
+	}
+	return db
+}
+
+// WithMetrics включает метрики длительности запросов
+func (db *DB) WithMetrics(m *metrics.Metrics) *DB {
+	db.metrics = m
+	return db
+}
+
+// WithClock задает источник времени для замера запросов
+func (db *DB) WithClock(c clock.Clock) *DB {
+	db.clock = c
+	return db
+}
+
+// timeQuery начинает замер запроса, возвращенная функция его завершает:
+//
+//	defer db.timeQuery("get_order_by_uid")()
+//
+// Длительность пишется в метрику, запрос дольше slowQueryThreshold - в лог
+func (db *DB) timeQuery(operation string) func() {
+	if db.clock == nil {
+		return func() {}
+	}
+	start := db.clock.Now()
+
+	return func() {
+		elapsed := db.clock.Now().Sub(start)
+		if db.metrics != nil {
+			db.metrics.DatabaseQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		}
+		if db.slowQueryThreshold <= 0 || elapsed < db.slowQueryThreshold {
+			return
+		}
+
+		if db.metrics != nil {
+			db.metrics.DatabaseSlowQueries.WithLabelValues(operation).Inc()
+		}
+		if db.logger != nil {
+			db.logger.WithFields(logrus.Fields{
+				"operation": operation,
+				"duration":  elapsed.String(),
+				"threshold": db.slowQueryThreshold.String(),
+			}).Warn("Slow database query")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"wbtest/internal/clock"
+	"wbtest/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestDB_TimeQuery_SlowQueryLog(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+	logger, hook := logtest.NewNullLogger()
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	db := (&DB{}).WithClock(fakeClock).WithSlowQueryLog(100*time.Millisecond, logger).WithMetrics(m)
+
+	// Быстрый запрос не логируется
+	done := db.timeQuery("get_order_by_uid")
+	fakeClock.Advance(50 * time.Millisecond)
+	done()
+	if len(hook.AllEntries()) != 0 {
+		t.Fatalf("Expected no log for fast query, got %d entries", len(hook.AllEntries()))
+	}
+
+	// Медленный запрос
+	done = db.timeQuery("save_order")
+	fakeClock.Advance(250 * time.Millisecond)
+	done()
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("Expected slow query to be logged")
+	}
+	if entry.Level != logrus.WarnLevel {
+		t.Errorf("Expected warn level, got %s", entry.Level)
+	}
+	if entry.Data["operation"] != "save_order" || entry.Data["duration"] != "250ms" {
+		t.Errorf("Expected operation and duration fields, got %v", entry.Data)
+	}
+
+	if got := testutil.ToFloat64(m.DatabaseSlowQueries.WithLabelValues("save_order")); got != 1 {
+		t.Errorf("Expected 1 slow save_order query, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.DatabaseSlowQueries.WithLabelValues("get_order_by_uid")); got != 0 {
+		t.Errorf("Expected no slow get_order_by_uid queries, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.DatabaseQueryDuration); got != 2 {
+		t.Errorf("Expected durations for 2 operations, got %d", got)
+	}
+}
+
+func TestDB_TimeQuery_Disabled(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+	logger, hook := logtest.NewNullLogger()
+	db := (&DB{}).WithClock(fakeClock).WithSlowQueryLog(0, logger)
+
+	done := db.timeQuery("load_all_orders")
+	fakeClock.Advance(time.Hour)
+	done()
+
+	if len(hook.AllEntries()) != 0 {
+		t.Errorf("Expected no log with threshold 0, got %d entries", len(hook.AllEntries()))
+	}
+
+	// Без часов замер не выполняется
+	(&DB{}).timeQuery("load_all_orders")()
+}
@@ -0,0 +1,128 @@
+//go:build integration
+// +build integration
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"wbtest/internal/config"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/model"
+)
+
+// TestDateCreatedRoundTrip проверяет, что date_created с произвольным смещением
+// загружается из БД тем же моментом и одинаково сериализуется до и после сохранения
+func TestDateCreatedRoundTrip(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	dbConn, err := New(cfg.DatabaseURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	uid := fmt.Sprintf("time-%d", time.Now().UnixNano())
+	// Смещение отличается от UTC, точность БД - микросекунды
+	created := time.Date(2021, 11, 26, 9, 22, 19, 123456000, time.FixedZone("MSK", 3*60*60))
+	order := &model.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + uid,
+		Entry:       "WBIL",
+		Payment: model.Payment{
+			Transaction: uid,
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      1000,
+			PaymentDT:   1637907727,
+			Bank:        "alpha",
+			GoodsTotal:  1000,
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		SmID:            99,
+		DateCreated:     created,
+	}
+	defer func() {
+		_, _ = dbConn.DB.Exec(context.Background(), "DELETE FROM orders WHERE order_uid = $1", uid)
+	}()
+
+	if err := dbConn.SaveOrder(ctx, order); err != nil {
+		t.Fatalf("SaveOrder failed: %v", err)
+	}
+
+	loaded, err := dbConn.GetOrderByUID(ctx, uid)
+	if err != nil {
+		t.Fatalf("GetOrderByUID failed: %v", err)
+	}
+	if !loaded.DateCreated.Equal(created) {
+		t.Errorf("GetOrderByUID: DateCreated = %v, want %v", loaded.DateCreated, created)
+	}
+
+	// Все пути чтения возвращают одно и то же время
+	all, err := dbConn.LoadAllOrders(ctx)
+	if err != nil {
+		t.Fatalf("LoadAllOrders failed: %v", err)
+	}
+	found := false
+	for _, o := range all {
+		if o.OrderUID == uid {
+			found = true
+			if !o.DateCreated.Equal(created) {
+				t.Errorf("LoadAllOrders: DateCreated = %v, want %v", o.DateCreated, created)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("LoadAllOrders: order %s not found", uid)
+	}
+
+	window := interfaces.CreatedBetween(created.Add(-time.Second), created.Add(time.Second))
+	batch, err := dbConn.LoadOrdersAfter(ctx, "", 1000, window)
+	if err != nil {
+		t.Fatalf("LoadOrdersAfter failed: %v", err)
+	}
+	found = false
+	for _, o := range batch {
+		if o.OrderUID == uid {
+			found = true
+			if !o.DateCreated.Equal(created) {
+				t.Errorf("LoadOrdersAfter: DateCreated = %v, want %v", o.DateCreated, created)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("LoadOrdersAfter: order %s not found in date_created window", uid)
+	}
+
+	// JSON заказа до сохранения и после загрузки совпадает
+	before, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	after, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var beforeFields, afterFields map[string]json.RawMessage
+	if err := json.Unmarshal(before, &beforeFields); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(after, &afterFields); err != nil {
+		t.Fatal(err)
+	}
+	if string(beforeFields["date_created"]) != string(afterFields["date_created"]) {
+		t.Errorf("date_created JSON = %s after load, want %s", afterFields["date_created"], beforeFields["date_created"])
+	}
+}
@@ -3,42 +3,185 @@ package dlq
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"wbtest/internal/config"
 	"wbtest/internal/interfaces"
+	kafkaproducer "wbtest/internal/kafka"
+	"wbtest/internal/model"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// Категории причин отправки в DLQ
+// Причина передается в формате "<категория>: <детали ошибки>"
+const (
+	ReasonParseError       = "parse_error"
+	ReasonValidationFailed = "validation_failed"
+	ReasonDBError          = "db_error"
+	// ReasonConstraintViolation запись нарушает ограничение БД, повтор не поможет
+	ReasonConstraintViolation = "constraint_violation"
+	// ReasonHookRejected заказ отклонен хуком обработки; хук может зависеть от внешнего
+	// сервиса, поэтому повтор допускается
+	ReasonHookRejected = "hook_rejected"
+	// ReasonPanic обработчик запаниковал на сообщении; повтор скорее всего снова упадет,
+	// сообщение ждет исправления кода
+	ReasonPanic = "panic"
+)
+
+// HeaderRetryCount заголовок сообщения, возвращенного из DLQ в основной топик:
+// номер попытки. Если сообщение снова не обработано, SendToDLQ продолжает счет с него,
+// и после MaxRetries попыток сообщение паркуется, а не ходит по кругу
+const HeaderRetryCount = "retry-count"
+
+// ReasonCategory возвращает категорию из причины вида "<категория>: <детали>"
+func ReasonCategory(reason string) string {
+	category, _, _ := strings.Cut(reason, ":")
+	return strings.TrimSpace(category)
+}
+
+// IsPermanentReason сообщает, что повторная обработка сообщения не поможет
+func IsPermanentReason(reason string) bool {
+	switch ReasonCategory(reason) {
+	case ReasonParseError, ReasonValidationFailed, ReasonConstraintViolation, ReasonPanic:
+		return true
+	default:
+		return false
+	}
+}
+
+// DLQMessageVersion текущая версия формата DLQMessage
+// Сообщения без версии записаны до ее появления и считаются версией 1
+const DLQMessageVersion = 1
+
 type DLQMessage struct {
+	Version         int       `json:"version"`
 	OriginalMessage []byte    `json:"original_message"`
 	Reason          string    `json:"reason"`
 	Timestamp       time.Time `json:"timestamp"`
 	RetryCount      int       `json:"retry_count"`
+	// Headers заголовки исходного сообщения, с ними оно возвращается в основной топик
+	Headers kafkaproducer.Headers `json:"headers,omitempty"`
 }
 
 // MarshalJSON implements json.Marshaler interface
 func (d *DLQMessage) MarshalJSON() ([]byte, error) {
 	type Alias DLQMessage
-	return json.Marshal((*Alias)(d))
+	msg := *d
+	if msg.Version == 0 {
+		msg.Version = DLQMessageVersion
+	}
+	return json.Marshal((*Alias)(&msg))
 }
 
 // UnmarshalJSON implements json.Unmarshaler interface
 func (d *DLQMessage) UnmarshalJSON(data []byte) error {
 	type Alias DLQMessage
-	return json.Unmarshal(data, (*Alias)(d))
+	if err := json.Unmarshal(data, (*Alias)(d)); err != nil {
+		return err
+	}
+	if d.Version == 0 {
+		d.Version = 1
+	}
+	return nil
+}
+
+// messageReader источник сообщений DLQ
+type messageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
 }
 
+// messageWriter приемник сообщений DLQ
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// route решение о дальнейшей судьбе сообщения из DLQ
+type route int
+
+const (
+	routeRetry route = iota // повторная обработка с backoff
+	routePark               // перенос в parking-топик без повторов
+)
+
 type DLQService struct {
-	config *config.DLQConfig
-	writer *kafka.Writer
-	reader *kafka.Reader
+	config  *config.DLQConfig
+	brokers []string
+	writer  messageWriter
+	reader  messageReader
+	parked  messageWriter
+	corrupt messageWriter
+	requeue interfaces.MessageProducer
+
+	// pending незавершенные записи в DLQ, parking- и corrupt-топики, их дожидается Flush
+	pending pendingWrites
+}
+
+// pendingWrites счетчик незавершенных записей
+// В отличие от sync.WaitGroup допускает новые записи во время ожидания
+type pendingWrites struct {
+	mu    sync.Mutex
+	count int
+	idle  chan struct{}
 }
 
-func NewDLQService(cfg *config.DLQConfig, brokers []string) interfaces.DLQService {
+func (p *pendingWrites) Add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.count == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.count++
+}
+
+func (p *pendingWrites) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count--
+	if p.count == 0 {
+		close(p.idle)
+	}
+}
+
+// Idle возвращает канал, который закроется, когда незавершенных записей не останется
+func (p *pendingWrites) Idle() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.count == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return p.idle
+}
+
+// Option настройка DLQService
+type Option func(*DLQService)
+
+// WithCompression включает сжатие сообщений DLQ, parking- и corrupt-топиков
+func WithCompression(codec kafka.Compression) Option {
+	return func(d *DLQService) {
+		for _, w := range []messageWriter{d.writer, d.parked, d.corrupt} {
+			if writer, ok := w.(*kafka.Writer); ok {
+				writer.Compression = codec
+			}
+		}
+	}
+}
+
+// NewDLQService создает DLQ сервис
+// requeue - producer основного топика для повторной обработки, может быть nil
+func NewDLQService(cfg *config.DLQConfig, brokers []string, requeue interfaces.MessageProducer, opts ...Option) interfaces.DLQService {
 	if !cfg.Enabled {
 		return &NoOpDLQService{}
 	}
@@ -57,29 +200,70 @@ func NewDLQService(cfg *config.DLQConfig, brokers []string) interfaces.DLQServic
 		MaxBytes: 10e6, // 10MB
 	})
 
-	return &DLQService{
-		config: cfg,
-		writer: writer,
-		reader: reader,
+	service := &DLQService{
+		config:  cfg,
+		brokers: brokers,
+		writer:  writer,
+		reader:  reader,
+		requeue: requeue,
+	}
+
+	if cfg.ParkingTopic != "" {
+		service.parked = &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    cfg.ParkingTopic,
+			Balancer: &kafka.LeastBytes{},
+		}
+	}
+
+	if cfg.CorruptTopic != "" {
+		service.corrupt = &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    cfg.CorruptTopic,
+			Balancer: &kafka.LeastBytes{},
+		}
+	}
+
+	for _, opt := range opts {
+		opt(service)
 	}
+
+	return service
 }
 
-func (d *DLQService) SendToDLQ(message []byte, reason string) error {
+// Ping проверяет, что брокер доступен и топик DLQ существует
+// Запись не проверяется: пробное сообщение попало бы в DLQ и обрабатывалось как настоящее
+func (d *DLQService) Ping(ctx context.Context) error {
+	if err := kafkaproducer.PingTopic(ctx, d.brokers, d.config.Topic); err != nil {
+		return fmt.Errorf("dlq topic %s: %w", d.config.Topic, err)
+	}
+	return nil
+}
+
+// SendToDLQ отправляет сообщение в DLQ
+// Заголовки исходного сообщения берутся из ctx (kafka.HeadersFromContext) и сохраняются вместе с ним
+// Счетчик попыток продолжается с заголовка retry-count, если сообщение уже возвращалось из DLQ
+func (d *DLQService) SendToDLQ(ctx context.Context, message []byte, reason string) error {
+	headers := kafkaproducer.HeadersFromContext(ctx)
 	dlqMessage := DLQMessage{
+		Version:         DLQMessageVersion,
 		OriginalMessage: message,
 		Reason:          reason,
 		Timestamp:       time.Now(),
-		RetryCount:      0,
+		RetryCount:      retryCount(headers),
+		Headers:         headers,
 	}
 
-	messageBytes, err := json.Marshal(dlqMessage)
+	messageBytes, err := json.Marshal(&dlqMessage)
 	if err != nil {
 		return fmt.Errorf("failed to marshal DLQ message: %w", err)
 	}
 
-	err = d.writer.WriteMessages(context.Background(), kafka.Message{
+	d.pending.Add()
+	err = d.writer.WriteMessages(context.WithoutCancel(ctx), kafka.Message{
 		Value: messageBytes,
 	})
+	d.pending.Done()
 	if err != nil {
 		return fmt.Errorf("failed to send message to DLQ: %w", err)
 	}
@@ -88,56 +272,260 @@ func (d *DLQService) SendToDLQ(message []byte, reason string) error {
 	return nil
 }
 
-func (d *DLQService) ProcessDLQ() error {
+// retryCount возвращает номер попытки из заголовка retry-count, 0 - сообщение в DLQ впервые
+func retryCount(headers kafkaproducer.Headers) int {
+	count, err := strconv.Atoi(headers[HeaderRetryCount])
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// ProcessDLQ читает DLQ и повторяет или паркует сообщения до отмены ctx или закрытия reader
+// Ожидание backoff прерывается отменой ctx, поэтому остановка не ждет задержку повтора
+func (d *DLQService) ProcessDLQ(ctx context.Context) error {
 	if !d.config.Enabled {
 		return nil
 	}
 
 	log.Println("Starting DLQ processing...")
 
+	var readDelay time.Duration
 	for {
-		message, err := d.reader.ReadMessage(context.Background())
+		message, err := d.reader.ReadMessage(ctx)
 		if err != nil {
-			log.Printf("Error reading from DLQ: %v", err)
+			if ctx.Err() != nil {
+				log.Println("DLQ processing stopped")
+				return nil
+			}
+			// Reader закрыт - завершаем обработку
+			if errors.Is(err, io.EOF) {
+				log.Println("DLQ reader closed, stopping processing")
+				return nil
+			}
+			// Топика нет или нет прав - повторы не помогут, ошибку увидит вызывающий
+			if kafkaproducer.IsFatalReadError(err) {
+				log.Printf("Fatal error reading from DLQ, stopping processing: %v", err)
+				return fmt.Errorf("read dlq topic %s: %w", d.config.Topic, err)
+			}
+			readDelay = nextReadDelay(readDelay)
+			log.Printf("Error reading from DLQ, retrying in %v: %v", readDelay, err)
+			if !sleep(ctx, readDelay) {
+				log.Println("DLQ processing stopped")
+				return nil
+			}
 			continue
 		}
+		readDelay = 0
 
 		var dlqMessage DLQMessage
 		if err := json.Unmarshal(message.Value, &dlqMessage); err != nil {
 			log.Printf("Failed to unmarshal DLQ message: %v", err)
+			if err := d.quarantine(ctx, message); err != nil {
+				log.Printf("Failed to send message to corrupt topic: %v", err)
+			}
+			continue
+		}
+
+		// Формат из будущей версии сервиса разобрать нельзя, оставляем его как есть
+		if dlqMessage.Version > DLQMessageVersion {
+			log.Printf("Skipping DLQ message with unsupported version %d (supported up to %d)",
+				dlqMessage.Version, DLQMessageVersion)
 			continue
 		}
 
 		// Увеличиваем счетчик попыток
 		dlqMessage.RetryCount++
 
-		// Если превышено максимальное количество попыток, логируем и пропускаем
-		if dlqMessage.RetryCount > d.config.MaxRetries {
-			log.Printf("Message exceeded max retries (%d), dropping: %s",
-				d.config.MaxRetries, dlqMessage.Reason)
-			continue
+		switch d.route(&dlqMessage) {
+		case routePark:
+			// Постоянные ошибки и исчерпанные попытки не повторяем
+			log.Printf("Parking DLQ message (attempt %d/%d): %s",
+				dlqMessage.RetryCount, d.config.MaxRetries, dlqMessage.Reason)
+			if err := d.park(ctx, &dlqMessage); err != nil {
+				log.Printf("Failed to park message: %v", err)
+			}
+		case routeRetry:
+			delay := d.backoff(dlqMessage.RetryCount)
+			log.Printf("Retrying DLQ message (attempt %d/%d) in %v: %s",
+				dlqMessage.RetryCount, d.config.MaxRetries, delay, dlqMessage.Reason)
+			// Смещение сообщения уже зафиксировано: при остановке во время backoff
+			// оно возвращается в DLQ без изменений и обработается после перезапуска
+			if !sleep(ctx, delay) {
+				if err := d.putBack(ctx, message); err != nil {
+					log.Printf("Failed to return message to DLQ: %v", err)
+				}
+				log.Println("DLQ processing stopped")
+				return nil
+			}
+
+			if err := d.retryMessage(ctx, &dlqMessage); err != nil {
+				log.Printf("Failed to retry message: %v", err)
+			}
 		}
+	}
+}
 
-		// Попытка повторной обработки
-		log.Printf("Retrying DLQ message (attempt %d/%d): %s",
-			dlqMessage.RetryCount, d.config.MaxRetries, dlqMessage.Reason)
+// sleep ждет delay или отмены ctx, false - ctx отменен раньше
+func sleep(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-		// Здесь можно добавить логику повторной обработки сообщения
-		// Например, отправить обратно в основной топик или обработать по-другому
-		if err := d.retryMessage(&dlqMessage); err != nil {
-			log.Printf("Failed to retry message: %v", err)
-			// Можно отправить в другой DLQ или обработать по-другому
-		}
+// nextReadDelay удваивает задержку после ошибки чтения в границах задержек consumer
+func nextReadDelay(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return kafkaproducer.DefaultReconnectMinBackoff
+	}
+	delay *= 2
+	if delay > kafkaproducer.DefaultReconnectMaxBackoff {
+		delay = kafkaproducer.DefaultReconnectMaxBackoff
+	}
+	return delay
+}
+
+// route выбирает маршрут для сообщения по причине и числу попыток
+func (d *DLQService) route(dlqMessage *DLQMessage) route {
+	if IsPermanentReason(dlqMessage.Reason) {
+		return routePark
+	}
+	if dlqMessage.RetryCount > d.config.MaxRetries {
+		return routePark
+	}
+	return routeRetry
+}
+
+// backoff вычисляет задержку перед повторной обработкой
+// delay = retryBackoff * 2^(attempt-1)
+func (d *DLQService) backoff(attempt int) time.Duration {
+	if d.config.RetryBackoff <= 0 || attempt < 1 {
+		return 0
+	}
+	return time.Duration(float64(d.config.RetryBackoff) * math.Pow(2, float64(attempt-1)))
+}
+
+// park переносит сообщение в parking-топик
+// Если parking-топик не настроен, сообщение отбрасывается
+func (d *DLQService) park(ctx context.Context, dlqMessage *DLQMessage) error {
+	if d.parked == nil {
+		log.Printf("Parking topic is not configured, dropping message: %s", dlqMessage.Reason)
+		return nil
+	}
+
+	messageBytes, err := json.Marshal(dlqMessage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal parked message: %w", err)
+	}
+
+	d.pending.Add()
+	defer d.pending.Done()
+	if err := d.parked.WriteMessages(ctx, kafka.Message{Value: messageBytes}); err != nil {
+		return fmt.Errorf("failed to send message to parking topic: %w", err)
+	}
+	return nil
+}
+
+// putBack возвращает прочитанное сообщение в DLQ без изменений
+// Запись не зависит от отмены ctx: она нужна как раз при остановке
+func (d *DLQService) putBack(ctx context.Context, message kafka.Message) error {
+	d.pending.Add()
+	defer d.pending.Done()
+	if err := d.writer.WriteMessages(context.WithoutCancel(ctx), kafka.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: message.Headers,
+	}); err != nil {
+		return fmt.Errorf("failed to return message to DLQ: %w", err)
+	}
+	return nil
+}
+
+// quarantine переносит неразобранное сообщение DLQ в corrupt-топик без изменений
+// Если corrupt-топик не настроен, сообщение отбрасывается
+func (d *DLQService) quarantine(ctx context.Context, message kafka.Message) error {
+	if d.corrupt == nil {
+		log.Printf("Corrupt topic is not configured, dropping message: size=%d bytes", len(message.Value))
+		return nil
 	}
+
+	d.pending.Add()
+	defer d.pending.Done()
+	if err := d.corrupt.WriteMessages(ctx, kafka.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: message.Headers,
+	}); err != nil {
+		return fmt.Errorf("failed to send message to corrupt topic: %w", err)
+	}
+
+	log.Printf("Corrupt DLQ message moved to %s: partition=%d, offset=%d", d.config.CorruptTopic, message.Partition, message.Offset)
+	return nil
 }
 
-func (d *DLQService) retryMessage(dlqMessage *DLQMessage) error {
-	// Здесь можно реализовать логику повторной обработки
-	// Например, отправить обратно в основной топик
-	log.Printf("Retrying message: %s", string(dlqMessage.OriginalMessage))
+// retryMessage возвращает исходное сообщение в основной топик
+// Без настроенного producer сообщение только логируется
+func (d *DLQService) retryMessage(ctx context.Context, dlqMessage *DLQMessage) error {
+	if d.requeue == nil {
+		log.Printf("Requeue is not configured, skipping message: %s", string(dlqMessage.OriginalMessage))
+		return nil
+	}
+
+	// Ключ нужен, чтобы сообщение попало в партицию своего заказа
+	key, err := kafkaproducer.OrderKey(dlqMessage.OriginalMessage)
+	if err != nil {
+		return fmt.Errorf("failed to get message key: %w", err)
+	}
+
+	headers := requeueHeaders(dlqMessage.Headers)
+	headers[HeaderRetryCount] = strconv.Itoa(dlqMessage.RetryCount)
+	ctx = kafkaproducer.ContextWithHeaders(ctx, headers)
+	if err := d.requeue.Produce(ctx, key, dlqMessage.OriginalMessage); err != nil {
+		return fmt.Errorf("failed to requeue message: %w", err)
+	}
+
+	log.Printf("Message requeued: reason=%s, attempt=%d", dlqMessage.Reason, dlqMessage.RetryCount)
 	return nil
 }
 
+// requeueHeaders возвращает заголовки для повторной отправки: исходные, чтобы
+// сохранить trace-id и schema-version сообщения. Номер попытки retryMessage добавляет сам
+// Сообщения, записанные без заголовков, получают новый trace-id и schema-version v1:
+// неподдерживаемые версии паркуются как parse_error и сюда не доходят
+func requeueHeaders(original kafkaproducer.Headers) kafkaproducer.Headers {
+	headers := make(kafkaproducer.Headers, len(original)+2)
+	for key, value := range original {
+		headers[key] = value
+	}
+	if headers[kafkaproducer.HeaderTraceID] == "" {
+		headers[kafkaproducer.HeaderTraceID] = kafkaproducer.NewTraceID()
+	}
+	if headers[kafkaproducer.HeaderSchemaVersion] == "" {
+		headers[kafkaproducer.HeaderSchemaVersion] = model.SchemaVersionV1
+	}
+	return headers
+}
+
+// Flush дожидается завершения начатых записей в DLQ, parking- и corrupt-топики
+// Вызывается при остановке перед Close: writer, закрытый во время записи,
+// может потерять сообщение
+func (d *DLQService) Flush(ctx context.Context) error {
+	select {
+	case <-d.pending.Idle():
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("dlq flush: %w", ctx.Err())
+	}
+}
+
 func (d *DLQService) Close() error {
 	if d.writer != nil {
 		if err := d.writer.Close(); err != nil {
@@ -149,18 +537,28 @@ func (d *DLQService) Close() error {
 			log.Printf("Error closing DLQ reader: %v", err)
 		}
 	}
+	if d.parked != nil {
+		if err := d.parked.Close(); err != nil {
+			log.Printf("Error closing DLQ parking writer: %v", err)
+		}
+	}
+	if d.corrupt != nil {
+		if err := d.corrupt.Close(); err != nil {
+			log.Printf("Error closing DLQ corrupt writer: %v", err)
+		}
+	}
 	return nil
 }
 
 // NoOpDLQService - заглушка для случая, когда DLQ отключен
 type NoOpDLQService struct{}
 
-func (n *NoOpDLQService) SendToDLQ(message []byte, reason string) error {
+func (n *NoOpDLQService) SendToDLQ(ctx context.Context, message []byte, reason string) error {
 	log.Printf("DLQ disabled, message dropped: %s", reason)
 	return nil
 }
 
-func (n *NoOpDLQService) ProcessDLQ() error {
+func (n *NoOpDLQService) ProcessDLQ(ctx context.Context) error {
 	return nil
 }
 
@@ -1,10 +1,23 @@
 package dlq
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"wbtest/internal/config"
+	"wbtest/internal/health"
+	kafkaproducer "wbtest/internal/kafka"
+	"wbtest/internal/model"
+
+	"github.com/segmentio/kafka-go"
 )
 
 func TestDLQService_SendToDLQ(t *testing.T) {
@@ -23,17 +36,75 @@ func TestDLQService_SendToDLQ(t *testing.T) {
 		message := []byte("test message")
 		reason := "test reason"
 
-		err := service.SendToDLQ(message, reason)
+		err := service.SendToDLQ(context.Background(), message, reason)
 		if err != nil {
 			t.Errorf("NoOpDLQService.SendToDLQ() error = %v", err)
 		}
 	})
 }
 
+func TestNewDLQService_Compression(t *testing.T) {
+	cfg := &config.DLQConfig{
+		Enabled:      true,
+		Topic:        "test-dlq",
+		ParkingTopic: "test-dlq-parked",
+		MaxRetries:   3,
+	}
+
+	cfg.CorruptTopic = "test-dlq-corrupt"
+
+	service := NewDLQService(cfg, []string{"localhost:9092"}, nil, WithCompression(kafka.Snappy)).(*DLQService)
+	defer service.Close()
+
+	for name, w := range map[string]messageWriter{"dlq": service.writer, "parked": service.parked, "corrupt": service.corrupt} {
+		writer, ok := w.(*kafka.Writer)
+		if !ok {
+			t.Fatalf("Expected %s writer to be *kafka.Writer, got %T", name, w)
+		}
+		if writer.Compression != kafka.Snappy {
+			t.Errorf("Expected %s writer compression snappy, got %v", name, writer.Compression)
+		}
+	}
+}
+
+func TestDLQService_HealthCheck(t *testing.T) {
+	t.Run("disabled dlq is healthy", func(t *testing.T) {
+		checker := health.NewDLQChecker("dlq", &NoOpDLQService{})
+		if err := checker.Check(context.Background()); err != nil {
+			t.Errorf("Expected disabled DLQ to be healthy, got %v", err)
+		}
+	})
+
+	t.Run("unreachable broker is unhealthy", func(t *testing.T) {
+		// Адрес закрытого слушателя: подключение к брокеру сразу отклоняется
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		broker := listener.Addr().String()
+		listener.Close()
+
+		cfg := &config.DLQConfig{Enabled: true, Topic: "test-dlq", MaxRetries: 3}
+		service := NewDLQService(cfg, []string{broker}, nil)
+		defer service.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		err = health.NewDLQChecker("dlq", service).Check(ctx)
+		if err == nil {
+			t.Fatal("Expected unreachable DLQ broker to fail the check")
+		}
+		if !strings.Contains(err.Error(), "test-dlq") {
+			t.Errorf("Expected error to name the DLQ topic, got %v", err)
+		}
+	})
+}
+
 func TestDLQService_ProcessDLQ(t *testing.T) {
 	service := &NoOpDLQService{}
 
-	err := service.ProcessDLQ()
+	err := service.ProcessDLQ(context.Background())
 	if err != nil {
 		t.Errorf("NoOpDLQService.ProcessDLQ() error = %v", err)
 	}
@@ -92,3 +163,583 @@ func TestDLQMessage_MarshalUnmarshal(t *testing.T) {
 		t.Errorf("Timestamp mismatch: got %v, want %v", unmarshaledMessage.Timestamp, originalMessage.Timestamp)
 	}
 }
+
+// fakeReader отдает заранее заданные ошибки, затем сообщения, затем io.EOF
+type fakeReader struct {
+	mu       sync.Mutex
+	errs     []error
+	messages []kafka.Message
+	reads    int
+}
+
+func (f *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reads++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return kafka.Message{}, err
+	}
+	if len(f.messages) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	message := f.messages[0]
+	f.messages = f.messages[1:]
+	return message, nil
+}
+
+func (f *fakeReader) Close() error {
+	return nil
+}
+
+// fakeWriter запоминает записанные сообщения
+type fakeWriter struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	return nil
+}
+
+func newDLQPayload(t *testing.T, reason string, retryCount int) kafka.Message {
+	t.Helper()
+	data, err := json.Marshal(&DLQMessage{
+		OriginalMessage: []byte(`{"order_uid":"test"}`),
+		Reason:          reason,
+		Timestamp:       time.Now(),
+		RetryCount:      retryCount,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal DLQ message: %v", err)
+	}
+	return kafka.Message{Value: data}
+}
+
+func TestDLQService_route(t *testing.T) {
+	service := &DLQService{config: &config.DLQConfig{Enabled: true, MaxRetries: 3}}
+
+	tests := []struct {
+		name       string
+		reason     string
+		retryCount int
+		expected   route
+	}{
+		{"validation failed is parked on first read", ReasonValidationFailed + ": field 'email'", 1, routePark},
+		{"parse error is parked on first read", ReasonParseError + ": invalid character", 1, routePark},
+		{"constraint violation is parked on first read", ReasonConstraintViolation + ": duplicate key", 1, routePark},
+		{"db error is retried", ReasonDBError + ": connection refused", 1, routeRetry},
+		{"db error is parked after max retries", ReasonDBError + ": connection refused", 4, routePark},
+		{"legacy reason is retried", "failed to save order", 1, routeRetry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := service.route(&DLQMessage{Reason: tt.reason, RetryCount: tt.retryCount})
+			if got != tt.expected {
+				t.Errorf("route() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDLQService_ProcessDLQ_Routing(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		newDLQPayload(t, ReasonValidationFailed+": field 'email' failed validation", 0),
+		newDLQPayload(t, ReasonDBError+": connection refused", 0),
+	}}
+	parked := &fakeWriter{}
+	producer := kafkaproducer.NewMemoryProducer()
+
+	service := &DLQService{
+		config: &config.DLQConfig{
+			Enabled:      true,
+			MaxRetries:   3,
+			RetryBackoff: time.Millisecond,
+		},
+		writer:  &fakeWriter{},
+		reader:  reader,
+		parked:  parked,
+		requeue: producer,
+	}
+
+	if err := service.ProcessDLQ(context.Background()); err != nil {
+		t.Fatalf("ProcessDLQ() error = %v", err)
+	}
+
+	// В parking-топик попадает только validation_failed
+	if len(parked.messages) != 1 {
+		t.Fatalf("Expected 1 parked message, got %d", len(parked.messages))
+	}
+
+	var parkedMessage DLQMessage
+	if err := json.Unmarshal(parked.messages[0].Value, &parkedMessage); err != nil {
+		t.Fatalf("Failed to unmarshal parked message: %v", err)
+	}
+	if ReasonCategory(parkedMessage.Reason) != ReasonValidationFailed {
+		t.Errorf("Expected parked reason %s, got %s", ReasonValidationFailed, parkedMessage.Reason)
+	}
+	if parkedMessage.RetryCount != 1 {
+		t.Errorf("Expected parked message retry count 1, got %d", parkedMessage.RetryCount)
+	}
+
+	// db_error возвращается в основной топик
+	requeued := producer.Messages()
+	if len(requeued) != 1 {
+		t.Fatalf("Expected 1 requeued message, got %d", len(requeued))
+	}
+	if string(requeued[0].Value) != `{"order_uid":"test"}` {
+		t.Errorf("Expected db_error message to be requeued, got %s", requeued[0].Value)
+	}
+}
+
+func TestDLQService_ProcessDLQ_BackoffCancelled(t *testing.T) {
+	payload := newDLQPayload(t, ReasonDBError+": connection refused", 0)
+	writer := &fakeWriter{}
+	producer := kafkaproducer.NewMemoryProducer()
+	service := &DLQService{
+		config: &config.DLQConfig{
+			Enabled:      true,
+			MaxRetries:   3,
+			RetryBackoff: time.Hour,
+		},
+		writer:  writer,
+		reader:  &fakeReader{messages: []kafka.Message{payload}},
+		requeue: producer,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- service.ProcessDLQ(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ProcessDLQ() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ProcessDLQ kept waiting for backoff after cancel")
+	}
+
+	// Сообщение не повторено, а возвращено в DLQ без изменений
+	if len(producer.Messages()) != 0 {
+		t.Errorf("Expected no requeue after cancel, got %d messages", len(producer.Messages()))
+	}
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	if len(writer.messages) != 1 || string(writer.messages[0].Value) != string(payload.Value) {
+		t.Errorf("Expected message to be returned to DLQ unchanged, got %v", writer.messages)
+	}
+}
+
+func TestDLQService_ProcessDLQ_Requeue(t *testing.T) {
+	original := []byte(`{"order_uid":"test123"}`)
+
+	headers := kafkaproducer.Headers{
+		kafkaproducer.HeaderTraceID:       "trace-123",
+		kafkaproducer.HeaderSchemaVersion: "2",
+	}
+	payload, err := json.Marshal(DLQMessage{
+		OriginalMessage: original,
+		Reason:          ReasonDBError + ": connection refused",
+		Headers:         headers,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal DLQ message: %v", err)
+	}
+
+	producer := kafkaproducer.NewMemoryProducer()
+	service := &DLQService{
+		config: &config.DLQConfig{
+			Enabled:      true,
+			MaxRetries:   3,
+			RetryBackoff: time.Millisecond,
+		},
+		writer:  &fakeWriter{},
+		reader:  &fakeReader{messages: []kafka.Message{{Value: payload}}},
+		parked:  &fakeWriter{},
+		requeue: producer,
+	}
+
+	if err := service.ProcessDLQ(context.Background()); err != nil {
+		t.Fatalf("ProcessDLQ() error = %v", err)
+	}
+
+	messages := producer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 requeued message, got %d", len(messages))
+	}
+	if string(messages[0].Value) != string(original) {
+		t.Errorf("Expected requeued value %s, got %s", original, messages[0].Value)
+	}
+	// Исходные заголовки сохраняются, trace-id не подменяется новым
+	if got := messages[0].Headers; got[kafkaproducer.HeaderTraceID] != "trace-123" || got[kafkaproducer.HeaderSchemaVersion] != "2" {
+		t.Errorf("Expected original headers on requeue, got %v", got)
+	}
+}
+
+func TestDLQService_RequeueCycleParksAfterMaxRetries(t *testing.T) {
+	writer := &fakeWriter{}
+	parked := &fakeWriter{}
+	producer := kafkaproducer.NewMemoryProducer()
+	service := &DLQService{
+		config: &config.DLQConfig{
+			Enabled:    true,
+			MaxRetries: 2,
+		},
+		writer:  writer,
+		parked:  parked,
+		requeue: producer,
+	}
+
+	reason := ReasonDBError + ": connection refused"
+	ctx := kafkaproducer.ContextWithHeaders(context.Background(), kafkaproducer.Headers{
+		kafkaproducer.HeaderTraceID: "trace-789",
+	})
+	if err := service.SendToDLQ(ctx, []byte(`{"order_uid":"test"}`), reason); err != nil {
+		t.Fatalf("SendToDLQ() error = %v", err)
+	}
+
+	// Каждый круг: DLQ -> основной топик -> обработка снова падает -> DLQ
+	for round := 1; round <= 3; round++ {
+		if len(writer.messages) != round {
+			t.Fatalf("Round %d: expected %d DLQ writes, got %d", round, round, len(writer.messages))
+		}
+		service.reader = &fakeReader{messages: writer.messages[round-1:]}
+		if err := service.ProcessDLQ(context.Background()); err != nil {
+			t.Fatalf("ProcessDLQ() error = %v", err)
+		}
+
+		requeued := producer.Messages()
+		if len(requeued) < round {
+			break
+		}
+		last := requeued[round-1]
+		if got := last.Headers[HeaderRetryCount]; got != strconv.Itoa(round) {
+			t.Errorf("Round %d: expected retry-count %d, got %q", round, round, got)
+		}
+		failedCtx := kafkaproducer.ContextWithHeaders(context.Background(), last.Headers)
+		if err := service.SendToDLQ(failedCtx, last.Value, reason); err != nil {
+			t.Fatalf("SendToDLQ() error = %v", err)
+		}
+	}
+
+	if got := len(producer.Messages()); got != 2 {
+		t.Errorf("Expected %d requeues before parking, got %d", 2, got)
+	}
+	if len(parked.messages) != 1 {
+		t.Fatalf("Expected message to be parked after MaxRetries, got %d parked", len(parked.messages))
+	}
+	var parkedMessage DLQMessage
+	if err := json.Unmarshal(parked.messages[0].Value, &parkedMessage); err != nil {
+		t.Fatalf("Failed to unmarshal parked message: %v", err)
+	}
+	if parkedMessage.RetryCount != 3 {
+		t.Errorf("Expected parked retry count 3, got %d", parkedMessage.RetryCount)
+	}
+	if parkedMessage.Headers[kafkaproducer.HeaderTraceID] != "trace-789" {
+		t.Errorf("Expected trace-id to survive the cycle, got %v", parkedMessage.Headers)
+	}
+}
+
+func TestDLQService_SendToDLQ_KeepsHeaders(t *testing.T) {
+	writer := &fakeWriter{}
+	service := &DLQService{
+		config: &config.DLQConfig{Enabled: true},
+		writer: writer,
+	}
+
+	ctx := kafkaproducer.ContextWithHeaders(context.Background(), kafkaproducer.Headers{
+		kafkaproducer.HeaderTraceID: "trace-456",
+	})
+	if err := service.SendToDLQ(ctx, []byte(`{"order_uid":"test"}`), ReasonDBError+": timeout"); err != nil {
+		t.Fatalf("SendToDLQ() error = %v", err)
+	}
+
+	if len(writer.messages) != 1 {
+		t.Fatalf("Expected 1 DLQ message, got %d", len(writer.messages))
+	}
+	var dlqMessage DLQMessage
+	if err := json.Unmarshal(writer.messages[0].Value, &dlqMessage); err != nil {
+		t.Fatalf("Failed to unmarshal DLQ message: %v", err)
+	}
+	if dlqMessage.Headers[kafkaproducer.HeaderTraceID] != "trace-456" {
+		t.Errorf("Expected trace-id to be stored in DLQ message, got %v", dlqMessage.Headers)
+	}
+}
+
+func TestRequeueHeaders_Defaults(t *testing.T) {
+	headers := requeueHeaders(nil)
+	if headers[kafkaproducer.HeaderTraceID] == "" {
+		t.Error("Expected new trace-id for message without headers")
+	}
+	if headers[kafkaproducer.HeaderSchemaVersion] != model.SchemaVersionV1 {
+		t.Errorf("Expected schema-version %s, got %s", model.SchemaVersionV1, headers[kafkaproducer.HeaderSchemaVersion])
+	}
+}
+
+func TestDLQService_backoff(t *testing.T) {
+	service := &DLQService{config: &config.DLQConfig{RetryBackoff: 10 * time.Millisecond}}
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := service.backoff(tt.attempt); got != tt.expected {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.expected)
+		}
+	}
+}
+
+func TestDLQMessage_UnmarshalJSON_Version(t *testing.T) {
+	t.Run("missing version is v1", func(t *testing.T) {
+		var message DLQMessage
+		data := []byte(`{"original_message":"dGVzdA==","reason":"db_error: timeout","retry_count":2}`)
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("Failed to unmarshal DLQ message: %v", err)
+		}
+		if message.Version != 1 {
+			t.Errorf("Expected version 1, got %d", message.Version)
+		}
+		if message.RetryCount != 2 {
+			t.Errorf("Expected retry count 2, got %d", message.RetryCount)
+		}
+	})
+
+	t.Run("marshal sets current version", func(t *testing.T) {
+		data, err := json.Marshal(&DLQMessage{Reason: "test"})
+		if err != nil {
+			t.Fatalf("Failed to marshal DLQ message: %v", err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal JSON: %v", err)
+		}
+		if decoded["version"] != float64(DLQMessageVersion) {
+			t.Errorf("Expected version %d, got %v", DLQMessageVersion, decoded["version"])
+		}
+	})
+}
+
+func TestDLQService_ProcessDLQ_SkipsUnknownVersion(t *testing.T) {
+	future := []byte(`{"version":99,"original_message":"e30=","reason":"validation_failed: unknown"}`)
+
+	parked := &fakeWriter{}
+	producer := kafkaproducer.NewMemoryProducer()
+	service := &DLQService{
+		config: &config.DLQConfig{
+			Enabled:      true,
+			MaxRetries:   3,
+			RetryBackoff: time.Millisecond,
+		},
+		writer:  &fakeWriter{},
+		reader:  &fakeReader{messages: []kafka.Message{{Value: future}}},
+		parked:  parked,
+		requeue: producer,
+	}
+
+	if err := service.ProcessDLQ(context.Background()); err != nil {
+		t.Fatalf("ProcessDLQ() error = %v", err)
+	}
+
+	if len(parked.messages) != 0 {
+		t.Errorf("Expected unknown version not to be parked, got %d messages", len(parked.messages))
+	}
+	if len(producer.Messages()) != 0 {
+		t.Errorf("Expected unknown version not to be requeued, got %d messages", len(producer.Messages()))
+	}
+}
+
+func TestDLQService_ProcessDLQ_CorruptMessage(t *testing.T) {
+	corruptPayload := []byte("not a json payload")
+
+	parked := &fakeWriter{}
+	corrupt := &fakeWriter{}
+	producer := kafkaproducer.NewMemoryProducer()
+	service := &DLQService{
+		config: &config.DLQConfig{
+			Enabled:      true,
+			MaxRetries:   3,
+			RetryBackoff: time.Millisecond,
+			CorruptTopic: "orders-dlq-corrupt",
+		},
+		writer: &fakeWriter{},
+		reader: &fakeReader{messages: []kafka.Message{
+			{Key: []byte("order-1"), Value: corruptPayload, Partition: 2, Offset: 17},
+			newDLQPayload(t, ReasonValidationFailed+": field 'email'", 0),
+		}},
+		parked:  parked,
+		corrupt: corrupt,
+		requeue: producer,
+	}
+
+	if err := service.ProcessDLQ(context.Background()); err != nil {
+		t.Fatalf("ProcessDLQ() error = %v", err)
+	}
+
+	if len(corrupt.messages) != 1 {
+		t.Fatalf("Expected 1 corrupt message, got %d", len(corrupt.messages))
+	}
+	if string(corrupt.messages[0].Value) != string(corruptPayload) {
+		t.Errorf("Expected corrupt payload %q, got %q", corruptPayload, corrupt.messages[0].Value)
+	}
+	if string(corrupt.messages[0].Key) != "order-1" {
+		t.Errorf("Expected corrupt message key order-1, got %q", corrupt.messages[0].Key)
+	}
+
+	// Следующее сообщение обрабатывается как обычно
+	if len(parked.messages) != 1 {
+		t.Errorf("Expected 1 parked message, got %d", len(parked.messages))
+	}
+	if len(producer.Messages()) != 0 {
+		t.Errorf("Expected corrupt message not to be requeued, got %d messages", len(producer.Messages()))
+	}
+}
+
+// orderedWriter пишет с задержкой и запоминает порядок завершения записей и закрытия
+type orderedWriter struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	events []string
+}
+
+func (w *orderedWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, "write")
+	return nil
+}
+
+func (w *orderedWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, "close")
+	return nil
+}
+
+func TestDLQService_FlushBeforeClose(t *testing.T) {
+	writer := &orderedWriter{delay: 50 * time.Millisecond}
+	service := &DLQService{
+		config: &config.DLQConfig{Enabled: true},
+		writer: writer,
+	}
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- service.SendToDLQ(context.Background(), []byte(`{"order_uid":"test"}`), ReasonDBError+": timeout")
+	}()
+	// Даем записи начаться
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := service.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if err := service.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("SendToDLQ() error = %v", err)
+	}
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	if len(writer.events) != 2 || writer.events[0] != "write" || writer.events[1] != "close" {
+		t.Errorf("Expected write to finish before close, got %v", writer.events)
+	}
+}
+
+func TestDLQService_FlushTimeout(t *testing.T) {
+	service := &DLQService{
+		config: &config.DLQConfig{Enabled: true},
+		writer: &orderedWriter{delay: time.Second},
+	}
+	go service.SendToDLQ(context.Background(), []byte("test"), ReasonDBError+": timeout")
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := service.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestDLQService_FlushIdle(t *testing.T) {
+	service := &DLQService{config: &config.DLQConfig{Enabled: true}, writer: &fakeWriter{}}
+	if err := service.Flush(context.Background()); err != nil {
+		t.Errorf("Expected Flush without pending writes to return immediately, got %v", err)
+	}
+}
+
+func TestDLQService_ProcessDLQ_FatalReadError(t *testing.T) {
+	reader := &fakeReader{errs: []error{kafka.UnknownTopicOrPartition, kafka.UnknownTopicOrPartition}}
+	service := &DLQService{
+		config: &config.DLQConfig{Enabled: true, Topic: "orders-dlq"},
+		writer: &fakeWriter{},
+		reader: reader,
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- service.ProcessDLQ(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, kafka.UnknownTopicOrPartition) {
+			t.Errorf("Expected UnknownTopicOrPartition, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ProcessDLQ kept looping on a fatal read error")
+	}
+	if reader.reads != 1 {
+		t.Errorf("Expected 1 read, got %d", reader.reads)
+	}
+}
+
+func TestDLQService_ProcessDLQ_TransientReadError(t *testing.T) {
+	reader := &fakeReader{errs: []error{kafka.LeaderNotAvailable}}
+	service := &DLQService{
+		config: &config.DLQConfig{Enabled: true},
+		writer: &fakeWriter{},
+		reader: reader,
+	}
+
+	if err := service.ProcessDLQ(context.Background()); err != nil {
+		t.Fatalf("Expected nil after transient error and EOF, got %v", err)
+	}
+	if reader.reads != 2 {
+		t.Errorf("Expected read to be retried once, got %d reads", reader.reads)
+	}
+}
+
+func TestNextReadDelay(t *testing.T) {
+	delay := nextReadDelay(0)
+	if delay != kafkaproducer.DefaultReconnectMinBackoff {
+		t.Errorf("Expected first delay %v, got %v", kafkaproducer.DefaultReconnectMinBackoff, delay)
+	}
+	if got := nextReadDelay(delay); got != 2*delay {
+		t.Errorf("Expected doubled delay %v, got %v", 2*delay, got)
+	}
+	if got := nextReadDelay(kafkaproducer.DefaultReconnectMaxBackoff); got != kafkaproducer.DefaultReconnectMaxBackoff {
+		t.Errorf("Expected delay capped at %v, got %v", kafkaproducer.DefaultReconnectMaxBackoff, got)
+	}
+}
@@ -243,3 +243,84 @@ var (
 		"DLQ_PROCESS_FAILED",
 	)
 )
+
+// HTTP errors
+var (
+	ErrInvalidJSON = NewWithCode(
+		ErrorTypeValidation,
+		"Invalid JSON",
+		"INVALID_JSON",
+	)
+
+	ErrRequestTooLarge = &AppError{
+		Type:       ErrorTypeHTTP,
+		Message:    "Request body too large",
+		Code:       "REQUEST_TOO_LARGE",
+		HTTPStatus: http.StatusRequestEntityTooLarge,
+	}
+
+	ErrRateLimited = &AppError{
+		Type:       ErrorTypeHTTP,
+		Message:    "Rate limit exceeded, try again later",
+		Code:       "RATE_LIMITED",
+		HTTPStatus: http.StatusTooManyRequests,
+	}
+
+	ErrUnauthorized = &AppError{
+		Type:       ErrorTypeHTTP,
+		Message:    "Missing or invalid API key",
+		Code:       "UNAUTHORIZED",
+		HTTPStatus: http.StatusUnauthorized,
+	}
+
+	ErrMaintenance = &AppError{
+		Type:       ErrorTypeHTTP,
+		Message:    "Service is in maintenance mode, writes are temporarily disabled",
+		Code:       "MAINTENANCE",
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+
+	ErrShuttingDown = &AppError{
+		Type:       ErrorTypeHTTP,
+		Message:    "Service is shutting down",
+		Code:       "SHUTTING_DOWN",
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+
+	ErrAdminDisabled = &AppError{
+		Type:       ErrorTypeHTTP,
+		Message:    "Admin endpoints are disabled",
+		Code:       "ADMIN_DISABLED",
+		HTTPStatus: http.StatusForbidden,
+	}
+
+	ErrNotFound = NewWithCode(
+		ErrorTypeNotFound,
+		"Not found",
+		"NOT_FOUND",
+	)
+
+	ErrDatabaseUnavailable = NewWithCode(
+		ErrorTypeDatabase,
+		"Database is not available",
+		"DB_UNAVAILABLE",
+	)
+
+	ErrDatabaseTimeout = &AppError{
+		Type:       ErrorTypeTimeout,
+		Message:    "Database did not respond in time",
+		Code:       "DB_TIMEOUT",
+		HTTPStatus: http.StatusGatewayTimeout,
+	}
+
+	ErrEncodeFailed = NewWithCode(
+		ErrorTypeInternal,
+		"Failed to encode response",
+		"ENCODE_FAILED",
+	)
+)
+
+// InvalidParameter возвращает ошибку некорректного или отсутствующего параметра запроса
+func InvalidParameter(message string) *AppError {
+	return NewWithCode(ErrorTypeValidation, message, "INVALID_PARAMETER")
+}
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"testing"
 )
@@ -184,3 +185,22 @@ func TestPredefinedErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestPermanent(t *testing.T) {
+	if Permanent(nil) != nil {
+		t.Error("Expected Permanent(nil) to be nil")
+	}
+
+	cause := fmt.Errorf("duplicate key")
+	err := fmt.Errorf("failed to save order: %w", Permanent(cause))
+
+	if !IsPermanent(err) {
+		t.Error("Expected wrapped permanent error to be permanent")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected permanent error to unwrap to its cause")
+	}
+	if IsPermanent(cause) {
+		t.Error("Expected plain error not to be permanent")
+	}
+}
@@ -0,0 +1,31 @@
+package errors
+
+import stderrors "errors"
+
+// permanentError ошибка, повтор операции после которой не поможет
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent помечает ошибку как постоянную: RetryService прекращает повторы сразу
+// Исходная ошибка остается доступной через errors.Is и errors.As
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent сообщает, что в цепочке ошибки есть ошибка, помеченная Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return stderrors.As(err, &permanent)
+}
@@ -4,59 +4,256 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// DefaultVersionTTL как долго хранится версия зависимости, версии меняются редко
+const DefaultVersionTTL = 10 * time.Minute
+
+// DefaultCheckTimeout ограничение на проверки, запущенные в фоне
+const DefaultCheckTimeout = 5 * time.Second
+
+// defaultStalenessIntervals во сколько интервалов фоновых проверок по умолчанию
+// укладывается допустимый возраст результата
+const defaultStalenessIntervals = 3
+
 // Checker интерфейс для health check
 type Checker interface {
 	Check(ctx context.Context) error
 	Name() string
 }
 
+// VersionChecker checker, который умеет сообщить версию зависимости
+type VersionChecker interface {
+	Checker
+	Version(ctx context.Context) (string, error)
+}
+
+// cachedVersion версия зависимости и время ее получения
+type cachedVersion struct {
+	version   string
+	fetchedAt time.Time
+}
+
 // Health структура для health checks
 type Health struct {
 	checkers []Checker
+
+	mu         sync.Mutex
+	versions   map[string]cachedVersion
+	versionTTL time.Duration
+
+	// Фоновые проверки: Check отдает последний результат, пока он не старше maxStaleness
+	stop         chan struct{}
+	resultMu     sync.Mutex
+	interval     time.Duration
+	maxStaleness time.Duration
+	result       map[string]interface{}
+	checkedAt    time.Time
 }
 
 // New создает новый Health checker
 func New() *Health {
 	return &Health{
-		checkers: make([]Checker, 0),
+		checkers:   make([]Checker, 0),
+		versions:   make(map[string]cachedVersion),
+		versionTTL: DefaultVersionTTL,
+		stop:       make(chan struct{}),
 	}
 }
 
+// WithVersionTTL задает время хранения версий зависимостей
+func (h *Health) WithVersionTTL(ttl time.Duration) *Health {
+	h.versionTTL = ttl
+	return h
+}
+
+// WithMaxStaleness задает допустимый возраст результата фоновых проверок
+// Если фоновая проверка зависла и результат старше, Check проверяет зависимости сам
+// Значение <= 0 - три интервала фоновых проверок
+func (h *Health) WithMaxStaleness(d time.Duration) *Health {
+	h.resultMu.Lock()
+	h.maxStaleness = d
+	h.resultMu.Unlock()
+	return h
+}
+
+// Start проверяет зависимости в фоне каждые interval до вызова Stop
+// Check и обработчики проб отдают последний результат, поэтому частые пробы
+// Kubernetes не нагружают БД и Kafka. Первая проверка запускается сразу
+func (h *Health) Start(interval time.Duration) {
+	h.resultMu.Lock()
+	h.interval = interval
+	h.resultMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			h.refresh()
+			select {
+			case <-ticker.C:
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает фоновые проверки
+func (h *Health) Stop() {
+	close(h.stop)
+}
+
+// refresh выполняет проверки и сохраняет результат
+func (h *Health) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCheckTimeout)
+	defer cancel()
+	h.store(h.run(ctx))
+}
+
+// store сохраняет результат проверок, если включены фоновые проверки
+func (h *Health) store(results map[string]interface{}) {
+	h.resultMu.Lock()
+	defer h.resultMu.Unlock()
+	if h.interval <= 0 {
+		return
+	}
+	h.result = results
+	h.checkedAt = time.Now()
+}
+
+// cached возвращает копию сохраненного результата, если он достаточно свежий
+func (h *Health) cached() (map[string]interface{}, bool) {
+	h.resultMu.Lock()
+	defer h.resultMu.Unlock()
+	if h.interval <= 0 || h.result == nil {
+		return nil, false
+	}
+
+	staleness := h.maxStaleness
+	if staleness <= 0 {
+		staleness = defaultStalenessIntervals * h.interval
+	}
+	if time.Since(h.checkedAt) > staleness {
+		return nil, false
+	}
+
+	results := make(map[string]interface{}, len(h.result))
+	for name, result := range h.result {
+		results[name] = result
+	}
+	return results, true
+}
+
 // AddChecker добавляет checker
 func (h *Health) AddChecker(checker Checker) {
 	h.checkers = append(h.checkers, checker)
 }
 
 // Check выполняет все health checks
+// При запущенных фоновых проверках возвращает их последний результат, см. Start
 func (h *Health) Check(ctx context.Context) map[string]interface{} {
-	results := make(map[string]interface{})
-	overall := "healthy"
+	if results, ok := h.cached(); ok {
+		return results
+	}
+	results := h.run(ctx)
+	h.store(results)
+	return results
+}
 
-	for _, checker := range h.checkers {
-		start := time.Now()
-		err := checker.Check(ctx)
-		duration := time.Since(start)
+// run выполняет проверки параллельно, время ответа - самая долгая проверка, а не их сумма
+func (h *Health) run(ctx context.Context) map[string]interface{} {
+	checks := make([]map[string]interface{}, len(h.checkers))
+	var wg sync.WaitGroup
+	for i, checker := range h.checkers {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
 
-		status := "healthy"
-		if err != nil {
-			status = "unhealthy"
-			overall = "unhealthy"
-		}
+			start := time.Now()
+			err := checker.Check(ctx)
+			duration := time.Since(start)
+
+			status := "healthy"
+			var errMessage interface{}
+			if err != nil {
+				status = "unhealthy"
+				// error сериализуется в JSON как {}, поэтому отдаем текст
+				errMessage = err.Error()
+			}
 
-		results[checker.Name()] = map[string]interface{}{
-			"status":   status,
-			"duration": duration.String(),
-			"error":    err,
+			checks[i] = map[string]interface{}{
+				"status":   status,
+				"duration": duration.String(),
+				"error":    errMessage,
+			}
+		}(i, checker)
+	}
+	wg.Wait()
+
+	results := make(map[string]interface{}, len(checks)+1)
+	overall := "healthy"
+	for i, checker := range h.checkers {
+		if checks[i]["status"] == "unhealthy" {
+			overall = "unhealthy"
 		}
+		results[checker.Name()] = checks[i]
 	}
 
 	results["overall"] = overall
 	return results
 }
 
+// Ready выполняет проверки и добавляет версии доступных зависимостей
+// Версия попадает в поле "<имя checker>_version", например postgres_version
+func (h *Health) Ready(ctx context.Context) map[string]interface{} {
+	checks := h.Check(ctx)
+	summary := map[string]interface{}{
+		"status": checks["overall"],
+		"checks": checks,
+	}
+
+	for _, checker := range h.checkers {
+		versionChecker, ok := checker.(VersionChecker)
+		if !ok {
+			continue
+		}
+		// Версию недоступной зависимости не запрашиваем
+		result, _ := checks[checker.Name()].(map[string]interface{})
+		if result["status"] != "healthy" {
+			continue
+		}
+		if version := h.version(ctx, versionChecker); version != "" {
+			summary[checker.Name()+"_version"] = version
+		}
+	}
+
+	return summary
+}
+
+// version возвращает версию зависимости из кеша или запрашивает ее
+// Ошибка получения версии не влияет на статус, версия просто не выводится
+func (h *Health) version(ctx context.Context, checker VersionChecker) string {
+	h.mu.Lock()
+	cached, ok := h.versions[checker.Name()]
+	h.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < h.versionTTL {
+		return cached.version
+	}
+
+	version, err := checker.Version(ctx)
+	if err != nil || version == "" {
+		return ""
+	}
+
+	h.mu.Lock()
+	h.versions[checker.Name()] = cachedVersion{version: version, fetchedAt: time.Now()}
+	h.mu.Unlock()
+	return version
+}
+
 // Handler возвращает HTTP handler для health checks
 func (h *Health) Handler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -83,8 +280,9 @@ func (h *Health) Handler() http.HandlerFunc {
 
 // DatabaseChecker проверяет состояние базы данных
 type DatabaseChecker struct {
-	name      string
-	checkFunc func(ctx context.Context) error
+	name        string
+	checkFunc   func(ctx context.Context) error
+	versionFunc func(ctx context.Context) (string, error)
 }
 
 // NewDatabaseChecker создает новый DatabaseChecker
@@ -105,10 +303,25 @@ func (c *DatabaseChecker) Name() string {
 	return c.name
 }
 
+// WithVersion задает получение версии сервера БД
+func (c *DatabaseChecker) WithVersion(versionFunc func(ctx context.Context) (string, error)) *DatabaseChecker {
+	c.versionFunc = versionFunc
+	return c
+}
+
+// Version возвращает версию сервера БД, пустую если получение версии не задано
+func (c *DatabaseChecker) Version(ctx context.Context) (string, error) {
+	if c.versionFunc == nil {
+		return "", nil
+	}
+	return c.versionFunc(ctx)
+}
+
 // KafkaChecker проверяет состояние Kafka
 type KafkaChecker struct {
-	name      string
-	checkFunc func(ctx context.Context) error
+	name        string
+	checkFunc   func(ctx context.Context) error
+	versionFunc func(ctx context.Context) (string, error)
 }
 
 // NewKafkaChecker создает новый KafkaChecker
@@ -129,6 +342,20 @@ func (c *KafkaChecker) Name() string {
 	return c.name
 }
 
+// WithVersion задает получение сведений о кластере Kafka
+func (c *KafkaChecker) WithVersion(versionFunc func(ctx context.Context) (string, error)) *KafkaChecker {
+	c.versionFunc = versionFunc
+	return c
+}
+
+// Version возвращает сведения о кластере, пустые если их получение не задано
+func (c *KafkaChecker) Version(ctx context.Context) (string, error) {
+	if c.versionFunc == nil {
+		return "", nil
+	}
+	return c.versionFunc(ctx)
+}
+
 // CacheChecker проверяет состояние кеша
 type CacheChecker struct {
 	name      string
@@ -152,3 +379,35 @@ func (c *CacheChecker) Check(ctx context.Context) error {
 func (c *CacheChecker) Name() string {
 	return c.name
 }
+
+// DLQChecker проверяет, что топик DLQ доступен для записи
+// Без DLQ упавшие сообщения теряются, поэтому недоступный брокер делает сервис unhealthy
+type DLQChecker struct {
+	name   string
+	target interface{}
+}
+
+// NewDLQChecker создает новый DLQChecker
+// dlq проверяется через метод Ping(ctx) error; сервис без Ping (выключенный DLQ) всегда здоров
+func NewDLQChecker(name string, dlq interface{}) *DLQChecker {
+	return &DLQChecker{
+		name:   name,
+		target: dlq,
+	}
+}
+
+// Check запрашивает метаданные топика DLQ у брокера
+func (c *DLQChecker) Check(ctx context.Context) error {
+	pinger, ok := c.target.(interface {
+		Ping(ctx context.Context) error
+	})
+	if !ok {
+		return nil
+	}
+	return pinger.Ping(ctx)
+}
+
+// Name возвращает имя checker'а
+func (c *DLQChecker) Name() string {
+	return c.name
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -157,6 +158,51 @@ func TestCacheChecker(t *testing.T) {
 	}
 }
 
+func TestDLQChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		dlq     interface{}
+		wantErr bool
+	}{
+		{name: "disabled dlq without ping", dlq: &noOpDLQ{}},
+		{name: "reachable broker", dlq: &mockDLQ{}},
+		{name: "broker failure", dlq: &mockDLQ{err: errors.New("kafka is unreachable: connection refused")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			h.AddChecker(NewDLQChecker("dlq", tt.dlq))
+
+			results := h.Check(context.Background())
+			result := results["dlq"].(map[string]interface{})
+
+			wantStatus := "healthy"
+			if tt.wantErr {
+				wantStatus = "unhealthy"
+			}
+			if result["status"] != wantStatus || results["overall"] != wantStatus {
+				t.Errorf("Expected dlq and overall status %s, got %v and %v", wantStatus, result["status"], results["overall"])
+			}
+			if tt.wantErr && result["error"] == nil {
+				t.Error("Expected broker error in result")
+			}
+		})
+	}
+}
+
+// noOpDLQ выключенный DLQ без проверки доступности
+type noOpDLQ struct{}
+
+// mockDLQ DLQ, чья проверка брокера возвращает err
+type mockDLQ struct {
+	err error
+}
+
+func (m *mockDLQ) Ping(ctx context.Context) error {
+	return m.err
+}
+
 // mockChecker для тестирования
 type mockChecker struct {
 	name       string
@@ -174,3 +220,171 @@ func (m *mockChecker) Check(ctx context.Context) error {
 func (m *mockChecker) Name() string {
 	return m.name
 }
+
+func TestReady_Versions(t *testing.T) {
+	versionCalls := 0
+	dbErr := error(nil)
+
+	h := New()
+	h.AddChecker(NewDatabaseChecker("postgres", func(ctx context.Context) error {
+		return dbErr
+	}).WithVersion(func(ctx context.Context) (string, error) {
+		versionCalls++
+		return "PostgreSQL 15.4", nil
+	}))
+	h.AddChecker(NewKafkaChecker("kafka", func(ctx context.Context) error {
+		return nil
+	}))
+
+	summary := h.Ready(context.Background())
+	if summary["status"] != "healthy" {
+		t.Errorf("Expected healthy status, got %v", summary["status"])
+	}
+	if summary["postgres_version"] != "PostgreSQL 15.4" {
+		t.Errorf("Expected postgres_version, got %v", summary["postgres_version"])
+	}
+	// Kafka checker без получения версии поле не добавляет
+	if _, ok := summary["kafka_version"]; ok {
+		t.Errorf("Expected no kafka_version, got %v", summary["kafka_version"])
+	}
+
+	// Версия берется из кеша
+	h.Ready(context.Background())
+	if versionCalls != 1 {
+		t.Errorf("Expected version to be fetched once, got %d calls", versionCalls)
+	}
+
+	// Для недоступной БД версия не выводится
+	dbErr = errors.New("connection refused")
+	summary = h.Ready(context.Background())
+	if summary["status"] != "unhealthy" {
+		t.Errorf("Expected unhealthy status, got %v", summary["status"])
+	}
+	if _, ok := summary["postgres_version"]; ok {
+		t.Error("Expected no postgres_version for failed check")
+	}
+}
+
+func TestReady_VersionExpires(t *testing.T) {
+	versionCalls := 0
+	h := New().WithVersionTTL(0)
+	h.AddChecker(NewDatabaseChecker("postgres", func(ctx context.Context) error {
+		return nil
+	}).WithVersion(func(ctx context.Context) (string, error) {
+		versionCalls++
+		return "PostgreSQL 15.4", nil
+	}))
+
+	h.Ready(context.Background())
+	h.Ready(context.Background())
+	if versionCalls != 2 {
+		t.Errorf("Expected version to be fetched on each call with zero TTL, got %d calls", versionCalls)
+	}
+}
+
+// countingChecker считает вызовы Check
+type countingChecker struct {
+	name  string
+	calls atomic.Int32
+	err   error
+}
+
+// Ошибка читается до счетчика: тест, увидевший вызов, может менять err без гонки
+func (c *countingChecker) Check(ctx context.Context) error {
+	err := c.err
+	c.calls.Add(1)
+	return err
+}
+
+func (c *countingChecker) Name() string {
+	return c.name
+}
+
+// waitForResult ждет, пока фоновая проверка сохранит результат
+func waitForResult(t *testing.T, h *Health) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		h.resultMu.Lock()
+		done := h.result != nil
+		h.resultMu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Background check did not complete")
+}
+
+func TestCheck_Concurrent(t *testing.T) {
+	// Каждая проверка ждет, пока запустится вторая: последовательно они не завершатся
+	started := make(chan struct{}, 2)
+	barrier := func(ctx context.Context) error {
+		started <- struct{}{}
+		deadline := time.After(time.Second)
+		for len(started) < 2 {
+			select {
+			case <-deadline:
+				return errors.New("checks are not concurrent")
+			case <-time.After(time.Millisecond):
+			}
+		}
+		return nil
+	}
+
+	h := New()
+	h.AddChecker(NewDatabaseChecker("postgres", barrier))
+	h.AddChecker(NewKafkaChecker("kafka", barrier))
+
+	results := h.Check(context.Background())
+	if results["overall"] != "healthy" {
+		t.Errorf("Expected checks to run concurrently, got %v", results)
+	}
+}
+
+func TestStart_CachesResult(t *testing.T) {
+	checker := &countingChecker{name: "postgres"}
+	h := New()
+	h.AddChecker(checker)
+
+	h.Start(time.Hour)
+	defer h.Stop()
+
+	// Первая фоновая проверка запускается сразу
+	waitForResult(t, h)
+
+	handler := h.Handler()
+	for i := 0; i < 5; i++ {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest("GET", "/health", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		h.Check(context.Background())
+	}
+
+	if calls := checker.calls.Load(); calls != 1 {
+		t.Errorf("Expected checker to run once within interval, got %d calls", calls)
+	}
+}
+
+func TestStart_MaxStaleness(t *testing.T) {
+	checker := &countingChecker{name: "postgres"}
+	h := New().WithMaxStaleness(time.Nanosecond)
+	h.AddChecker(checker)
+
+	h.Start(time.Hour)
+	defer h.Stop()
+
+	waitForResult(t, h)
+	time.Sleep(time.Millisecond)
+
+	// Результат старше допустимого, проверка выполняется заново
+	checker.err = errors.New("connection refused")
+	if results := h.Check(context.Background()); results["overall"] != "unhealthy" {
+		t.Errorf("Expected fresh unhealthy result, got %v", results["overall"])
+	}
+	if calls := checker.calls.Load(); calls != 2 {
+		t.Errorf("Expected stale result to be rechecked, got %d calls", calls)
+	}
+}
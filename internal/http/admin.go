@@ -0,0 +1,223 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/model"
+)
+
+// handleAdmin маршрутизирует служебные запросы
+// Все эндпоинты /admin/* доступны только если они включены в конфигурации
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	if !s.adminEnabled {
+		writeError(w, apperrors.ErrAdminDisabled)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/admin/cache/keys" && r.Method == http.MethodGet:
+		s.handleCacheKeys(w, r)
+	case r.URL.Path == "/admin/cache/invalidate" && r.Method == http.MethodPost:
+		s.handleCacheInvalidate(w, r)
+	case r.URL.Path == "/admin/cache/reload" && r.Method == http.MethodPost:
+		s.handleCacheReload(w, r)
+	case r.URL.Path == "/admin/breaker/reset" && r.Method == http.MethodPost:
+		s.handleBreakerReset(w, r)
+	case r.URL.Path == "/admin/orders/delete" && r.Method == http.MethodPost:
+		s.handleOrderDelete(w, r)
+	case r.URL.Path == "/admin/orders/restore" && r.Method == http.MethodPost:
+		s.handleOrderRestore(w, r)
+	case r.URL.Path == "/admin/maintenance" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		s.handleMaintenance(w, r)
+	default:
+		writeError(w, apperrors.ErrNotFound)
+	}
+}
+
+// handleCacheKeys возвращает список UID заказов в кеше
+// count - число всех ключей, список обрезается до maxListRows
+func (s *Server) handleCacheKeys(w http.ResponseWriter, r *http.Request) {
+	keys := s.Cache.Keys()
+	sort.Strings(keys)
+
+	count := len(keys)
+	truncated := count > s.maxListRows
+	if truncated {
+		keys = keys[:s.maxListRows]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"count":     count,
+		"keys":      keys,
+		"truncated": truncated,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// handleCacheInvalidate удаляет из кеша все заказы покупателя
+func (s *Server) handleCacheInvalidate(w http.ResponseWriter, r *http.Request) {
+	customerID := r.URL.Query().Get("customer_id")
+	if customerID == "" {
+		writeError(w, apperrors.InvalidParameter("customer_id is required"))
+		return
+	}
+
+	removed := s.Cache.DeleteWhere(func(order *model.Order) bool {
+		return order.CustomerID == customerID
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"customer_id": customerID,
+		"removed":     removed,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// handleCacheReload заменяет содержимое кеша заказами из БД
+// Кеш очищается только после успешной загрузки, частота вызовов ограничена
+func (s *Server) handleCacheReload(w http.ResponseWriter, r *http.Request) {
+	allowed, err := s.reloadLimiter.Allow(r.Context(), "cache-reload")
+	if err != nil || !allowed {
+		writeError(w, apperrors.ErrRateLimited)
+		return
+	}
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	orders, err := s.DB.LoadAllOrders(r.Context())
+	if err != nil {
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to load orders", "ORDER_LOAD_FAILED"))
+		return
+	}
+
+	s.Cache.Clear()
+	s.Cache.LoadAll(orders)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"loaded": len(orders),
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// handleBreakerReset вручную закрывает circuit breaker
+// Параметр name выбирает один breaker, без него сбрасываются все
+func (s *Server) handleBreakerReset(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	names := make([]string, 0, len(s.breakers))
+	if name != "" {
+		if _, ok := s.breakers[name]; !ok {
+			writeError(w, apperrors.NewWithCode(apperrors.ErrorTypeNotFound, "Breaker not found", "BREAKER_NOT_FOUND"))
+			return
+		}
+		names = append(names, name)
+	} else {
+		for key := range s.breakers {
+			names = append(names, key)
+		}
+	}
+
+	states := make(map[string]string, len(names))
+	for _, key := range names {
+		breaker := s.breakers[key]
+		breaker.Reset()
+		states[key] = breaker.GetState().String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"breakers": states,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// handleOrderDelete удаляет заказ и убирает его из кеша
+// При включенном soft delete заказ только помечается удаленным
+func (s *Server) handleOrderDelete(w http.ResponseWriter, r *http.Request) {
+	orderUID := r.URL.Query().Get("order_uid")
+	if orderUID == "" {
+		writeError(w, apperrors.InvalidParameter("order_uid is required"))
+		return
+	}
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	if err := s.DB.DeleteOrder(r.Context(), orderUID); err != nil {
+		if errors.Is(err, apperrors.ErrOrderNotFound) {
+			writeError(w, apperrors.ErrOrderNotFound)
+			return
+		}
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to delete order", "ORDER_DELETE_FAILED"))
+		return
+	}
+	s.Cache.Delete(orderUID)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"order_uid": orderUID,
+		"deleted":   true,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// handleOrderRestore восстанавливает мягко удаленный заказ и возвращает его в кеш
+func (s *Server) handleOrderRestore(w http.ResponseWriter, r *http.Request) {
+	orderUID := r.URL.Query().Get("order_uid")
+	if orderUID == "" {
+		writeError(w, apperrors.InvalidParameter("order_uid is required"))
+		return
+	}
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	if err := s.DB.RestoreOrder(r.Context(), orderUID); err != nil {
+		if errors.Is(err, apperrors.ErrOrderNotFound) {
+			writeError(w, apperrors.ErrOrderNotFound)
+			return
+		}
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to restore order", "ORDER_RESTORE_FAILED"))
+		return
+	}
+
+	order, err := s.DB.GetOrderByUID(r.Context(), orderUID)
+	if err == nil && order != nil {
+		s.Cache.Set(order)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"order_uid": orderUID,
+		"restored":  true,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
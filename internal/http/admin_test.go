@@ -0,0 +1,276 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wbtest/internal/circuitbreaker"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/model"
+)
+
+func TestServer_handleCacheKeys(t *testing.T) {
+	cache := NewMockOrderCache()
+	server := NewServer(cache, NewMockOrderRepository()).WithAdmin(true)
+
+	cache.Set(&model.Order{OrderUID: "order-b"})
+	cache.Set(&model.Order{OrderUID: "order-a"})
+
+	req := httptest.NewRequest("GET", "/admin/cache/keys", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Count int      `json:"count"`
+		Keys  []string `json:"keys"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Count != 2 {
+		t.Errorf("Expected count 2, got %d", response.Count)
+	}
+	if len(response.Keys) != 2 || response.Keys[0] != "order-a" || response.Keys[1] != "order-b" {
+		t.Errorf("Expected sorted keys [order-a order-b], got %v", response.Keys)
+	}
+}
+
+func TestServer_handleCacheKeys_Truncated(t *testing.T) {
+	cache := NewMockOrderCache()
+	server := NewServer(cache, NewMockOrderRepository()).WithAdmin(true).WithMaxListRows(2)
+
+	for _, uid := range []string{"order-c", "order-a", "order-b"} {
+		cache.Set(&model.Order{OrderUID: uid})
+	}
+
+	req := httptest.NewRequest("GET", "/admin/cache/keys", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	var response struct {
+		Count     int      `json:"count"`
+		Keys      []string `json:"keys"`
+		Truncated bool     `json:"truncated"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Count != 3 || !response.Truncated {
+		t.Errorf("Expected count 3 and truncated, got %d %v", response.Count, response.Truncated)
+	}
+	if len(response.Keys) != 2 || response.Keys[0] != "order-a" || response.Keys[1] != "order-b" {
+		t.Errorf("Expected keys [order-a order-b], got %v", response.Keys)
+	}
+}
+
+func TestServer_handleAdmin_Disabled(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	req := httptest.NewRequest("GET", "/admin/cache/keys", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestServer_handleCacheInvalidate(t *testing.T) {
+	cache := NewMockOrderCache()
+	server := NewServer(cache, NewMockOrderRepository()).WithAdmin(true)
+
+	cache.Set(&model.Order{OrderUID: "order-1", CustomerID: "alice"})
+	cache.Set(&model.Order{OrderUID: "order-2", CustomerID: "bob"})
+	cache.Set(&model.Order{OrderUID: "order-3", CustomerID: "alice"})
+
+	req := httptest.NewRequest("POST", "/admin/cache/invalidate?customer_id=alice", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["removed"] != float64(2) {
+		t.Errorf("Expected 2 removed orders, got %v", response["removed"])
+	}
+	if _, exists := cache.Get("order-2"); !exists {
+		t.Error("Expected order of other customer to remain in cache")
+	}
+
+	// Без customer_id возвращаем 400
+	req = httptest.NewRequest("POST", "/admin/cache/invalidate", nil)
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestServer_handleBreakerReset(t *testing.T) {
+	breaker := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: time.Hour})
+	other := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: time.Hour})
+
+	// Открываем оба breaker ошибкой
+	for _, cb := range []*circuitbreaker.CircuitBreaker{breaker, other} {
+		cb.Execute(context.Background(), func() (interface{}, error) {
+			return nil, errors.New("downstream failure")
+		})
+		if cb.GetState() != circuitbreaker.StateOpen {
+			t.Fatalf("Expected breaker to be open, got %s", cb.GetState())
+		}
+	}
+
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository()).
+		WithAdmin(true).
+		WithBreaker("retry", breaker).
+		WithBreaker("other", other)
+
+	req := httptest.NewRequest("POST", "/admin/breaker/reset?name=retry", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Breakers map[string]string `json:"breakers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Breakers["retry"] != "CLOSED" {
+		t.Errorf("Expected retry breaker state CLOSED, got %v", response.Breakers)
+	}
+	if breaker.GetState() != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker to be closed, got %s", breaker.GetState())
+	}
+	if other.GetState() != circuitbreaker.StateOpen {
+		t.Errorf("Expected other breaker to stay open, got %s", other.GetState())
+	}
+
+	// Неизвестный breaker
+	req = httptest.NewRequest("POST", "/admin/breaker/reset?name=missing", nil)
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown breaker, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestServer_handleOrderDeleteRestore(t *testing.T) {
+	cache := NewMockOrderCache()
+	db := NewMockOrderRepository()
+	server := NewServer(cache, db).WithAdmin(true)
+
+	order := &model.Order{OrderUID: "order-deleted"}
+	db.orders[order.OrderUID] = order
+	cache.Set(order)
+
+	do := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := do("POST", "/admin/orders/delete?order_uid=order-deleted"); code != http.StatusOK {
+		t.Fatalf("Expected delete status %d, got %d", http.StatusOK, code)
+	}
+
+	// Удаленный заказ скрыт и из кеша, и из выборок по умолчанию
+	if code := do("GET", "/order/order-deleted"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for deleted order, got %d", http.StatusNotFound, code)
+	}
+	orders, _ := db.LoadAllOrders(context.Background())
+	if len(orders) != 0 {
+		t.Errorf("Expected deleted order to be hidden, got %d orders", len(orders))
+	}
+
+	// Но доступен с опцией IncludeDeleted
+	deleted, _ := db.GetOrderByUID(context.Background(), "order-deleted", interfaces.IncludeDeleted())
+	if deleted == nil {
+		t.Error("Expected deleted order to be returned with IncludeDeleted")
+	}
+
+	if code := do("POST", "/admin/orders/restore?order_uid=order-deleted"); code != http.StatusOK {
+		t.Fatalf("Expected restore status %d, got %d", http.StatusOK, code)
+	}
+	if code := do("GET", "/order/order-deleted"); code != http.StatusOK {
+		t.Errorf("Expected status %d for restored order, got %d", http.StatusOK, code)
+	}
+
+	// Повторное восстановление - заказ уже не удален
+	if code := do("POST", "/admin/orders/restore?order_uid=order-deleted"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for second restore, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestServer_handleCacheReload(t *testing.T) {
+	cache := NewMockOrderCache()
+	db := NewMockOrderRepository()
+	server := NewServer(cache, db).WithAdmin(true)
+
+	db.orders["order-1"] = &model.Order{OrderUID: "order-1", CustomerID: "old"}
+	db.orders["order-2"] = &model.Order{OrderUID: "order-2"}
+	orders, _ := db.LoadAllOrders(context.Background())
+	cache.LoadAll(orders)
+
+	// Изменяем БД напрямую, минуя сервис
+	db.orders["order-1"] = &model.Order{OrderUID: "order-1", CustomerID: "new"}
+	delete(db.orders, "order-2")
+	db.orders["order-3"] = &model.Order{OrderUID: "order-3"}
+
+	req := httptest.NewRequest("POST", "/admin/cache/reload", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Loaded int `json:"loaded"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Loaded != 2 {
+		t.Errorf("Expected 2 loaded orders, got %d", response.Loaded)
+	}
+
+	if order, ok := cache.Get("order-1"); !ok || order.CustomerID != "new" {
+		t.Errorf("Expected cache to contain updated order-1, got %+v", order)
+	}
+	if _, ok := cache.Get("order-2"); ok {
+		t.Error("Expected order-2 to be removed from cache")
+	}
+	if _, ok := cache.Get("order-3"); !ok {
+		t.Error("Expected order-3 to be loaded into cache")
+	}
+
+	// Повторный вызов сразу после первого ограничен
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/cache/reload", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+}
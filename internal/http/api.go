@@ -1,121 +1,592 @@
 package httpapi
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+	"wbtest/internal/buildinfo"
+	"wbtest/internal/cache"
+	"wbtest/internal/circuitbreaker"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/health"
 	"wbtest/internal/interfaces"
 	"wbtest/internal/model"
+	"wbtest/internal/ratelimit"
 )
 
+// DefaultMaxBodyBytes ограничение размера тела запроса по умолчанию
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// DefaultMaxListRows максимум строк в ответах списков по умолчанию
+const DefaultMaxListRows = 1000
+
+// DefaultOrderMaxAge время кеширования ответа с заказом по умолчанию
+// Заказ после создания не меняется, поэтому его можно кешировать в прокси и браузере
+const DefaultOrderMaxAge = 5 * time.Minute
+
+// DefaultCacheReloadInterval минимальный интервал между перезагрузками кеша из БД
+const DefaultCacheReloadInterval = time.Minute
+
+// SchemaVersionHeader заголовок с версией схемы тела POST /order, как schema-version в Kafka
+// В ответе 201 возвращает версию, по которой заказ разобран
+const SchemaVersionHeader = "Schema-Version"
+
+// DefaultDBReadTimeout ограничение на чтение заказа из БД при промахе кеша по умолчанию
+// Меньше таймаута запроса, чтобы медленная БД отличалась от прочих таймаутов
+const DefaultDBReadTimeout = 3 * time.Second
+
+// healthCheckTimeout ограничение на проверки зависимостей в /health
+const healthCheckTimeout = 5 * time.Second
+
 // Server HTTP сервер для заказов
 type Server struct {
 	Cache interfaces.OrderCache
 	DB    interfaces.OrderRepository
+
+	adminEnabled bool
+	maxBodyBytes int64
+	breakers     map[string]*circuitbreaker.CircuitBreaker
+	health       *health.Health
+	orderMaxAge  time.Duration
+	// dbReadTimeout ограничение на чтение заказа из БД в GET /order, 0 - без ограничения
+	dbReadTimeout time.Duration
+	// reloadLimiter ограничивает частоту /admin/cache/reload
+	reloadLimiter ratelimit.RateLimiter
+	// stats кеш ответов /orders/stats
+	stats *statsCache
+	// statsHistory снимки статистики кеша для /stats/history, nil - история выключена
+	statsHistory *cache.StatsHistory
+	// prettyJSON отступы в JSON ответах по умолчанию, ?pretty переопределяет
+	prettyJSON bool
+	// maxListRows максимум строк в ответах списков
+	maxListRows int
+	// maintenance режим обслуживания: изменяющие запросы отклоняются с 503, чтение работает
+	maintenance atomic.Bool
+	// strictJSON отклоняет тела запросов с полями, которых нет в модели
+	strictJSON bool
+	// requireSchemaVersion отклоняет заказы без версии схемы вместо разбора как v1
+	requireSchemaVersion bool
 }
 
 // NewServer создает сервер
 func NewServer(c interfaces.OrderCache, db interfaces.OrderRepository) *Server {
-	return &Server{Cache: c, DB: db}
+	return &Server{
+		Cache:         c,
+		DB:            db,
+		maxBodyBytes:  DefaultMaxBodyBytes,
+		orderMaxAge:   DefaultOrderMaxAge,
+		dbReadTimeout: DefaultDBReadTimeout,
+		reloadLimiter: newReloadLimiter(DefaultCacheReloadInterval),
+		stats:         newStatsCache(DefaultStatsTTL),
+		maxListRows:   DefaultMaxListRows,
+	}
+}
+
+// newReloadLimiter разрешает одну перезагрузку кеша за interval
+func newReloadLimiter(interval time.Duration) ratelimit.RateLimiter {
+	return ratelimit.NewTokenBucket(ratelimit.Config{Requests: 1, Window: interval})
+}
+
+// WithBreaker регистрирует circuit breaker для сброса через /admin/breaker/reset
+// nil игнорируется
+func (s *Server) WithBreaker(name string, breaker *circuitbreaker.CircuitBreaker) *Server {
+	if breaker == nil {
+		return s
+	}
+	if s.breakers == nil {
+		s.breakers = make(map[string]*circuitbreaker.CircuitBreaker)
+	}
+	s.breakers[name] = breaker
+	return s
+}
+
+// WithMaxBodyBytes задает максимальный размер тела запроса
+// Значение <= 0 оставляет DefaultMaxBodyBytes
+func (s *Server) WithMaxBodyBytes(n int64) *Server {
+	if n > 0 {
+		s.maxBodyBytes = n
+	}
+	return s
+}
+
+// WithMaxListRows задает максимум строк в ответах списков
+// Ответ с большим числом строк обрезается и помечается truncated
+// Значение <= 0 оставляет DefaultMaxListRows
+func (s *Server) WithMaxListRows(n int) *Server {
+	if n > 0 {
+		s.maxListRows = n
+	}
+	return s
+}
+
+// WithPrettyJSON включает отступы в JSON ответах по умолчанию
+// Параметр запроса ?pretty=true|false имеет приоритет
+func (s *Server) WithPrettyJSON(enabled bool) *Server {
+	s.prettyJSON = enabled
+	return s
+}
+
+// WithStrictJSON отклоняет тела запросов с неизвестными полями с кодом UNKNOWN_FIELD
+// По умолчанию такие поля игнорируются
+func (s *Server) WithStrictJSON(enabled bool) *Server {
+	s.strictJSON = enabled
+	return s
+}
+
+// WithRequireSchemaVersion требует версию схемы в POST /order
+// Без нее заказ отклоняется с 400, иначе разбирается как v1
+func (s *Server) WithRequireSchemaVersion(enabled bool) *Server {
+	s.requireSchemaVersion = enabled
+	return s
+}
+
+// encoder возвращает JSON encoder ответа
+// По умолчанию ответ компактный, отступы нужны только при отладке
+func (s *Server) encoder(w io.Writer, r *http.Request) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	pretty := s.prettyJSON
+	if value, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		pretty = value
+	}
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder
+}
+
+// WithHealth подключает проверки зависимостей к /health
+// Если хотя бы одна проверка не прошла, /health отвечает 503
+func (s *Server) WithHealth(h *health.Health) *Server {
+	s.health = h
+	return s
+}
+
+// WithOrderMaxAge задает max-age в Cache-Control для ответов с заказом
+// Значение <= 0 отключает кеширование (no-cache)
+func (s *Server) WithOrderMaxAge(d time.Duration) *Server {
+	s.orderMaxAge = d
+	return s
+}
+
+// WithDBReadTimeout задает ограничение на чтение заказа из БД при промахе кеша
+// По его истечении GET /order отвечает 504 DB_TIMEOUT. Значение <= 0 снимает ограничение
+func (s *Server) WithDBReadTimeout(d time.Duration) *Server {
+	s.dbReadTimeout = d
+	return s
+}
+
+// WithCacheReloadInterval задает минимальный интервал между перезагрузками кеша
+// Значение <= 0 оставляет DefaultCacheReloadInterval
+func (s *Server) WithCacheReloadInterval(interval time.Duration) *Server {
+	if interval > 0 {
+		s.reloadLimiter = newReloadLimiter(interval)
+	}
+	return s
+}
+
+// WithStatsTTL задает время кеширования /orders/stats
+// Значение <= 0 отключает кеширование
+func (s *Server) WithStatsTTL(ttl time.Duration) *Server {
+	s.stats = newStatsCache(ttl)
+	return s
+}
+
+// WithStatsHistory подключает историю статистики кеша к /stats/history
+func (s *Server) WithStatsHistory(history *cache.StatsHistory) *Server {
+	s.statsHistory = history
+	return s
+}
+
+// WithAdmin включает служебные эндпоинты /admin/*
+func (s *Server) WithAdmin(enabled bool) *Server {
+	s.adminEnabled = enabled
+	return s
 }
 
 // ServeHTTP маршрутизирует запросы
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.rejectedByMaintenance(r) {
+		writeMaintenance(w)
+		return
+	}
+
+	if r.URL.Path == "/health/ready" {
+		s.handleReady(w, r)
+		return
+	}
 	if r.URL.Path == "/health" {
 		s.handleHealth(w, r)
 		return
 	}
 
+	if r.URL.Path == "/version" && r.Method == http.MethodGet {
+		s.handleVersion(w, r)
+		return
+	}
+
+	if r.URL.Path == "/orders/stats" && r.Method == http.MethodGet {
+		s.handleOrderStats(w, r)
+		return
+	}
+
+	if r.URL.Path == "/stats/history" && r.Method == http.MethodGet {
+		s.handleStatsHistory(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, brandsPathPrefix) && r.Method == http.MethodGet {
+		s.handleBrandCount(w, r)
+		return
+	}
+
+	if r.URL.Path == ExportPath && r.Method == http.MethodGet {
+		s.handleExportOrders(w, r)
+		return
+	}
+
+	if r.URL.Path == "/orders" && r.Method == http.MethodGet {
+		s.handleListOrders(w, r)
+		return
+	}
+
 	if r.URL.Path == "/order" && r.Method == "POST" {
 		s.handleCreateOrder(w, r)
 		return
 	}
 
+	if strings.HasPrefix(r.URL.Path, "/order/track/") && r.Method == http.MethodGet {
+		s.handleGetOrdersByTrack(w, r)
+		return
+	}
+
 	if strings.HasPrefix(r.URL.Path, "/order/") {
 		s.handleGetOrder(w, r)
 		return
 	}
 
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		s.handleAdmin(w, r)
+		return
+	}
+
 	serveStatic(w, r)
 }
 
+// RouteLabel возвращает маршрут запроса для меток HTTP метрик
+// Идентификаторы в пути заменяются шаблоном, остальные пути сводятся к static,
+// иначе каждый номер заказа или случайный путь создавал бы отдельный временной ряд
+func RouteLabel(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case path == "/health", path == "/health/ready", path == "/version",
+		path == "/orders/stats", path == "/stats/history", path == ExportPath,
+		path == "/orders", path == "/order":
+		return path
+	case strings.HasPrefix(path, brandsPathPrefix):
+		return brandsPathPrefix + "{brand}"
+	case strings.HasPrefix(path, "/order/track/"):
+		return "/order/track/{track}"
+	case strings.HasPrefix(path, "/order/"):
+		return "/order/{uid}"
+	case strings.HasPrefix(path, "/admin/"):
+		return "/admin/*"
+	default:
+		return "static"
+	}
+}
+
+// handleVersion возвращает сведения о сборке
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.encoder(w, r).Encode(buildinfo.Get()); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
 // handleHealth возвращает статус
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	cacheStats := s.Cache.GetStats()
 	response := map[string]interface{}{
 		"status":     "ok",
 		"service":    "order-service",
-		"cache_size": s.Cache.GetStats().Size,
+		"cache_size": cacheStats.Size,
+		// Кеш заполнен и часто вытесняет записи - CACHE_MAX_SIZE мал для нагрузки
+		"cache_full": cacheStats.Full && cacheStats.EvictionRateHigh,
+	}
+
+	status := http.StatusOK
+	if s.health != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		checks := s.health.Check(ctx)
+		if checks["overall"] == "unhealthy" {
+			response["status"] = "unhealthy"
+			status = http.StatusServiceUnavailable
+		}
+		response["checks"] = checks
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+
+	// Состояние сервиса нельзя кешировать
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// handleReady возвращает готовность зависимостей и их версии
+// Версии кешируются в health.Health, поэтому частые проверки не нагружают БД
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"status": "healthy"}
+	status := http.StatusOK
+	if s.health != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		response = s.health.Ready(ctx)
+		if response["status"] == "unhealthy" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
 		return
 	}
 }
 
 // handleCreateOrder создает заказ
 func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
-	var order model.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	order, version, ok := s.decodeOrder(w, r)
+	if !ok {
 		return
 	}
 
-	// Добавляем заказ в кеш
-	s.Cache.Set(&order)
+	// Добавляем заказ в кеш, в БД он не записывается
+	s.Cache.SetCacheOnly(order)
 
+	w.Header().Set(SchemaVersionHeader, version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	response := map[string]interface{}{
 		"message":   "Order created successfully",
 		"order_uid": order.OrderUID,
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
 		return
 	}
 }
 
+// decodeOrder читает тело запроса с ограничением размера и разбирает заказ по версии схемы
+// Версия задается заголовком Schema-Version или полем schema_version, без них - v1
+// Возвращает заказ и версию, по которой он разобран. При ошибке сам пишет ответ и возвращает false
+func (s *Server) decodeOrder(w http.ResponseWriter, r *http.Request) (*model.Order, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, apperrors.ErrRequestTooLarge)
+			return nil, "", false
+		}
+		writeError(w, apperrors.ErrInvalidJSON)
+		return nil, "", false
+	}
+
+	version, err := model.NegotiateSchemaVersion(r.Header.Get(SchemaVersionHeader), body)
+	if err != nil {
+		writeError(w, apperrors.NewWithCode(apperrors.ErrorTypeValidation, err.Error(), "INVALID_SCHEMA_VERSION"))
+		return nil, "", false
+	}
+	if version == "" {
+		if s.requireSchemaVersion {
+			writeError(w, apperrors.NewWithCode(apperrors.ErrorTypeValidation,
+				"Schema version is required: set "+SchemaVersionHeader+" header or "+model.SchemaVersionField+" field",
+				"SCHEMA_VERSION_REQUIRED"))
+			return nil, "", false
+		}
+		version = model.SchemaVersionV1
+	}
+
+	decode := model.DecodeOrder
+	if s.strictJSON {
+		decode = model.DecodeOrderStrict
+	}
+	order, err := decode(version, body)
+
+	var unknownErr *model.UnknownFieldError
+	switch {
+	case err == nil:
+		return order, version, true
+	case errors.Is(err, model.ErrUnsupportedSchemaVersion):
+		writeError(w, apperrors.NewWithCode(apperrors.ErrorTypeValidation,
+			fmt.Sprintf("Unsupported schema version %q, supported: %s", version, strings.Join(model.SupportedSchemaVersions, ", ")),
+			"UNSUPPORTED_SCHEMA_VERSION"))
+	case errors.As(err, &unknownErr):
+		writeError(w, apperrors.NewWithCode(apperrors.ErrorTypeValidation, unknownErr.Error(), "UNKNOWN_FIELD"))
+	default:
+		writeError(w, apperrors.ErrInvalidJSON)
+	}
+	return nil, "", false
+}
+
 // handleGetOrder возвращает заказ по UID
+// На HEAD отвечает теми же статусом и заголовками без тела: так клиент проверяет,
+// что заказ существует, не скачивая его
 func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	orderUID := strings.TrimPrefix(r.URL.Path, "/order/")
 	if orderUID == "" {
-		http.Error(w, "Order ID is required", http.StatusBadRequest)
+		writeError(w, apperrors.InvalidParameter("Order ID is required"))
+		return
+	}
+	fields, appErr := orderProjection(r.URL.Query())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	maxItems, appErr := itemsLimit(r.URL.Query())
+	if appErr != nil {
+		writeError(w, appErr)
 		return
 	}
 
 	// Сначала пытаемся найти в кеше
 	order, ok := s.Cache.Get(orderUID)
 	if ok {
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(order); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-			return
-		}
+		s.writeOrder(w, r, order, fields, maxItems)
 		return
 	}
 
 	// Если не найдено в кеше, пытаемся загрузить из БД
 	if s.DB != nil {
 		ctx := r.Context()
+		if s.dbReadTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.dbReadTimeout)
+			defer cancel()
+		}
 		dbOrder, err := s.DB.GetOrderByUID(ctx, orderUID)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// Заказ может существовать, 404 здесь ввел бы клиента в заблуждение
+			writeError(w, apperrors.ErrDatabaseTimeout)
+			return
+		}
 		if err == nil && dbOrder != nil {
 			// Загружаем в кеш для следующих запросов
 			s.Cache.Set(dbOrder)
 
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(dbOrder); err != nil {
-				http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-				return
-			}
+			s.writeOrder(w, r, dbOrder, fields, maxItems)
 			return
 		}
 	}
 
 	// Если не найдено ни в кеше, ни в БД
-	http.Error(w, "Order not found", http.StatusNotFound)
+	writeError(w, apperrors.ErrOrderNotFound)
+}
+
+// writeOrder отдает заказ с заголовками кеширования
+// Last-Modified берется из date_created, по If-Modified-Since отвечаем 304
+// ETag - хеш тела ответа, Content-Length выставляется и для HEAD
+// fields оставляет в ответе только перечисленные поля, nil - заказ целиком
+// maxItems ограничивает число товаров в ответе, noItemsLimit - все товары
+func (s *Server) writeOrder(w http.ResponseWriter, r *http.Request, order *model.Order, fields []string, maxItems int) {
+	if s.orderMaxAge > 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.orderMaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if !order.DateCreated.IsZero() {
+		modified := order.DateCreated.UTC().Truncate(time.Second)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	var response interface{} = order
+	if fields != nil || maxItems != noItemsLimit {
+		projected, err := projectOrder(order, fields, maxItems)
+		if err != nil {
+			writeError(w, apperrors.ErrEncodeFailed)
+			return
+		}
+		response = projected
+	}
+
+	var body bytes.Buffer
+	if err := s.encoder(&body, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.Header().Set("ETag", bodyETag(body.Bytes()))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Write(body.Bytes())
+}
+
+// bodyETag возвращает сильный ETag тела ответа
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// handleGetOrdersByTrack возвращает заказы по трек-номеру
+// Трек-номер может принадлежать нескольким заказам, поэтому ответ всегда список
+func (s *Server) handleGetOrdersByTrack(w http.ResponseWriter, r *http.Request) {
+	trackNumber := strings.TrimPrefix(r.URL.Path, "/order/track/")
+	if trackNumber == "" {
+		writeError(w, apperrors.InvalidParameter("Track number is required"))
+		return
+	}
+
+	if s.DB == nil {
+		writeError(w, apperrors.ErrOrderNotFound)
+		return
+	}
+
+	orders, err := s.DB.GetOrderByTrackNumber(r.Context(), trackNumber)
+	if err != nil {
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to load orders", "ORDER_LOAD_FAILED"))
+		return
+	}
+	if len(orders) == 0 {
+		writeError(w, apperrors.ErrOrderNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.encoder(w, r).Encode(orders); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
 }
 
 // serveStatic отдает статику
@@ -132,7 +603,7 @@ func serveStatic(w http.ResponseWriter, r *http.Request) {
 	filePath := filepath.Join("web", cleanPath)
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		http.NotFound(w, r)
+		writeError(w, apperrors.ErrNotFound)
 		return
 	}
 
@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/health"
 	"wbtest/internal/interfaces"
 	"wbtest/internal/model"
 )
@@ -34,6 +41,18 @@ func (m *MockOrderCache) Set(order *model.Order) {
 	m.orders[order.OrderUID] = order
 }
 
+func (m *MockOrderCache) SetCacheOnly(order *model.Order) {
+	m.orders[order.OrderUID] = order
+}
+
+func (m *MockOrderCache) SetIfNewer(order *model.Order) bool {
+	if cached, exists := m.orders[order.OrderUID]; exists && cached.DateCreated.After(order.DateCreated) {
+		return false
+	}
+	m.orders[order.OrderUID] = order
+	return true
+}
+
 func (m *MockOrderCache) LoadAll(orders []*model.Order) {
 	for _, order := range orders {
 		m.orders[order.OrderUID] = order
@@ -44,10 +63,29 @@ func (m *MockOrderCache) Delete(orderUID string) {
 	delete(m.orders, orderUID)
 }
 
+func (m *MockOrderCache) DeleteWhere(pred func(*model.Order) bool) int {
+	removed := 0
+	for uid, order := range m.orders {
+		if pred(order) {
+			delete(m.orders, uid)
+			removed++
+		}
+	}
+	return removed
+}
+
 func (m *MockOrderCache) Size() int {
 	return len(m.orders)
 }
 
+func (m *MockOrderCache) Keys() []string {
+	keys := make([]string, 0, len(m.orders))
+	for key := range m.orders {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 func (m *MockOrderCache) Clear() {
 	m.orders = make(map[string]*model.Order)
 }
@@ -62,18 +100,30 @@ func (m *MockOrderCache) Stop() {}
 
 // MockOrderRepository мок БД
 type MockOrderRepository struct {
-	orders map[string]*model.Order
+	orders     map[string]*model.Order
+	deleted    map[string]bool
+	statsCalls int
+	// loadAfterCalls число вызовов LoadOrdersAfter, loadAfterErrs - ошибки вызовов по порядку
+	loadAfterCalls int
+	loadAfterErrs  []error
+	// getDelay задержка GetOrderByUID, прерывается отменой контекста
+	getDelay time.Duration
 }
 
 func NewMockOrderRepository() *MockOrderRepository {
 	return &MockOrderRepository{
-		orders: make(map[string]*model.Order),
+		orders:  make(map[string]*model.Order),
+		deleted: make(map[string]bool),
 	}
 }
 
-func (m *MockOrderRepository) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
+func (m *MockOrderRepository) LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
 	var orders []*model.Order
-	for _, order := range m.orders {
+	for uid, order := range m.orders {
+		if m.deleted[uid] && !options.IncludeDeleted {
+			continue
+		}
 		orders = append(orders, order)
 	}
 	return orders, nil
@@ -84,13 +134,171 @@ func (m *MockOrderRepository) SaveOrder(ctx context.Context, order *model.Order)
 	return nil
 }
 
-func (m *MockOrderRepository) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+func (m *MockOrderRepository) UpsertOrder(ctx context.Context, order *model.Order, version int64) (bool, error) {
+	m.orders[order.OrderUID] = order
+	return true, nil
+}
+
+func (m *MockOrderRepository) GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error) {
+	if m.getDelay > 0 {
+		select {
+		case <-time.After(m.getDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	options := interfaces.ApplyQueryOptions(opts...)
+	if m.deleted[orderUID] && !options.IncludeDeleted {
+		return nil, nil
+	}
 	if order, exists := m.orders[orderUID]; exists {
 		return order, nil
 	}
 	return nil, nil
 }
 
+func (m *MockOrderRepository) GetOrderByTrackNumber(ctx context.Context, trackNumber string) ([]*model.Order, error) {
+	var orders []*model.Order
+	for _, order := range m.orders {
+		if order.TrackNumber == trackNumber {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MockOrderRepository) LoadOrdersAfter(ctx context.Context, afterUID string, limit int, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	m.loadAfterCalls++
+	if len(m.loadAfterErrs) > 0 {
+		err := m.loadAfterErrs[0]
+		m.loadAfterErrs = m.loadAfterErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := interfaces.ApplyQueryOptions(opts...)
+	orders := make([]*model.Order, 0)
+	for uid, order := range m.orders {
+		if !m.deleted[uid] && uid > afterUID && options.Matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	if limit < len(orders) {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+func (m *MockOrderRepository) FindOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	orders := make([]*model.Order, 0)
+	for uid, order := range m.orders {
+		if !m.deleted[uid] && options.Matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+	if options.Offset >= len(orders) {
+		return orders[:0], nil
+	}
+	orders = orders[options.Offset:]
+	if options.Limit > 0 && options.Limit < len(orders) {
+		orders = orders[:options.Limit]
+	}
+	return orders, nil
+}
+
+func (m *MockOrderRepository) DeleteOrder(ctx context.Context, orderUID string) error {
+	if _, exists := m.orders[orderUID]; !exists || m.deleted[orderUID] {
+		return apperrors.ErrOrderNotFound
+	}
+	m.deleted[orderUID] = true
+	return nil
+}
+
+func (m *MockOrderRepository) RestoreOrder(ctx context.Context, orderUID string) error {
+	if !m.deleted[orderUID] {
+		return apperrors.ErrOrderNotFound
+	}
+	delete(m.deleted, orderUID)
+	return nil
+}
+
+func (m *MockOrderRepository) GetOrderStats(ctx context.Context, since time.Time, topServices int) (*model.OrderStats, error) {
+	m.statsCalls++
+
+	stats := &model.OrderStats{
+		OrdersPerDay:        make([]model.DailyOrderCount, 0),
+		TopDeliveryServices: make([]model.DeliveryServiceCount, 0),
+	}
+	perDay := make(map[string]int64)
+	perService := make(map[string]int64)
+	for uid, order := range m.orders {
+		if m.deleted[uid] {
+			continue
+		}
+		stats.TotalOrders++
+		stats.TotalRevenue += int64(order.Payment.Amount)
+		perService[order.DeliveryService]++
+		if !order.DateCreated.Before(since) {
+			perDay[order.DateCreated.UTC().Format("2006-01-02")]++
+		}
+	}
+
+	for day, count := range perDay {
+		stats.OrdersPerDay = append(stats.OrdersPerDay, model.DailyOrderCount{Day: day, Count: count})
+	}
+	sort.Slice(stats.OrdersPerDay, func(i, j int) bool {
+		return stats.OrdersPerDay[i].Day < stats.OrdersPerDay[j].Day
+	})
+
+	for service, count := range perService {
+		stats.TopDeliveryServices = append(stats.TopDeliveryServices, model.DeliveryServiceCount{Service: service, Count: count})
+	}
+	sort.Slice(stats.TopDeliveryServices, func(i, j int) bool {
+		a, b := stats.TopDeliveryServices[i], stats.TopDeliveryServices[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Service < b.Service
+	})
+	if len(stats.TopDeliveryServices) > topServices {
+		stats.TopDeliveryServices = stats.TopDeliveryServices[:topServices]
+	}
+
+	return stats, nil
+}
+
+func (m *MockOrderRepository) CountOrdersByBrand(ctx context.Context, brand string, sampleSize int) (*model.BrandCount, error) {
+	count := &model.BrandCount{Brand: brand, SampleOrderUIDs: make([]string, 0)}
+	uids := make([]string, 0)
+	for uid, order := range m.orders {
+		if m.deleted[uid] {
+			continue
+		}
+		for _, item := range order.Items {
+			if item.Brand == brand {
+				uids = append(uids, uid)
+				break
+			}
+		}
+	}
+	sort.Strings(uids)
+	count.Orders = int64(len(uids))
+	if sampleSize < len(uids) {
+		uids = uids[:sampleSize]
+	}
+	count.SampleOrderUIDs = append(count.SampleOrderUIDs, uids...)
+	return count, nil
+}
+
 func (m *MockOrderRepository) Close() {}
 
 func TestServer_handleGetOrder_CacheHit(t *testing.T) {
@@ -237,6 +445,334 @@ func TestServer_handleGetOrder_NotFound(t *testing.T) {
 	}
 }
 
+func TestServer_handleGetOrder_DBTimeout(t *testing.T) {
+	cache := NewMockOrderCache()
+	db := NewMockOrderRepository()
+	db.orders["slow-order"] = &model.Order{OrderUID: "slow-order"}
+	db.getDelay = time.Second
+
+	server := NewServer(cache, db).WithDBReadTimeout(20 * time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/order/slow-order", nil)
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	server.ServeHTTP(rr, req)
+
+	if elapsed := time.Since(start); elapsed >= db.getDelay {
+		t.Errorf("Expected handler to give up before DB responds, took %v", elapsed)
+	}
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	if response := decodeError(t, rr); response.Code != "DB_TIMEOUT" {
+		t.Errorf("Expected code DB_TIMEOUT, got %q", response.Code)
+	}
+	if _, ok := cache.Get("slow-order"); ok {
+		t.Error("Expected order not to be cached after timeout")
+	}
+
+	// Без ограничения медленный ответ БД дожидается
+	db.getDelay = 30 * time.Millisecond
+	server.WithDBReadTimeout(0)
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/order/slow-order", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d without timeout, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestServer_handleGetOrder_CacheHeaders(t *testing.T) {
+	cache := NewMockOrderCache()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache.Set(&model.Order{OrderUID: "cached-order", DateCreated: created})
+
+	server := NewServer(cache, NewMockOrderRepository()).WithOrderMaxAge(10 * time.Minute)
+
+	req := httptest.NewRequest("GET", "/order/cached-order", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=600" {
+		t.Errorf("Expected Cache-Control 'public, max-age=600', got %q", got)
+	}
+	if got := rr.Header().Get("Last-Modified"); got != created.Format(http.TimeFormat) {
+		t.Errorf("Expected Last-Modified %q, got %q", created.Format(http.TimeFormat), got)
+	}
+
+	// Повторный запрос с If-Modified-Since получает 304 без тела
+	req = httptest.NewRequest("GET", "/order/cached-order", nil)
+	req.Header.Set("If-Modified-Since", created.Format(http.TimeFormat))
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected empty body for 304, got %q", rr.Body.String())
+	}
+}
+
+func TestServer_handleHealth_NoStore(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Expected Cache-Control 'no-store', got %q", got)
+	}
+}
+
+func TestServer_handleReady(t *testing.T) {
+	checks := health.New()
+	checks.AddChecker(health.NewDatabaseChecker("postgres", func(ctx context.Context) error {
+		return nil
+	}).WithVersion(func(ctx context.Context) (string, error) {
+		return "PostgreSQL 15.4", nil
+	}))
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository()).WithHealth(checks)
+
+	req := httptest.NewRequest("GET", "/health/ready", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["status"] != "healthy" {
+		t.Errorf("Expected healthy status, got %v", response["status"])
+	}
+	if response["postgres_version"] != "PostgreSQL 15.4" {
+		t.Errorf("Expected postgres_version in response, got %v", response["postgres_version"])
+	}
+}
+
+func TestServer_handleGetOrder_Fields(t *testing.T) {
+	cache := NewMockOrderCache()
+	server := NewServer(cache, NewMockOrderRepository())
+
+	cache.Set(&model.Order{
+		OrderUID:    "test-order-123",
+		TrackNumber: "TRACK123",
+		Delivery:    model.Delivery{Name: "Test User", City: "Kiryat Mozkin"},
+		Payment:     model.Payment{Transaction: "test-order-123", Amount: 1000},
+		Items:       []model.Item{{ChrtID: 9934930, Name: "Mascaras"}},
+	})
+
+	t.Run("only delivery", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/order/test-order-123?fields=delivery", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(response) != 1 {
+			t.Errorf("Expected only delivery in response, got %s", rr.Body.String())
+		}
+		for _, omitted := range []string{"items", "payment", "order_uid"} {
+			if _, ok := response[omitted]; ok {
+				t.Errorf("Expected %s to be omitted, got %s", omitted, rr.Body.String())
+			}
+		}
+
+		var delivery model.Delivery
+		if err := json.Unmarshal(response["delivery"], &delivery); err != nil {
+			t.Fatalf("Failed to unmarshal delivery: %v", err)
+		}
+		if delivery.Name != "Test User" || delivery.City != "Kiryat Mozkin" {
+			t.Errorf("Unexpected delivery %+v", delivery)
+		}
+	})
+
+	t.Run("several fields", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/order/test-order-123?fields=delivery,%20payment", nil))
+
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		_, hasDelivery := response["delivery"]
+		_, hasPayment := response["payment"]
+		if len(response) != 2 || !hasDelivery || !hasPayment {
+			t.Errorf("Expected delivery and payment only, got %s", rr.Body.String())
+		}
+	})
+
+	for _, query := range []string{"fields=delivery,secret", "fields=Delivery", "fields=,"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, httptest.NewRequest("GET", "/order/test-order-123?"+query, nil))
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestServer_handleGetOrder_MaxItems(t *testing.T) {
+	cache := NewMockOrderCache()
+	server := NewServer(cache, NewMockOrderRepository())
+
+	items := make([]model.Item, 5)
+	for i := range items {
+		items[i] = model.Item{ChrtID: i + 1}
+	}
+	cache.Set(&model.Order{OrderUID: "test-order-123", Items: items})
+
+	get := func(t *testing.T, query string) map[string]json.RawMessage {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/order/test-order-123"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		items     int
+		truncated string
+	}{
+		{"truncated", "?max_items=2", 2, "true"},
+		{"zero", "?max_items=0", 0, "true"},
+		{"limit above count", "?max_items=10", 5, "false"},
+		{"with fields", "?fields=items&max_items=3", 3, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := get(t, tt.query)
+
+			var got []model.Item
+			if err := json.Unmarshal(response["items"], &got); err != nil {
+				t.Fatalf("Failed to unmarshal items: %v", err)
+			}
+			if len(got) != tt.items {
+				t.Fatalf("Expected %d items, got %d", tt.items, len(got))
+			}
+			for i, item := range got {
+				if item.ChrtID != i+1 {
+					t.Errorf("Expected first items in order, items[%d].chrt_id = %d", i, item.ChrtID)
+				}
+			}
+			if string(response["items_truncated"]) != tt.truncated {
+				t.Errorf("items_truncated = %s, want %s", response["items_truncated"], tt.truncated)
+			}
+			if string(response["total_items"]) != "5" {
+				t.Errorf("total_items = %s, want 5", response["total_items"])
+			}
+		})
+	}
+
+	t.Run("full by default", func(t *testing.T) {
+		response := get(t, "")
+		var got []model.Item
+		if err := json.Unmarshal(response["items"], &got); err != nil {
+			t.Fatalf("Failed to unmarshal items: %v", err)
+		}
+		if len(got) != 5 {
+			t.Errorf("Expected all 5 items, got %d", len(got))
+		}
+		for _, field := range []string{"items_truncated", "total_items"} {
+			if _, ok := response[field]; ok {
+				t.Errorf("Expected no %s without max_items", field)
+			}
+		}
+	})
+
+	// Ответ обрезается, а заказ в кеше остается целым
+	if order, _ := cache.Get("test-order-123"); len(order.Items) != 5 {
+		t.Errorf("Expected cached order to keep 5 items, got %d", len(order.Items))
+	}
+
+	for _, query := range []string{"max_items=-1", "max_items=abc"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, httptest.NewRequest("GET", "/order/test-order-123?"+query, nil))
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestServer_handleGetOrdersByTrack(t *testing.T) {
+	cache := NewMockOrderCache()
+	db := NewMockOrderRepository()
+	db.orders["order-1"] = &model.Order{OrderUID: "order-1", TrackNumber: "WBILMTESTTRACK"}
+	db.orders["order-2"] = &model.Order{OrderUID: "order-2", TrackNumber: "WBILMTESTTRACK"}
+	db.orders["order-3"] = &model.Order{OrderUID: "order-3", TrackNumber: "OTHERTRACK"}
+
+	server := NewServer(cache, db)
+
+	req, err := http.NewRequest("GET", "/order/track/WBILMTESTTRACK", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, status)
+	}
+
+	var orders []*model.Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &orders); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	// Оба заказа с общим трек-номером должны вернуться списком
+	if len(orders) != 2 {
+		t.Fatalf("Expected 2 orders, got %d", len(orders))
+	}
+	for _, order := range orders {
+		if order.TrackNumber != "WBILMTESTTRACK" {
+			t.Errorf("Expected track number WBILMTESTTRACK, got %s", order.TrackNumber)
+		}
+	}
+}
+
+func TestServer_handleGetOrdersByTrack_NotFound(t *testing.T) {
+	cache := NewMockOrderCache()
+	db := NewMockOrderRepository()
+
+	server := NewServer(cache, db)
+
+	req, err := http.NewRequest("GET", "/order/track/UNKNOWNTRACK", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, status)
+	}
+}
+
 func TestServer_handleCreateOrder(t *testing.T) {
 	// Создаем моки
 	cache := NewMockOrderCache()
@@ -293,6 +829,133 @@ func TestServer_handleCreateOrder(t *testing.T) {
 	}
 }
 
+func TestServer_handleCreateOrder_UnknownField(t *testing.T) {
+	body := `{"order_uid":"test-order-789","payment":{"ammount":3000}}`
+
+	tests := []struct {
+		name       string
+		strictJSON bool
+		wantStatus int
+	}{
+		{name: "lenient by default", strictJSON: false, wantStatus: http.StatusCreated},
+		{name: "strict mode", strictJSON: true, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMockOrderCache()
+			server := NewServer(cache, NewMockOrderRepository()).WithStrictJSON(tt.strictJSON)
+
+			req := httptest.NewRequest("POST", "/order", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if !tt.strictJSON {
+				return
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["code"] != "UNKNOWN_FIELD" || !strings.Contains(rr.Body.String(), "payment.ammount") {
+				t.Errorf("Expected UNKNOWN_FIELD naming payment.ammount, got %s", rr.Body.String())
+			}
+			if _, exists := cache.Get("test-order-789"); exists {
+				t.Error("Expected order with unknown field not to be cached")
+			}
+		})
+	}
+}
+
+func TestServer_handleCreateOrder_SchemaVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		body        string
+		require     bool
+		wantStatus  int
+		wantCode    string
+		wantVersion string
+	}{
+		{name: "header v1", header: "1", body: `{"order_uid":"test-order-789"}`, wantStatus: http.StatusCreated, wantVersion: "1"},
+		{name: "field v1", body: `{"schema_version":"1","order_uid":"test-order-789"}`, wantStatus: http.StatusCreated, wantVersion: "1"},
+		{name: "no version defaults to v1", body: `{"order_uid":"test-order-789"}`, wantStatus: http.StatusCreated, wantVersion: "1"},
+		{name: "unsupported header", header: "2", body: `{"order_uid":"test-order-789"}`, wantStatus: http.StatusBadRequest, wantCode: "UNSUPPORTED_SCHEMA_VERSION"},
+		{name: "unsupported field", body: `{"schema_version":2,"order_uid":"test-order-789"}`, wantStatus: http.StatusBadRequest, wantCode: "UNSUPPORTED_SCHEMA_VERSION"},
+		{name: "header and field differ", header: "1", body: `{"schema_version":"2","order_uid":"test-order-789"}`, wantStatus: http.StatusBadRequest, wantCode: "INVALID_SCHEMA_VERSION"},
+		{name: "version required", require: true, body: `{"order_uid":"test-order-789"}`, wantStatus: http.StatusBadRequest, wantCode: "SCHEMA_VERSION_REQUIRED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMockOrderCache()
+			// В строгом режиме поле schema_version не считается неизвестным
+			server := NewServer(cache, NewMockOrderRepository()).
+				WithStrictJSON(true).
+				WithRequireSchemaVersion(tt.require)
+
+			req := httptest.NewRequest("POST", "/order", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(SchemaVersionHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			_, cached := cache.Get("test-order-789")
+			if tt.wantStatus == http.StatusCreated {
+				if got := rr.Header().Get(SchemaVersionHeader); got != tt.wantVersion {
+					t.Errorf("Expected %s %q, got %q", SchemaVersionHeader, tt.wantVersion, got)
+				}
+				if !cached {
+					t.Error("Expected order to be cached")
+				}
+				return
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["code"] != tt.wantCode {
+				t.Errorf("Expected code %s, got %s", tt.wantCode, rr.Body.String())
+			}
+			if cached {
+				t.Error("Expected rejected order not to be cached")
+			}
+		})
+	}
+}
+
+func TestServer_handleCreateOrder_BodyTooLarge(t *testing.T) {
+	cache := NewMockOrderCache()
+	server := NewServer(cache, NewMockOrderRepository()).WithMaxBodyBytes(64)
+
+	// Валидный JSON, но больше лимита
+	body := `{"order_uid":"test-order-789","internal_signature":"` + strings.Repeat("x", 128) + `"}`
+
+	req := httptest.NewRequest("POST", "/order", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	server.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, status)
+	}
+	if _, exists := cache.Get("test-order-789"); exists {
+		t.Error("Expected oversized order not to be cached")
+	}
+}
+
 func TestServer_handleHealth(t *testing.T) {
 	// Создаем моки
 	cache := NewMockOrderCache()
@@ -332,3 +995,163 @@ func TestServer_handleHealth(t *testing.T) {
 		t.Errorf("Expected service 'order-service', got %v", response["service"])
 	}
 }
+
+func TestServer_handleHealth_KafkaUnhealthy(t *testing.T) {
+	checks := health.New()
+	checks.AddChecker(health.NewKafkaChecker("kafka", func(ctx context.Context) error {
+		return errors.New("kafka is unreachable: connection refused")
+	}))
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository()).WithHealth(checks)
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	var response struct {
+		Status string `json:"status"`
+		Checks struct {
+			Kafka map[string]interface{} `json:"kafka"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Status != "unhealthy" {
+		t.Errorf("Expected status 'unhealthy', got %s", response.Status)
+	}
+	if response.Checks.Kafka["status"] != "unhealthy" {
+		t.Errorf("Expected kafka check unhealthy, got %v", response.Checks.Kafka)
+	}
+	if response.Checks.Kafka["error"] != "kafka is unreachable: connection refused" {
+		t.Errorf("Expected kafka error message, got %v", response.Checks.Kafka["error"])
+	}
+}
+
+func TestServer_handleVersion(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", got)
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	for _, key := range []string{"version", "commit", "build_time", "go_version"} {
+		if response[key] == "" {
+			t.Errorf("Expected non-empty %q in response, got %v", key, response)
+		}
+	}
+	if response["version"] != "dev" {
+		t.Errorf("Expected default version dev, got %q", response["version"])
+	}
+}
+
+func TestServer_PrettyJSON(t *testing.T) {
+	cache := NewMockOrderCache()
+	cache.Set(&model.Order{OrderUID: "test123", TrackNumber: "TRACK123"})
+
+	tests := []struct {
+		name     string
+		pretty   bool
+		query    string
+		indented bool
+	}{
+		{"compact by default", false, "", false},
+		{"pretty query param", false, "?pretty=true", true},
+		{"pretty default", true, "", true},
+		{"query overrides pretty default", true, "?pretty=false", false},
+		{"invalid value keeps default", false, "?pretty=yes", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(cache, NewMockOrderRepository()).WithPrettyJSON(tt.pretty)
+
+			req := httptest.NewRequest("GET", "/order/test123"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			body := rr.Body.String()
+			if indented := strings.Contains(body, "\n  \"order_uid\": \"test123\""); indented != tt.indented {
+				t.Errorf("Expected indented=%v, got body %s", tt.indented, body)
+			}
+			if !json.Valid(rr.Body.Bytes()) {
+				t.Errorf("Expected valid JSON, got %s", body)
+			}
+		})
+	}
+}
+
+func TestServer_HeadOrder(t *testing.T) {
+	cache := NewMockOrderCache()
+	cache.Set(&model.Order{OrderUID: "cached123", TrackNumber: "TRACK123"})
+	repo := NewMockOrderRepository()
+	repo.orders["stored123"] = &model.Order{OrderUID: "stored123", TrackNumber: "TRACK456"}
+	server := NewServer(cache, repo)
+
+	for _, uid := range []string{"cached123", "stored123"} {
+		t.Run(uid, func(t *testing.T) {
+			get := httptest.NewRecorder()
+			server.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/order/"+uid, nil))
+
+			head := httptest.NewRecorder()
+			server.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/order/"+uid, nil))
+
+			if head.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, head.Code)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("Expected empty body for HEAD, got %q", head.Body.String())
+			}
+			if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+				t.Errorf("Expected Content-Length %s, got %s", want, got)
+			}
+			etag := head.Header().Get("ETag")
+			if etag == "" || etag != get.Header().Get("ETag") {
+				t.Errorf("Expected HEAD ETag to match GET, got %q and %q", etag, get.Header().Get("ETag"))
+			}
+		})
+	}
+
+	t.Run("missing order", func(t *testing.T) {
+		head := httptest.NewRecorder()
+		server.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/order/missing", nil))
+		if head.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, head.Code)
+		}
+	})
+}
+
+func TestRouteLabel(t *testing.T) {
+	tests := map[string]string{
+		"/health":                     "/health",
+		"/orders":                     "/orders",
+		"/order/b563feb7b2b84b6test":  "/order/{uid}",
+		"/order/track/WBILMTESTTRACK": "/order/track/{track}",
+		"/items/brands/Vivienne":      "/items/brands/{brand}",
+		"/admin/cache/keys":           "/admin/*",
+		"/index.html":                 "static",
+		"/../../etc/passwd":           "static",
+	}
+	for path, want := range tests {
+		if got := RouteLabel(httptest.NewRequest("GET", path, nil)); got != want {
+			t.Errorf("RouteLabel(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	apperrors "wbtest/internal/errors"
+)
+
+// brandsPathPrefix префикс пути GET /items/brands/{brand}/count
+const brandsPathPrefix = "/items/brands/"
+
+// handleBrandCount возвращает число заказов с товаром бренда
+// Параметр sample добавляет в ответ до sample order_uid таких заказов, не больше maxListRows
+func (s *Server) handleBrandCount(w http.ResponseWriter, r *http.Request) {
+	brand, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, brandsPathPrefix), "/count")
+	if !ok {
+		writeError(w, apperrors.ErrNotFound)
+		return
+	}
+	if brand == "" {
+		writeError(w, apperrors.InvalidParameter("brand is required"))
+		return
+	}
+
+	sample := 0
+	if value := r.URL.Query().Get("sample"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > s.maxListRows {
+			writeError(w, apperrors.InvalidParameter("sample must be between 0 and "+strconv.Itoa(s.maxListRows)))
+			return
+		}
+		sample = n
+	}
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	count, err := s.DB.CountOrdersByBrand(r.Context(), brand, sample)
+	if err != nil {
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to count orders by brand", "BRAND_COUNT_FAILED"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.encoder(w, r).Encode(count); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"wbtest/internal/model"
+)
+
+func TestServer_handleBrandCount(t *testing.T) {
+	db := NewMockOrderRepository()
+	server := NewServer(NewMockOrderCache(), db)
+
+	add := func(uid string, brands ...string) {
+		order := &model.Order{OrderUID: uid}
+		for _, brand := range brands {
+			order.Items = append(order.Items, model.Item{Brand: brand})
+		}
+		db.orders[uid] = order
+	}
+	add("order-1", "Vivienne Sabo", "Nike")
+	add("order-2", "Nike")
+	add("order-3", "Nike", "Nike")
+	add("order-4", "Adidas")
+	add("order-5", "Nike")
+	db.deleted["order-5"] = true
+
+	req := httptest.NewRequest("GET", "/items/brands/Nike/count?sample=2", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var count model.BrandCount
+	if err := json.Unmarshal(rr.Body.Bytes(), &count); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	// Заказ с двумя товарами бренда считается один раз, удаленный не считается
+	if count.Brand != "Nike" || count.Orders != 3 {
+		t.Errorf("Expected 3 orders for Nike, got %+v", count)
+	}
+	if expected := []string{"order-1", "order-2"}; !reflect.DeepEqual(count.SampleOrderUIDs, expected) {
+		t.Errorf("Expected sample %v, got %v", expected, count.SampleOrderUIDs)
+	}
+
+	// Бренд с пробелом передается в пути закодированным
+	req = httptest.NewRequest("GET", "/items/brands/Vivienne%20Sabo/count", nil)
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	count = model.BrandCount{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &count); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if count.Orders != 1 || len(count.SampleOrderUIDs) != 0 {
+		t.Errorf("Expected 1 order without sample, got %+v", count)
+	}
+}
+
+func TestServer_handleBrandCount_InvalidRequest(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository()).WithMaxListRows(10)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/items/brands//count", http.StatusBadRequest},
+		{"/items/brands/Nike/count?sample=-1", http.StatusBadRequest},
+		{"/items/brands/Nike/count?sample=11", http.StatusBadRequest},
+		{"/items/brands/Nike", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	apperrors "wbtest/internal/errors"
+)
+
+// writeError отвечает ошибкой в JSON: type, code и message, статус - HTTPStatus ошибки
+// Причина ошибки клиенту не отдается
+func writeError(w http.ResponseWriter, appErr *apperrors.AppError) {
+	status := appErr.HTTPStatus
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(appErr)
+}
@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apperrors "wbtest/internal/errors"
+)
+
+// errorResponse тело ответа writeError
+type errorResponse struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", ct)
+	}
+	var response errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal error response %q: %v", rr.Body.String(), err)
+	}
+	return response
+}
+
+func TestServer_ErrorResponses(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository()).WithMaxBodyBytes(16)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		errType string
+		code    string
+	}{
+		{"order not found", "GET", "/order/missing", "", http.StatusNotFound, "not_found", "ORDER_NOT_FOUND"},
+		{"invalid json", "POST", "/order", "{", http.StatusBadRequest, "validation", "INVALID_JSON"},
+		{"body too large", "POST", "/order", `{"order_uid":"` + strings.Repeat("a", 32) + `"}`, http.StatusRequestEntityTooLarge, "http", "REQUEST_TOO_LARGE"},
+		{"invalid parameter", "GET", "/orders/stats?days=0", "", http.StatusBadRequest, "validation", "INVALID_PARAMETER"},
+		{"admin disabled", "GET", "/admin/cache/keys", "", http.StatusForbidden, "http", "ADMIN_DISABLED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rr.Code)
+			}
+			response := decodeError(t, rr)
+			if response.Type != tt.errType || response.Code != tt.code || response.Message == "" {
+				t.Errorf("Expected type %s and code %s, got %+v", tt.errType, tt.code, response)
+			}
+		})
+	}
+}
+
+func TestWriteError_HidesCause(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeError(rr, apperrors.WrapWithCode(
+		errSecret, apperrors.ErrorTypeDatabase, "Failed to load orders", "ORDER_LOAD_FAILED"))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), errSecret.Error()) {
+		t.Errorf("Expected cause to be hidden, got %s", rr.Body.String())
+	}
+	if response := decodeError(t, rr); response.Code != "ORDER_LOAD_FAILED" {
+		t.Errorf("Expected code ORDER_LOAD_FAILED, got %s", response.Code)
+	}
+}
+
+var errSecret = errors.New("password authentication failed for user orders")
@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/logger"
+)
+
+// ExportPath путь выгрузки всех заказов
+// Выгрузка длится дольше обычного запроса, поэтому на нее не действует HTTP_REQUEST_TIMEOUT
+const ExportPath = "/orders/export"
+
+// exportBatchSize заказов в одном запросе к БД при выгрузке
+const exportBatchSize = 500
+
+// exportWriteTimeout время на отправку одной пачки клиенту
+// Дедлайн продлевается перед каждой пачкой, поэтому WriteTimeout сервера не обрывает выгрузку
+const exportWriteTimeout = 30 * time.Second
+
+// Трейлеры ответа выгрузки: число выгруженных заказов и ошибка, прервавшая выгрузку
+// Статус 200 уже отправлен к моменту ошибки, поэтому полноту выгрузки показывают трейлеры
+const (
+	exportCountTrailer = "X-Export-Count"
+	exportErrorTrailer = "X-Export-Error"
+)
+
+// handleExportOrders выгружает все заказы в формате NDJSON, по заказу в строке
+// Заказы читаются из БД пачками по order_uid и отправляются клиенту после каждой пачки,
+// поэтому память не растет с числом заказов. Параметры from и to (RFC3339 или 2006-01-02)
+// отбирают заказы по date_created в интервале [from, to)
+func (s *Server) handleExportOrders(w http.ResponseWriter, r *http.Request) {
+	opts, appErr := exportFilter(r.URL.Query())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	// Первая пачка читается до заголовков, чтобы ошибку БД можно было вернуть статусом
+	ctx := r.Context()
+	batch, err := s.DB.LoadOrdersAfter(ctx, "", exportBatchSize, opts...)
+	if err != nil {
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to load orders", "ORDER_LOAD_FAILED"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Trailer", exportCountTrailer+", "+exportErrorTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	exported := 0
+	defer func() {
+		w.Header().Set(exportCountTrailer, strconv.Itoa(exported))
+	}()
+
+	for len(batch) > 0 {
+		// Обертки без поддержки дедлайна оставляют WriteTimeout сервера
+		_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+		for _, order := range batch {
+			if err := encoder.Encode(order); err != nil {
+				// Клиент отключился, продолжать некуда
+				logger.FromContext(ctx).Warnf("Export aborted after %d orders: %v", exported, err)
+				return
+			}
+			exported++
+		}
+		_ = rc.Flush()
+
+		if len(batch) < exportBatchSize {
+			return
+		}
+
+		batch, err = s.DB.LoadOrdersAfter(ctx, batch[len(batch)-1].OrderUID, exportBatchSize, opts...)
+		if err != nil {
+			logger.FromContext(ctx).Errorf("Export failed after %d orders: %v", exported, err)
+			w.Header().Set(exportErrorTrailer, "ORDER_LOAD_FAILED")
+			return
+		}
+	}
+}
+
+// exportFilter разбирает параметры from и to выгрузки
+func exportFilter(query url.Values) ([]interfaces.QueryOption, *apperrors.AppError) {
+	from, err := parseExportTime(query.Get("from"))
+	if err != nil {
+		return nil, apperrors.InvalidParameter("from must be RFC3339 time or YYYY-MM-DD date")
+	}
+	to, err := parseExportTime(query.Get("to"))
+	if err != nil {
+		return nil, apperrors.InvalidParameter("to must be RFC3339 time or YYYY-MM-DD date")
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, apperrors.InvalidParameter("from must be before to")
+	}
+
+	if from.IsZero() && to.IsZero() {
+		return nil, nil
+	}
+	return []interfaces.QueryOption{interfaces.CreatedBetween(from, to)}, nil
+}
+
+// parseExportTime разбирает время RFC3339 или дату, пустая строка - нулевое время
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,173 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wbtest/internal/model"
+)
+
+// readExport разбирает NDJSON ответ выгрузки и возвращает UID заказов по порядку
+func readExport(t *testing.T, rr *httptest.ResponseRecorder) []string {
+	t.Helper()
+
+	var uids []string
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var order model.Order
+		if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+			t.Fatalf("Failed to unmarshal line %d: %v", len(uids)+1, err)
+		}
+		uids = append(uids, order.OrderUID)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	return uids
+}
+
+func TestServer_handleExportOrders(t *testing.T) {
+	const total = 2*exportBatchSize + 3
+
+	db := NewMockOrderRepository()
+	for i := 0; i < total; i++ {
+		uid := fmt.Sprintf("order-%05d", i)
+		db.orders[uid] = &model.Order{OrderUID: uid}
+	}
+	db.orders["order-deleted"] = &model.Order{OrderUID: "order-deleted"}
+	db.deleted["order-deleted"] = true
+	server := NewServer(NewMockOrderCache(), db)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", ExportPath, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected Content-Type application/x-ndjson, got %q", ct)
+	}
+
+	uids := readExport(t, rr)
+	if len(uids) != total {
+		t.Fatalf("Expected %d exported orders, got %d", total, len(uids))
+	}
+	for i, uid := range uids {
+		if want := fmt.Sprintf("order-%05d", i); uid != want {
+			t.Fatalf("Line %d: expected %s, got %s", i+1, want, uid)
+		}
+	}
+
+	// Заказы читаются пачками, а не одним запросом
+	if db.loadAfterCalls != 3 {
+		t.Errorf("Expected 3 batch loads, got %d", db.loadAfterCalls)
+	}
+	trailer := rr.Result().Trailer
+	if got := trailer.Get(exportCountTrailer); got != fmt.Sprint(total) {
+		t.Errorf("Expected %s trailer %d, got %q", exportCountTrailer, total, got)
+	}
+	if got := trailer.Get(exportErrorTrailer); got != "" {
+		t.Errorf("Expected no %s trailer, got %q", exportErrorTrailer, got)
+	}
+}
+
+func TestServer_handleExportOrders_DateRange(t *testing.T) {
+	db := NewMockOrderRepository()
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	add := func(uid string, created time.Time) {
+		db.orders[uid] = &model.Order{OrderUID: uid, DateCreated: created}
+	}
+	add("order-1", day.Add(-time.Hour))
+	add("order-2", day)
+	add("order-3", day.Add(12*time.Hour))
+	add("order-4", day.AddDate(0, 0, 1))
+	server := NewServer(NewMockOrderCache(), db)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{"dates", "from=2024-03-10&to=2024-03-11", 2},
+		{"rfc3339", "from=2024-03-10T12:00:00Z", 2},
+		{"only to", "to=2024-03-10", 1},
+		{"no range", "", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, httptest.NewRequest("GET", ExportPath+"?"+tt.query, nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			if uids := readExport(t, rr); len(uids) != tt.expected {
+				t.Errorf("Expected %d orders, got %v", tt.expected, uids)
+			}
+		})
+	}
+}
+
+func TestServer_handleExportOrders_BadRequest(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	for _, query := range []string{"from=yesterday", "to=10.03.2024", "from=2024-03-11&to=2024-03-10"} {
+		t.Run(query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, httptest.NewRequest("GET", ExportPath+"?"+query, nil))
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestServer_handleExportOrders_LoadError(t *testing.T) {
+	db := NewMockOrderRepository()
+	for i := 0; i < exportBatchSize+10; i++ {
+		uid := fmt.Sprintf("order-%05d", i)
+		db.orders[uid] = &model.Order{OrderUID: uid}
+	}
+	server := NewServer(NewMockOrderCache(), db)
+
+	t.Run("first batch", func(t *testing.T) {
+		db.loadAfterErrs = []error{errors.New("connection refused")}
+
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", ExportPath, nil))
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+
+	t.Run("mid-stream", func(t *testing.T) {
+		db.loadAfterErrs = []error{nil, errors.New("connection reset")}
+
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", ExportPath, nil))
+
+		// Статус уже отправлен, обрыв выгрузки виден по трейлерам
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if uids := readExport(t, rr); len(uids) != exportBatchSize {
+			t.Errorf("Expected %d orders before failure, got %d", exportBatchSize, len(uids))
+		}
+		trailer := rr.Result().Trailer
+		if got := trailer.Get(exportErrorTrailer); got != "ORDER_LOAD_FAILED" {
+			t.Errorf("Expected %s trailer ORDER_LOAD_FAILED, got %q", exportErrorTrailer, got)
+		}
+		if got := trailer.Get(exportCountTrailer); got != fmt.Sprint(exportBatchSize) {
+			t.Errorf("Expected %s trailer %d, got %q", exportCountTrailer, exportBatchSize, got)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/model"
+)
+
+// orderFields верхнеуровневые поля заказа в JSON, их можно запросить через ?fields=
+var orderFields = jsonFieldNames(reflect.TypeOf(model.Order{}))
+
+// jsonFieldNames возвращает имена полей структуры в JSON
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// orderProjection разбирает параметр fields, например fields=delivery,payment
+// Пустой параметр - заказ целиком (nil), неизвестное поле - ошибка
+func orderProjection(query url.Values) ([]string, *apperrors.AppError) {
+	value := query.Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		if !orderFields[field] {
+			allowed := make([]string, 0, len(orderFields))
+			for name := range orderFields {
+				allowed = append(allowed, name)
+			}
+			sort.Strings(allowed)
+			return nil, apperrors.InvalidParameter("unknown field '" + field + "', expected one of " + strings.Join(allowed, ","))
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, apperrors.InvalidParameter("fields must list at least one field")
+	}
+	return fields, nil
+}
+
+// noItemsLimit значение maxItems, при котором товары заказа отдаются целиком
+const noItemsLimit = -1
+
+// itemsLimit разбирает параметр max_items, без него - noItemsLimit
+func itemsLimit(query url.Values) (int, *apperrors.AppError) {
+	value := query.Get("max_items")
+	if value == "" {
+		return noItemsLimit, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, apperrors.InvalidParameter("max_items must be a non-negative integer")
+	}
+	return n, nil
+}
+
+// projectOrder оставляет в заказе только поля fields, nil - все поля
+// Поля берутся из JSON представления заказа, поэтому совпадают с полным ответом
+// При maxItems >= 0 в ответе не больше maxItems товаров, а поля items_truncated и total_items
+// показывают, что список обрезан и сколько товаров в заказе
+func projectOrder(order *model.Order, fields []string, maxItems int) (map[string]json.RawMessage, error) {
+	totalItems := len(order.Items)
+	truncated := maxItems >= 0 && totalItems > maxItems
+	if truncated {
+		// Заказ может быть из кеша, меняем копию
+		limited := *order
+		limited.Items = order.Items[:maxItems]
+		order = &limited
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	projected := all
+	if fields != nil {
+		projected = make(map[string]json.RawMessage, len(fields)+2)
+		for _, field := range fields {
+			projected[field] = all[field]
+		}
+	}
+	if maxItems >= 0 {
+		projected["items_truncated"] = json.RawMessage(strconv.FormatBool(truncated))
+		projected["total_items"] = json.RawMessage(strconv.Itoa(totalItems))
+	}
+	return projected, nil
+}
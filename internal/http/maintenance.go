@@ -0,0 +1,69 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/logger"
+)
+
+// maintenanceRetryAfter значение Retry-After в секундах для запросов, отклоненных в режиме обслуживания
+const maintenanceRetryAfter = 60
+
+// WithMaintenance задает начальное состояние режима обслуживания
+// Во время работы режим переключается через /admin/maintenance
+func (s *Server) WithMaintenance(enabled bool) *Server {
+	s.maintenance.Store(enabled)
+	return s
+}
+
+// Maintenance сообщает, включен ли режим обслуживания
+func (s *Server) Maintenance() bool {
+	return s.maintenance.Load()
+}
+
+// rejectedByMaintenance сообщает, что запрос изменяет данные и режим обслуживания его не пропускает
+// Чтение, /health и /admin/* работают всегда, иначе режим нельзя было бы выключить
+func (s *Server) rejectedByMaintenance(r *http.Request) bool {
+	if !s.maintenance.Load() || strings.HasPrefix(r.URL.Path, "/admin/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeMaintenance отвечает 503 с подсказкой, когда повторить запрос
+func writeMaintenance(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+	writeError(w, apperrors.ErrMaintenance)
+}
+
+// handleMaintenance возвращает состояние режима обслуживания, POST с enabled=true|false переключает его
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			writeError(w, apperrors.InvalidParameter("enabled must be true or false"))
+			return
+		}
+		if s.maintenance.Swap(enabled) != enabled {
+			logger.FromContext(r.Context()).Warnf("Maintenance mode set to %t", enabled)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"maintenance": s.maintenance.Load(),
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
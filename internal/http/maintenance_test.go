@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wbtest/internal/model"
+)
+
+func TestServer_Maintenance(t *testing.T) {
+	cache := NewMockOrderCache()
+	cache.Set(&model.Order{OrderUID: "order-1"})
+	server := NewServer(cache, NewMockOrderRepository()).WithAdmin(true).WithMaintenance(true)
+
+	t.Run("writes are rejected", func(t *testing.T) {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			req := httptest.NewRequest(method, "/order", strings.NewReader(`{"order_uid":"order-2"}`))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusServiceUnavailable {
+				t.Errorf("%s: expected status %d, got %d", method, http.StatusServiceUnavailable, rr.Code)
+			}
+			if rr.Header().Get("Retry-After") == "" {
+				t.Errorf("%s: expected Retry-After header", method)
+			}
+			if !strings.Contains(rr.Body.String(), `"MAINTENANCE"`) {
+				t.Errorf("%s: expected MAINTENANCE code, got %s", method, rr.Body.String())
+			}
+		}
+		if _, ok := cache.Get("order-2"); ok {
+			t.Error("Expected rejected order not to be cached")
+		}
+	})
+
+	t.Run("reads succeed", func(t *testing.T) {
+		for _, path := range []string{"/order/order-1", "/health"} {
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			if rr.Code != http.StatusOK {
+				t.Errorf("GET %s: expected status %d, got %d", path, http.StatusOK, rr.Code)
+			}
+		}
+	})
+
+	t.Run("admin endpoint switches mode off", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/maintenance?enabled=false", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response struct {
+			Maintenance bool `json:"maintenance"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response.Maintenance || server.Maintenance() {
+			t.Error("Expected maintenance mode to be disabled")
+		}
+
+		rr = httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{"order_uid":"order-2"}`)))
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status %d after disabling maintenance, got %d", http.StatusCreated, rr.Code)
+		}
+	})
+}
+
+func TestServer_handleMaintenance(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository()).WithAdmin(true)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/maintenance?enabled=yes", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid value, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/maintenance?enabled=true", nil))
+	if rr.Code != http.StatusOK || !server.Maintenance() {
+		t.Fatalf("Expected maintenance mode to be enabled, got status %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/maintenance", nil))
+	if !strings.Contains(rr.Body.String(), `"maintenance":true`) {
+		t.Errorf("Expected GET to report maintenance on, got %s", rr.Body.String())
+	}
+}
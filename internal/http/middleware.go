@@ -0,0 +1,343 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLogMiddleware пишет access log по каждому запросу
+type AccessLogMiddleware struct {
+	logger *logrus.Logger
+}
+
+// NewAccessLogMiddleware создает middleware для access log
+func NewAccessLogMiddleware(logger *logrus.Logger) *AccessLogMiddleware {
+	return &AccessLogMiddleware{logger: logger}
+}
+
+// Handler возвращает HTTP handler с логированием запросов
+func (m *AccessLogMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Создаем ResponseWriter для отслеживания статуса и размера
+		wrapped := metrics.NewStatusRecorder(w)
+
+		next.ServeHTTP(wrapped, r)
+
+		m.logger.WithFields(logrus.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      wrapped.StatusCode,
+			"size":        wrapped.Size,
+			"duration_ms": time.Since(start).Milliseconds(),
+			"remote_addr": r.RemoteAddr,
+		}).Info("HTTP request")
+	})
+}
+
+// RecoveryMiddleware перехватывает панику в обработчике и отвечает JSON 500
+type RecoveryMiddleware struct {
+	logger *logrus.Logger
+}
+
+// NewRecoveryMiddleware создает middleware для восстановления после паники
+func NewRecoveryMiddleware(logger *logrus.Logger) *RecoveryMiddleware {
+	return &RecoveryMiddleware{logger: logger}
+}
+
+// Handler возвращает HTTP handler с восстановлением после паники
+func (m *RecoveryMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			// ErrAbortHandler штатно прерывает ответ, его обрабатывает net/http
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			m.logger.WithFields(logrus.Fields{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"panic":  fmt.Sprint(rec),
+				"stack":  string(debug.Stack()),
+			}).Error("HTTP handler panic recovered")
+
+			appErr := apperrors.NewWithCode(
+				apperrors.ErrorTypeInternal,
+				"Internal server error",
+				"INTERNAL_ERROR",
+			)
+
+			writeError(w, appErr)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthMiddleware проверяет ключ API в заголовке X-API-Key или Authorization: Bearer
+// Ключ нужен для изменяющих запросов и /admin/*, чтение по умолчанию открыто.
+// /health никогда не требует ключа, иначе его не смогут опрашивать балансировщик и оркестратор
+type AuthMiddleware struct {
+	keys         [][]byte
+	protectReads bool
+}
+
+// NewAuthMiddleware создает middleware с допустимыми ключами
+// Без ключей аутентификация выключена
+func NewAuthMiddleware(keys []string) *AuthMiddleware {
+	m := &AuthMiddleware{}
+	for _, key := range keys {
+		if key != "" {
+			m.keys = append(m.keys, []byte(key))
+		}
+	}
+	return m
+}
+
+// WithProtectReads требует ключ и для GET и HEAD запросов
+func (m *AuthMiddleware) WithProtectReads(enabled bool) *AuthMiddleware {
+	m.protectReads = enabled
+	return m
+}
+
+// Handler возвращает HTTP handler с проверкой ключа
+func (m *AuthMiddleware) Handler(next http.Handler) http.Handler {
+	if len(m.keys) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.requiresAuth(r) && !m.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="order-service"`)
+			writeError(w, apperrors.ErrUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiresAuth сообщает, нужен ли ключ для запроса
+func (m *AuthMiddleware) requiresAuth(r *http.Request) bool {
+	if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") {
+		return false
+	}
+	// Выгрузка отдает все заказы разом, поэтому защищена как служебные эндпоинты
+	if strings.HasPrefix(r.URL.Path, "/admin/") || r.URL.Path == ExportPath {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return m.protectReads
+	default:
+		return true
+	}
+}
+
+// authorized сравнивает ключ запроса с допустимыми за постоянное время
+func (m *AuthMiddleware) authorized(r *http.Request) bool {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return false
+		}
+		key = strings.TrimSpace(token)
+	}
+	if key == "" {
+		return false
+	}
+
+	matched := 0
+	for _, allowed := range m.keys {
+		matched |= subtle.ConstantTimeCompare([]byte(key), allowed)
+	}
+	return matched == 1
+}
+
+// InFlightMiddleware отслеживает выполняющиеся запросы,
+// чтобы при остановке дождаться их завершения
+// После Drain новые запросы получают 503, начатые выполняются до конца
+type InFlightMiddleware struct {
+	wg       sync.WaitGroup
+	count    int64
+	draining atomic.Bool
+}
+
+// NewInFlightMiddleware создает middleware для учета выполняющихся запросов
+func NewInFlightMiddleware() *InFlightMiddleware {
+	return &InFlightMiddleware{}
+}
+
+// Handler возвращает HTTP handler с учетом выполняющихся запросов
+func (m *InFlightMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.draining.Load() {
+			// Клиент с keep-alive переподключится к другому экземпляру
+			w.Header().Set("Connection", "close")
+			writeError(w, apperrors.ErrShuttingDown)
+			return
+		}
+
+		m.wg.Add(1)
+		atomic.AddInt64(&m.count, 1)
+		defer func() {
+			atomic.AddInt64(&m.count, -1)
+			m.wg.Done()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Drain переводит middleware в режим остановки: новые запросы отклоняются с 503
+// Вызывается в начале остановки, пока сервер еще принимает соединения
+func (m *InFlightMiddleware) Drain() {
+	m.draining.Store(true)
+}
+
+// Draining сообщает, вызван ли Drain
+func (m *InFlightMiddleware) Draining() bool {
+	return m.draining.Load()
+}
+
+// Count возвращает количество выполняющихся запросов
+func (m *InFlightMiddleware) Count() int64 {
+	return atomic.LoadInt64(&m.count)
+}
+
+// Wait ждет завершения выполняющихся запросов или отмены контекста
+// Вызывается после остановки приема новых соединений
+func (m *InFlightMiddleware) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TimeoutMiddleware ограничивает время обработки запроса
+// Контекст запроса отменяется по таймауту, поэтому обращения к БД и Kafka
+// в обработчике прерываются. Если ответ еще не начат, клиент получает 504
+type TimeoutMiddleware struct {
+	timeout time.Duration
+	// exempt пути без таймаута, например потоковая выгрузка
+	exempt map[string]bool
+}
+
+// NewTimeoutMiddleware создает middleware с таймаутом на запрос
+// timeout <= 0 отключает ограничение
+func NewTimeoutMiddleware(timeout time.Duration) *TimeoutMiddleware {
+	return &TimeoutMiddleware{timeout: timeout}
+}
+
+// WithExempt снимает таймаут с запросов по путям paths
+// Запрос по-прежнему прерывается, если клиент отключился
+func (m *TimeoutMiddleware) WithExempt(paths ...string) *TimeoutMiddleware {
+	if m.exempt == nil {
+		m.exempt = make(map[string]bool, len(paths))
+	}
+	for _, path := range paths {
+		m.exempt[path] = true
+	}
+	return m
+}
+
+// Handler возвращает HTTP handler с таймаутом на запрос
+func (m *TimeoutMiddleware) Handler(next http.Handler) http.Handler {
+	if m.timeout <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.exempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), m.timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{ResponseWriter: w, ctx: ctx}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		// Обработчик завершился по отмене контекста, ничего не ответив
+		if !tw.wroteHeader && ctx.Err() == context.DeadlineExceeded {
+			tw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// timeoutWriter подменяет ответ на 504, если он начат после истечения таймаута
+// Иначе обработчик ответил бы по ошибке отмены контекста, например 404 вместо таймаута
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+
+	if tw.ctx.Err() != context.DeadlineExceeded {
+		tw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	tw.timedOut = true
+	appErr := apperrors.NewWithCode(
+		apperrors.ErrorTypeTimeout,
+		"Request timed out",
+		"REQUEST_TIMEOUT",
+	)
+	appErr.HTTPStatus = http.StatusGatewayTimeout
+
+	header := tw.ResponseWriter.Header()
+	header.Del("Cache-Control")
+	header.Del("Last-Modified")
+	writeError(tw.ResponseWriter, appErr)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	// Тело запоздавшего ответа отбрасывается, клиент уже получил 504
+	if tw.timedOut {
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap открывает исходный writer для http.ResponseController
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,360 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apperrors "wbtest/internal/errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAccessLogMiddleware_Handler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Order not found"))
+	})
+
+	wrapped := NewAccessLogMiddleware(logger).Handler(handler)
+
+	req := httptest.NewRequest("GET", "/order/missing", nil)
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log entry %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"method": "GET",
+		"path":   "/order/missing",
+		"status": float64(http.StatusNotFound),
+		"size":   float64(len("Order not found")),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected log field %s=%v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Error("Expected log field duration_ms")
+	}
+}
+
+func TestRecoveryMiddleware_Handler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var order *struct{ OrderUID string }
+		w.Write([]byte(order.OrderUID)) // nil deref
+	})
+
+	wrapped := NewRecoveryMiddleware(logger).Handler(handler)
+
+	req := httptest.NewRequest("GET", "/order/test", nil)
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", ct)
+	}
+
+	var body apperrors.AppError
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", rr.Body.String(), err)
+	}
+	if body.Type != apperrors.ErrorTypeInternal {
+		t.Errorf("Expected error type %s, got %s", apperrors.ErrorTypeInternal, body.Type)
+	}
+	if body.Code != "INTERNAL_ERROR" {
+		t.Errorf("Expected code INTERNAL_ERROR, got %s", body.Code)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte("panic recovered")) {
+		t.Errorf("Expected panic to be logged, got %q", buf.String())
+	}
+}
+
+func TestInFlightMiddleware_Wait(t *testing.T) {
+	inFlight := NewInFlightMiddleware()
+
+	started := make(chan struct{})
+	var finished int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrapped := inFlight.Handler(handler)
+	go wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/order", nil))
+
+	<-started
+	if count := inFlight.Count(); count != 1 {
+		t.Errorf("Expected 1 in-flight request, got %d", count)
+	}
+
+	// Медленный запрос успевает завершиться в пределах таймаута
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := inFlight.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("Expected in-flight request to finish before Wait returns")
+	}
+	if count := inFlight.Count(); count != 0 {
+		t.Errorf("Expected 0 in-flight requests, got %d", count)
+	}
+}
+
+func TestInFlightMiddleware_Drain(t *testing.T) {
+	inFlight := NewInFlightMiddleware()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := inFlight.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	slow := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(slow, httptest.NewRequest("GET", "/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	inFlight.Drain()
+	if !inFlight.Draining() {
+		t.Error("Expected Draining() to be true after Drain")
+	}
+
+	// Новый запрос после Drain отклоняется, не попадая в обработчик
+	rejected := httptest.NewRecorder()
+	handler.ServeHTTP(rejected, httptest.NewRequest("GET", "/order/1", nil))
+	if rejected.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rejected.Code)
+	}
+	if !strings.Contains(rejected.Body.String(), "SHUTTING_DOWN") {
+		t.Errorf("Expected SHUTTING_DOWN code, got %s", rejected.Body.String())
+	}
+	if rejected.Header().Get("Connection") != "close" {
+		t.Errorf("Expected Connection: close, got %q", rejected.Header().Get("Connection"))
+	}
+	if count := inFlight.Count(); count != 1 {
+		t.Errorf("Expected 1 in-flight request, got %d", count)
+	}
+
+	// Начатый до Drain запрос выполняется до конца
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := inFlight.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	<-done
+	if slow.Code != http.StatusOK {
+		t.Errorf("Expected in-flight request to finish with 200, got %d", slow.Code)
+	}
+}
+
+func TestInFlightMiddleware_WaitTimeout(t *testing.T) {
+	inFlight := NewInFlightMiddleware()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	go inFlight.Handler(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := inFlight.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTimeoutMiddleware_Handler(t *testing.T) {
+	var handlerErr error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Имитация медленного обращения к БД, которое уважает контекст
+		select {
+		case <-r.Context().Done():
+			handlerErr = r.Context().Err()
+		case <-time.After(time.Second):
+		}
+		// Обработчик отвечает по ошибке, но клиент должен получить 504
+		http.Error(w, "Order not found", http.StatusNotFound)
+	})
+
+	wrapped := NewTimeoutMiddleware(20 * time.Millisecond).Handler(handler)
+
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, httptest.NewRequest("GET", "/order/slow", nil))
+
+	if handlerErr != context.DeadlineExceeded {
+		t.Errorf("Expected handler context to be cancelled with DeadlineExceeded, got %v", handlerErr)
+	}
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+
+	var body apperrors.AppError
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if body.Code != "REQUEST_TIMEOUT" {
+		t.Errorf("Expected code REQUEST_TIMEOUT, got %s", body.Code)
+	}
+}
+
+func TestTimeoutMiddleware_FastHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+
+	wrapped := NewTimeoutMiddleware(time.Second).Handler(handler)
+
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, httptest.NewRequest("POST", "/order", nil))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	if rr.Body.String() != "ok" {
+		t.Errorf("Expected body 'ok', got %q", rr.Body.String())
+	}
+}
+
+func TestTimeoutMiddleware_Exempt(t *testing.T) {
+	var deadlineSet bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadlineSet = r.Context().Deadline()
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrapped := NewTimeoutMiddleware(time.Second).WithExempt(ExportPath).Handler(handler)
+
+	wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", ExportPath, nil))
+	if deadlineSet {
+		t.Error("Expected no deadline for exempt path")
+	}
+
+	wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+	if !deadlineSet {
+		t.Error("Expected deadline for regular path")
+	}
+}
+
+func TestAuthMiddleware_Handler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		protectReads bool
+		method       string
+		path         string
+		header       string
+		value        string
+		expected     int
+	}{
+		{"post without key", false, "POST", "/order", "", "", http.StatusUnauthorized},
+		{"post with api key", false, "POST", "/order", "X-API-Key", "key-1", http.StatusOK},
+		{"post with bearer token", false, "POST", "/order", "Authorization", "Bearer key-2", http.StatusOK},
+		{"post with wrong key", false, "POST", "/order", "X-API-Key", "wrong", http.StatusUnauthorized},
+		{"post with basic auth", false, "POST", "/order", "Authorization", "Basic a2V5LTE=", http.StatusUnauthorized},
+		{"admin get without key", false, "GET", "/admin/cache/keys", "", "", http.StatusUnauthorized},
+		{"admin get with key", false, "GET", "/admin/cache/keys", "X-API-Key", "key-1", http.StatusOK},
+		{"export without key", false, "GET", ExportPath, "", "", http.StatusUnauthorized},
+		{"export with key", false, "GET", ExportPath, "X-API-Key", "key-1", http.StatusOK},
+		{"public read", false, "GET", "/order/test123", "", "", http.StatusOK},
+		{"protected read without key", true, "GET", "/order/test123", "", "", http.StatusUnauthorized},
+		{"protected read with key", true, "GET", "/order/test123", "Authorization", "bearer key-1", http.StatusOK},
+		{"health always public", true, "GET", "/health", "", "", http.StatusOK},
+		{"readiness always public", true, "GET", "/health/ready", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthMiddleware([]string{"key-1", "key-2"}).WithProtectReads(tt.protectReads).Handler(next)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, rr.Code)
+			}
+			if tt.expected != http.StatusUnauthorized {
+				return
+			}
+
+			if rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header")
+			}
+			var appErr apperrors.AppError
+			if err := json.Unmarshal(rr.Body.Bytes(), &appErr); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if appErr.Code != "UNAUTHORIZED" {
+				t.Errorf("Expected code UNAUTHORIZED, got %s", appErr.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_Disabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := NewAuthMiddleware(nil).Handler(next)
+
+	req := httptest.NewRequest("POST", "/order", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected request to pass without configured keys, got %d", rr.Code)
+	}
+}
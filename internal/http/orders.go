@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/interfaces"
+)
+
+// DefaultOrdersLimit размер страницы /orders по умолчанию
+const DefaultOrdersLimit = 100
+
+// handleListOrders возвращает заказы по sm_id и oof_shard
+// Нужен для отладки шардирования, поэтому без фильтра запрос отклоняется:
+// иначе он выгружал бы все заказы. Страница задается параметрами limit и offset,
+// limit больше maxListRows обрезается, и если заказов больше, ответ помечается truncated
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var opts []interfaces.QueryOption
+	if value := query.Get("sm_id"); value != "" {
+		smID, err := strconv.Atoi(value)
+		if err != nil || smID < 1 {
+			writeError(w, apperrors.InvalidParameter("sm_id must be a positive integer"))
+			return
+		}
+		opts = append(opts, interfaces.BySmID(smID))
+	}
+	if shard := query.Get("oof_shard"); shard != "" {
+		opts = append(opts, interfaces.ByOofShard(shard))
+	}
+	if len(opts) == 0 {
+		writeError(w, apperrors.InvalidParameter("sm_id or oof_shard is required"))
+		return
+	}
+
+	limit := DefaultOrdersLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeError(w, apperrors.InvalidParameter("limit must be a positive integer"))
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeError(w, apperrors.InvalidParameter("offset must be a non-negative integer"))
+			return
+		}
+		offset = n
+	}
+	// Лишняя строка сверх лимита показывает, что ответ обрезан
+	capped := limit > s.maxListRows
+	if capped {
+		limit = s.maxListRows
+		opts = append(opts, interfaces.Page(limit+1, offset))
+	} else {
+		opts = append(opts, interfaces.Page(limit, offset))
+	}
+
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	orders, err := s.DB.FindOrders(r.Context(), opts...)
+	if err != nil {
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to load orders", "ORDER_LOAD_FAILED"))
+		return
+	}
+	truncated := capped && len(orders) > limit
+	if truncated {
+		orders = orders[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"orders":    orders,
+		"count":     len(orders),
+		"limit":     limit,
+		"offset":    offset,
+		"truncated": truncated,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"wbtest/internal/model"
+)
+
+func TestServer_handleListOrders(t *testing.T) {
+	db := NewMockOrderRepository()
+	server := NewServer(NewMockOrderCache(), db)
+
+	now := time.Now().UTC()
+	add := func(uid string, smID int, shard string, created time.Time) {
+		db.orders[uid] = &model.Order{OrderUID: uid, SmID: smID, OofShard: shard, DateCreated: created}
+	}
+	add("order-1", 99, "1", now)
+	add("order-2", 99, "2", now.Add(-time.Hour))
+	add("order-3", 42, "1", now.Add(-2*time.Hour))
+	add("order-4", 99, "1", now.Add(-3*time.Hour))
+	add("order-5", 99, "1", now)
+	db.deleted["order-5"] = true
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"by sm_id", "sm_id=99", []string{"order-1", "order-2", "order-4"}},
+		{"by oof_shard", "oof_shard=1", []string{"order-1", "order-3", "order-4"}},
+		{"by both", "sm_id=99&oof_shard=1", []string{"order-1", "order-4"}},
+		{"page", "sm_id=99&limit=1&offset=1", []string{"order-2"}},
+		{"no match", "sm_id=7", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/orders?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var response struct {
+				Orders []model.Order `json:"orders"`
+				Count  int           `json:"count"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Count != len(tt.expected) || len(response.Orders) != len(tt.expected) {
+				t.Fatalf("Expected %d orders, got %d", len(tt.expected), len(response.Orders))
+			}
+			for i, uid := range tt.expected {
+				if response.Orders[i].OrderUID != uid {
+					t.Errorf("Expected order %d to be %s, got %s", i, uid, response.Orders[i].OrderUID)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_handleListOrders_Truncated(t *testing.T) {
+	db := NewMockOrderRepository()
+	server := NewServer(NewMockOrderCache(), db).WithMaxListRows(2)
+
+	now := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		uid := "order-" + strconv.Itoa(i+1)
+		db.orders[uid] = &model.Order{OrderUID: uid, SmID: 99, DateCreated: now.Add(-time.Duration(i) * time.Hour)}
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		count     int
+		limit     int
+		truncated bool
+	}{
+		{"limit above cap", "sm_id=99&limit=10", 2, 2, true},
+		{"limit above cap last page", "sm_id=99&limit=10&offset=1", 2, 2, false},
+		{"limit within cap", "sm_id=99&limit=2", 2, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/orders?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var response struct {
+				Orders    []model.Order `json:"orders"`
+				Limit     int           `json:"limit"`
+				Truncated bool          `json:"truncated"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(response.Orders) != tt.count {
+				t.Errorf("Expected %d orders, got %d", tt.count, len(response.Orders))
+			}
+			if response.Limit != tt.limit {
+				t.Errorf("Expected limit %d, got %d", tt.limit, response.Limit)
+			}
+			if response.Truncated != tt.truncated {
+				t.Errorf("Expected truncated %v, got %v", tt.truncated, response.Truncated)
+			}
+		})
+	}
+}
+
+func TestServer_handleListOrders_BadRequest(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	for _, query := range []string{"", "sm_id=abc", "sm_id=0", "sm_id=1&limit=0", "oof_shard=1&offset=-1"} {
+		req := httptest.NewRequest("GET", "/orders?"+query, nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Query %q: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
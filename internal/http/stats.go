@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/model"
+)
+
+// Параметры /orders/stats
+const (
+	// DefaultStatsDays период статистики по дням по умолчанию
+	DefaultStatsDays = 7
+	// MaxStatsDays максимальный период статистики по дням
+	MaxStatsDays = 90
+	// DefaultStatsTTL время кеширования статистики, запросы к БД тяжелые
+	DefaultStatsTTL = 30 * time.Second
+	// statsTopServices число служб доставки в ответе
+	statsTopServices = 5
+)
+
+// statsCache кеш статистики по периоду в днях
+type statsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]statsEntry
+}
+
+type statsEntry struct {
+	stats     *model.OrderStats
+	expiresAt time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, entries: make(map[int]statsEntry)}
+}
+
+// handleOrderStats возвращает агрегированную статистику по заказам
+// Параметр days задает период статистики по дням
+func (s *Server) handleOrderStats(w http.ResponseWriter, r *http.Request) {
+	days := DefaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxStatsDays {
+			writeError(w, apperrors.InvalidParameter("days must be between 1 and "+strconv.Itoa(MaxStatsDays)))
+			return
+		}
+		days = n
+	}
+	if s.DB == nil {
+		writeError(w, apperrors.ErrDatabaseUnavailable)
+		return
+	}
+
+	stats, err := s.orderStats(r.Context(), days)
+	if err != nil {
+		writeError(w, apperrors.WrapWithCode(err, apperrors.ErrorTypeDatabase, "Failed to load order stats", "STATS_LOAD_FAILED"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.encoder(w, r).Encode(stats); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
+
+// orderStats возвращает статистику из кеша или считает ее в БД
+// Блокировка держится на время запроса, чтобы одновременные запросы не считали одно и то же
+func (s *Server) orderStats(ctx context.Context, days int) (*model.OrderStats, error) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := s.stats.entries[days]; ok && now.Before(entry.expiresAt) {
+		return entry.stats, nil
+	}
+
+	// Период включает сегодняшний день
+	today := now.UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	stats, err := s.DB.GetOrderStats(ctx, since, statsTopServices)
+	if err != nil {
+		return nil, err
+	}
+	if s.stats.ttl > 0 {
+		s.stats.entries[days] = statsEntry{stats: stats, expiresAt: now.Add(s.stats.ttl)}
+	}
+	return stats, nil
+}
+
+// handleStatsHistory возвращает последние снимки статистики кеша от старых к новым
+func (s *Server) handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	if s.statsHistory == nil {
+		writeError(w, apperrors.NewWithCode(apperrors.ErrorTypeNotFound, "Stats history is disabled", "STATS_HISTORY_DISABLED"))
+		return
+	}
+
+	samples := s.statsHistory.Samples()
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"count":   len(samples),
+		"samples": samples,
+	}
+	if err := s.encoder(w, r).Encode(response); err != nil {
+		writeError(w, apperrors.ErrEncodeFailed)
+		return
+	}
+}
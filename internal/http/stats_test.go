@@ -0,0 +1,162 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"wbtest/internal/cache"
+	"wbtest/internal/model"
+)
+
+func TestServer_handleOrderStats(t *testing.T) {
+	db := NewMockOrderRepository()
+	server := NewServer(NewMockOrderCache(), db)
+
+	now := time.Now().UTC()
+	add := func(uid, service string, amount int, created time.Time) {
+		db.orders[uid] = &model.Order{
+			OrderUID:        uid,
+			DeliveryService: service,
+			Payment:         model.Payment{Amount: amount},
+			DateCreated:     created,
+		}
+	}
+	add("order-1", "meest", 100, now)
+	add("order-2", "meest", 200, now)
+	add("order-3", "dhl", 300, now.AddDate(0, 0, -1))
+	add("order-4", "meest", 400, now.AddDate(0, 0, -30))
+	add("order-5", "dhl", 500, now)
+	db.deleted["order-5"] = true
+
+	req := httptest.NewRequest("GET", "/orders/stats?days=7", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var stats model.OrderStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if stats.TotalOrders != 4 {
+		t.Errorf("Expected 4 orders, got %d", stats.TotalOrders)
+	}
+	if stats.TotalRevenue != 1000 {
+		t.Errorf("Expected revenue 1000, got %d", stats.TotalRevenue)
+	}
+
+	expectedDays := []model.DailyOrderCount{
+		{Day: now.AddDate(0, 0, -1).Format("2006-01-02"), Count: 1},
+		{Day: now.Format("2006-01-02"), Count: 2},
+	}
+	if len(stats.OrdersPerDay) != len(expectedDays) {
+		t.Fatalf("Expected orders per day %v, got %v", expectedDays, stats.OrdersPerDay)
+	}
+	for i, day := range expectedDays {
+		if stats.OrdersPerDay[i] != day {
+			t.Errorf("Expected day %d to be %v, got %v", i, day, stats.OrdersPerDay[i])
+		}
+	}
+
+	expectedServices := []model.DeliveryServiceCount{
+		{Service: "meest", Count: 3},
+		{Service: "dhl", Count: 1},
+	}
+	if len(stats.TopDeliveryServices) != len(expectedServices) {
+		t.Fatalf("Expected services %v, got %v", expectedServices, stats.TopDeliveryServices)
+	}
+	for i, service := range expectedServices {
+		if stats.TopDeliveryServices[i] != service {
+			t.Errorf("Expected service %d to be %v, got %v", i, service, stats.TopDeliveryServices[i])
+		}
+	}
+}
+
+func TestServer_handleOrderStats_Cached(t *testing.T) {
+	db := NewMockOrderRepository()
+	server := NewServer(NewMockOrderCache(), db)
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/stats", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+	}
+
+	if db.statsCalls != 1 {
+		t.Errorf("Expected stats to be computed once, got %d calls", db.statsCalls)
+	}
+
+	// Без кеширования каждый запрос идет в БД
+	server.WithStatsTTL(0)
+	for i := 0; i < 2; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/stats", nil))
+	}
+	if db.statsCalls != 3 {
+		t.Errorf("Expected 3 stats calls with caching disabled, got %d", db.statsCalls)
+	}
+}
+
+func TestServer_handleOrderStats_InvalidDays(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	for _, days := range []string{"0", "-1", "abc", "91"} {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/stats?days="+days, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("days=%s: expected status %d, got %d", days, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestServer_handleStatsHistory(t *testing.T) {
+	orderCache := NewMockOrderCache()
+	history := cache.NewStatsHistory(orderCache, 3)
+	server := NewServer(orderCache, NewMockOrderRepository()).WithStatsHistory(history)
+
+	// Буфер на 3 снимка: из 5 снимков остаются последние 3, размер кеша растет
+	for i := 1; i <= 5; i++ {
+		orderCache.Set(&model.Order{OrderUID: "order-" + strconv.Itoa(i)})
+		history.Sample()
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/history", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Count   int                 `json:"count"`
+		Samples []cache.StatsSample `json:"samples"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Count != 3 || len(response.Samples) != 3 {
+		t.Fatalf("Expected 3 samples, got count=%d samples=%d", response.Count, len(response.Samples))
+	}
+	for i, sample := range response.Samples {
+		if sample.Size != i+3 {
+			t.Errorf("Expected sample %d size %d, got %d", i, i+3, sample.Size)
+		}
+	}
+}
+
+func TestServer_handleStatsHistory_Disabled(t *testing.T) {
+	server := NewServer(NewMockOrderCache(), NewMockOrderRepository())
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/history", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
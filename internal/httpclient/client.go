@@ -0,0 +1,143 @@
+// Package httpclient HTTP клиент для исходящих вызовов внешних систем
+// Идемпотентные запросы повторяются через retry.RetryService,
+// а circuit breaker перестает обращаться к системе, которая раз за разом отвечает 5xx
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"wbtest/internal/circuitbreaker"
+	"wbtest/internal/config"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/retry"
+)
+
+// DefaultTimeout ограничение на одну попытку запроса по умолчанию
+const DefaultTimeout = 10 * time.Second
+
+// StatusError внешняя система ответила 5xx
+// Тело ответа уже прочитано и закрыто, достается из ошибки Do через errors.As
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: server responded %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Client HTTP клиент с повторами и circuit breaker
+type Client struct {
+	http    *http.Client
+	retry   *retry.RetryService
+	breaker *circuitbreaker.CircuitBreaker
+}
+
+// Option настройка Client
+type Option func(*Client)
+
+// WithHTTPClient задает клиент, через который отправляются запросы
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		if client != nil {
+			c.http = client
+		}
+	}
+}
+
+// New создает клиент
+// retryCfg задает число и задержки повторов, breakerCfg - сколько 5xx подряд открывают breaker
+func New(retryCfg config.RetryConfig, breakerCfg circuitbreaker.Config, opts ...Option) *Client {
+	// Бюджет повторов RetryService не нужен: его роль выполняет breaker клиента
+	retryCfg.BreakerFailureThreshold = 0
+	if retryCfg.MaxAttempts < 1 {
+		retryCfg.MaxAttempts = 1
+	}
+
+	c := &Client{
+		http:    &http.Client{Timeout: DefaultTimeout},
+		retry:   retry.NewRetryService(&retryCfg).(*retry.RetryService),
+		breaker: circuitbreaker.New(breakerCfg),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Breaker возвращает circuit breaker клиента, например для /admin/breaker/reset
+func (c *Client) Breaker() *circuitbreaker.CircuitBreaker {
+	return c.breaker
+}
+
+// Do отправляет запрос
+// Ответ 5xx и ошибка сети считаются неудачей: идемпотентный запрос повторяется,
+// а неудачи подряд открывают breaker, после чего Do сразу возвращает ошибку breaker.
+// Ответы 2xx-4xx возвращаются как есть, тело закрывает вызывающий.
+// Запрос с телом повторяется, только если у него задан GetBody
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if !retryable(req) {
+		return c.attempt(ctx, req, false)
+	}
+
+	var resp *http.Response
+	first := true
+	err := c.retry.ExecuteWithRetryContext(ctx, func() error {
+		var err error
+		resp, err = c.attempt(ctx, req, !first)
+		first = false
+		if circuitbreaker.IsCircuitBreakerOpen(err) {
+			// Пока breaker открыт, повторы только тратят время
+			return apperrors.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// attempt выполняет одну попытку запроса через breaker
+// rewind - повторная попытка, тело запроса берется заново из GetBody
+func (c *Client) attempt(ctx context.Context, req *http.Request, rewind bool) (*http.Response, error) {
+	resp, err := circuitbreaker.ExecuteTyped(ctx, c.breaker, func() (*http.Response, error) {
+		attemptReq := req.Clone(ctx)
+		if rewind && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, apperrors.Permanent(fmt.Errorf("rewind request body: %w", err))
+			}
+			attemptReq.Body = body
+		}
+
+		resp, err := c.http.Do(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			// Тело дочитывается, чтобы соединение вернулось в пул
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			return nil, &StatusError{Method: req.Method, URL: req.URL.Redacted(), StatusCode: resp.StatusCode}
+		}
+		return resp, nil
+	})
+	return resp, err
+}
+
+// retryable сообщает, можно ли безопасно повторить запрос
+// Повторяются только идемпотентные методы, запрос с телом - если тело можно прочитать заново
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
@@ -0,0 +1,162 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wbtest/internal/circuitbreaker"
+	"wbtest/internal/config"
+)
+
+func testRetryConfig(attempts int) config.RetryConfig {
+	return config.RetryConfig{
+		MaxAttempts:  attempts,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   2,
+	}
+}
+
+func testBreakerConfig(threshold int) circuitbreaker.Config {
+	return circuitbreaker.Config{
+		FailureThreshold: threshold,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		MaxRequests:      1,
+	}
+}
+
+// flakyServer отвечает failStatus на первые fails запросов, затем 200
+// В ответ 200 возвращается тело запроса
+func flakyServer(t *testing.T, fails int32, failStatus int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= fails {
+			w.WriteHeader(failStatus)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestClient_Do_RetryThenSuccess(t *testing.T) {
+	server, hits := flakyServer(t, 2, http.StatusServiceUnavailable)
+	client := New(testRetryConfig(3), testBreakerConfig(5))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("Expected 3 attempts, got %d", got)
+	}
+	if state := client.Breaker().GetState(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker closed after success, got %s", state)
+	}
+}
+
+func TestClient_Do_RetryResendsBody(t *testing.T) {
+	server, hits := flakyServer(t, 1, http.StatusBadGateway)
+	client := New(testRetryConfig(3), testBreakerConfig(5))
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"status":"saved"}`))
+	resp, err := client.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"status":"saved"}` {
+		t.Errorf("Expected body to be resent on retry, got %q", body)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("Expected 2 attempts, got %d", got)
+	}
+}
+
+func TestClient_Do_BreakerOpensAfterFailures(t *testing.T) {
+	server, hits := flakyServer(t, 100, http.StatusInternalServerError)
+	client := New(testRetryConfig(3), testBreakerConfig(3))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(context.Background(), req)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected StatusError 500, got %v", err)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("Expected 3 attempts, got %d", got)
+	}
+	if state := client.Breaker().GetState(); state != circuitbreaker.StateOpen {
+		t.Fatalf("Expected breaker open after 3 failures, got %s", state)
+	}
+
+	// Открытый breaker не пропускает запрос к серверу и не повторяет его
+	_, err = client.Do(context.Background(), req)
+	var breakerErr *circuitbreaker.CircuitBreakerError
+	if !errors.As(err, &breakerErr) || breakerErr.State != circuitbreaker.StateOpen {
+		t.Errorf("Expected open breaker error, got %v", err)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("Expected no requests while breaker is open, got %d", got)
+	}
+}
+
+func TestClient_Do_NonIdempotentNotRetried(t *testing.T) {
+	server, hits := flakyServer(t, 1, http.StatusServiceUnavailable)
+	client := New(testRetryConfig(3), testBreakerConfig(5))
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	_, err := client.Do(context.Background(), req)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected StatusError, got %v", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("Expected POST to be sent once, got %d", got)
+	}
+}
+
+func TestClient_Do_ClientErrorsDoNotTripBreaker(t *testing.T) {
+	server, hits := flakyServer(t, 100, http.StatusNotFound)
+	client := New(testRetryConfig(3), testBreakerConfig(2))
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	}
+
+	if got := hits.Load(); got != 3 {
+		t.Errorf("Expected 4xx not to be retried, got %d requests", got)
+	}
+	if state := client.Breaker().GetState(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker closed, got %s", state)
+	}
+}
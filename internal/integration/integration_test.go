@@ -44,7 +44,7 @@ func TestOrderServiceIntegration(t *testing.T) {
 	retryService := retry.NewRetryService(&cfg.Retry)
 
 	// Создаем DLQ сервис
-	dlqService := dlq.NewDLQService(&cfg.DLQ, cfg.Kafka.Brokers)
+	dlqService := dlq.NewDLQService(&cfg.DLQ, cfg.Kafka.Brokers, nil)
 	defer dlqService.Close()
 
 	// Создаем тестовый заказ
@@ -161,7 +161,7 @@ func TestOrderServiceIntegration(t *testing.T) {
 		testMessage := []byte("test message for DLQ")
 		reason := "integration test"
 
-		err := dlqService.SendToDLQ(testMessage, reason)
+		err := dlqService.SendToDLQ(context.Background(), testMessage, reason)
 		if err != nil {
 			t.Errorf("Failed to send message to DLQ: %v", err)
 		}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sort"
 	"testing"
 	"time"
 
 	"wbtest/internal/cache"
 	"wbtest/internal/config"
+	"wbtest/internal/interfaces"
 	"wbtest/internal/model"
 	"wbtest/internal/retry"
 	"wbtest/internal/validator"
@@ -25,7 +27,7 @@ func NewMockDB() *MockDB {
 	}
 }
 
-func (m *MockDB) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
+func (m *MockDB) LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
 	var orders []*model.Order
 	for _, order := range m.orders {
 		orders = append(orders, order)
@@ -38,13 +40,71 @@ func (m *MockDB) SaveOrder(ctx context.Context, order *model.Order) error {
 	return nil
 }
 
-func (m *MockDB) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+func (m *MockDB) UpsertOrder(ctx context.Context, order *model.Order, version int64) (bool, error) {
+	m.orders[order.OrderUID] = order
+	return true, nil
+}
+
+func (m *MockDB) GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error) {
 	if order, exists := m.orders[orderUID]; exists {
 		return order, nil
 	}
 	return nil, nil
 }
 
+func (m *MockDB) GetOrderByTrackNumber(ctx context.Context, trackNumber string) ([]*model.Order, error) {
+	var orders []*model.Order
+	for _, order := range m.orders {
+		if order.TrackNumber == trackNumber {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MockDB) FindOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	var orders []*model.Order
+	for _, order := range m.orders {
+		if options.Matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (m *MockDB) LoadOrdersAfter(ctx context.Context, afterUID string, limit int, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	options := interfaces.ApplyQueryOptions(opts...)
+	var orders []*model.Order
+	for uid, order := range m.orders {
+		if uid > afterUID && options.Matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	if limit < len(orders) {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+func (m *MockDB) DeleteOrder(ctx context.Context, orderUID string) error {
+	delete(m.orders, orderUID)
+	return nil
+}
+
+func (m *MockDB) RestoreOrder(ctx context.Context, orderUID string) error {
+	return nil
+}
+
+func (m *MockDB) GetOrderStats(ctx context.Context, since time.Time, topServices int) (*model.OrderStats, error) {
+	return &model.OrderStats{}, nil
+}
+
+func (m *MockDB) CountOrdersByBrand(ctx context.Context, brand string, sampleSize int) (*model.BrandCount, error) {
+	return &model.BrandCount{Brand: brand, SampleOrderUIDs: []string{}}, nil
+}
+
 func (m *MockDB) Close() {}
 
 // TestOrderServiceMockIntegration тестирует цикл
@@ -2,6 +2,9 @@ package interfaces
 
 import (
 	"context"
+	"hash/fnv"
+	"time"
+
 	"wbtest/internal/model"
 )
 
@@ -13,13 +16,149 @@ type CacheStats struct {
 	HitRate     float64
 	Evictions   int64
 	Expirations int64
+	// MaxSize емкость кеша, Full - кеш заполнен до MaxSize
+	MaxSize int
+	Full    bool
+	// EvictionRate вытеснений в секунду за последнюю минуту
+	EvictionRate float64
+	// EvictionRateHigh частота вытеснений выше порога: кеш мал для нагрузки
+	EvictionRateHigh bool
+}
+
+// QueryOptions параметры выборки заказов
+type QueryOptions struct {
+	// IncludeDeleted включает в выборку мягко удаленные заказы
+	IncludeDeleted bool
+	// PartitionIndex и PartitionCount выбирают одну часть заказов, 0 частей - все заказы
+	PartitionIndex int
+	PartitionCount int
+	// SmID и OofShard отбирают заказы по полям шардирования, нулевое значение - без отбора
+	SmID     int
+	OofShard string
+	// Limit и Offset задают страницу выборки, Limit 0 - без ограничения
+	Limit  int
+	Offset int
+	// CreatedFrom и CreatedTo отбирают заказы по date_created в интервале [from, to),
+	// нулевое время - без границы
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// Matches сообщает, подходит ли заказ под отбор по SmID, OofShard и date_created
+// Реализации без отбора на стороне хранилища используют эту проверку
+func (o QueryOptions) Matches(order *model.Order) bool {
+	if o.SmID != 0 && order.SmID != o.SmID {
+		return false
+	}
+	if o.OofShard != "" && order.OofShard != o.OofShard {
+		return false
+	}
+	if !o.CreatedFrom.IsZero() && order.DateCreated.Before(o.CreatedFrom) {
+		return false
+	}
+	if !o.CreatedTo.IsZero() && !order.DateCreated.Before(o.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// InPartition сообщает, попадает ли заказ в выбранную часть
+// Реализации без собственного разбиения на стороне хранилища используют этот хеш
+func (o QueryOptions) InPartition(orderUID string) bool {
+	if o.PartitionCount <= 1 {
+		return true
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(orderUID))
+	return int(hash.Sum32()%uint32(o.PartitionCount)) == o.PartitionIndex
+}
+
+// QueryOption настраивает выборку заказов
+type QueryOption func(*QueryOptions)
+
+// IncludeDeleted возвращает опцию, включающую мягко удаленные заказы
+func IncludeDeleted() QueryOption {
+	return func(o *QueryOptions) {
+		o.IncludeDeleted = true
+	}
+}
+
+// Partition возвращает опцию выборки части index из count частей
+// Части не пересекаются и вместе покрывают все заказы, что позволяет читать их параллельно
+func Partition(index, count int) QueryOption {
+	return func(o *QueryOptions) {
+		o.PartitionIndex = index
+		o.PartitionCount = count
+	}
+}
+
+// BySmID возвращает опцию отбора заказов по sm_id
+func BySmID(smID int) QueryOption {
+	return func(o *QueryOptions) {
+		o.SmID = smID
+	}
+}
+
+// ByOofShard возвращает опцию отбора заказов по oof_shard
+func ByOofShard(shard string) QueryOption {
+	return func(o *QueryOptions) {
+		o.OofShard = shard
+	}
+}
+
+// Page возвращает опцию выборки limit заказов, пропустив первые offset
+func Page(limit, offset int) QueryOption {
+	return func(o *QueryOptions) {
+		o.Limit = limit
+		o.Offset = offset
+	}
+}
+
+// CreatedBetween возвращает опцию отбора заказов с date_created в [from, to)
+// Нулевое время снимает соответствующую границу
+func CreatedBetween(from, to time.Time) QueryOption {
+	return func(o *QueryOptions) {
+		o.CreatedFrom = from
+		o.CreatedTo = to
+	}
+}
+
+// ApplyQueryOptions собирает параметры выборки из опций
+func ApplyQueryOptions(opts ...QueryOption) QueryOptions {
+	var options QueryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
 }
 
 // OrderRepository интерфейс БД
+// По умолчанию мягко удаленные заказы не возвращаются
 type OrderRepository interface {
-	LoadAllOrders(ctx context.Context) ([]*model.Order, error)
+	LoadAllOrders(ctx context.Context, opts ...QueryOption) ([]*model.Order, error)
 	SaveOrder(ctx context.Context, order *model.Order) error
-	GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error)
+	// UpsertOrder заменяет заказ целиком, если version не меньше сохраненной версии
+	// Возвращает false, если сохранена более новая версия
+	UpsertOrder(ctx context.Context, order *model.Order, version int64) (bool, error)
+	GetOrderByUID(ctx context.Context, orderUID string, opts ...QueryOption) (*model.Order, error)
+	// GetOrderByTrackNumber возвращает все заказы с трек-номером, пустой список если таких нет
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string) ([]*model.Order, error)
+	// FindOrders возвращает заказы, отобранные опциями BySmID, ByOofShard и Page,
+	// новые заказы первыми
+	FindOrders(ctx context.Context, opts ...QueryOption) ([]*model.Order, error)
+	// LoadOrdersAfter возвращает до limit заказов с order_uid больше afterUID в порядке order_uid,
+	// отобранных опциями BySmID, ByOofShard и CreatedBetween
+	// Последний order_uid пачки - afterUID следующей, так все заказы читаются пачками без OFFSET
+	LoadOrdersAfter(ctx context.Context, afterUID string, limit int, opts ...QueryOption) ([]*model.Order, error)
+	// DeleteOrder удаляет заказ, в режиме soft delete только помечает его удаленным
+	DeleteOrder(ctx context.Context, orderUID string) error
+	// RestoreOrder снимает пометку об удалении
+	RestoreOrder(ctx context.Context, orderUID string) error
+	// GetOrderStats считает статистику по заказам: заказы по дням начиная с since
+	// и topServices самых частых служб доставки
+	GetOrderStats(ctx context.Context, since time.Time, topServices int) (*model.OrderStats, error)
+	// CountOrdersByBrand считает заказы с товаром бренда brand и возвращает до sampleSize их order_uid
+	CountOrdersByBrand(ctx context.Context, brand string, sampleSize int) (*model.BrandCount, error)
 	Close()
 }
 
@@ -27,9 +166,16 @@ type OrderRepository interface {
 type OrderCache interface {
 	Get(orderUID string) (*model.Order, bool)
 	Set(order *model.Order)
+	// SetCacheOnly сохраняет заказ, которого нет в БД, сверка кеша с БД его не удаляет
+	SetCacheOnly(order *model.Order)
+	// SetIfNewer сохраняет заказ, только если он не старше закешированного по DateCreated
+	// Возвращает true, если заказ сохранен
+	SetIfNewer(order *model.Order) bool
 	LoadAll(orders []*model.Order)
 	Delete(orderUID string)
+	DeleteWhere(pred func(*model.Order) bool) int
 	Size() int
+	Keys() []string
 	Clear()
 	GetStats() CacheStats
 	Stop()
@@ -37,7 +183,17 @@ type OrderCache interface {
 
 // MessageConsumer интерфейс Kafka consumer
 type MessageConsumer interface {
-	ReadMessages(ctx context.Context, handle func([]byte)) error
+	// ReadMessages вызывает handle для каждого сообщения
+	// Контекст handle несет заголовки сообщения
+	ReadMessages(ctx context.Context, handle func(context.Context, []byte)) error
+	Pause()
+	Resume()
+	Close() error
+}
+
+// MessageProducer интерфейс Kafka producer
+type MessageProducer interface {
+	Produce(ctx context.Context, key, value []byte) error
 	Close() error
 }
 
@@ -49,11 +205,15 @@ type OrderValidator interface {
 // RetryService интерфейс retry
 type RetryService interface {
 	ExecuteWithRetry(operation func() error) error
+	// ExecuteWithRetryNamed то же, что ExecuteWithRetry, name - имя операции в метриках
+	ExecuteWithRetryNamed(name string, operation func() error) error
 }
 
 // DLQService интерфейс DLQ
 type DLQService interface {
-	SendToDLQ(message []byte, reason string) error
-	ProcessDLQ() error
+	// SendToDLQ отправляет сообщение в DLQ вместе с заголовками из ctx
+	SendToDLQ(ctx context.Context, message []byte, reason string) error
+	// ProcessDLQ обрабатывает DLQ до отмены ctx
+	ProcessDLQ(ctx context.Context) error
 	Close() error
 }
@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Pauser потребитель, чтение которого можно приостановить
+type Pauser interface {
+	Pause()
+	Resume()
+}
+
+// Backpressure приостанавливает чтение из Kafka, когда запись в БД замедляется
+// Чтение возобновляется после первой быстрой записи или по истечении cooldown,
+// после чего следующее сообщение служит пробой
+type Backpressure struct {
+	target    Pauser
+	threshold time.Duration
+	cooldown  time.Duration
+
+	mu     sync.Mutex
+	paused bool
+	timer  *time.Timer
+}
+
+// NewBackpressure создает контроллер backpressure
+// threshold допустимая задержка записи, cooldown максимальная длительность паузы
+func NewBackpressure(target Pauser, threshold, cooldown time.Duration) *Backpressure {
+	return &Backpressure{
+		target:    target,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Observe учитывает задержку очередной записи
+// Безопасен для вызова на nil, тогда ничего не делает
+func (b *Backpressure) Observe(latency time.Duration) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case latency > b.threshold && !b.paused:
+		log.Printf("[KAFKA] Write latency %v exceeds %v, pausing consumption", latency, b.threshold)
+		b.paused = true
+		b.target.Pause()
+		b.timer = time.AfterFunc(b.cooldown, b.onCooldown)
+	case latency <= b.threshold && b.paused:
+		log.Printf("[KAFKA] Write latency recovered to %v, resuming consumption", latency)
+		b.resume()
+	}
+}
+
+// Paused сообщает, приостановлено ли чтение
+func (b *Backpressure) Paused() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.paused
+}
+
+// Stop снимает паузу и останавливает таймер
+func (b *Backpressure) Stop() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused {
+		b.resume()
+	}
+}
+
+func (b *Backpressure) onCooldown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.paused {
+		log.Printf("[KAFKA] Backpressure cooldown %v elapsed, resuming consumption", b.cooldown)
+		b.resume()
+	}
+}
+
+// resume вызывается под блокировкой b.mu
+func (b *Backpressure) resume() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.paused = false
+	b.target.Resume()
+}
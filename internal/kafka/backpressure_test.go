@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakePauser struct {
+	mu      sync.Mutex
+	pauses  int
+	resumes int
+}
+
+func (f *fakePauser) Pause() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pauses++
+}
+
+func (f *fakePauser) Resume() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resumes++
+}
+
+func (f *fakePauser) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pauses, f.resumes
+}
+
+func TestBackpressure_Cooldown(t *testing.T) {
+	pauser := &fakePauser{}
+	bp := NewBackpressure(pauser, 10*time.Millisecond, 20*time.Millisecond)
+
+	bp.Observe(50 * time.Millisecond)
+	if !bp.Paused() {
+		t.Fatal("Expected pause after slow write")
+	}
+
+	// Без новых записей пауза снимается по cooldown
+	time.Sleep(100 * time.Millisecond)
+	if bp.Paused() {
+		t.Error("Expected resume after cooldown")
+	}
+	if pauses, resumes := pauser.counts(); pauses != 1 || resumes != 1 {
+		t.Errorf("Expected 1 pause and 1 resume, got %d and %d", pauses, resumes)
+	}
+}
+
+func TestBackpressure_Nil(t *testing.T) {
+	var bp *Backpressure
+
+	bp.Observe(time.Hour)
+	bp.Stop()
+	if bp.Paused() {
+		t.Error("Nil backpressure must never be paused")
+	}
+}
@@ -3,25 +3,281 @@ package kafka
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"wbtest/internal/metrics"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Задержки между попытками чтения после ошибки по умолчанию
+const (
+	DefaultReconnectMinBackoff = 100 * time.Millisecond
+	DefaultReconnectMaxBackoff = 10 * time.Second
+)
+
+// commitInterval период отправки зафиксированных смещений брокеру
+// CommitMessages только запоминает смещение, поэтому воркеры не ждут брокер,
+// а незаправленные смещения отправляются при Close
+const commitInterval = time.Second
+
+// Стратегии распределения партиций в группе
+const (
+	GroupBalancerRange      = "range"
+	GroupBalancerRoundRobin = "round-robin"
 )
 
+// ParseGroupBalancer возвращает стратегию распределения партиций по имени
+// Пустое имя возвращает nil - остаются стратегии kafka-go по умолчанию
+// kafka-go не реализует sticky, поэтому он не поддерживается
+func ParseGroupBalancer(name string) (kafka.GroupBalancer, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case GroupBalancerRange:
+		return kafka.RangeGroupBalancer{}, nil
+	case GroupBalancerRoundRobin:
+		return kafka.RoundRobinGroupBalancer{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported group balancer %q, expected %s or %s",
+			name, GroupBalancerRange, GroupBalancerRoundRobin)
+	}
+}
+
+// ConsumerOption настраивает ReaderConfig при создании consumer
+type ConsumerOption func(*kafka.ReaderConfig)
+
+// WithGroupBalancer задает стратегию распределения партиций между участниками группы
+func WithGroupBalancer(balancer kafka.GroupBalancer) ConsumerOption {
+	return func(cfg *kafka.ReaderConfig) {
+		if balancer != nil {
+			cfg.GroupBalancers = []kafka.GroupBalancer{balancer}
+		}
+	}
+}
+
+// WithSessionTimeout задает время, после которого брокер считает участника группы
+// упавшим и запускает ребалансировку. Значение <= 0 оставляет значение kafka-go
+func WithSessionTimeout(timeout time.Duration) ConsumerOption {
+	return func(cfg *kafka.ReaderConfig) {
+		if timeout > 0 {
+			cfg.SessionTimeout = timeout
+		}
+	}
+}
+
+// WithHeartbeatInterval задает частоту heartbeat участника группы
+// Значение <= 0 оставляет значение kafka-go
+func WithHeartbeatInterval(interval time.Duration) ConsumerOption {
+	return func(cfg *kafka.ReaderConfig) {
+		if interval > 0 {
+			cfg.HeartbeatInterval = interval
+		}
+	}
+}
+
+// newReaderConfig собирает ReaderConfig с примененными опциями
+func newReaderConfig(brokers []string, topic, groupID string, opts ...ConsumerOption) kafka.ReaderConfig {
+	cfg := kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   topic,
+		GroupID: groupID,
+
+		CommitInterval: commitInterval,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
 // Consumer простой consumer для чтения сообщений из Kafka
 type Consumer struct {
 	Reader *kafka.Reader
+	reader messageReader
+
+	brokers []string
+	topic   string
+	groupID string
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	sleep      func(ctx context.Context, d time.Duration) error
+	metrics    *metrics.Metrics
+	// logger лог паник обработчика, nil - стандартный логгер logrus
+	logger logrus.FieldLogger
+	// concurrency число партиций, обрабатываемых одновременно, 0 - без ограничения
+	concurrency int
+	// panicHandler получает сообщение, обработка которого завершилась паникой
+	panicHandler PanicHandler
+	// drainTimeout время на обработку уже прочитанных сообщений при остановке, 0 - без ограничения
+	drainTimeout time.Duration
+
+	mu      sync.Mutex
+	resumed chan struct{} // не nil, пока чтение приостановлено
 }
 
 // NewConsumer создаёт новый consumer
 // brokers адреса брокеров topic топик groupID группа потребителей
-func NewConsumer(brokers []string, topic, groupID string) *Consumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
-		Topic:   topic,
-		GroupID: groupID,
-	})
-	return &Consumer{Reader: reader}
+func NewConsumer(brokers []string, topic, groupID string, opts ...ConsumerOption) *Consumer {
+	reader := kafka.NewReader(newReaderConfig(brokers, topic, groupID, opts...))
+	return &Consumer{
+		Reader:     reader,
+		reader:     reader,
+		brokers:    brokers,
+		topic:      topic,
+		groupID:    groupID,
+		minBackoff: DefaultReconnectMinBackoff,
+		maxBackoff: DefaultReconnectMaxBackoff,
+	}
+}
+
+// WithReconnectBackoff задает границы экспоненциальной задержки
+// между попытками чтения при недоступности брокеров
+// Значения <= 0 оставляют значения по умолчанию
+func (c *Consumer) WithReconnectBackoff(min, max time.Duration) *Consumer {
+	if min > 0 {
+		c.minBackoff = min
+	}
+	if max > 0 {
+		c.maxBackoff = max
+	}
+	return c
+}
+
+// WithConcurrency ограничивает число сообщений разных партиций, обрабатываемых одновременно
+// Партиции получают слот обработки по очереди, порядок внутри партиции сохраняется
+// Значения <= 0 - каждая партиция обрабатывается в своей горутине без ограничения
+func (c *Consumer) WithConcurrency(n int) *Consumer {
+	if n < 0 {
+		n = 0
+	}
+	c.concurrency = n
+	return c
+}
+
+// WithDrainTimeout ограничивает время обработки уже прочитанных сообщений при остановке
+// По истечении контекст обработчиков отменяется, а необработанные сообщения
+// остаются незафиксированными и придут повторно. Значения <= 0 - ждать без ограничения
+func (c *Consumer) WithDrainTimeout(timeout time.Duration) *Consumer {
+	if timeout < 0 {
+		timeout = 0
+	}
+	c.drainTimeout = timeout
+	return c
+}
+
+// PanicHandler получает сообщение, обработка которого завершилась паникой,
+// и значение паники. Например, отправляет сообщение в DLQ
+type PanicHandler func(ctx context.Context, msg []byte, recovered interface{})
+
+// WithPanicHandler задает обработку сообщений, на которых обработчик запаниковал
+// Паника перехватывается и без него: сообщение пропускается, чтение продолжается
+func (c *Consumer) WithPanicHandler(handler PanicHandler) *Consumer {
+	c.panicHandler = handler
+	return c
+}
+
+// WithMetrics включает учет ошибок чтения в KafkaMessagesFailed
+func (c *Consumer) WithMetrics(m *metrics.Metrics) *Consumer {
+	c.metrics = m
+	return c
+}
+
+// WithLogger задает логгер для паник обработчика, например logger.ForComponent("kafka")
+func (c *Consumer) WithLogger(logger logrus.FieldLogger) *Consumer {
+	c.logger = logger
+	return c
+}
+
+// log возвращает логгер consumer
+func (c *Consumer) log() logrus.FieldLogger {
+	if c.logger == nil {
+		return logrus.StandardLogger()
+	}
+	return c.logger
+}
+
+// Ping проверяет доступность Kafka: подключается к брокеру
+// и запрашивает партиции топика. Достаточно одного доступного брокера
+func (c *Consumer) Ping(ctx context.Context) error {
+	return PingTopic(ctx, c.brokers, c.topic)
+}
+
+// ClusterInfo возвращает сведения о кластере Kafka: число брокеров и контроллер
+// Kafka не сообщает версию сервера, по этим данным видно, к какому кластеру подключен сервис
+func (c *Consumer) ClusterInfo(ctx context.Context) (string, error) {
+	if len(c.brokers) == 0 {
+		return "", errors.New("no kafka brokers configured")
+	}
+
+	var lastErr error
+	for _, broker := range c.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		brokers, err := conn.Brokers()
+		if err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+		controller, err := conn.Controller()
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return fmt.Sprintf("brokers=%d controller=%d", len(brokers), controller.ID), nil
+	}
+
+	return "", fmt.Errorf("failed to get kafka cluster info: %w", lastErr)
+}
+
+// PingTopic проверяет, что хотя бы один брокер доступен и у топика есть партиции
+func PingTopic(ctx context.Context, brokers []string, topic string) error {
+	if len(brokers) == 0 {
+		return errors.New("no kafka brokers configured")
+	}
+
+	var lastErr error
+	for _, broker := range brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		partitions, err := conn.ReadPartitions(topic)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(partitions) == 0 {
+			lastErr = fmt.Errorf("topic %s has no partitions", topic)
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("kafka is unreachable: %w", lastErr)
 }
 
 // Close закрывает reader
@@ -29,22 +285,222 @@ func (c *Consumer) Close() error {
 	return c.Reader.Close()
 }
 
+// Pause приостанавливает чтение новых сообщений
+// Уже прочитанные сообщения продолжают обрабатываться
+func (c *Consumer) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+	}
+}
+
+// Resume возобновляет чтение сообщений
+func (c *Consumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+// Paused сообщает, приостановлено ли чтение
+func (c *Consumer) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resumed != nil
+}
+
+// waitResumed блокируется, пока чтение приостановлено
+func (c *Consumer) waitResumed(ctx context.Context) error {
+	c.mu.Lock()
+	resumed := c.resumed
+	c.mu.Unlock()
+
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ReadMessages читает сообщения и вызывает handle для каждого
+// Сообщения одной партиции обрабатываются последовательно в одной горутине,
+// разные партиции - параллельно, не больше concurrency одновременно.
+// У каждой партиции своя ограниченная очередь, поэтому медленная партиция
+// не занимает место остальных. Смещение фиксируется после обработки сообщения,
+// а не при чтении
+// После отмены ctx чтение прекращается, а уже прочитанные сообщения обрабатываются
+// с неотмененным контекстом, не дольше drainTimeout (WithDrainTimeout)
+// Заголовки сообщения доступны обработчику через HeadersFromContext
+// Паника в handle перехватывается: сообщение передается PanicHandler и пропускается
 // Если handle не задан вернём ошибку
-func (c *Consumer) ReadMessages(ctx context.Context, handle func([]byte)) error {
+func (c *Consumer) ReadMessages(ctx context.Context, handle func(context.Context, []byte)) error {
 	if handle == nil {
 		return errors.New("handle is nil")
 	}
+
+	dispatcher := newPartitionDispatcher(ctx, handle, c.concurrency, c.handlePanic, c.commitMessage)
+	defer dispatcher.stop(c.drainTimeout)
+
+	backoff := newReconnectBackoff(c.minBackoff, c.maxBackoff)
+	sleep := c.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
 	for {
-		m, err := c.Reader.ReadMessage(ctx)
+		if err := c.waitResumed(ctx); err != nil {
+			return err
+		}
+
+		m, err := c.reader.FetchMessage(ctx)
 		if err != nil {
 			if ctx.Err() != nil {
 				return ctx.Err()
 			}
-			log.Printf("Kafka read error: %v", err)
+
+			// Брокер недоступен - ждем с растущей задержкой, а не крутимся в цикле
+			errorType := readErrorType(err)
+			c.recordFailure(errorType)
+			delay := backoff.next()
+			log.Printf("Kafka read error (%s), retrying in %v: %v", errorType, delay, err)
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
 			continue
 		}
+		backoff.reset()
+
+		if err := dispatcher.dispatch(ctx, m); err != nil {
+			return err
+		}
+	}
+}
+
+// handlePanic записывает панику обработчика в лог и метрики и передает сообщение PanicHandler
+func (c *Consumer) handlePanic(ctx context.Context, m kafka.Message, recovered interface{}) {
+	c.log().WithFields(logrus.Fields{
+		"partition": m.Partition,
+		"offset":    m.Offset,
+	}).Errorf("Kafka message handler panicked: %v\n%s", recovered, debug.Stack())
+	c.recordFailure("panic")
+
+	if c.panicHandler != nil {
+		c.panicHandler(ctx, m.Value, recovered)
+	}
+}
+
+// commitMessage фиксирует смещение обработанного сообщения
+// Без группы kafka-go не хранит смещения, поэтому фиксировать нечего
+// Ошибка только логируется: сообщение уже обработано и при повторной доставке
+// будет обработано еще раз
+func (c *Consumer) commitMessage(ctx context.Context, m kafka.Message) {
+	if c.groupID == "" {
+		return
+	}
+	if err := c.reader.CommitMessages(ctx, m); err != nil {
+		c.log().WithFields(logrus.Fields{
+			"partition": m.Partition,
+			"offset":    m.Offset,
+		}).Warnf("Failed to commit Kafka message: %v", err)
+		c.recordFailure("commit")
+	}
+}
+
+// recordFailure учитывает ошибку чтения в метриках
+func (c *Consumer) recordFailure(errorType string) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.KafkaMessagesFailed.WithLabelValues(c.topic, c.groupID, errorType).Inc()
+}
+
+// readErrorType классифицирует ошибку чтения для метки error_type
+func readErrorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &netErr):
+		return "connection"
+	default:
+		return "other"
+	}
+}
+
+// IsFatalReadError сообщает, что чтение не восстановится без вмешательства:
+// топика нет или нет прав. kafka-go считает отсутствие топика временной ошибкой,
+// но при выключенном автосоздании топиков повторы только нагружают брокер
+func IsFatalReadError(err error) bool {
+	var kafkaErr kafka.Error
+	if !errors.As(err, &kafkaErr) {
+		return false
+	}
+	switch kafkaErr {
+	case kafka.UnknownTopicOrPartition,
+		kafka.InvalidTopic,
+		kafka.TopicAuthorizationFailed,
+		kafka.GroupAuthorizationFailed,
+		kafka.ClusterAuthorizationFailed,
+		kafka.SASLAuthenticationFailed,
+		kafka.UnsupportedSASLMechanism,
+		kafka.IllegalSASLState:
+		return true
+	default:
+		return false
+	}
+}
+
+// reconnectBackoff экспоненциальная задержка с ограничением сверху
+type reconnectBackoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newReconnectBackoff(min, max time.Duration) *reconnectBackoff {
+	if max < min {
+		max = min
+	}
+	return &reconnectBackoff{min: min, max: max}
+}
+
+// next возвращает следующую задержку, каждая следующая вдвое больше предыдущей
+func (b *reconnectBackoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	} else {
+		b.current *= 2
+	}
+	if b.current > b.max {
+		b.current = b.max
+	}
+	return b.current
+}
+
+// reset сбрасывает задержку после успешного чтения
+func (b *reconnectBackoff) reset() {
+	b.current = 0
+}
+
+// sleepContext ждет d или отмены контекста
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-		handle(m.Value)
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
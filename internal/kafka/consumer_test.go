@@ -1,12 +1,29 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"wbtest/internal/metrics"
 	"wbtest/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 func TestNewConsumer(t *testing.T) {
@@ -81,6 +98,66 @@ func TestNewConsumer(t *testing.T) {
 	}
 }
 
+func TestNewConsumer_GroupOptions(t *testing.T) {
+	balancer, err := ParseGroupBalancer(GroupBalancerRoundRobin)
+	if err != nil {
+		t.Fatalf("ParseGroupBalancer() error = %v", err)
+	}
+
+	consumer := NewConsumer([]string{"localhost:9092"}, "test-topic", "test-group",
+		WithGroupBalancer(balancer),
+		WithSessionTimeout(45*time.Second),
+		WithHeartbeatInterval(5*time.Second),
+	)
+	defer consumer.Close()
+
+	cfg := consumer.Reader.Config()
+	if len(cfg.GroupBalancers) != 1 {
+		t.Fatalf("Expected 1 group balancer, got %d", len(cfg.GroupBalancers))
+	}
+	if name := cfg.GroupBalancers[0].ProtocolName(); name != "roundrobin" {
+		t.Errorf("Expected roundrobin balancer, got %s", name)
+	}
+	if cfg.SessionTimeout != 45*time.Second {
+		t.Errorf("Expected session timeout 45s, got %v", cfg.SessionTimeout)
+	}
+	if cfg.HeartbeatInterval != 5*time.Second {
+		t.Errorf("Expected heartbeat interval 5s, got %v", cfg.HeartbeatInterval)
+	}
+}
+
+func TestParseGroupBalancer(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		wantErr  bool
+	}{
+		{GroupBalancerRange, "range", false},
+		{GroupBalancerRoundRobin, "roundrobin", false},
+		{"sticky", "", true},
+		{"unknown", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balancer, err := ParseGroupBalancer(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGroupBalancer(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if !tt.wantErr && balancer.ProtocolName() != tt.protocol {
+				t.Errorf("Expected protocol %s, got %s", tt.protocol, balancer.ProtocolName())
+			}
+		})
+	}
+
+	t.Run("empty keeps defaults", func(t *testing.T) {
+		balancer, err := ParseGroupBalancer("")
+		if err != nil || balancer != nil {
+			t.Errorf("ParseGroupBalancer(\"\") = %v, %v, want nil, nil", balancer, err)
+		}
+	})
+}
+
 func TestKafkaConsumer_ReadMessages(t *testing.T) {
 	consumer := NewConsumer([]string{"localhost:9092"}, "test-topic", "test-group")
 
@@ -90,7 +167,7 @@ func TestKafkaConsumer_ReadMessages(t *testing.T) {
 
 	// Тестируем обработку сообщений
 	messageCount := 0
-	handler := func(msg []byte) {
+	handler := func(_ context.Context, msg []byte) {
 		messageCount++
 	}
 
@@ -117,7 +194,7 @@ func TestKafkaConsumer_ReadMessagesWithValidHandler(t *testing.T) {
 
 	// Создаем обработчик, который проверяет валидность JSON
 	processedMessages := make([][]byte, 0)
-	handler := func(msg []byte) {
+	handler := func(_ context.Context, msg []byte) {
 		// Проверяем, что сообщение можно распарсить как JSON
 		var order model.Order
 		err := json.Unmarshal(msg, &order)
@@ -159,7 +236,7 @@ func TestKafkaConsumer_ReadMessagesWithContextCancellation(t *testing.T) {
 	cancel() // Отменяем сразу
 
 	messageCount := 0
-	handler := func(msg []byte) {
+	handler := func(_ context.Context, msg []byte) {
 		messageCount++
 	}
 
@@ -185,7 +262,7 @@ func TestKafkaConsumer_ReadMessagesWithPanicHandler(t *testing.T) {
 	defer cancel()
 
 	// Создаем обработчик, который паникует
-	handler := func(msg []byte) {
+	handler := func(_ context.Context, msg []byte) {
 		panic("test panic")
 	}
 
@@ -206,7 +283,7 @@ func TestKafkaConsumer_ReadMessagesWithSlowHandler(t *testing.T) {
 	defer cancel()
 
 	// Создаем медленный обработчик
-	handler := func(msg []byte) {
+	handler := func(_ context.Context, msg []byte) {
 		time.Sleep(200 * time.Millisecond) // Больше таймаута контекста
 	}
 
@@ -250,3 +327,764 @@ func TestKafkaConsumer_Configuration(t *testing.T) {
 	// (В реальной реализации мы бы проверяли поля consumer)
 	// Здесь мы просто проверяем, что consumer создался без ошибок
 }
+
+// fakeReader отдает заранее подготовленные сообщения, затем ждет отмены контекста
+// Зафиксированные смещения запоминаются в committed
+type fakeReader struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if len(f.messages) > 0 {
+		m := f.messages[0]
+		f.messages = f.messages[1:]
+		f.mu.Unlock()
+		return m, nil
+	}
+	f.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (f *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = append(f.committed, msgs...)
+	return nil
+}
+
+// commits возвращает зафиксированные сообщения
+func (f *fakeReader) commits() []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kafka.Message(nil), f.committed...)
+}
+
+func (f *fakeReader) Close() error {
+	return nil
+}
+
+// goroutineID возвращает номер текущей горутины из runtime.Stack
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	buf = buf[:bytes.IndexByte(buf, ' ')]
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+func TestKafkaConsumer_ReadMessagesOrderedPerKey(t *testing.T) {
+	const (
+		partitions     = 4
+		keys           = 8
+		messagesPerKey = 20
+		totalMessages  = keys * messagesPerKey
+	)
+
+	// Партиции назначаются так же, как их выбирает Producer
+	balancer := &kafka.Hash{}
+	partitionIDs := make([]int, partitions)
+	for i := range partitionIDs {
+		partitionIDs[i] = i
+	}
+
+	reader := &fakeReader{}
+	for seq := 0; seq < messagesPerKey; seq++ {
+		for k := 0; k < keys; k++ {
+			m := kafka.Message{
+				Key:   []byte(fmt.Sprintf("order-%d", k)),
+				Value: []byte(fmt.Sprintf("order-%d:%d", k, seq)),
+			}
+			m.Partition = balancer.Balance(m, partitionIDs...)
+			reader.messages = append(reader.messages, m)
+		}
+	}
+
+	consumer := &Consumer{reader: reader}
+
+	var (
+		mu         sync.Mutex
+		received   = make(map[string][]int)
+		goroutines = make(map[string]map[uint64]bool)
+		done       sync.WaitGroup
+	)
+	done.Add(totalMessages)
+
+	handler := func(_ context.Context, msg []byte) {
+		defer done.Done()
+
+		var key string
+		var seq int
+		if _, err := fmt.Sscanf(string(bytes.Replace(msg, []byte(":"), []byte(" "), 1)), "%s %d", &key, &seq); err != nil {
+			t.Errorf("Failed to parse message %s: %v", msg, err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		received[key] = append(received[key], seq)
+		if goroutines[key] == nil {
+			goroutines[key] = make(map[uint64]bool)
+		}
+		goroutines[key][goroutineID()] = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, handler)
+	}()
+
+	done.Wait()
+	cancel()
+	<-errCh
+
+	if len(received) != keys {
+		t.Fatalf("Expected %d keys, got %d", keys, len(received))
+	}
+
+	for key, seqs := range received {
+		if len(seqs) != messagesPerKey {
+			t.Errorf("Key %s: expected %d messages, got %d", key, messagesPerKey, len(seqs))
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Errorf("Key %s: message %d delivered out of order (got seq %d)", key, i, seq)
+				break
+			}
+		}
+		if len(goroutines[key]) != 1 {
+			t.Errorf("Key %s: expected 1 handler goroutine, got %d", key, len(goroutines[key]))
+		}
+	}
+}
+
+// partitionMessages возвращает count сообщений партиции со значениями "p<partition>:<seq>"
+func partitionMessages(partition, count int) []kafka.Message {
+	messages := make([]kafka.Message, count)
+	for i := range messages {
+		messages[i] = kafka.Message{
+			Partition: partition,
+			Offset:    int64(i),
+			Value:     []byte(fmt.Sprintf("p%d:%d", partition, i)),
+		}
+	}
+	return messages
+}
+
+func TestKafkaConsumer_ReadMessagesHotPartitionDoesNotBlock(t *testing.T) {
+	const hotMessages = 100
+
+	// Горячая партиция 0 прочитана раньше партиции 1 и обработать ее
+	// не удается, пока партиция 1 не продвинется
+	reader := &fakeReader{messages: append(partitionMessages(0, hotMessages), partitionMessages(1, 3)...)}
+	consumer := &Consumer{reader: reader}
+
+	coldDone := make(chan struct{})
+	var (
+		mu        sync.Mutex
+		cold, hot int
+	)
+	handler := func(_ context.Context, msg []byte) {
+		if bytes.HasPrefix(msg, []byte("p1:")) {
+			mu.Lock()
+			cold++
+			if cold == 3 {
+				close(coldDone)
+			}
+			mu.Unlock()
+			return
+		}
+
+		select {
+		case <-coldDone:
+		case <-time.After(5 * time.Second):
+		}
+		mu.Lock()
+		hot++
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, handler)
+	}()
+
+	select {
+	case <-coldDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Partition 1 made no progress while partition 0 was busy")
+	}
+
+	cancel()
+	<-errCh
+
+	if hot != hotMessages {
+		t.Errorf("Expected %d messages of partition 0 handled, got %d", hotMessages, hot)
+	}
+}
+
+func TestKafkaConsumer_ReadMessages_CommitsAfterHandle(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Partition: 0, Offset: 10, Value: []byte("slow")},
+		{Partition: 0, Offset: 11, Value: []byte("boom")},
+	}}
+	consumer := (&Consumer{reader: reader, groupID: "order-service"}).
+		WithPanicHandler(func(context.Context, []byte, interface{}) {}).
+		WithLogger(logrus.New())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(_ context.Context, msg []byte) {
+			if string(msg) == "boom" {
+				panic("boom")
+			}
+			close(started)
+			<-release
+		})
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Handler was not called")
+	}
+	if commits := reader.commits(); len(commits) != 0 {
+		t.Fatalf("Expected no commits while handler is running, got %v", commits)
+	}
+	close(release)
+
+	// Сообщение с паникой уже передано PanicHandler, поэтому тоже фиксируется
+	deadline := time.After(time.Second)
+	for len(reader.commits()) < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("Expected both messages committed, got %v", reader.commits())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	for i, m := range reader.commits() {
+		if want := int64(10 + i); m.Offset != want {
+			t.Errorf("Expected commit %d at offset %d, got %d", i, want, m.Offset)
+		}
+	}
+
+	cancel()
+	<-errCh
+}
+
+// remaining возвращает число еще не прочитанных сообщений
+func (f *fakeReader) remaining() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.messages)
+}
+
+func TestKafkaConsumer_ReadMessages_DrainsQueuedOnShutdown(t *testing.T) {
+	reader := &fakeReader{messages: partitionMessages(0, 3)}
+	consumer := &Consumer{reader: reader, groupID: "order-service"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		handled []string
+		ctxErrs []error
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(msgCtx context.Context, msg []byte) {
+			if string(msg) == "p0:0" {
+				close(started)
+				<-release
+			}
+			mu.Lock()
+			handled = append(handled, string(msg))
+			ctxErrs = append(ctxErrs, msgCtx.Err())
+			mu.Unlock()
+		})
+	}()
+
+	// Первое сообщение обрабатывается, остальные прочитаны и ждут в очереди
+	<-started
+	for reader.remaining() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	close(release)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadMessages did not return after shutdown")
+	}
+
+	if want := []string{"p0:0", "p0:1", "p0:2"}; !reflect.DeepEqual(handled, want) {
+		t.Fatalf("Expected queued messages drained in order %v, got %v", want, handled)
+	}
+	for i, err := range ctxErrs {
+		if err != nil {
+			t.Errorf("Expected message %d handled with live context, got %v", i, err)
+		}
+	}
+	if commits := reader.commits(); len(commits) != 3 {
+		t.Errorf("Expected all drained messages committed, got %d", len(commits))
+	}
+}
+
+func TestKafkaConsumer_ReadMessages_DrainTimeout(t *testing.T) {
+	reader := &fakeReader{messages: partitionMessages(0, 3)}
+	consumer := (&Consumer{reader: reader, groupID: "order-service"}).WithDrainTimeout(50 * time.Millisecond)
+
+	started := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		handled []string
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(msgCtx context.Context, msg []byte) {
+			if string(msg) == "p0:0" {
+				close(started)
+				<-msgCtx.Done()
+			}
+			mu.Lock()
+			handled = append(handled, string(msg))
+			mu.Unlock()
+		})
+	}()
+
+	<-started
+	for reader.remaining() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case <-errCh:
+	case <-time.After(time.Second):
+		t.Fatal("Drain timeout did not cancel the handler")
+	}
+
+	// Зависшее сообщение прервано, оставшиеся не обработаны и придут повторно
+	if want := []string{"p0:0"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("Expected only %v handled, got %v", want, handled)
+	}
+	for _, m := range reader.commits() {
+		if m.Offset > 0 {
+			t.Errorf("Expected skipped message at offset %d to stay uncommitted", m.Offset)
+		}
+	}
+}
+
+func TestKafkaConsumer_ReadMessages_NoCommitWithoutGroup(t *testing.T) {
+	reader := &fakeReader{messages: partitionMessages(0, 3)}
+	consumer := &Consumer{reader: reader}
+
+	handled := make(chan struct{}, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(context.Context, []byte) {
+			handled <- struct{}{}
+		})
+	}()
+	for i := 0; i < 3; i++ {
+		<-handled
+	}
+	cancel()
+	<-errCh
+
+	if commits := reader.commits(); len(commits) != 0 {
+		t.Errorf("Expected no commits without consumer group, got %v", commits)
+	}
+}
+
+func TestKafkaConsumer_ReadMessagesConcurrencyFairness(t *testing.T) {
+	const (
+		hotMessages  = 50
+		coldMessages = 5
+	)
+
+	reader := &fakeReader{messages: append(partitionMessages(0, hotMessages), partitionMessages(1, coldMessages)...)}
+	consumer := (&Consumer{reader: reader}).WithConcurrency(1)
+
+	var (
+		mu      sync.Mutex
+		handled []string
+		active  int32
+		peak    int32
+		done    sync.WaitGroup
+	)
+	done.Add(hotMessages + coldMessages)
+
+	handler := func(_ context.Context, msg []byte) {
+		defer done.Done()
+
+		if n := atomic.AddInt32(&active, 1); n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+
+		mu.Lock()
+		handled = append(handled, string(msg))
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, handler)
+	}()
+
+	done.Wait()
+	cancel()
+	<-errCh
+
+	if peak != 1 {
+		t.Errorf("Expected at most 1 message handled at a time, got %d", peak)
+	}
+
+	// Партиции получают слот по очереди: партиция 1 заканчивает раньше,
+	// чем обработана очередь партиции 0, и порядок внутри партиций сохраняется
+	lastCold, lastHot := -1, -1
+	next := map[byte]int{}
+	for i, msg := range handled {
+		partition := msg[1]
+		var seq int
+		fmt.Sscanf(msg[3:], "%d", &seq)
+		if seq != next[partition] {
+			t.Errorf("Partition %c: expected seq %d, got %d", partition, next[partition], seq)
+		}
+		next[partition] = seq + 1
+
+		if partition == '1' {
+			lastCold = i
+		} else {
+			lastHot = i
+		}
+	}
+	if lastCold > lastHot {
+		t.Errorf("Partition 1 waited for partition 0 to drain: handled order %v", handled)
+	}
+}
+
+func TestKafkaConsumer_ReadMessages_Headers(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Value: []byte("with headers"), Headers: []kafka.Header{
+			{Key: HeaderTraceID, Value: []byte("trace-123")},
+			{Key: HeaderSchemaVersion, Value: []byte("1")},
+		}},
+		{Value: []byte("without headers")},
+	}}
+	consumer := &Consumer{reader: reader}
+
+	received := make(chan Headers, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(msgCtx context.Context, msg []byte) {
+			received <- HeadersFromContext(msgCtx)
+		})
+	}()
+
+	first, second := <-received, <-received
+	cancel()
+	<-errCh
+
+	if first[HeaderTraceID] != "trace-123" || first[HeaderSchemaVersion] != "1" {
+		t.Errorf("Expected trace-id and schema-version headers, got %v", first)
+	}
+	if second != nil {
+		t.Errorf("Expected no headers for second message, got %v", second)
+	}
+}
+
+func TestKafkaConsumer_ReadMessages_Position(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Partition: 2, Offset: 42, Value: []byte("order")},
+	}}
+	consumer := &Consumer{reader: reader}
+
+	received := make(chan Position, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(msgCtx context.Context, msg []byte) {
+			pos, ok := PositionFromContext(msgCtx)
+			if !ok {
+				t.Error("Expected message position in context")
+			}
+			received <- pos
+		})
+	}()
+
+	pos := <-received
+	cancel()
+	<-errCh
+
+	if pos.Partition != 2 || pos.Offset != 42 {
+		t.Errorf("Expected partition 2 offset 42, got %+v", pos)
+	}
+}
+
+func TestKafkaConsumer_PauseResume(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Partition: 0, Value: []byte("first")},
+		{Partition: 0, Value: []byte("second")},
+	}}
+	consumer := &Consumer{reader: reader}
+
+	consumer.Pause()
+	if !consumer.Paused() {
+		t.Fatal("Expected consumer to be paused")
+	}
+
+	received := make(chan string, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(_ context.Context, msg []byte) {
+			received <- string(msg)
+		})
+	}()
+
+	// Пока consumer на паузе, сообщения не читаются
+	select {
+	case msg := <-received:
+		t.Fatalf("Unexpected message while paused: %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	consumer.Resume()
+	if consumer.Paused() {
+		t.Fatal("Expected consumer to be resumed")
+	}
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case msg := <-received:
+			if msg != expected {
+				t.Errorf("Expected %s, got %s", expected, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s", expected)
+		}
+	}
+
+	cancel()
+	<-errCh
+}
+
+func TestKafkaConsumer_Ping(t *testing.T) {
+	t.Run("no brokers", func(t *testing.T) {
+		consumer := &Consumer{}
+		if err := consumer.Ping(context.Background()); err == nil {
+			t.Error("Expected error for consumer without brokers")
+		}
+	})
+
+	t.Run("unreachable broker", func(t *testing.T) {
+		// Занимаем порт и сразу освобождаем, чтобы по адресу никто не слушал
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		addr := listener.Addr().String()
+		listener.Close()
+
+		consumer := NewConsumer([]string{addr}, "test-topic", "test-group")
+		defer consumer.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := consumer.Ping(ctx); err == nil {
+			t.Error("Expected error for unreachable broker")
+		}
+	})
+}
+
+// flakyReader возвращает ошибки и сообщения по сценарию, затем ждет отмены контекста
+type flakyReader struct {
+	mu    sync.Mutex
+	steps []error // nil - успешное чтение
+}
+
+func (f *flakyReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if len(f.steps) > 0 {
+		err := f.steps[0]
+		f.steps = f.steps[1:]
+		f.mu.Unlock()
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		return kafka.Message{Value: []byte("ok")}, nil
+	}
+	f.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (f *flakyReader) CommitMessages(context.Context, ...kafka.Message) error {
+	return nil
+}
+
+func (f *flakyReader) Close() error {
+	return nil
+}
+
+func TestKafkaConsumer_ReadMessagesReconnectBackoff(t *testing.T) {
+	brokerDown := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var delays []time.Duration
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+	consumer := &Consumer{
+		reader:     &flakyReader{steps: []error{brokerDown, brokerDown, brokerDown, nil, brokerDown}},
+		topic:      "orders",
+		groupID:    "order-service",
+		minBackoff: 10 * time.Millisecond,
+		maxBackoff: 30 * time.Millisecond,
+		metrics:    m,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			if len(delays) == 4 {
+				cancel()
+			}
+			return nil
+		},
+	}
+
+	var received int32
+	err := consumer.ReadMessages(ctx, func(_ context.Context, msg []byte) {
+		atomic.AddInt32(&received, 1)
+	})
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// Задержка растет до ограничения и сбрасывается после успешного чтения
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 10 * time.Millisecond}
+	if len(delays) != len(expected) {
+		t.Fatalf("Expected delays %v, got %v", expected, delays)
+	}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Errorf("Delay %d: expected %v, got %v", i, expected[i], delays[i])
+		}
+	}
+
+	if atomic.LoadInt32(&received) != 1 {
+		t.Errorf("Expected 1 message handled, got %d", received)
+	}
+	if got := testutil.ToFloat64(m.KafkaMessagesFailed.WithLabelValues("orders", "order-service", "connection")); got != 4 {
+		t.Errorf("Expected 4 connection failures in metrics, got %v", got)
+	}
+}
+
+func TestIsFatalReadError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"unknown topic", kafka.UnknownTopicOrPartition, true},
+		{"wrapped auth", fmt.Errorf("fetch: %w", kafka.TopicAuthorizationFailed), true},
+		{"sasl", kafka.SASLAuthenticationFailed, true},
+		{"leader not available", kafka.LeaderNotAvailable, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, false},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFatalReadError(tt.err); got != tt.expected {
+				t.Errorf("IsFatalReadError(%v) = %v, want %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKafkaConsumer_ReadMessages_PanicRecovered(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Partition: 0, Value: []byte("first")},
+		{Partition: 0, Value: []byte("boom")},
+		{Partition: 0, Value: []byte("after")},
+	}}
+
+	type panicked struct {
+		msg       string
+		recovered interface{}
+	}
+	dlq := make(chan panicked, 1)
+	logger, hook := logtest.NewNullLogger()
+	consumer := (&Consumer{reader: reader}).WithPanicHandler(func(_ context.Context, msg []byte, recovered interface{}) {
+		dlq <- panicked{msg: string(msg), recovered: recovered}
+	}).WithLogger(logger)
+
+	handled := make(chan string, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- consumer.ReadMessages(ctx, func(_ context.Context, msg []byte) {
+			if string(msg) == "boom" {
+				panic("nil map write")
+			}
+			handled <- string(msg)
+		})
+	}()
+
+	// Сообщение после паники обрабатывается тем же воркером партиции
+	for _, want := range []string{"first", "after"} {
+		select {
+		case got := <-handled:
+			if got != want {
+				t.Errorf("Expected %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Consumer stopped after panic, %q not handled", want)
+		}
+	}
+
+	select {
+	case p := <-dlq:
+		if p.msg != "boom" || p.recovered != "nil map write" {
+			t.Errorf("Expected panicked message boom with its panic value, got %+v", p)
+		}
+	default:
+		t.Error("Expected panicked message to be passed to PanicHandler")
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Level != logrus.ErrorLevel || !strings.Contains(entry.Message, "nil map write") {
+		t.Fatalf("Expected panic to be logged with the consumer logger, got %+v", entry)
+	}
+	if entry.Data["partition"] != 0 || entry.Data["offset"] != int64(0) {
+		t.Errorf("Expected partition and offset fields, got %v", entry.Data)
+	}
+
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
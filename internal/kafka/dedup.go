@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// Deduplicator помнит недавно обработанные сообщения
+// Kafka доставляет сообщения at-least-once, повторная доставка того же
+// сообщения в пределах окна не должна снова записываться в БД
+// Ключ - order_uid, вместе с ним хранится хеш сообщения,
+// поэтому новая версия заказа с тем же UID дубликатом не считается
+type Deduplicator struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]dedupEntry
+	lastSweep time.Time
+}
+
+type dedupEntry struct {
+	hash      [sha256.Size]byte
+	expiresAt time.Time
+}
+
+// NewDeduplicator создает дедупликатор с окном window
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		window:  window,
+		now:     time.Now,
+		entries: make(map[string]dedupEntry),
+	}
+}
+
+// Seen сообщает, что такое же сообщение с ключом key уже обработано в пределах окна
+// Безопасен для вызова на nil, тогда всегда возвращает false
+func (d *Deduplicator) Seen(key string, message []byte) bool {
+	if d == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[key]
+	if !ok {
+		return false
+	}
+	if !d.now().Before(entry.expiresAt) {
+		delete(d.entries, key)
+		return false
+	}
+	return entry.hash == sha256.Sum256(message)
+}
+
+// Remember запоминает успешно обработанное сообщение
+// Безопасен для вызова на nil, тогда ничего не делает
+func (d *Deduplicator) Remember(key string, message []byte) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.entries[key] = dedupEntry{
+		hash:      sha256.Sum256(message),
+		expiresAt: now.Add(d.window),
+	}
+
+	// Истекшие записи удаляем не чаще раза за окно
+	if now.Sub(d.lastSweep) >= d.window {
+		d.sweep(now)
+	}
+}
+
+// sweep удаляет истекшие записи, вызывается под блокировкой
+func (d *Deduplicator) sweep(now time.Time) {
+	for key, entry := range d.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(d.entries, key)
+		}
+	}
+	d.lastSweep = now
+}
+
+// Len возвращает число запомненных сообщений
+func (d *Deduplicator) Len() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.entries)
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDeduplicator(time.Minute)
+	d.now = func() time.Time { return now }
+
+	message := []byte(`{"order_uid":"order-1"}`)
+
+	if d.Seen("order-1", message) {
+		t.Fatal("Expected unknown message not to be seen")
+	}
+
+	d.Remember("order-1", message)
+	if !d.Seen("order-1", message) {
+		t.Error("Expected remembered message to be seen")
+	}
+
+	// Новая версия заказа с тем же UID не дубликат
+	if d.Seen("order-1", []byte(`{"order_uid":"order-1","entry":"WBIL"}`)) {
+		t.Error("Expected changed message not to be seen")
+	}
+
+	now = now.Add(time.Minute)
+	if d.Seen("order-1", message) {
+		t.Error("Expected message to be forgotten after window")
+	}
+	if d.Len() != 0 {
+		t.Errorf("Expected expired entry to be removed, got %d entries", d.Len())
+	}
+}
+
+func TestDeduplicator_Nil(t *testing.T) {
+	var d *Deduplicator
+	d.Remember("order-1", []byte("message"))
+	if d.Seen("order-1", []byte("message")) {
+		t.Error("Expected nil deduplicator never to report duplicates")
+	}
+}
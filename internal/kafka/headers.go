@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки сообщений о заказах
+const (
+	// HeaderTraceID идентификатор трассировки, общий для producer и consumer
+	HeaderTraceID = "trace-id"
+	// HeaderSchemaVersion версия схемы тела сообщения
+	HeaderSchemaVersion = "schema-version"
+)
+
+// Headers заголовки сообщения Kafka
+type Headers map[string]string
+
+type headersKey struct{}
+
+// Position положение сообщения в топике
+// Сообщения с одним ключом попадают в одну партицию, поэтому для них
+// больший Offset означает более позднюю версию
+type Position struct {
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+type positionKey struct{}
+
+// ContextWithHeaders сохраняет заголовки сообщения в контексте
+// Consumer передает так заголовки обработчику, Producer берет их из контекста при записи
+func ContextWithHeaders(ctx context.Context, headers Headers) context.Context {
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+// HeadersFromContext возвращает заголовки из контекста, nil если их нет
+func HeadersFromContext(ctx context.Context) Headers {
+	headers, _ := ctx.Value(headersKey{}).(Headers)
+	return headers
+}
+
+// ContextWithPosition сохраняет положение сообщения в контексте
+func ContextWithPosition(ctx context.Context, pos Position) context.Context {
+	return context.WithValue(ctx, positionKey{}, pos)
+}
+
+// PositionFromContext возвращает положение сообщения, false если его нет
+func PositionFromContext(ctx context.Context) (Position, bool) {
+	pos, ok := ctx.Value(positionKey{}).(Position)
+	return pos, ok
+}
+
+// NewTraceID создает случайный идентификатор трассировки
+func NewTraceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// messageHeaders собирает заголовки сообщения, при повторе ключа побеждает последний
+func messageHeaders(m kafka.Message) Headers {
+	if len(m.Headers) == 0 {
+		return nil
+	}
+	headers := make(Headers, len(m.Headers))
+	for _, header := range m.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	return headers
+}
+
+// kafkaHeaders переводит заголовки в формат kafka-go в порядке ключей
+func (h Headers) kafkaHeaders() []kafka.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	headers := make([]kafka.Header, 0, len(keys))
+	for _, key := range keys {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(h[key])})
+	}
+	return headers
+}
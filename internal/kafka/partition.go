@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// maxQueuedPerPartition предел прочитанных, но еще не обработанных сообщений одной партиции
+const maxQueuedPerPartition = 256
+
+// messageReader источник сообщений Kafka
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// partitionDispatcher раздает сообщения воркерам по партициям
+// Каждая партиция обрабатывается одной горутиной, поэтому сообщения
+// с одним ключом обрабатываются строго по порядку, а разные партиции - параллельно
+// У каждой партиции своя очередь на maxQueuedPerPartition сообщений:
+// отставшая партиция не занимает место в очередях остальных.
+// kafka-go отдает все партиции одним потоком и не умеет приостанавливать
+// отдельную партицию, поэтому чтение ждет, только когда следующее сообщение
+// приходится на заполненную очередь. Остальные партиции при этом
+// продолжают обрабатывать уже принятые сообщения
+type partitionDispatcher struct {
+	handle func(context.Context, []byte)
+	// onPanic вызывается для сообщения, обработка которого завершилась паникой
+	onPanic func(context.Context, kafka.Message, interface{})
+	// commit фиксирует смещение обработанного сообщения, nil - смещения не фиксируются
+	commit  func(context.Context, kafka.Message)
+	workers map[int]chan kafka.Message
+	wg      sync.WaitGroup
+
+	// workCtx контекст обработки. Он не отменяется вместе с чтением, чтобы при остановке
+	// уже принятые сообщения обработались, а не ушли в DLQ с ошибкой отмены
+	// cancelWork прерывает обработку, если дренаж не уложился в срок
+	workCtx    context.Context
+	cancelWork context.CancelFunc
+
+	// slots ограничивает число одновременно обрабатываемых сообщений, nil - без ограничения
+	// Ожидающие воркеры получают слот по очереди, поэтому горячая партиция
+	// не занимает его постоянно
+	slots chan struct{}
+}
+
+// newPartitionDispatcher создает диспетчер
+// concurrency - число партиций, обрабатываемых одновременно, 0 - все сразу
+// onPanic получает сообщение, на котором handle запаниковал, nil - паника только перехватывается
+// commit вызывается после обработки каждого сообщения, в том числе завершившейся паникой
+// Обработчики получают значения ctx, но не его отмену
+func newPartitionDispatcher(ctx context.Context, handle func(context.Context, []byte), concurrency int,
+	onPanic func(context.Context, kafka.Message, interface{}),
+	commit func(context.Context, kafka.Message)) *partitionDispatcher {
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	d := &partitionDispatcher{
+		handle:     handle,
+		onPanic:    onPanic,
+		commit:     commit,
+		workers:    make(map[int]chan kafka.Message),
+		workCtx:    workCtx,
+		cancelWork: cancelWork,
+	}
+	if concurrency > 0 {
+		d.slots = make(chan struct{}, concurrency)
+	}
+	return d
+}
+
+// dispatch ставит сообщение в очередь его партиции
+// Блокируется, если очередь этой партиции заполнена. В свободную очередь
+// прочитанное сообщение принимается и после отмены ctx, чтобы обработаться при дренаже
+func (d *partitionDispatcher) dispatch(ctx context.Context, m kafka.Message) error {
+	queue, ok := d.workers[m.Partition]
+	if !ok {
+		queue = make(chan kafka.Message, maxQueuedPerPartition)
+		d.workers[m.Partition] = queue
+
+		d.wg.Add(1)
+		go d.work(queue)
+	}
+
+	select {
+	case queue <- m:
+		return nil
+	default:
+	}
+
+	select {
+	case queue <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work обрабатывает сообщения партиции, заголовки и положение сообщения
+// передаются обработчику через контекст. Смещение фиксируется только
+// после обработки, поэтому необработанные сообщения придут повторно
+func (d *partitionDispatcher) work(queue <-chan kafka.Message) {
+	defer d.wg.Done()
+	ctx := d.workCtx
+	for m := range queue {
+		// Дренаж прерван: оставшиеся сообщения не обрабатываются и не фиксируются,
+		// после перезапуска они придут снова
+		if ctx.Err() != nil {
+			continue
+		}
+
+		msgCtx := ContextWithPosition(ctx, Position{Partition: m.Partition, Offset: m.Offset, Time: m.Time})
+		if headers := messageHeaders(m); headers != nil {
+			msgCtx = ContextWithHeaders(msgCtx, headers)
+		}
+
+		d.acquire()
+		d.safeHandle(msgCtx, m)
+		d.release()
+
+		if d.commit != nil {
+			d.commit(ctx, m)
+		}
+	}
+}
+
+// safeHandle вызывает обработчик и перехватывает его панику
+// Иначе паника на одном сообщении завершила бы процесс, а воркер партиции
+// не освободил бы слот. Сообщение передано onPanic, поэтому дальше оно пропускается
+func (d *partitionDispatcher) safeHandle(ctx context.Context, m kafka.Message) {
+	defer func() {
+		if recovered := recover(); recovered != nil && d.onPanic != nil {
+			d.onPanic(ctx, m, recovered)
+		}
+	}()
+	d.handle(ctx, m.Value)
+}
+
+// acquire занимает слот обработки
+func (d *partitionDispatcher) acquire() {
+	if d.slots != nil {
+		d.slots <- struct{}{}
+	}
+}
+
+// release освобождает слот обработки
+func (d *partitionDispatcher) release() {
+	if d.slots != nil {
+		<-d.slots
+	}
+}
+
+// stop закрывает очереди и ждет обработки уже принятых сообщений
+// Если обработка не закончилась за drainTimeout, контекст обработчиков отменяется,
+// а оставшиеся в очередях сообщения пропускаются. drainTimeout <= 0 - ждать без ограничения
+func (d *partitionDispatcher) stop(drainTimeout time.Duration) {
+	defer d.cancelWork()
+
+	for partition, queue := range d.workers {
+		close(queue)
+		delete(d.workers, partition)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+
+	if drainTimeout <= 0 {
+		<-drained
+		return
+	}
+
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+		d.cancelWork()
+		<-drained
+	}
+}
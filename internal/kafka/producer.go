@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrEmptyKey ключ сообщения не задан
+// Ключ определяет партицию, без него события одного заказа
+// могут попасть в разные партиции и обработаться не по порядку
+var ErrEmptyKey = errors.New("message key is empty")
+
+// ParseCompression возвращает кодек сжатия по имени
+// Пустое имя и none означают запись без сжатия
+func ParseCompression(name string) (kafka.Compression, error) {
+	switch name {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unsupported compression %q, expected none, gzip, snappy, lz4 or zstd", name)
+	}
+}
+
+// Producer отправляет сообщения в Kafka
+// Партиция выбирается по хешу ключа, поэтому сообщения с одним ключом
+// всегда попадают в одну партицию
+type Producer struct {
+	Writer *kafka.Writer
+}
+
+// NewProducer создаёт новый producer
+// brokers адреса брокеров topic топик для записи
+func NewProducer(brokers []string, topic string) *Producer {
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+	}
+	return &Producer{Writer: writer}
+}
+
+// WithCompression включает сжатие сообщений, 0 - без сжатия
+func (p *Producer) WithCompression(codec kafka.Compression) *Producer {
+	p.Writer.Compression = codec
+	return p
+}
+
+// WithAsync включает асинхронную запись: Produce не ждет подтверждения брокера
+// и не держит обработчик на время накопления пачки. Ошибка доставки передается
+// onError для каждого сообщения пачки, Close дожидается отправки накопленных сообщений
+func (p *Producer) WithAsync(onError func(key []byte, err error)) *Producer {
+	p.Writer.Async = true
+	p.Writer.Completion = func(messages []kafka.Message, err error) {
+		if err == nil || onError == nil {
+			return
+		}
+		for _, m := range messages {
+			onError(m.Key, err)
+		}
+	}
+	return p
+}
+
+// Produce отправляет одно сообщение
+// key обязателен, для заказов это order_uid
+// Заголовки берутся из контекста, см. ContextWithHeaders
+func (p *Producer) Produce(ctx context.Context, key, value []byte) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	return p.Writer.WriteMessages(ctx, kafka.Message{
+		Key:     key,
+		Value:   value,
+		Headers: HeadersFromContext(ctx).kafkaHeaders(),
+	})
+}
+
+// Close закрывает writer
+func (p *Producer) Close() error {
+	return p.Writer.Close()
+}
+
+// OrderKey возвращает ключ сообщения для заказа - его order_uid
+func OrderKey(value []byte) ([]byte, error) {
+	var order struct {
+		OrderUID string `json:"order_uid"`
+	}
+	if err := json.Unmarshal(value, &order); err != nil {
+		return nil, err
+	}
+	if order.OrderUID == "" {
+		return nil, ErrEmptyKey
+	}
+	return []byte(order.OrderUID), nil
+}
+
+// ProducedMessage сообщение, сохраненное MemoryProducer
+type ProducedMessage struct {
+	Key     []byte
+	Value   []byte
+	Headers Headers
+}
+
+// MemoryProducer хранит сообщения в памяти вместо отправки в Kafka
+// Используется в тестах и при локальной отладке
+type MemoryProducer struct {
+	mu       sync.Mutex
+	messages []ProducedMessage
+	closed   bool
+}
+
+// NewMemoryProducer создаёт producer в памяти
+func NewMemoryProducer() *MemoryProducer {
+	return &MemoryProducer{}
+}
+
+// Produce сохраняет сообщение
+func (p *MemoryProducer) Produce(ctx context.Context, key, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return errors.New("producer is closed")
+	}
+	p.messages = append(p.messages, ProducedMessage{Key: key, Value: value, Headers: HeadersFromContext(ctx)})
+	return nil
+}
+
+// Messages возвращает копию сохраненных сообщений
+func (p *MemoryProducer) Messages() []ProducedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	messages := make([]ProducedMessage, len(p.messages))
+	copy(messages, p.messages)
+	return messages
+}
+
+// Close помечает producer закрытым
+func (p *MemoryProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
@@ -0,0 +1,195 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"wbtest/internal/interfaces"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestNewProducer(t *testing.T) {
+	producer := NewProducer([]string{"localhost:9092"}, "test-topic")
+	defer producer.Close()
+
+	if producer.Writer == nil {
+		t.Fatal("Writer is nil")
+	}
+	if producer.Writer.Topic != "test-topic" {
+		t.Errorf("Expected topic 'test-topic', got '%s'", producer.Writer.Topic)
+	}
+}
+
+func TestNewProducer_Compression(t *testing.T) {
+	codec, err := ParseCompression("zstd")
+	if err != nil {
+		t.Fatalf("ParseCompression() error = %v", err)
+	}
+
+	producer := NewProducer([]string{"localhost:9092"}, "test-topic").WithCompression(codec)
+	defer producer.Close()
+
+	if producer.Writer.Compression != kafka.Zstd {
+		t.Errorf("Expected zstd compression, got %v", producer.Writer.Compression)
+	}
+}
+
+func TestNewProducer_Async(t *testing.T) {
+	var failed []string
+	producer := NewProducer([]string{"localhost:9092"}, "test-topic").WithAsync(func(key []byte, err error) {
+		failed = append(failed, string(key))
+	})
+	defer producer.Close()
+
+	if !producer.Writer.Async {
+		t.Fatal("Expected async writer")
+	}
+
+	// Успешная пачка ошибок не дает, ошибка доставки передается по каждому сообщению
+	messages := []kafka.Message{{Key: []byte("order-1")}, {Key: []byte("order-2")}}
+	producer.Writer.Completion(messages, nil)
+	producer.Writer.Completion(messages, errors.New("broker unavailable"))
+
+	if len(failed) != 2 || failed[0] != "order-1" || failed[1] != "order-2" {
+		t.Errorf("Expected both messages reported as failed, got %v", failed)
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected kafka.Compression
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"none", 0, false},
+		{"gzip", kafka.Gzip, false},
+		{"snappy", kafka.Snappy, false},
+		{"lz4", kafka.Lz4, false},
+		{"zstd", kafka.Zstd, false},
+		{"brotli", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := ParseCompression(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCompression(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if codec != tt.expected {
+				t.Errorf("ParseCompression(%q) = %v, want %v", tt.name, codec, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMemoryProducer_Produce(t *testing.T) {
+	var producer interfaces.MessageProducer = NewMemoryProducer()
+
+	if err := producer.Produce(context.Background(), []byte("key-1"), []byte("value-1")); err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+	if err := producer.Produce(context.Background(), []byte("key-2"), []byte("value-2")); err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+
+	messages := producer.(*MemoryProducer).Messages()
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if string(messages[0].Key) != "key-1" || string(messages[0].Value) != "value-1" {
+		t.Errorf("Unexpected first message: %s=%s", messages[0].Key, messages[0].Value)
+	}
+}
+
+func TestProducers_RequireKey(t *testing.T) {
+	producers := map[string]interfaces.MessageProducer{
+		"kafka":  NewProducer([]string{"localhost:9092"}, "test-topic"),
+		"memory": NewMemoryProducer(),
+	}
+
+	for name, producer := range producers {
+		t.Run(name, func(t *testing.T) {
+			defer producer.Close()
+
+			err := producer.Produce(context.Background(), nil, []byte("value"))
+			if !errors.Is(err, ErrEmptyKey) {
+				t.Errorf("Expected ErrEmptyKey, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOrderKey(t *testing.T) {
+	key, err := OrderKey([]byte(`{"order_uid":"b563feb7b2b84b6test","track_number":"WBILMTESTTRACK"}`))
+	if err != nil {
+		t.Fatalf("OrderKey() error = %v", err)
+	}
+	if string(key) != "b563feb7b2b84b6test" {
+		t.Errorf("Expected key 'b563feb7b2b84b6test', got '%s'", key)
+	}
+
+	if _, err := OrderKey([]byte(`{"track_number":"WBILMTESTTRACK"}`)); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey for missing order_uid, got %v", err)
+	}
+	if _, err := OrderKey([]byte(`not json`)); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+func TestMemoryProducer_Closed(t *testing.T) {
+	producer := NewMemoryProducer()
+	producer.Close()
+
+	if err := producer.Produce(context.Background(), []byte("key"), []byte("value")); err == nil {
+		t.Error("Expected error when producing to closed producer")
+	}
+}
+
+func TestMemoryProducer_CancelledContext(t *testing.T) {
+	producer := NewMemoryProducer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := producer.Produce(ctx, []byte("key"), []byte("value")); err == nil {
+		t.Error("Expected error for cancelled context")
+	}
+	if len(producer.Messages()) != 0 {
+		t.Error("Expected no messages after cancelled produce")
+	}
+}
+
+func TestMemoryProducer_Headers(t *testing.T) {
+	producer := NewMemoryProducer()
+	ctx := ContextWithHeaders(context.Background(), Headers{HeaderTraceID: "trace-123"})
+
+	if err := producer.Produce(ctx, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+
+	messages := producer.Messages()
+	if len(messages) != 1 || messages[0].Headers[HeaderTraceID] != "trace-123" {
+		t.Errorf("Expected message with trace-id header, got %+v", messages)
+	}
+}
+
+func TestHeaders_kafkaHeaders(t *testing.T) {
+	headers := Headers{HeaderTraceID: "trace-123", HeaderSchemaVersion: "1"}.kafkaHeaders()
+
+	if len(headers) != 2 {
+		t.Fatalf("Expected 2 headers, got %d", len(headers))
+	}
+	// Заголовки упорядочены по ключу
+	if headers[0].Key != HeaderSchemaVersion || string(headers[0].Value) != "1" {
+		t.Errorf("Unexpected first header %s=%s", headers[0].Key, headers[0].Value)
+	}
+	if headers[1].Key != HeaderTraceID || string(headers[1].Value) != "trace-123" {
+		t.Errorf("Unexpected second header %s=%s", headers[1].Key, headers[1].Value)
+	}
+	if Headers(nil).kafkaHeaders() != nil {
+		t.Error("Expected nil headers for empty map")
+	}
+}
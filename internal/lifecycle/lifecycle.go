@@ -2,6 +2,8 @@ package lifecycle
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -16,6 +18,8 @@ type Service interface {
 // Manager управляет жизненным циклом сервисов
 type Manager struct {
 	services []Service
+	// timeouts собственные таймауты остановки по индексу сервиса, 0 - общий таймаут
+	timeouts []time.Duration
 	mu       sync.RWMutex
 }
 
@@ -31,6 +35,15 @@ func (m *Manager) Register(service Service) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.services = append(m.services, service)
+	m.timeouts = append(m.timeouts, 0)
+}
+
+// RegisterWithTimeout регистрирует сервис с собственным таймаутом остановки для Shutdown
+func (m *Manager) RegisterWithTimeout(service Service, timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.services = append(m.services, service)
+	m.timeouts = append(m.timeouts, timeout)
 }
 
 // Start запускает все зарегистрированные сервисы
@@ -117,6 +130,51 @@ func (m *Manager) Stop(ctx context.Context, timeout time.Duration) error {
 	return nil
 }
 
+// Shutdown останавливает сервисы по очереди в порядке регистрации
+// Каждый сервис получает свой дедлайн: зависший сервис не съедает время следующих
+// Сервис без собственного таймаута получает defaultTimeout
+// Возвращает ошибки всех сервисов, не остановившихся вовремя или с ошибкой
+func (m *Manager) Shutdown(ctx context.Context, defaultTimeout time.Duration) error {
+	m.mu.RLock()
+	services := make([]Service, len(m.services))
+	copy(services, m.services)
+	timeouts := make([]time.Duration, len(m.timeouts))
+	copy(timeouts, m.timeouts)
+	m.mu.RUnlock()
+
+	var errs []error
+	for i, service := range services {
+		timeout := timeouts[i]
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		if err := stopWithTimeout(ctx, service, timeout); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", service.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopWithTimeout останавливает сервис и не ждет его дольше timeout,
+// даже если Stop не учитывает контекст
+func stopWithTimeout(ctx context.Context, service Service, timeout time.Duration) error {
+	stopCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- service.Stop(stopCtx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-stopCtx.Done():
+		return stopCtx.Err()
+	}
+}
+
 // ServiceWrapper обертка для сервисов без интерфейса Service
 type ServiceWrapper struct {
 	name    string
@@ -3,6 +3,8 @@ package lifecycle
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -223,3 +225,65 @@ func TestServiceWrapperWithNilFunctions(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 }
+
+func TestShutdownPerServiceDeadline(t *testing.T) {
+	m := New()
+
+	var mu sync.Mutex
+	var order []string
+	budgets := make(map[string]time.Duration)
+	record := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			if deadline, ok := ctx.Deadline(); ok {
+				budgets[name] = time.Until(deadline)
+			}
+			return nil
+		}
+	}
+
+	m.RegisterWithTimeout(NewServiceWrapper("http", nil, record("http")), 200*time.Millisecond)
+	// Зависший сервис не должен уменьшить время следующих
+	m.RegisterWithTimeout(NewServiceWrapper("db", nil, func(ctx context.Context) error {
+		mu.Lock()
+		order = append(order, "db")
+		mu.Unlock()
+		time.Sleep(time.Second)
+		return nil
+	}), 20*time.Millisecond)
+	m.RegisterWithTimeout(NewServiceWrapper("dlq", nil, record("dlq")), 300*time.Millisecond)
+	m.Register(NewServiceWrapper("cache", nil, record("cache")))
+
+	start := time.Now()
+	err := m.Shutdown(context.Background(), 400*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Shutdown waited for hung service: %v", elapsed)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded for db, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "db:") {
+		t.Errorf("Expected error to name db service, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(order, ",") != "http,db,dlq,cache" {
+		t.Errorf("Expected services stopped in registration order, got %v", order)
+	}
+
+	expected := map[string]time.Duration{
+		"http":  200 * time.Millisecond,
+		"dlq":   300 * time.Millisecond,
+		"cache": 400 * time.Millisecond,
+	}
+	for name, want := range expected {
+		got := budgets[name]
+		if got > want || got < want-50*time.Millisecond {
+			t.Errorf("Expected %s deadline about %v, got %v", name, want, got)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
@@ -10,15 +13,25 @@ import (
 // Logger структура для логирования
 type Logger struct {
 	*logrus.Logger
+
+	// components логгеры компонентов со своим уровнем, ключ - имя компонента в нижнем регистре
+	components map[string]*logrus.Logger
 }
 
 // Config конфигурация логгера
 type Config struct {
 	Level  string `env:"LOG_LEVEL" envDefault:"info"`
 	Format string `env:"LOG_FORMAT" envDefault:"json"`
+	// ComponentLevels уровни отдельных компонентов из LOG_LEVEL_<COMPONENT>, например kafka: debug
+	ComponentLevels map[string]string
 }
 
+// current последний созданный логгер, из него берет записи пакетная ForComponent
+var current atomic.Pointer[Logger]
+
 // New создает новый логгер
+// Компоненты из ComponentLevels получают отдельный логгер с тем же форматом и выводом,
+// но своим уровнем
 func New(config Config) *Logger {
 	logger := logrus.New()
 
@@ -49,7 +62,51 @@ func New(config Config) *Logger {
 	// Устанавливаем вывод в stdout
 	logger.SetOutput(os.Stdout)
 
-	return &Logger{Logger: logger}
+	l := &Logger{Logger: logger, components: make(map[string]*logrus.Logger, len(config.ComponentLevels))}
+	for name, value := range config.ComponentLevels {
+		componentLevel, err := logrus.ParseLevel(strings.ToLower(value))
+		if err != nil {
+			logger.Warnf("Invalid log level %s for component %s, using %s", value, name, level)
+			continue
+		}
+		l.components[strings.ToLower(name)] = &logrus.Logger{
+			Out:       logger.Out,
+			Formatter: logger.Formatter,
+			Hooks:     logger.Hooks,
+			Level:     componentLevel,
+			ExitFunc:  logger.ExitFunc,
+		}
+	}
+
+	current.Store(l)
+	return l
+}
+
+// SetOutput задает вывод основного логгера и логгеров компонентов
+func (l *Logger) SetOutput(output io.Writer) {
+	l.Logger.SetOutput(output)
+	for _, component := range l.components {
+		component.SetOutput(output)
+	}
+}
+
+// ForComponent возвращает запись лога с полем component
+// Уровень задается LOG_LEVEL_<COMPONENT>, без него действует общий LOG_LEVEL
+func (l *Logger) ForComponent(name string) *logrus.Entry {
+	base := l.Logger
+	if component, ok := l.components[strings.ToLower(name)]; ok {
+		base = component
+	}
+	return base.WithField("component", name)
+}
+
+// ForComponent возвращает запись лога компонента из последнего созданного логгера
+// Если логгер еще не создан, запись пишется стандартным логгером logrus
+func ForComponent(name string) *logrus.Entry {
+	if l := current.Load(); l != nil {
+		return l.ForComponent(name)
+	}
+	return logrus.WithField("component", name)
 }
 
 // WithField создает новую запись с полем
@@ -74,3 +131,19 @@ func Default() *Logger {
 		Format: "json",
 	})
 }
+
+type entryKey struct{}
+
+// WithContext сохраняет запись лога с полями запроса в контексте
+func WithContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// FromContext возвращает запись лога из контекста
+// Если ее нет, возвращает запись стандартного логгера logrus
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
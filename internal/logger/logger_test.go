@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -91,6 +94,38 @@ func TestLogger_WithError(t *testing.T) {
 	}
 }
 
+func TestLogger_ForComponent(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{
+		Level:           "info",
+		Format:          "json",
+		ComponentLevels: map[string]string{"kafka": "debug"},
+	})
+	logger.SetOutput(&buf)
+
+	logger.ForComponent("kafka").Debug("kafka debug")
+	logger.ForComponent("cache").Debug("cache debug")
+	logger.ForComponent("cache").Info("cache info")
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		messages = append(messages, record["component"].(string)+": "+record["msg"].(string))
+	}
+
+	want := []string{"kafka: kafka debug", "cache: cache info"}
+	if strings.Join(messages, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, messages)
+	}
+
+	if entry := ForComponent("KAFKA"); entry.Logger.GetLevel().String() != "debug" {
+		t.Errorf("Expected package ForComponent to use the last logger, got level %s", entry.Logger.GetLevel())
+	}
+}
+
 // testError для тестирования
 type testError struct {
 	message string
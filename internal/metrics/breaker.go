@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"strings"
+
+	"wbtest/internal/circuitbreaker"
+)
+
+// SetBreakerState публикует текущее состояние breaker name
+// Значение метрики совпадает с circuitbreaker.State: 0 - closed, 1 - open, 2 - half-open
+func (m *Metrics) SetBreakerState(name string, state circuitbreaker.State) {
+	m.CircuitBreakerState.WithLabelValues(name).Set(float64(state))
+}
+
+// BreakerStateChanged обновляет метрики при смене состояния breaker name
+// Предназначен для CircuitBreaker.WithStateChangeCallback. Reset закрытого breaker
+// тоже вызывает callback, такой вызов не считается переходом
+func (m *Metrics) BreakerStateChanged(name string, from, to circuitbreaker.State) {
+	m.SetBreakerState(name, to)
+	if from == to {
+		return
+	}
+	m.CircuitBreakerTransitions.WithLabelValues(name, stateLabel(from), stateLabel(to)).Inc()
+}
+
+// stateLabel имя состояния для меток: closed, open, half_open
+func stateLabel(state circuitbreaker.State) string {
+	return strings.ToLower(state.String())
+}
@@ -1,11 +1,11 @@
 package metrics
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -16,11 +16,15 @@ type Metrics struct {
 	HTTPRequestDuration *prometheus.HistogramVec
 	HTTPRequestSize     *prometheus.HistogramVec
 	HTTPResponseSize    *prometheus.HistogramVec
+	// InFlightRequests число запросов, обрабатываемых в данный момент
+	InFlightRequests prometheus.Gauge
 
 	// Kafka метрики
 	KafkaMessagesConsumed *prometheus.CounterVec
 	KafkaMessagesFailed   *prometheus.CounterVec
 	KafkaConsumerLag      *prometheus.GaugeVec
+	// KafkaMessagesDeduplicated повторно доставленные сообщения, пропущенные без обработки
+	KafkaMessagesDeduplicated *prometheus.CounterVec
 
 	// Order метрики
 	OrdersProcessed *prometheus.CounterVec
@@ -28,10 +32,26 @@ type Metrics struct {
 	OrdersInCache   *prometheus.GaugeVec
 	OrdersInDB      *prometheus.GaugeVec
 
+	// Cache метрики фоновой очистки просроченных записей
+	CacheCleanupSweeps    prometheus.Counter
+	CacheLastSweepExpired prometheus.Gauge
+	// Сверка кеша с БД: проверенные заказы, расхождения по видам и исправленные записи
+	CacheReconcileChecked    prometheus.Counter
+	CacheReconcileMismatches *prometheus.CounterVec
+	CacheReconcileHealed     prometheus.Counter
+
+	// ValidationFailures ошибки валидации по правилам
+	ValidationFailures *prometheus.CounterVec
+
 	// Retry метрики
 	RetryAttempts *prometheus.CounterVec
 	RetryFailures *prometheus.CounterVec
 
+	// Circuit breaker метрики: состояние по имени breaker (0 - closed, 1 - open, 2 - half-open)
+	// и число переходов между состояниями
+	CircuitBreakerState       *prometheus.GaugeVec
+	CircuitBreakerTransitions *prometheus.CounterVec
+
 	// DLQ метрики
 	DLQMessagesSent      *prometheus.CounterVec
 	DLQMessagesProcessed *prometheus.CounterVec
@@ -39,20 +59,39 @@ type Metrics struct {
 	// Database метрики
 	DatabaseConnections   *prometheus.GaugeVec
 	DatabaseQueryDuration *prometheus.HistogramVec
+	DatabaseSlowQueries   *prometheus.CounterVec
+
+	// registry реестр метрик, nil - глобальный реестр prometheus
+	registry *prometheus.Registry
+	// routeLabel метка path HTTP метрик, nil - r.URL.Path
+	routeLabel func(r *http.Request) string
 }
 
-// New создает новые метрики
+// New создает метрики в глобальном реестре prometheus
+// Повторный вызов не паникует, а возвращает уже зарегистрированные метрики
 func New() *Metrics {
+	return newMetrics(factory{prometheus.DefaultRegisterer})
+}
+
+// NewWithRegistry создает метрики в отдельном реестре
+// Handler отдает метрики только из этого реестра
+func NewWithRegistry(reg *prometheus.Registry) *Metrics {
+	m := newMetrics(factory{reg})
+	m.registry = reg
+	return m
+}
+
+func newMetrics(f factory) *Metrics {
 	return &Metrics{
 		// HTTP метрики
-		HTTPRequestsTotal: promauto.NewCounterVec(
+		HTTPRequestsTotal: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "http_requests_total",
 				Help: "Total number of HTTP requests",
 			},
 			[]string{"method", "endpoint", "status"},
 		),
-		HTTPRequestDuration: promauto.NewHistogramVec(
+		HTTPRequestDuration: f.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "http_request_duration_seconds",
 				Help:    "HTTP request duration in seconds",
@@ -60,7 +99,7 @@ func New() *Metrics {
 			},
 			[]string{"method", "endpoint"},
 		),
-		HTTPRequestSize: promauto.NewHistogramVec(
+		HTTPRequestSize: f.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "http_request_size_bytes",
 				Help:    "HTTP request size in bytes",
@@ -68,7 +107,7 @@ func New() *Metrics {
 			},
 			[]string{"method", "endpoint"},
 		),
-		HTTPResponseSize: promauto.NewHistogramVec(
+		HTTPResponseSize: f.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "http_response_size_bytes",
 				Help:    "HTTP response size in bytes",
@@ -76,53 +115,66 @@ func New() *Metrics {
 			},
 			[]string{"method", "endpoint"},
 		),
+		InFlightRequests: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_requests_in_flight",
+				Help: "Number of HTTP requests currently being served",
+			},
+		),
 
 		// Kafka метрики
-		KafkaMessagesConsumed: promauto.NewCounterVec(
+		KafkaMessagesConsumed: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "kafka_messages_consumed_total",
 				Help: "Total number of Kafka messages consumed",
 			},
 			[]string{"topic", "group_id"},
 		),
-		KafkaMessagesFailed: promauto.NewCounterVec(
+		KafkaMessagesFailed: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "kafka_messages_failed_total",
 				Help: "Total number of Kafka messages failed",
 			},
 			[]string{"topic", "group_id", "error_type"},
 		),
-		KafkaConsumerLag: promauto.NewGaugeVec(
+		KafkaConsumerLag: f.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "kafka_consumer_lag",
 				Help: "Kafka consumer lag",
 			},
 			[]string{"topic", "group_id"},
 		),
+		KafkaMessagesDeduplicated: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_messages_deduplicated_total",
+				Help: "Total number of redelivered Kafka messages skipped as duplicates",
+			},
+			[]string{"topic"},
+		),
 
 		// Order метрики
-		OrdersProcessed: promauto.NewCounterVec(
+		OrdersProcessed: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "orders_processed_total",
 				Help: "Total number of orders processed",
 			},
 			[]string{"status"},
 		),
-		OrdersFailed: promauto.NewCounterVec(
+		OrdersFailed: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "orders_failed_total",
 				Help: "Total number of orders failed",
 			},
 			[]string{"error_type"},
 		),
-		OrdersInCache: promauto.NewGaugeVec(
+		OrdersInCache: f.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "orders_in_cache",
 				Help: "Number of orders in cache",
 			},
 			[]string{},
 		),
-		OrdersInDB: promauto.NewGaugeVec(
+		OrdersInDB: f.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "orders_in_database",
 				Help: "Number of orders in database",
@@ -130,15 +182,23 @@ func New() *Metrics {
 			[]string{},
 		),
 
+		ValidationFailures: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "validation_failures_total",
+				Help: "Total number of order validation failures by rule",
+			},
+			[]string{"rule"},
+		),
+
 		// Retry метрики
-		RetryAttempts: promauto.NewCounterVec(
+		RetryAttempts: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "retry_attempts_total",
 				Help: "Total number of retry attempts",
 			},
 			[]string{"operation", "attempt"},
 		),
-		RetryFailures: promauto.NewCounterVec(
+		RetryFailures: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "retry_failures_total",
 				Help: "Total number of retry failures",
@@ -147,14 +207,14 @@ func New() *Metrics {
 		),
 
 		// DLQ метрики
-		DLQMessagesSent: promauto.NewCounterVec(
+		DLQMessagesSent: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "dlq_messages_sent_total",
 				Help: "Total number of messages sent to DLQ",
 			},
 			[]string{"topic", "reason"},
 		),
-		DLQMessagesProcessed: promauto.NewCounterVec(
+		DLQMessagesProcessed: f.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "dlq_messages_processed_total",
 				Help: "Total number of DLQ messages processed",
@@ -163,14 +223,14 @@ func New() *Metrics {
 		),
 
 		// Database метрики
-		DatabaseConnections: promauto.NewGaugeVec(
+		DatabaseConnections: f.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "database_connections",
 				Help: "Number of database connections",
 			},
 			[]string{"state"},
 		),
-		DatabaseQueryDuration: promauto.NewHistogramVec(
+		DatabaseQueryDuration: f.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "database_query_duration_seconds",
 				Help:    "Database query duration in seconds",
@@ -178,49 +238,174 @@ func New() *Metrics {
 			},
 			[]string{"operation"},
 		),
+		DatabaseSlowQueries: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "database_slow_queries_total",
+				Help: "Total number of database queries slower than DB_SLOW_QUERY_THRESHOLD",
+			},
+			[]string{"operation"},
+		),
+
+		// Cache метрики
+		CacheCleanupSweeps: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_cleanup_sweeps_total",
+				Help: "Total number of background cache cleanup sweeps",
+			},
+		),
+		CacheLastSweepExpired: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cache_cleanup_last_sweep_expired",
+				Help: "Number of expired entries removed by the last cache cleanup sweep",
+			},
+		),
+		CircuitBreakerState: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "circuit_breaker_state",
+				Help: "Current circuit breaker state: 0 - closed, 1 - open, 2 - half-open",
+			},
+			[]string{"name"},
+		),
+		CircuitBreakerTransitions: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "circuit_breaker_transitions_total",
+				Help: "Total number of circuit breaker state transitions",
+			},
+			[]string{"name", "from", "to"},
+		),
+		CacheReconcileChecked: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_reconcile_checked_total",
+				Help: "Total number of cached orders compared with the database",
+			},
+		),
+		CacheReconcileMismatches: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_reconcile_mismatches_total",
+				Help: "Total number of cached orders that differ from the database",
+			},
+			[]string{"kind"},
+		),
+		CacheReconcileHealed: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_reconcile_healed_total",
+				Help: "Total number of cache entries refreshed or removed by reconciliation",
+			},
+		),
 	}
 }
 
+// WithRouteLabel задает метку path HTTP метрик по запросу, например httpapi.RouteLabel
+// По умолчанию используется r.URL.Path
+func (m *Metrics) WithRouteLabel(route func(r *http.Request) string) *Metrics {
+	m.routeLabel = route
+	return m
+}
+
 // HTTPMiddleware создает middleware для HTTP метрик
 func (m *Metrics) HTTPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		// Уменьшаем через defer, чтобы счетчик вернулся и при панике в обработчике
+		m.InFlightRequests.Inc()
+		defer m.InFlightRequests.Dec()
+
 		// Создаем ResponseWriter для отслеживания статуса и размера
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+		wrapped := NewStatusRecorder(w)
 
 		next.ServeHTTP(wrapped, r)
 
 		duration := time.Since(start).Seconds()
-		status := http.StatusText(wrapped.statusCode)
+		status := http.StatusText(wrapped.StatusCode)
+		path := r.URL.Path
+		if m.routeLabel != nil {
+			path = m.routeLabel(r)
+		}
 
 		// Обновляем метрики
-		m.HTTPRequestsTotal.WithLabelValues(r.Method, r.URL.Path, status).Inc()
-		m.HTTPRequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
-		m.HTTPRequestSize.WithLabelValues(r.Method, r.URL.Path).Observe(float64(r.ContentLength))
-		m.HTTPResponseSize.WithLabelValues(r.Method, r.URL.Path).Observe(float64(wrapped.size))
+		m.HTTPRequestsTotal.WithLabelValues(r.Method, path, status).Inc()
+		m.HTTPRequestDuration.WithLabelValues(r.Method, path).Observe(duration)
+		m.HTTPRequestSize.WithLabelValues(r.Method, path).Observe(float64(r.ContentLength))
+		m.HTTPResponseSize.WithLabelValues(r.Method, path).Observe(float64(wrapped.Size))
 	})
 }
 
 // Handler возвращает HTTP handler для Prometheus метрик
 func (m *Metrics) Handler() http.Handler {
+	if m.registry != nil {
+		return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	}
 	return promhttp.Handler()
 }
 
-// responseWriter обертка для http.ResponseWriter
-type responseWriter struct {
+// factory регистрирует метрики как promauto, но при повторной регистрации
+// той же метрики возвращает уже зарегистрированную вместо паники
+type factory struct {
+	reg prometheus.Registerer
+}
+
+func (f factory) NewCounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	return register(f.reg, prometheus.NewCounterVec(opts, labels))
+}
+
+func (f factory) NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	return register(f.reg, prometheus.NewCounter(opts))
+}
+
+func (f factory) NewGaugeVec(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	return register(f.reg, prometheus.NewGaugeVec(opts, labels))
+}
+
+func (f factory) NewGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	return register(f.reg, prometheus.NewGauge(opts))
+}
+
+func (f factory) NewHistogramVec(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	return register(f.reg, prometheus.NewHistogramVec(opts, labels))
+}
+
+// register регистрирует коллектор, уже зарегистрированный коллектор того же типа
+// переиспользуется, остальные ошибки регистрации - ошибка программы
+func register[C prometheus.Collector](reg prometheus.Registerer, collector C) C {
+	if err := reg.Register(collector); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// StatusRecorder обертка http.ResponseWriter, запоминающая статус и размер ответа
+// Используется middleware метрик и access log
+type StatusRecorder struct {
 	http.ResponseWriter
-	statusCode int
-	size       int
+	StatusCode int
+	Size       int
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+// NewStatusRecorder оборачивает w, статус по умолчанию 200
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, StatusCode: http.StatusOK}
+}
+
+func (rw *StatusRecorder) WriteHeader(code int) {
+	rw.StatusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-func (rw *responseWriter) Write(b []byte) (int, error) {
+func (rw *StatusRecorder) Write(b []byte) (int, error) {
 	size, err := rw.ResponseWriter.Write(b)
-	rw.size += size
+	rw.Size += size
 	return size, err
 }
+
+// Unwrap открывает исходный writer для http.ResponseController,
+// через него потоковые ответы отправляются клиенту по частям
+func (rw *StatusRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -6,10 +6,11 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNew(t *testing.T) {
-	m := New()
+	m := NewWithRegistry(prometheus.NewRegistry())
 	if m == nil {
 		t.Error("Metrics is nil")
 	}
@@ -39,12 +40,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestHTTPMiddleware(t *testing.T) {
-	// Создаем новый registry для теста чтобы избежать конфликтов
-	reg := prometheus.NewRegistry()
-	prometheus.DefaultRegisterer = reg
-	prometheus.DefaultGatherer = reg
-
-	m := New()
+	m := NewWithRegistry(prometheus.NewRegistry())
 
 	// Создаем тестовый handler
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -75,13 +71,42 @@ func TestHTTPMiddleware(t *testing.T) {
 	}
 }
 
-func TestHandler(t *testing.T) {
-	// Создаем новый registry для теста чтобы избежать конфликтов
-	reg := prometheus.NewRegistry()
-	prometheus.DefaultRegisterer = reg
-	prometheus.DefaultGatherer = reg
+func TestHTTPMiddleware_InFlightRequests(t *testing.T) {
+	m := NewWithRegistry(prometheus.NewRegistry())
+
+	var during float64
+	handler := m.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = testutil.ToFloat64(m.InFlightRequests)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	if during != 1 {
+		t.Errorf("Expected 1 in-flight request during handling, got %v", during)
+	}
+	if got := testutil.ToFloat64(m.InFlightRequests); got != 0 {
+		t.Errorf("Expected 0 in-flight requests after completion, got %v", got)
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		panicking := m.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+
+		func() {
+			defer func() { _ = recover() }()
+			panicking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+		}()
+
+		if got := testutil.ToFloat64(m.InFlightRequests); got != 0 {
+			t.Errorf("Expected 0 in-flight requests after panic, got %v", got)
+		}
+	})
+}
 
-	m := New()
+func TestHandler(t *testing.T) {
+	m := NewWithRegistry(prometheus.NewRegistry())
 	handler := m.Handler()
 
 	if handler == nil {
@@ -111,14 +136,53 @@ func TestHandler(t *testing.T) {
 	}
 }
 
-func TestResponseWriter(t *testing.T) {
+func TestNew_RepeatedRegistration(t *testing.T) {
+	// Глобальный реестр подменяется на время теста и восстанавливается после него
+	registerer, gatherer := prometheus.DefaultRegisterer, prometheus.DefaultGatherer
+	t.Cleanup(func() {
+		prometheus.DefaultRegisterer, prometheus.DefaultGatherer = registerer, gatherer
+	})
+	reg := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = reg
+	prometheus.DefaultGatherer = reg
+
+	// Повторный вызов не паникует и переиспользует зарегистрированные метрики
+	first := New()
+	second := New()
+
+	first.OrdersProcessed.WithLabelValues("success").Inc()
+	if got := testutil.ToFloat64(second.OrdersProcessed.WithLabelValues("success")); got != 1 {
+		t.Errorf("Expected shared counter value 1, got %v", got)
+	}
+}
+
+func TestNewWithRegistry_Isolated(t *testing.T) {
+	regA := prometheus.NewRegistry()
+	regB := prometheus.NewRegistry()
+	a := NewWithRegistry(regA)
+	b := NewWithRegistry(regB)
+
+	a.OrdersProcessed.WithLabelValues("success").Inc()
+	if got := testutil.ToFloat64(b.OrdersProcessed.WithLabelValues("success")); got != 0 {
+		t.Errorf("Expected isolated counter value 0, got %v", got)
+	}
+
+	// Handler отдает метрики своего реестра
+	rr := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !contains(rr.Body.String(), `orders_processed_total{status="success"} 1`) {
+		t.Errorf("Expected registry metrics in response, got %s", rr.Body.String())
+	}
+}
+
+func TestStatusRecorder(t *testing.T) {
 	rr := httptest.NewRecorder()
-	rw := &responseWriter{ResponseWriter: rr, statusCode: 200}
+	rw := NewStatusRecorder(rr)
 
 	// Тестируем WriteHeader
 	rw.WriteHeader(http.StatusNotFound)
-	if rw.statusCode != http.StatusNotFound {
-		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rw.statusCode)
+	if rw.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rw.StatusCode)
 	}
 
 	// Тестируем Write
@@ -132,8 +196,16 @@ func TestResponseWriter(t *testing.T) {
 		t.Errorf("Expected written %d bytes, got %d", len(data), n)
 	}
 
-	if rw.size != len(data) {
-		t.Errorf("Expected size %d, got %d", len(data), rw.size)
+	if rw.Size != len(data) {
+		t.Errorf("Expected size %d, got %d", len(data), rw.Size)
+	}
+
+	// Потоковые ответы доходят до исходного writer через http.ResponseController
+	if err := http.NewResponseController(rw).Flush(); err != nil {
+		t.Errorf("Flush through recorder error: %v", err)
+	}
+	if !rr.Flushed {
+		t.Error("Expected underlying writer to be flushed")
 	}
 }
 
@@ -5,6 +5,7 @@ package mocks
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"wbtest/internal/interfaces"
 	"wbtest/internal/model"
@@ -36,18 +37,23 @@ func (m *MockOrderRepository) EXPECT() *MockOrderRepositoryMockRecorder {
 }
 
 // LoadAllOrders mocks base method
-func (m *MockOrderRepository) LoadAllOrders(ctx context.Context) ([]*model.Order, error) {
+func (m *MockOrderRepository) LoadAllOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoadAllOrders", ctx)
+	varargs := []interface{}{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "LoadAllOrders", varargs...)
 	ret0, _ := ret[0].([]*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // LoadAllOrders indicates an expected call of LoadAllOrders
-func (mr *MockOrderRepositoryMockRecorder) LoadAllOrders(ctx interface{}) *gomock.Call {
+func (mr *MockOrderRepositoryMockRecorder) LoadAllOrders(ctx interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAllOrders", reflect.TypeOf((*MockOrderRepository)(nil).LoadAllOrders), ctx)
+	varargs := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadAllOrders", reflect.TypeOf((*MockOrderRepository)(nil).LoadAllOrders), varargs...)
 }
 
 // SaveOrder mocks base method
@@ -64,19 +70,152 @@ func (mr *MockOrderRepositoryMockRecorder) SaveOrder(ctx, order interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockOrderRepository)(nil).SaveOrder), ctx, order)
 }
 
+// UpsertOrder mocks base method
+func (m *MockOrderRepository) UpsertOrder(ctx context.Context, order *model.Order, version int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertOrder", ctx, order, version)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertOrder indicates an expected call of UpsertOrder
+func (mr *MockOrderRepositoryMockRecorder) UpsertOrder(ctx, order, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertOrder", reflect.TypeOf((*MockOrderRepository)(nil).UpsertOrder), ctx, order, version)
+}
+
 // GetOrderByUID mocks base method
-func (m *MockOrderRepository) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+func (m *MockOrderRepository) GetOrderByUID(ctx context.Context, orderUID string, opts ...interfaces.QueryOption) (*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrderByUID", ctx, orderUID)
+	varargs := []interface{}{ctx, orderUID}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOrderByUID", varargs...)
 	ret0, _ := ret[0].(*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrderByUID indicates an expected call of GetOrderByUID
-func (mr *MockOrderRepositoryMockRecorder) GetOrderByUID(ctx, orderUID interface{}) *gomock.Call {
+func (mr *MockOrderRepositoryMockRecorder) GetOrderByUID(ctx, orderUID interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByUID", reflect.TypeOf((*MockOrderRepository)(nil).GetOrderByUID), ctx, orderUID)
+	varargs := append([]interface{}{ctx, orderUID}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByUID", reflect.TypeOf((*MockOrderRepository)(nil).GetOrderByUID), varargs...)
+}
+
+// GetOrderByTrackNumber mocks base method
+func (m *MockOrderRepository) GetOrderByTrackNumber(ctx context.Context, trackNumber string) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderByTrackNumber", ctx, trackNumber)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderByTrackNumber indicates an expected call of GetOrderByTrackNumber
+func (mr *MockOrderRepositoryMockRecorder) GetOrderByTrackNumber(ctx, trackNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByTrackNumber", reflect.TypeOf((*MockOrderRepository)(nil).GetOrderByTrackNumber), ctx, trackNumber)
+}
+
+// DeleteOrder mocks base method
+func (m *MockOrderRepository) DeleteOrder(ctx context.Context, orderUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrder", ctx, orderUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOrder indicates an expected call of DeleteOrder
+func (mr *MockOrderRepositoryMockRecorder) DeleteOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrder", reflect.TypeOf((*MockOrderRepository)(nil).DeleteOrder), ctx, orderUID)
+}
+
+// RestoreOrder mocks base method
+func (m *MockOrderRepository) RestoreOrder(ctx context.Context, orderUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreOrder", ctx, orderUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreOrder indicates an expected call of RestoreOrder
+func (mr *MockOrderRepositoryMockRecorder) RestoreOrder(ctx, orderUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreOrder", reflect.TypeOf((*MockOrderRepository)(nil).RestoreOrder), ctx, orderUID)
+}
+
+// FindOrders mocks base method
+func (m *MockOrderRepository) FindOrders(ctx context.Context, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindOrders", varargs...)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOrders indicates an expected call of FindOrders
+func (mr *MockOrderRepositoryMockRecorder) FindOrders(ctx interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrders", reflect.TypeOf((*MockOrderRepository)(nil).FindOrders), varargs...)
+}
+
+// LoadOrdersAfter mocks base method
+func (m *MockOrderRepository) LoadOrdersAfter(ctx context.Context, afterUID string, limit int, opts ...interfaces.QueryOption) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, afterUID, limit}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "LoadOrdersAfter", varargs...)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadOrdersAfter indicates an expected call of LoadOrdersAfter
+func (mr *MockOrderRepositoryMockRecorder) LoadOrdersAfter(ctx, afterUID, limit interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, afterUID, limit}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadOrdersAfter", reflect.TypeOf((*MockOrderRepository)(nil).LoadOrdersAfter), varargs...)
+}
+
+// GetOrderStats mocks base method
+func (m *MockOrderRepository) GetOrderStats(ctx context.Context, since time.Time, topServices int) (*model.OrderStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderStats", ctx, since, topServices)
+	ret0, _ := ret[0].(*model.OrderStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderStats indicates an expected call of GetOrderStats
+func (mr *MockOrderRepositoryMockRecorder) GetOrderStats(ctx, since, topServices interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderStats", reflect.TypeOf((*MockOrderRepository)(nil).GetOrderStats), ctx, since, topServices)
+}
+
+// CountOrdersByBrand mocks base method
+func (m *MockOrderRepository) CountOrdersByBrand(ctx context.Context, brand string, sampleSize int) (*model.BrandCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOrdersByBrand", ctx, brand, sampleSize)
+	ret0, _ := ret[0].(*model.BrandCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOrdersByBrand indicates an expected call of CountOrdersByBrand
+func (mr *MockOrderRepositoryMockRecorder) CountOrdersByBrand(ctx, brand, sampleSize interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrdersByBrand", reflect.TypeOf((*MockOrderRepository)(nil).CountOrdersByBrand), ctx, brand, sampleSize)
 }
 
 // Close mocks base method
@@ -141,6 +280,32 @@ func (mr *MockOrderCacheMockRecorder) Set(order interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockOrderCache)(nil).Set), order)
 }
 
+// SetCacheOnly mocks base method
+func (m *MockOrderCache) SetCacheOnly(order *model.Order) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetCacheOnly", order)
+}
+
+// SetCacheOnly indicates an expected call of SetCacheOnly
+func (mr *MockOrderCacheMockRecorder) SetCacheOnly(order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCacheOnly", reflect.TypeOf((*MockOrderCache)(nil).SetCacheOnly), order)
+}
+
+// SetIfNewer mocks base method
+func (m *MockOrderCache) SetIfNewer(order *model.Order) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetIfNewer", order)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SetIfNewer indicates an expected call of SetIfNewer
+func (mr *MockOrderCacheMockRecorder) SetIfNewer(order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIfNewer", reflect.TypeOf((*MockOrderCache)(nil).SetIfNewer), order)
+}
+
 // LoadAll mocks base method
 func (m *MockOrderCache) LoadAll(orders []*model.Order) {
 	m.ctrl.T.Helper()
@@ -165,6 +330,20 @@ func (mr *MockOrderCacheMockRecorder) Delete(orderUID interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOrderCache)(nil).Delete), orderUID)
 }
 
+// DeleteWhere mocks base method
+func (m *MockOrderCache) DeleteWhere(pred func(*model.Order) bool) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWhere", pred)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// DeleteWhere indicates an expected call of DeleteWhere
+func (mr *MockOrderCacheMockRecorder) DeleteWhere(pred interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWhere", reflect.TypeOf((*MockOrderCache)(nil).DeleteWhere), pred)
+}
+
 // Size mocks base method
 func (m *MockOrderCache) Size() int {
 	m.ctrl.T.Helper()
@@ -179,6 +358,20 @@ func (mr *MockOrderCacheMockRecorder) Size() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockOrderCache)(nil).Size))
 }
 
+// Keys mocks base method
+func (m *MockOrderCache) Keys() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Keys indicates an expected call of Keys
+func (mr *MockOrderCacheMockRecorder) Keys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockOrderCache)(nil).Keys))
+}
+
 // Clear mocks base method
 func (m *MockOrderCache) Clear() {
 	m.ctrl.T.Helper()
@@ -241,7 +434,7 @@ func (m *MockMessageConsumer) EXPECT() *MockMessageConsumerMockRecorder {
 }
 
 // ReadMessages mocks base method
-func (m *MockMessageConsumer) ReadMessages(ctx context.Context, handle func([]byte)) error {
+func (m *MockMessageConsumer) ReadMessages(ctx context.Context, handle func(context.Context, []byte)) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ReadMessages", ctx, handle)
 	ret0, _ := ret[0].(error)
@@ -254,6 +447,30 @@ func (mr *MockMessageConsumerMockRecorder) ReadMessages(ctx, handle interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadMessages", reflect.TypeOf((*MockMessageConsumer)(nil).ReadMessages), ctx, handle)
 }
 
+// Pause mocks base method
+func (m *MockMessageConsumer) Pause() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Pause")
+}
+
+// Pause indicates an expected call of Pause
+func (mr *MockMessageConsumerMockRecorder) Pause() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockMessageConsumer)(nil).Pause))
+}
+
+// Resume mocks base method
+func (m *MockMessageConsumer) Resume() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Resume")
+}
+
+// Resume indicates an expected call of Resume
+func (mr *MockMessageConsumerMockRecorder) Resume() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resume", reflect.TypeOf((*MockMessageConsumer)(nil).Resume))
+}
+
 // Close mocks base method
 func (m *MockMessageConsumer) Close() error {
 	m.ctrl.T.Helper()
@@ -268,6 +485,57 @@ func (mr *MockMessageConsumerMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMessageConsumer)(nil).Close))
 }
 
+// MockMessageProducer is a mock of MessageProducer interface
+type MockMessageProducer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMessageProducerMockRecorder
+}
+
+// MockMessageProducerMockRecorder is the mock recorder for MockMessageProducer
+type MockMessageProducerMockRecorder struct {
+	mock *MockMessageProducer
+}
+
+// NewMockMessageProducer creates a new mock instance
+func NewMockMessageProducer(ctrl *gomock.Controller) *MockMessageProducer {
+	mock := &MockMessageProducer{ctrl: ctrl}
+	mock.recorder = &MockMessageProducerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMessageProducer) EXPECT() *MockMessageProducerMockRecorder {
+	return m.recorder
+}
+
+// Produce mocks base method
+func (m *MockMessageProducer) Produce(ctx context.Context, key, value []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Produce", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Produce indicates an expected call of Produce
+func (mr *MockMessageProducerMockRecorder) Produce(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Produce", reflect.TypeOf((*MockMessageProducer)(nil).Produce), ctx, key, value)
+}
+
+// Close mocks base method
+func (m *MockMessageProducer) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close
+func (mr *MockMessageProducerMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMessageProducer)(nil).Close))
+}
+
 // MockOrderValidator is a mock of OrderValidator interface
 type MockOrderValidator struct {
 	ctrl     *gomock.Controller
@@ -342,6 +610,20 @@ func (mr *MockRetryServiceMockRecorder) ExecuteWithRetry(operation interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteWithRetry", reflect.TypeOf((*MockRetryService)(nil).ExecuteWithRetry), operation)
 }
 
+// ExecuteWithRetryNamed mocks base method
+func (m *MockRetryService) ExecuteWithRetryNamed(name string, operation func() error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecuteWithRetryNamed", name, operation)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecuteWithRetryNamed indicates an expected call of ExecuteWithRetryNamed
+func (mr *MockRetryServiceMockRecorder) ExecuteWithRetryNamed(name, operation interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteWithRetryNamed", reflect.TypeOf((*MockRetryService)(nil).ExecuteWithRetryNamed), name, operation)
+}
+
 // MockDLQService is a mock of DLQService interface
 type MockDLQService struct {
 	ctrl     *gomock.Controller
@@ -366,31 +648,31 @@ func (m *MockDLQService) EXPECT() *MockDLQServiceMockRecorder {
 }
 
 // SendToDLQ mocks base method
-func (m *MockDLQService) SendToDLQ(message []byte, reason string) error {
+func (m *MockDLQService) SendToDLQ(ctx context.Context, message []byte, reason string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendToDLQ", message, reason)
+	ret := m.ctrl.Call(m, "SendToDLQ", ctx, message, reason)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendToDLQ indicates an expected call of SendToDLQ
-func (mr *MockDLQServiceMockRecorder) SendToDLQ(message, reason interface{}) *gomock.Call {
+func (mr *MockDLQServiceMockRecorder) SendToDLQ(ctx, message, reason interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendToDLQ", reflect.TypeOf((*MockDLQService)(nil).SendToDLQ), message, reason)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendToDLQ", reflect.TypeOf((*MockDLQService)(nil).SendToDLQ), ctx, message, reason)
 }
 
 // ProcessDLQ mocks base method
-func (m *MockDLQService) ProcessDLQ() error {
+func (m *MockDLQService) ProcessDLQ(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessDLQ")
+	ret := m.ctrl.Call(m, "ProcessDLQ", ctx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ProcessDLQ indicates an expected call of ProcessDLQ
-func (mr *MockDLQServiceMockRecorder) ProcessDLQ() *gomock.Call {
+func (mr *MockDLQServiceMockRecorder) ProcessDLQ(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessDLQ", reflect.TypeOf((*MockDLQService)(nil).ProcessDLQ))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessDLQ", reflect.TypeOf((*MockDLQService)(nil).ProcessDLQ), ctx)
 }
 
 // Close mocks base method
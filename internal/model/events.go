@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// OrderStatusSaved заказ записан в БД и кеш
+const OrderStatusSaved = "saved"
+
+// OrderProcessedEventVersion версия схемы OrderProcessedEvent, уходит в заголовке schema-version
+// Не связана с версией схемы входящего заказа
+const OrderProcessedEventVersion = "1"
+
+// OrderProcessedEvent событие о сохраненном заказе для внешних сервисов
+type OrderProcessedEvent struct {
+	OrderUID  string    `json:"order_uid"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// numberField числовое поле, которое поставщик может прислать и числом, и строкой: 1000 или "1000"
+type numberField struct {
+	name  string
+	raw   json.RawMessage
+	value *int
+}
+
+// decodeNumbers разбирает числовые поля структуры structName
+// Отсутствующее поле и null оставляют значение без изменений,
+// дробные и нечисловые значения - ошибка *json.UnmarshalTypeError, как и для обычного int
+func decodeNumbers(structName string, fields ...numberField) error {
+	for _, field := range fields {
+		if len(field.raw) == 0 || bytes.Equal(field.raw, []byte("null")) {
+			continue
+		}
+
+		raw, kind := string(field.raw), jsonKind(field.raw)
+		if kind == "string" {
+			var s string
+			if err := json.Unmarshal(field.raw, &s); err != nil {
+				return err
+			}
+			raw = strings.TrimSpace(s)
+		}
+
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return &json.UnmarshalTypeError{
+				Value:  kind + " " + string(field.raw),
+				Type:   reflect.TypeOf(0),
+				Struct: structName,
+				Field:  field.name,
+			}
+		}
+		*field.value = value
+	}
+	return nil
+}
+
+// jsonKind возвращает тип JSON значения для сообщения об ошибке
+func jsonKind(raw json.RawMessage) string {
+	switch raw[0] {
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	default:
+		return "number"
+	}
+}
+
+// UnmarshalJSON разбирает платеж, числовые поля допускаются строками
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	type payment Payment
+	aux := struct {
+		*payment
+		Amount       json.RawMessage `json:"amount"`
+		PaymentDT    json.RawMessage `json:"payment_dt"`
+		DeliveryCost json.RawMessage `json:"delivery_cost"`
+		GoodsTotal   json.RawMessage `json:"goods_total"`
+		CustomFee    json.RawMessage `json:"custom_fee"`
+	}{payment: (*payment)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	return decodeNumbers("Payment",
+		numberField{"amount", aux.Amount, &p.Amount},
+		numberField{"payment_dt", aux.PaymentDT, &p.PaymentDT},
+		numberField{"delivery_cost", aux.DeliveryCost, &p.DeliveryCost},
+		numberField{"goods_total", aux.GoodsTotal, &p.GoodsTotal},
+		numberField{"custom_fee", aux.CustomFee, &p.CustomFee},
+	)
+}
+
+// UnmarshalJSON разбирает товар, числовые поля допускаются строками
+func (i *Item) UnmarshalJSON(data []byte) error {
+	type item Item
+	aux := struct {
+		*item
+		ChrtID     json.RawMessage `json:"chrt_id"`
+		Price      json.RawMessage `json:"price"`
+		Sale       json.RawMessage `json:"sale"`
+		TotalPrice json.RawMessage `json:"total_price"`
+		NmID       json.RawMessage `json:"nm_id"`
+		Status     json.RawMessage `json:"status"`
+	}{item: (*item)(i)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	return decodeNumbers("Item",
+		numberField{"chrt_id", aux.ChrtID, &i.ChrtID},
+		numberField{"price", aux.Price, &i.Price},
+		numberField{"sale", aux.Sale, &i.Sale},
+		numberField{"total_price", aux.TotalPrice, &i.TotalPrice},
+		numberField{"nm_id", aux.NmID, &i.NmID},
+		numberField{"status", aux.Status, &i.Status},
+	)
+}
@@ -0,0 +1,297 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// keysOf возвращает отсортированные ключи JSON объекта
+func keysOf(t *testing.T, raw json.RawMessage) []string {
+	t.Helper()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal object: %v", err)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func assertKeys(t *testing.T, name string, got, expected []string) {
+	t.Helper()
+
+	sort.Strings(expected)
+	if len(got) != len(expected) {
+		t.Errorf("%s keys = %v, want %v", name, got, expected)
+		return
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("%s keys = %v, want %v", name, got, expected)
+			return
+		}
+	}
+}
+
+// TestOrder_JSONKeys фиксирует каноничную схему заказа
+// Пустые значения тоже должны сериализоваться, omitempty не используется
+func TestOrder_JSONKeys(t *testing.T) {
+	order := Order{
+		Items:       []Item{{}},
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("Failed to marshal order: %v", err)
+	}
+
+	var raw struct {
+		Delivery json.RawMessage   `json:"delivery"`
+		Payment  json.RawMessage   `json:"payment"`
+		Items    []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Failed to unmarshal order: %v", err)
+	}
+
+	assertKeys(t, "order", keysOf(t, data), []string{
+		"order_uid", "track_number", "entry", "delivery", "payment", "items",
+		"locale", "internal_signature", "customer_id", "delivery_service",
+		"shardkey", "sm_id", "date_created", "oof_shard",
+	})
+
+	assertKeys(t, "delivery", keysOf(t, raw.Delivery), []string{
+		"name", "phone", "zip", "city", "address", "region", "email",
+	})
+
+	assertKeys(t, "payment", keysOf(t, raw.Payment), []string{
+		"transaction", "request_id", "currency", "provider", "amount",
+		"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee",
+	})
+
+	if len(raw.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(raw.Items))
+	}
+	assertKeys(t, "item", keysOf(t, raw.Items[0]), []string{
+		"chrt_id", "track_number", "price", "rid", "name", "sale",
+		"size", "total_price", "nm_id", "brand", "status",
+	})
+}
+
+func TestOrder_UnmarshalStringNumbers(t *testing.T) {
+	data := []byte(`{
+		"order_uid": "b563feb7b2b84b6test",
+		"payment": {"amount": "1817", "payment_dt": 1637907727, "delivery_cost": " 1500 ", "goods_total": "317", "custom_fee": 0},
+		"items": [
+			{"chrt_id": 9934930, "price": "453", "sale": "30", "total_price": "317", "nm_id": "2389212", "status": 202},
+			{"chrt_id": "9934931", "price": 200, "total_price": 200, "nm_id": 2389213, "status": "202"}
+		]
+	}`)
+
+	var order Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if order.OrderUID != "b563feb7b2b84b6test" {
+		t.Errorf("OrderUID = %q", order.OrderUID)
+	}
+	payment := order.Payment
+	if payment.Amount != 1817 || payment.PaymentDT != 1637907727 || payment.DeliveryCost != 1500 ||
+		payment.GoodsTotal != 317 || payment.CustomFee != 0 {
+		t.Errorf("Payment = %+v", payment)
+	}
+	if len(order.Items) != 2 {
+		t.Fatalf("Items = %d, want 2", len(order.Items))
+	}
+	first, second := order.Items[0], order.Items[1]
+	if first.Price != 453 || first.Sale != 30 || first.TotalPrice != 317 || first.NmID != 2389212 || first.Status != 202 {
+		t.Errorf("Items[0] = %+v", first)
+	}
+	if second.ChrtID != 9934931 || second.Price != 200 || second.Status != 202 {
+		t.Errorf("Items[1] = %+v", second)
+	}
+
+	// Обратно числа сериализуются числами
+	encoded, err := json.Marshal(order.Payment)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["amount"]) != "1817" {
+		t.Errorf("amount encoded as %s, want 1817", fields["amount"])
+	}
+}
+
+func TestOrder_UnmarshalInvalidNumbers(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		structName string
+		field      string
+	}{
+		{"non-numeric amount", `{"payment": {"amount": "abc"}}`, "Payment", "amount"},
+		{"empty amount", `{"payment": {"amount": ""}}`, "Payment", "amount"},
+		{"fractional price", `{"items": [{"price": "45.3"}]}`, "Item", "price"},
+		{"fractional number", `{"items": [{"price": 45.3}]}`, "Item", "price"},
+		{"bool price", `{"items": [{"price": true}]}`, "Item", "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order Order
+			err := json.Unmarshal([]byte(tt.data), &order)
+			if err == nil {
+				t.Fatal("Unmarshal() error = nil, want error")
+			}
+
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("Unmarshal() error = %v, want *json.UnmarshalTypeError", err)
+			}
+			if typeErr.Struct != tt.structName || typeErr.Field != tt.field {
+				t.Errorf("error field = %s.%s, want %s.%s", typeErr.Struct, typeErr.Field, tt.structName, tt.field)
+			}
+		})
+	}
+}
+
+func TestDecodeOrderStrict(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantField string
+	}{
+		{name: "known fields", data: `{"order_uid": "b563feb7b2b84b6test", "payment": {"amount": "1817"}, "items": [{"chrt_id": 1}]}`},
+		{name: "case-insensitive match", data: `{"Order_UID": "b563feb7b2b84b6test", "payment": {"Amount": 1}}`},
+		{name: "top-level typo", data: `{"order_uid": "b563feb7b2b84b6test", "trak_number": "WBILMTESTTRACK"}`, wantField: "trak_number"},
+		{name: "payment typo", data: `{"payment": {"ammount": 1817}}`, wantField: "payment.ammount"},
+		{name: "item typo", data: `{"items": [{"chrt_id": 1}, {"sizee": "0"}]}`, wantField: "items[1].sizee"},
+		{name: "schema version field", data: `{"schema_version": "1", "order_uid": "b563feb7b2b84b6test"}`},
+		{name: "schema version only at top level", data: `{"payment": {"schema_version": "1"}}`, wantField: "payment.schema_version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Без строгого режима неизвестные поля игнорируются
+			if _, err := DecodeOrder("", []byte(tt.data)); err != nil {
+				t.Fatalf("DecodeOrder() error = %v", err)
+			}
+
+			_, err := DecodeOrderStrict("", []byte(tt.data))
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("DecodeOrderStrict() error = %v", err)
+				}
+				return
+			}
+			var unknownErr *UnknownFieldError
+			if !errors.As(err, &unknownErr) || unknownErr.Field != tt.wantField {
+				t.Errorf("DecodeOrderStrict() error = %v, want unknown field %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestNegotiateSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared string
+		data     string
+		want     string
+		wantErr  error
+	}{
+		{name: "not declared", data: `{"order_uid": "b563feb7b2b84b6test"}`, want: ""},
+		{name: "header only", declared: "1", data: `{"order_uid": "b563feb7b2b84b6test"}`, want: "1"},
+		{name: "field only", data: `{"schema_version": "2"}`, want: "2"},
+		{name: "numeric field", data: `{"schema_version": 1}`, want: "1"},
+		{name: "header and field agree", declared: "1", data: `{"schema_version": "1"}`, want: "1"},
+		{name: "header and field differ", declared: "1", data: `{"schema_version": "2"}`, wantErr: ErrSchemaVersionMismatch},
+		{name: "invalid json left to decoder", declared: "1", data: `{"schema_version":`, want: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NegotiateSchemaVersion(tt.declared, []byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("NegotiateSchemaVersion() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NegotiateSchemaVersion() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NegotiateSchemaVersion("", []byte(`{"schema_version": true}`)); err == nil {
+		t.Error("Expected error for boolean schema_version")
+	}
+}
+
+func TestOrder_MarshalDateCreated(t *testing.T) {
+	t.Cleanup(func() { SetTimeFormat(DefaultTimeFormat) })
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	created := time.Date(2021, 11, 26, 9, 22, 19, 123456789, moscow)
+
+	dateCreated := func(t *testing.T) string {
+		t.Helper()
+		data, err := json.Marshal(&Order{OrderUID: "b563feb7b2b84b6test", DateCreated: created})
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var fields struct {
+			OrderUID    string `json:"order_uid"`
+			DateCreated string `json:"date_created"`
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if fields.OrderUID != "b563feb7b2b84b6test" {
+			t.Errorf("order_uid = %q, other fields must be kept", fields.OrderUID)
+		}
+		return fields.DateCreated
+	}
+
+	if got := dateCreated(t); got != "2021-11-26T06:22:19Z" {
+		t.Errorf("date_created = %q, want RFC3339 in UTC", got)
+	}
+
+	if err := SetTimeFormat(TimeFormatRFC3339Nano); err != nil {
+		t.Fatalf("SetTimeFormat() error = %v", err)
+	}
+	if got := dateCreated(t); got != "2021-11-26T06:22:19.123456789Z" {
+		t.Errorf("date_created = %q, want RFC3339Nano in UTC", got)
+	}
+
+	// Сериализованный заказ разбирается обратно в тот же момент времени
+	data, err := json.Marshal(Order{DateCreated: created})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded Order
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !decoded.DateCreated.Equal(created) {
+		t.Errorf("DateCreated = %v, want %v", decoded.DateCreated, created)
+	}
+
+	if err := SetTimeFormat("unix"); err == nil {
+		t.Error("Expected error for unknown time format")
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersionV1 первая версия схемы заказа, совпадает с Order
+const SchemaVersionV1 = "1"
+
+// SchemaVersionField поле верхнего уровня, в котором клиент может указать версию схемы в теле
+const SchemaVersionField = "schema_version"
+
+// SupportedSchemaVersions версии схемы заказа, которые умеет разбирать DecodeOrder
+var SupportedSchemaVersions = []string{SchemaVersionV1}
+
+// ErrUnsupportedSchemaVersion версия схемы заказа не поддерживается
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
+// ErrSchemaVersionMismatch версия в заголовке и в поле schema_version различаются
+var ErrSchemaVersionMismatch = errors.New("schema version mismatch")
+
+// DecodeOrder разбирает заказ по версии схемы
+// Пустая версия означает v1: так отправляли заказы до появления версий
+func DecodeOrder(version string, data []byte) (*Order, error) {
+	switch version {
+	case "", SchemaVersionV1:
+		var order Order
+		if err := json.Unmarshal(data, &order); err != nil {
+			return nil, err
+		}
+		return &order, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedSchemaVersion, version)
+	}
+}
+
+// NegotiateSchemaVersion определяет версию схемы тела data
+// Версия берется из declared (заголовок) или поля schema_version, число 1 равно строке "1"
+// Если указаны обе, они должны совпадать. Пустая строка - версия не указана
+// Тело, которое не разбирается как JSON объект, не ошибка: о нем сообщит DecodeOrder
+func NegotiateSchemaVersion(declared string, data []byte) (string, error) {
+	var envelope struct {
+		SchemaVersion json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return declared, nil
+	}
+
+	var field string
+	raw := bytes.TrimSpace(envelope.SchemaVersion)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+	case raw[0] == '"':
+		if err := json.Unmarshal(raw, &field); err != nil {
+			return "", fmt.Errorf("invalid %s: %w", SchemaVersionField, err)
+		}
+	default:
+		var number json.Number
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return "", fmt.Errorf("%s must be a string or a number", SchemaVersionField)
+		}
+		field = number.String()
+	}
+
+	switch {
+	case field == "":
+		return declared, nil
+	case declared == "" || declared == field:
+		return field, nil
+	default:
+		return "", fmt.Errorf("%w: header %q, body %q", ErrSchemaVersionMismatch, declared, field)
+	}
+}
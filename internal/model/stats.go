@@ -0,0 +1,31 @@
+package model
+
+// OrderStats агрегированная статистика по заказам
+type OrderStats struct {
+	TotalOrders  int64 `json:"total_orders"`
+	TotalRevenue int64 `json:"total_revenue"`
+	// OrdersPerDay число заказов по дням за период, дни без заказов не включаются
+	OrdersPerDay []DailyOrderCount `json:"orders_per_day"`
+	// TopDeliveryServices службы доставки по убыванию числа заказов
+	TopDeliveryServices []DeliveryServiceCount `json:"top_delivery_services"`
+}
+
+// DailyOrderCount число заказов за день, Day в формате 2006-01-02
+type DailyOrderCount struct {
+	Day   string `json:"day"`
+	Count int64  `json:"count"`
+}
+
+// BrandCount число заказов, в которых есть товар бренда
+// SampleOrderUIDs - несколько таких заказов по возрастанию order_uid
+type BrandCount struct {
+	Brand           string   `json:"brand"`
+	Orders          int64    `json:"orders"`
+	SampleOrderUIDs []string `json:"sample_order_uids"`
+}
+
+// DeliveryServiceCount число заказов службы доставки
+type DeliveryServiceCount struct {
+	Service string `json:"service"`
+	Count   int64  `json:"count"`
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnknownFieldError поле JSON, которого нет в модели
+// Field - путь к полю, например payment.ammount или items[0].sizee
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+// DecodeOrderStrict разбирает заказ как DecodeOrder, но отклоняет поля, которых нет в модели
+// Опечатка поставщика (например "ammount") иначе молча теряется
+func DecodeOrderStrict(version string, data []byte) (*Order, error) {
+	order, err := DecodeOrder(version, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckUnknownFields(data, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CheckUnknownFields возвращает *UnknownFieldError для первого поля JSON, которого нет в v
+// Decoder.DisallowUnknownFields не действует внутри типов со своим UnmarshalJSON (Payment, Item),
+// поэтому поля сверяются с json тегами модели рекурсивно. Имена сравниваются без учета регистра,
+// как при разборе encoding/json. Проверяется первое значение JSON в data, как у json.Decoder
+func CheckUnknownFields(data []byte, v interface{}) error {
+	var raw json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil {
+		return err
+	}
+	if field := unknownField(raw, reflect.TypeOf(v), ""); field != "" {
+		return &UnknownFieldError{Field: field}
+	}
+	return nil
+}
+
+// orderType тип заказа, в его корне допустимо поле schema_version
+var orderType = reflect.TypeOf(Order{})
+
+// modelPkgPath пакет моделей, типы других пакетов (time.Time) разбирают JSON сами
+var modelPkgPath = orderType.PkgPath()
+
+// unknownField возвращает путь первого неизвестного поля в raw для типа t, пусто - таких нет
+// Значения, которые не разбираются как ожидаемый тип, пропускаются: о них сообщает сам разбор
+func unknownField(raw json.RawMessage, t reflect.Type, path string) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.PkgPath() != modelPkgPath {
+			return ""
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return ""
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			// Версия схемы в теле заказа не поле модели, ее разбирает NegotiateSchemaVersion
+			if path == "" && t == orderType && strings.EqualFold(key, SchemaVersionField) {
+				continue
+			}
+			field, ok := jsonField(t, key)
+			if !ok {
+				return path + key
+			}
+			if unknown := unknownField(object[key], field.Type, path+key+"."); unknown != "" {
+				return unknown
+			}
+		}
+	case reflect.Slice:
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			return ""
+		}
+		var elements []json.RawMessage
+		if err := json.Unmarshal(raw, &elements); err != nil {
+			return ""
+		}
+		prefix := strings.TrimSuffix(path, ".")
+		for i, element := range elements {
+			if unknown := unknownField(element, t.Elem(), prefix+"["+strconv.Itoa(i)+"]."); unknown != "" {
+				return unknown
+			}
+		}
+	}
+	return ""
+}
+
+// jsonField ищет поле структуры по имени в JSON: сначала точное совпадение тега, затем без учета регистра
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	var folded reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		if tag == name {
+			return field, true
+		}
+		if !found && strings.EqualFold(tag, name) {
+			folded, found = field, true
+		}
+	}
+	return folded, found
+}
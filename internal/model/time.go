@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Форматы date_created в JSON заказа
+const (
+	// TimeFormatRFC3339 время в UTC с точностью до секунды: 2021-11-26T06:22:19Z
+	TimeFormatRFC3339 = "rfc3339"
+	// TimeFormatRFC3339Nano время в UTC с дробной частью секунды без завершающих нулей
+	TimeFormatRFC3339Nano = "rfc3339nano"
+)
+
+// DefaultTimeFormat формат date_created по умолчанию
+const DefaultTimeFormat = TimeFormatRFC3339
+
+// timeLayout раскладка time.Format для date_created
+var timeLayout atomic.Value
+
+func init() {
+	timeLayout.Store(time.RFC3339)
+}
+
+// TimeLayout возвращает раскладку time.Format для формата format
+// Пустая строка - DefaultTimeFormat
+func TimeLayout(format string) (string, error) {
+	switch format {
+	case "", TimeFormatRFC3339:
+		return time.RFC3339, nil
+	case TimeFormatRFC3339Nano:
+		return time.RFC3339Nano, nil
+	default:
+		return "", fmt.Errorf("unknown time format %q, expected %s or %s",
+			format, TimeFormatRFC3339, TimeFormatRFC3339Nano)
+	}
+}
+
+// SetTimeFormat задает формат date_created в JSON заказа для всего процесса
+// Вызывается при старте до сериализации заказов
+func SetTimeFormat(format string) error {
+	layout, err := TimeLayout(format)
+	if err != nil {
+		return err
+	}
+	timeLayout.Store(layout)
+	return nil
+}
+
+// FormatTime форматирует время заказа в UTC в выбранном формате
+// Смещение исходного времени не сохраняется: один момент всегда дает одну строку
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout.Load().(string))
+}
+
+// MarshalJSON сериализует заказ, date_created пишется через FormatTime
+// Без этого time.Time пишется с исходным смещением и наносекундами, и один заказ
+// из Kafka, кеша и БД выглядел бы по-разному. Разбор принимает любое время RFC3339
+func (o Order) MarshalJSON() ([]byte, error) {
+	type order Order
+	return json.Marshal(struct {
+		order
+		DateCreated string `json:"date_created"`
+	}{order: order(o), DateCreated: FormatTime(o.DateCreated)})
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPList набор подсетей для allowlist и denylist middleware
+type IPList []netip.Prefix
+
+// ParseIPList разбирает подсети в нотации CIDR и отдельные адреса:
+// "10.0.0.0/8", "192.168.1.10", "::1". Пустые элементы пропускаются
+func ParseIPList(entries []string) (IPList, error) {
+	list := make(IPList, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			list = append(list, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		list = append(list, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return list, nil
+}
+
+// Contains сообщает, входит ли адрес в одну из подсетей списка
+func (l IPList) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerAddr возвращает адрес, с которого установлено соединение
+// Заголовки X-Forwarded-For и X-Real-IP задает клиент, поэтому для списков доступа они не используются
+func peerAddr(r *http.Request) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
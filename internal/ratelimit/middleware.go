@@ -15,6 +15,10 @@ type Middleware struct {
 	logger  *logrus.Logger
 	keyFunc KeyFunc
 	onLimit OnLimitFunc
+	// allowList адреса без ограничения частоты: проверки здоровья, доверенные прокси
+	allowList IPList
+	// denyList адреса, запросы с которых всегда отклоняются с 403
+	denyList IPList
 }
 
 // KeyFunc функция для извлечения ключа из запроса
@@ -61,9 +65,41 @@ func (m *Middleware) WithOnLimit(onLimit OnLimitFunc) *Middleware {
 	return m
 }
 
+// WithAllowList задает адреса, запросы с которых не ограничиваются
+// Адрес берется из соединения (RemoteAddr), а не из X-Forwarded-For
+func (m *Middleware) WithAllowList(list IPList) *Middleware {
+	m.allowList = list
+	return m
+}
+
+// WithDenyList задает адреса, запросы с которых всегда отклоняются с 403
+// Denylist проверяется первым: адрес из обоих списков отклоняется
+func (m *Middleware) WithDenyList(list IPList) *Middleware {
+	m.denyList = list
+	return m
+}
+
 // Handler возвращает HTTP handler с rate limiting
+// Сначала проверяются denylist и allowlist, лимит применяется к остальным запросам
 func (m *Middleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := peerAddr(r); ok {
+			if m.denyList.Contains(addr) {
+				m.logger.WithFields(logrus.Fields{
+					"method":      r.Method,
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				}).Warn("Request from denied address")
+
+				DefaultOnDeny(w, r)
+				return
+			}
+			if m.allowList.Contains(addr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
 		key := m.keyFunc(r)
 
 		// Проверяем лимит
@@ -120,6 +156,13 @@ func DefaultOnLimit(w http.ResponseWriter, r *http.Request, key string) {
 	w.Write([]byte(`{"error": "Rate limit exceeded", "message": "Too many requests"}`))
 }
 
+// DefaultOnDeny ответ на запрос с адреса из denylist
+func DefaultOnDeny(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(`{"error": "Forbidden", "message": "Access denied"}`))
+}
+
 // IPKeyFunc извлекает ключ по IP адресу
 func IPKeyFunc(r *http.Request) string {
 	return DefaultKeyFunc(r)
@@ -265,3 +265,86 @@ func TestCustomOnLimitFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestMiddleware_AllowDenyLists(t *testing.T) {
+	logger := logrus.New()
+	config := MiddlewareConfig{
+		Requests:  1,
+		Window:    time.Minute,
+		Burst:     1,
+		Algorithm: "token-bucket",
+	}
+
+	allow, err := ParseIPList([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatalf("ParseIPList() error = %v", err)
+	}
+	deny, err := ParseIPList([]string{"192.168.1.66", "10.6.6.0/24"})
+	if err != nil {
+		t.Fatalf("ParseIPList() error = %v", err)
+	}
+
+	middleware := NewMiddleware(config, logger).WithAllowList(allow).WithDenyList(deny)
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("allowlisted IP is never limited", func(t *testing.T) {
+		for _, addr := range []string{"10.1.2.3:1234", "[::1]:1234"} {
+			for i := 0; i < 20; i++ {
+				if code := serve(addr, ""); code != http.StatusOK {
+					t.Fatalf("%s request %d: status = %d, want %d", addr, i+1, code, http.StatusOK)
+				}
+			}
+		}
+	})
+
+	t.Run("denylisted IP is always rejected", func(t *testing.T) {
+		for _, addr := range []string{"192.168.1.66:1234", "10.6.6.7:1234"} {
+			for i := 0; i < 3; i++ {
+				if code := serve(addr, ""); code != http.StatusForbidden {
+					t.Fatalf("%s request %d: status = %d, want %d", addr, i+1, code, http.StatusForbidden)
+				}
+			}
+		}
+	})
+
+	t.Run("forwarded header does not bypass lists", func(t *testing.T) {
+		if code := serve("192.168.1.66:1234", "10.1.2.3"); code != http.StatusForbidden {
+			t.Errorf("denied peer with allowlisted X-Forwarded-For: status = %d, want %d", code, http.StatusForbidden)
+		}
+		if code := serve("172.16.0.1:1234", "10.1.2.3"); code != http.StatusOK {
+			t.Fatalf("first request: status = %d, want %d", code, http.StatusOK)
+		}
+		if code := serve("172.16.0.1:1234", "10.1.2.3"); code != http.StatusTooManyRequests {
+			t.Errorf("second request: status = %d, want %d", code, http.StatusTooManyRequests)
+		}
+	})
+}
+
+func TestParseIPList(t *testing.T) {
+	list, err := ParseIPList([]string{" 10.0.0.1/8 ", "", "127.0.0.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("ParseIPList() error = %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("len(list) = %d, want 3", len(list))
+	}
+
+	for _, entries := range [][]string{{"10.0.0.0/33"}, {"not-an-ip"}, {"10.0.0"}} {
+		if _, err := ParseIPList(entries); err == nil {
+			t.Errorf("ParseIPList(%v) expected error", entries)
+		}
+	}
+}
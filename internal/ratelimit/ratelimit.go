@@ -81,27 +81,63 @@ func (tb *TokenBucket) Allow(ctx context.Context, key string) (bool, error) {
 }
 
 // Wait ждет пока можно будет выполнить запрос
+// Вместо периодического опроса спит ровно до появления следующего токена
 func (tb *TokenBucket) Wait(ctx context.Context, key string) error {
 	for {
-		allowed, err := tb.Allow(ctx, key)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
 
+		allowed, waitTime := tb.take(key)
 		if allowed {
 			return nil
 		}
 
-		// Ждем немного перед следующей попыткой
+		timer := time.NewTimer(waitTime)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return ctx.Err()
-		case <-time.After(time.Millisecond * 10):
-			// Продолжаем
+		case <-timer.C:
+			// Токен должен был появиться, пробуем снова
 		}
 	}
 }
 
+// take забирает токен, если он есть, иначе возвращает время до появления следующего
+func (tb *TokenBucket) take(key string) (bool, time.Duration) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	b := tb.getOrCreateBucket(key)
+	now := time.Now()
+	tb.refill(b, now)
+
+	if b.tokens >= 1.0 {
+		b.tokens -= 1.0
+		b.allowed++
+		return true, 0
+	}
+
+	b.denied++
+	return false, tb.timeUntilToken(b)
+}
+
+// timeUntilToken вычисляет время до накопления целого токена по скорости пополнения
+func (tb *TokenBucket) timeUntilToken(b *bucket) time.Duration {
+	// Если токены не пополняются, ждем одно окно до следующей проверки
+	waitTime := tb.config.Window
+	if tb.config.Requests > 0 && tb.config.Window > 0 {
+		perToken := float64(tb.config.Window) / float64(tb.config.Requests)
+		waitTime = time.Duration((1.0 - b.tokens) * perToken)
+	}
+	if waitTime < time.Millisecond {
+		// Не даем таймеру крутиться вхолостую из-за погрешности float
+		waitTime = time.Millisecond
+	}
+	return waitTime
+}
+
 // Reset сбрасывает bucket для ключа
 func (tb *TokenBucket) Reset(key string) {
 	tb.mutex.Lock()
@@ -127,6 +127,51 @@ func TestTokenBucket_Reset(t *testing.T) {
 	}
 }
 
+func TestTokenBucket_Wait(t *testing.T) {
+	// 10 запросов в секунду - новый токен каждые 100ms
+	limiter := NewTokenBucket(Config{Requests: 10, Window: time.Second})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		if allowed, _ := limiter.Allow(ctx, "key"); !allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+
+	start := time.Now()
+	if err := limiter.Wait(ctx, "key"); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 80*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("Expected Wait to wake near 100ms, took %v", elapsed)
+	}
+
+	// Одна неудачная попытка до сна и успешная после - без опроса
+	if denied := limiter.Stats("key").Denied; denied != 1 {
+		t.Errorf("Expected 1 denied attempt, got %d", denied)
+	}
+}
+
+func TestTokenBucket_Wait_ContextCanceled(t *testing.T) {
+	limiter := NewTokenBucket(Config{Requests: 1, Window: time.Hour})
+	if allowed, _ := limiter.Allow(context.Background(), "key"); !allowed {
+		t.Fatal("Expected first request to be allowed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := limiter.Wait(ctx, "key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Wait to return on cancellation, took %v", elapsed)
+	}
+}
+
 func TestFixedWindow_Allow(t *testing.T) {
 	config := Config{
 		Requests: 5,
@@ -2,38 +2,192 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"time"
 
+	"wbtest/internal/circuitbreaker"
 	"wbtest/internal/config"
+	apperrors "wbtest/internal/errors"
 	"wbtest/internal/interfaces"
+	"wbtest/internal/metrics"
 )
 
+// ErrRetryExhausted повторы прекращены: исчерпан бюджет времени MaxElapsed
+// Ошибка оборачивает и последнюю ошибку операции
+var ErrRetryExhausted = errors.New("retry time budget exhausted")
+
+// DefaultOperation имя операции в метриках для вызовов без имени
+const DefaultOperation = "default"
+
 type RetryService struct {
 	config *config.RetryConfig
+
+	// breaker общий бюджет повторов: при массовых ошибках открывается,
+	// и на время cooldown операции выполняются без повторов
+	breaker *circuitbreaker.CircuitBreaker
+
+	// metrics счетчики попыток и исчерпанных повторов, nil - не записываются
+	metrics *metrics.Metrics
+
+	// retryable решает, стоит ли повторять ошибку; nil - повторяются все,
+	// кроме постоянных (apperrors.Permanent)
+	retryable func(error) bool
 }
 
 func NewRetryService(cfg *config.RetryConfig) interfaces.RetryService {
-	return &RetryService{
+	service := &RetryService{
 		config: cfg,
 	}
+
+	if cfg.BreakerFailureThreshold > 0 {
+		service.breaker = circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold: cfg.BreakerFailureThreshold,
+			SuccessThreshold: 1,
+			Timeout:          cfg.BreakerCooldown,
+			MaxRequests:      1,
+		})
+	}
+
+	return service
+}
+
+// WithMetrics включает запись метрик retry_attempts_total и retry_failures_total
+func (r *RetryService) WithMetrics(m *metrics.Metrics) *RetryService {
+	r.metrics = m
+	return r
+}
+
+// WithRetryable задает классификатор ошибок: неповторяемые ошибки прерывают повторы
+// и не расходуют бюджет повторов
+func (r *RetryService) WithRetryable(fn func(error) bool) *RetryService {
+	r.retryable = fn
+	return r
+}
+
+// isRetryable сообщает, стоит ли повторять операцию после ошибки err
+func (r *RetryService) isRetryable(err error) bool {
+	if r.retryable != nil {
+		return r.retryable(err)
+	}
+	return !apperrors.IsPermanent(err)
+}
+
+// Breaker возвращает circuit breaker бюджета повторов или nil, если бюджет выключен
+func (r *RetryService) Breaker() *circuitbreaker.CircuitBreaker {
+	return r.breaker
+}
+
+// BreakerStats возвращает состояние бюджета повторов для метрик
+// Второе значение false, если бюджет выключен
+func (r *RetryService) BreakerStats() (circuitbreaker.Stats, bool) {
+	if r.breaker == nil {
+		return circuitbreaker.Stats{}, false
+	}
+	return r.breaker.GetStats(), true
 }
 
 func (r *RetryService) ExecuteWithRetry(operation func() error) error {
+	return r.ExecuteWithRetryNamed(DefaultOperation, operation)
+}
+
+// ExecuteWithRetryNamed выполняет операцию с retry
+// name попадает в метки метрик попыток и неудач
+func (r *RetryService) ExecuteWithRetryNamed(name string, operation func() error) error {
+	return r.withBudget(context.Background(), r.counted(name, operation), func() error {
+		return r.executeWithRetry(name, operation)
+	})
+}
+
+// counted оборачивает операцию, считая ее вызовы как первые попытки
+// Используется, когда бюджет повторов исчерпан и операция выполняется один раз
+func (r *RetryService) counted(name string, operation func() error) func() error {
+	return func() error {
+		r.recordAttempt(name, 1)
+		return operation()
+	}
+}
+
+// recordAttempt увеличивает счетчик попыток операции
+func (r *RetryService) recordAttempt(name string, attempt int) {
+	if r.metrics == nil {
+		return
+	}
+	r.metrics.RetryAttempts.WithLabelValues(name, strconv.Itoa(attempt)).Inc()
+}
+
+// recordFailure увеличивает счетчик операций, исчерпавших повторы
+func (r *RetryService) recordFailure(name string) {
+	if r.metrics == nil {
+		return
+	}
+	r.metrics.RetryFailures.WithLabelValues(name).Inc()
+}
+
+// withBudget выполняет retryLoop под защитой бюджета повторов
+// Если бюджет исчерпан, операция выполняется один раз без повторов
+// Бюджет расходуют только повторяемые ошибки: битые или невалидные сообщения
+// не говорят о деградации зависимостей и не должны открывать breaker
+func (r *RetryService) withBudget(ctx context.Context, operation, retryLoop func() error) error {
+	if r.breaker == nil {
+		return retryLoop()
+	}
+
+	var loopErr error
+	_, err := r.breaker.Execute(ctx, func() (interface{}, error) {
+		loopErr = retryLoop()
+		if loopErr != nil && !r.isRetryable(loopErr) {
+			return nil, nil
+		}
+		return nil, loopErr
+	})
+
+	// Ошибку самого breaker отличаем от ошибки операции: retryLoop всегда оборачивает свои ошибки
+	if _, ok := err.(*circuitbreaker.CircuitBreakerError); ok {
+		return operation()
+	}
+	return loopErr
+}
+
+// elapsedExceeded сообщает, что следующая попытка после delay выйдет за MaxElapsed
+func (r *RetryService) elapsedExceeded(start time.Time, delay time.Duration) bool {
+	return r.config.MaxElapsed > 0 && time.Since(start)+delay > r.config.MaxElapsed
+}
+
+// exhaustedError ошибка при исчерпании бюджета времени
+func (r *RetryService) exhaustedError(name string, attempt int, start time.Time, lastErr error) error {
+	r.recordFailure(name)
+	return fmt.Errorf("%w: %d attempts in %v, last error: %w",
+		ErrRetryExhausted, attempt, time.Since(start).Round(time.Millisecond), lastErr)
+}
+
+func (r *RetryService) executeWithRetry(name string, operation func() error) error {
 	var lastErr error
+	start := time.Now()
 
 	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		r.recordAttempt(name, attempt)
 		if err := operation(); err != nil {
 			lastErr = err
 
+			// Неповторяемая ошибка не исправится повтором
+			if !r.isRetryable(err) {
+				return fmt.Errorf("operation failed with permanent error on attempt %d: %w", attempt, lastErr)
+			}
+
 			// Если это последняя попытка, возвращаем ошибку
 			if attempt == r.config.MaxAttempts {
+				r.recordFailure(name)
 				return fmt.Errorf("operation failed after %d attempts, last error: %w", r.config.MaxAttempts, lastErr)
 			}
 
 			// Вычисляем задержку с экспоненциальным backoff
 			delay := r.calculateDelay(attempt)
+			if r.elapsedExceeded(start, delay) {
+				return r.exhaustedError(name, attempt, start, lastErr)
+			}
 
 			// Ждем перед следующей попыткой
 			time.Sleep(delay)
@@ -61,7 +215,14 @@ func (r *RetryService) calculateDelay(attempt int) time.Duration {
 
 // ExecuteWithRetryContext выполняет операцию с retry и контекстом
 func (r *RetryService) ExecuteWithRetryContext(ctx context.Context, operation func() error) error {
+	return r.withBudget(ctx, r.counted(DefaultOperation, operation), func() error {
+		return r.executeWithRetryContext(ctx, DefaultOperation, operation)
+	})
+}
+
+func (r *RetryService) executeWithRetryContext(ctx context.Context, name string, operation func() error) error {
 	var lastErr error
+	start := time.Now()
 
 	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
 		// Проверяем контекст перед каждой попыткой
@@ -71,16 +232,26 @@ func (r *RetryService) ExecuteWithRetryContext(ctx context.Context, operation fu
 		default:
 		}
 
+		r.recordAttempt(name, attempt)
 		if err := operation(); err != nil {
 			lastErr = err
 
+			// Неповторяемая ошибка не исправится повтором
+			if !r.isRetryable(err) {
+				return fmt.Errorf("operation failed with permanent error on attempt %d: %w", attempt, lastErr)
+			}
+
 			// Если это последняя попытка, возвращаем ошибку
 			if attempt == r.config.MaxAttempts {
+				r.recordFailure(name)
 				return fmt.Errorf("operation failed after %d attempts, last error: %w", r.config.MaxAttempts, lastErr)
 			}
 
 			// Вычисляем задержку
 			delay := r.calculateDelay(attempt)
+			if r.elapsedExceeded(start, delay) {
+				return r.exhaustedError(name, attempt, start, lastErr)
+			}
 
 			// Ждем с возможностью отмены через контекст
 			select {
@@ -6,7 +6,13 @@ import (
 	"testing"
 	"time"
 
+	"wbtest/internal/circuitbreaker"
 	"wbtest/internal/config"
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestRetryService_ExecuteWithRetry(t *testing.T) {
@@ -112,6 +118,38 @@ func TestRetryService_ExecuteWithRetryContext(t *testing.T) {
 	})
 }
 
+func TestRetryService_MaxElapsed(t *testing.T) {
+	service := NewRetryService(&config.RetryConfig{
+		MaxAttempts:  10,
+		InitialDelay: 20 * time.Millisecond,
+		MaxDelay:     20 * time.Millisecond,
+		Multiplier:   1.0,
+		MaxElapsed:   50 * time.Millisecond,
+	})
+
+	opErr := errors.New("still failing")
+	attempts := 0
+	start := time.Now()
+	err := service.ExecuteWithRetry(func() error {
+		attempts++
+		return opErr
+	})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrRetryExhausted) {
+		t.Fatalf("Expected ErrRetryExhausted, got %v", err)
+	}
+	if !errors.Is(err, opErr) {
+		t.Errorf("Expected error to wrap the last operation error, got %v", err)
+	}
+	if attempts >= 10 {
+		t.Errorf("Expected budget to stop retries before MaxAttempts, got %d attempts", attempts)
+	}
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("Expected retries to stop within budget, took %v", elapsed)
+	}
+}
+
 func TestRetryService_calculateDelay(t *testing.T) {
 	config := &config.RetryConfig{
 		MaxAttempts:  3,
@@ -144,3 +182,223 @@ func TestRetryService_calculateDelay(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryService_Budget(t *testing.T) {
+	cfg := &config.RetryConfig{
+		MaxAttempts:             3,
+		InitialDelay:            time.Millisecond,
+		MaxDelay:                time.Millisecond,
+		Multiplier:              1.0,
+		BreakerFailureThreshold: 2,
+		BreakerCooldown:         50 * time.Millisecond,
+	}
+	service := NewRetryService(cfg).(*RetryService)
+
+	attempts := 0
+	failing := func() error {
+		attempts++
+		return errors.New("database is down")
+	}
+
+	// Серия неудачных операций исчерпывает бюджет
+	for i := 0; i < 2; i++ {
+		if err := service.ExecuteWithRetry(failing); err == nil {
+			t.Fatal("Expected error from failing operation")
+		}
+	}
+	if attempts != 6 {
+		t.Fatalf("Expected 6 attempts before budget is exhausted, got %d", attempts)
+	}
+
+	stats, enabled := service.BreakerStats()
+	if !enabled {
+		t.Fatal("Expected retry budget to be enabled")
+	}
+	if stats.State != circuitbreaker.StateOpen {
+		t.Fatalf("Expected budget state %s, got %s", circuitbreaker.StateOpen, stats.State)
+	}
+
+	// Во время cooldown операция выполняется один раз без повторов
+	attempts = 0
+	if err := service.ExecuteWithRetry(failing); err == nil {
+		t.Error("Expected error from failing operation")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected single attempt during cooldown, got %d", attempts)
+	}
+
+	// После cooldown повторы возвращаются, успех закрывает breaker
+	time.Sleep(60 * time.Millisecond)
+	attempts = 0
+	recovering := func() error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("still warming up")
+		}
+		return nil
+	}
+	if err := service.ExecuteWithRetry(recovering); err != nil {
+		t.Errorf("Expected success after cooldown, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected retries after cooldown, got %d attempts", attempts)
+	}
+
+	stats, _ = service.BreakerStats()
+	if stats.State != circuitbreaker.StateClosed {
+		t.Errorf("Expected budget state %s after recovery, got %s", circuitbreaker.StateClosed, stats.State)
+	}
+}
+
+func TestRetryService_BudgetDisabled(t *testing.T) {
+	service := NewRetryService(&config.RetryConfig{MaxAttempts: 1, Multiplier: 1.0}).(*RetryService)
+
+	if _, enabled := service.BreakerStats(); enabled {
+		t.Error("Expected retry budget to be disabled when threshold is 0")
+	}
+}
+
+func TestRetryService_BudgetIgnoresNonRetryable(t *testing.T) {
+	service := NewRetryService(&config.RetryConfig{
+		MaxAttempts:             3,
+		InitialDelay:            time.Millisecond,
+		MaxDelay:                time.Millisecond,
+		Multiplier:              1.0,
+		BreakerFailureThreshold: 2,
+		BreakerCooldown:         time.Minute,
+	}).(*RetryService)
+
+	invalid := errors.New("invalid message")
+	service.WithRetryable(func(err error) bool {
+		return !errors.Is(err, invalid)
+	})
+
+	// Поток невалидных сообщений не расходует бюджет повторов
+	attempts := 0
+	for i := 0; i < 5; i++ {
+		err := service.ExecuteWithRetry(func() error {
+			attempts++
+			return invalid
+		})
+		if !errors.Is(err, invalid) {
+			t.Fatalf("Expected error to wrap cause, got %v", err)
+		}
+	}
+	if attempts != 5 {
+		t.Errorf("Expected single attempt per non-retryable error, got %d", attempts)
+	}
+
+	stats, _ := service.BreakerStats()
+	if stats.State != circuitbreaker.StateClosed {
+		t.Fatalf("Expected budget state %s, got %s", circuitbreaker.StateClosed, stats.State)
+	}
+
+	// Повторяемые ошибки по-прежнему открывают breaker
+	for i := 0; i < 2; i++ {
+		service.ExecuteWithRetry(func() error { return errors.New("database is down") })
+	}
+	if stats, _ := service.BreakerStats(); stats.State != circuitbreaker.StateOpen {
+		t.Errorf("Expected budget state %s, got %s", circuitbreaker.StateOpen, stats.State)
+	}
+}
+
+func TestRetryService_PermanentError(t *testing.T) {
+	service := NewRetryService(&config.RetryConfig{
+		MaxAttempts:  5,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     10 * time.Millisecond,
+		Multiplier:   2.0,
+	})
+
+	cause := errors.New("constraint violated")
+	attempts := 0
+	err := service.ExecuteWithRetry(func() error {
+		attempts++
+		return apperrors.Permanent(cause)
+	})
+
+	if !errors.Is(err, cause) {
+		t.Errorf("Expected error to wrap cause, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt for permanent error, got %d", attempts)
+	}
+
+	attempts = 0
+	err = service.(*RetryService).ExecuteWithRetryContext(context.Background(), func() error {
+		attempts++
+		return apperrors.Permanent(cause)
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("Expected permanent error after 1 attempt, got %v after %d", err, attempts)
+	}
+}
+
+func TestRetryService_Metrics(t *testing.T) {
+	m := metrics.NewWithRegistry(prometheus.NewRegistry())
+
+	service := NewRetryService(&config.RetryConfig{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     10 * time.Millisecond,
+		Multiplier:   2.0,
+	}).(*RetryService).WithMetrics(m)
+
+	calls := 0
+	err := service.ExecuteWithRetryNamed("save_order", func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("temporary error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success on second attempt, got %v", err)
+	}
+
+	if err := service.ExecuteWithRetryNamed("publish", func() error {
+		return errors.New("persistent error")
+	}); err == nil {
+		t.Fatal("Expected error after exhausting attempts")
+	}
+
+	tests := []struct {
+		operation string
+		attempt   string
+		expected  float64
+	}{
+		{"save_order", "1", 1},
+		{"save_order", "2", 1},
+		{"save_order", "3", 0},
+		{"publish", "1", 1},
+		{"publish", "3", 1},
+	}
+	for _, tt := range tests {
+		got := testutil.ToFloat64(m.RetryAttempts.WithLabelValues(tt.operation, tt.attempt))
+		if got != tt.expected {
+			t.Errorf("retry_attempts_total{operation=%q,attempt=%q} = %v, want %v", tt.operation, tt.attempt, got, tt.expected)
+		}
+	}
+
+	if got := testutil.ToFloat64(m.RetryFailures.WithLabelValues("save_order")); got != 0 {
+		t.Errorf("Expected no failures for save_order, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.RetryFailures.WithLabelValues("publish")); got != 1 {
+		t.Errorf("Expected 1 failure for publish, got %v", got)
+	}
+}
+
+func TestRetryService_NilMetrics(t *testing.T) {
+	service := NewRetryService(&config.RetryConfig{
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   1.0,
+	}).(*RetryService).WithMetrics(nil)
+
+	if err := service.ExecuteWithRetryNamed("noop", func() error {
+		return errors.New("error")
+	}); err == nil {
+		t.Error("Expected error without metrics")
+	}
+}
@@ -0,0 +1,111 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"wbtest/internal/model"
+)
+
+// DefaultSanitizeMaxLength предельная длина строк без собственного ограничения
+const DefaultSanitizeMaxLength = 255
+
+// noTruncate поле не обрезается: идентификатор после обрезки стал бы другим заказом,
+// слишком длинное значение отклонит валидация
+const noTruncate = -1
+
+// stringField строковое поле заказа для очистки
+type stringField struct {
+	name      string
+	value     *string
+	maxLength int // 0 - maxLength по умолчанию
+}
+
+// Sanitizer очищает строковые поля заказа перед сохранением:
+// обрезает пробелы, удаляет управляющие символы и слишком длинные значения
+// Длины соответствуют ограничениям модели
+type Sanitizer struct {
+	maxLength int
+}
+
+// NewSanitizer создает очистку, maxLength ограничивает поля без собственного лимита
+func NewSanitizer(maxLength int) *Sanitizer {
+	if maxLength <= 0 {
+		maxLength = DefaultSanitizeMaxLength
+	}
+	return &Sanitizer{maxLength: maxLength}
+}
+
+// Sanitize очищает заказ на месте и возвращает имена измененных полей
+func (s *Sanitizer) Sanitize(order *model.Order) []string {
+	var changed []string
+	for _, field := range orderStringFields(order) {
+		maxLength := field.maxLength
+		if maxLength == 0 {
+			maxLength = s.maxLength
+		}
+		if cleaned := sanitizeString(*field.value, maxLength); cleaned != *field.value {
+			*field.value = cleaned
+			changed = append(changed, field.name)
+		}
+	}
+	return changed
+}
+
+// orderStringFields перечисляет строковые поля заказа с их ограничениями
+func orderStringFields(order *model.Order) []stringField {
+	fields := []stringField{
+		{"order_uid", &order.OrderUID, noTruncate},
+		{"track_number", &order.TrackNumber, noTruncate},
+		{"entry", &order.Entry, 0},
+		{"locale", &order.Locale, 0},
+		{"internal_signature", &order.InternalSignature, 0},
+		{"customer_id", &order.CustomerID, 0},
+		{"delivery_service", &order.DeliveryService, 0},
+		{"shardkey", &order.ShardKey, 0},
+		{"oof_shard", &order.OofShard, 0},
+		{"delivery.name", &order.Delivery.Name, 100},
+		{"delivery.phone", &order.Delivery.Phone, 20},
+		{"delivery.zip", &order.Delivery.Zip, 10},
+		{"delivery.city", &order.Delivery.City, 50},
+		{"delivery.address", &order.Delivery.Address, 200},
+		{"delivery.region", &order.Delivery.Region, 50},
+		{"delivery.email", &order.Delivery.Email, 0},
+		{"payment.transaction", &order.Payment.Transaction, noTruncate},
+		{"payment.request_id", &order.Payment.RequestID, 0},
+		{"payment.currency", &order.Payment.Currency, 0},
+		{"payment.provider", &order.Payment.Provider, 0},
+		{"payment.bank", &order.Payment.Bank, 0},
+	}
+	for i := range order.Items {
+		item := &order.Items[i]
+		prefix := fmt.Sprintf("items[%d].", i)
+		fields = append(fields,
+			stringField{prefix + "track_number", &item.TrackNumber, noTruncate},
+			stringField{prefix + "rid", &item.Rid, noTruncate},
+			stringField{prefix + "name", &item.Name, 200},
+			stringField{prefix + "size", &item.Size, 0},
+			stringField{prefix + "brand", &item.Brand, 100},
+		)
+	}
+	return fields
+}
+
+// sanitizeString удаляет некорректный UTF-8 и управляющие символы,
+// обрезает пробелы по краям и ограничивает длину в символах
+func sanitizeString(value string, maxLength int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(value, ""))
+	cleaned = strings.TrimSpace(cleaned)
+
+	if maxLength > 0 && utf8.RuneCountInString(cleaned) > maxLength {
+		cleaned = strings.TrimSpace(string([]rune(cleaned)[:maxLength]))
+	}
+	return cleaned
+}
@@ -0,0 +1,85 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"wbtest/internal/model"
+)
+
+func TestSanitizer_ControlChars(t *testing.T) {
+	order := &model.Order{
+		OrderUID: "  test-order-1\x00 ",
+		Delivery: model.Delivery{
+			Name:    "Ivan\tIvanov\n",
+			Address: "Lenina\x07 street 1\r\n",
+		},
+		Items: []model.Item{{Brand: "Vivienne\x1bSabo"}},
+	}
+
+	changed := NewSanitizer(0).Sanitize(order)
+
+	if order.OrderUID != "test-order-1" {
+		t.Errorf("Expected order_uid without control chars, got %q", order.OrderUID)
+	}
+	if order.Delivery.Name != "IvanIvanov" {
+		t.Errorf("Expected name without control chars, got %q", order.Delivery.Name)
+	}
+	if order.Delivery.Address != "Lenina street 1" {
+		t.Errorf("Expected address without control chars, got %q", order.Delivery.Address)
+	}
+	if order.Items[0].Brand != "VivienneSabo" {
+		t.Errorf("Expected brand without control chars, got %q", order.Items[0].Brand)
+	}
+
+	expected := "order_uid, delivery.name, delivery.address, items[0].brand"
+	if got := strings.Join(changed, ", "); got != expected {
+		t.Errorf("Expected changed fields %q, got %q", expected, got)
+	}
+}
+
+func TestSanitizer_Truncate(t *testing.T) {
+	longUID := strings.Repeat("u", 80)
+	order := &model.Order{
+		OrderUID:        longUID,
+		DeliveryService: strings.Repeat("d", 40),
+		Delivery: model.Delivery{
+			City: strings.Repeat("г", 60),
+		},
+		Items: []model.Item{{Name: strings.Repeat("n", 250)}},
+	}
+
+	changed := NewSanitizer(32).Sanitize(order)
+
+	// Идентификаторы не обрезаются, их отклонит валидация
+	if order.OrderUID != longUID {
+		t.Errorf("Expected order_uid to stay untouched, got length %d", len(order.OrderUID))
+	}
+	if got := utf8.RuneCountInString(order.Delivery.City); got != 50 {
+		t.Errorf("Expected city truncated to 50 runes, got %d", got)
+	}
+	if !utf8.ValidString(order.Delivery.City) {
+		t.Error("Expected truncated city to stay valid UTF-8")
+	}
+	if got := len(order.Items[0].Name); got != 200 {
+		t.Errorf("Expected item name truncated to 200, got %d", got)
+	}
+	if got := len(order.DeliveryService); got != 32 {
+		t.Errorf("Expected delivery_service truncated to default 32, got %d", got)
+	}
+	if len(changed) != 3 {
+		t.Errorf("Expected 3 changed fields, got %v", changed)
+	}
+}
+
+func TestSanitizer_CleanOrderUnchanged(t *testing.T) {
+	order := &model.Order{
+		OrderUID: "test-order-1",
+		Delivery: model.Delivery{Name: "Ivan Ivanov", City: "Москва"},
+	}
+
+	if changed := NewSanitizer(0).Sanitize(order); len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+}
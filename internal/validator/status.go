@@ -0,0 +1,98 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/model"
+)
+
+// StatusRange диапазон допустимых статусов товара, границы включаются
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// Contains сообщает, входит ли статус в диапазон
+func (r StatusRange) Contains(status int) bool {
+	return status >= r.Min && status <= r.Max
+}
+
+func (r StatusRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// ParseStatusRanges разбирает список статусов вида "200-299,404"
+// Элемент - одно значение или диапазон min-max, пустая строка - без ограничений
+func ParseStatusRanges(spec string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high, isRange := strings.Cut(part, "-")
+		min, err := strconv.Atoi(strings.TrimSpace(low))
+		if err != nil {
+			return nil, fmt.Errorf("invalid item status %q", part)
+		}
+		max := min
+		if isRange {
+			if max, err = strconv.Atoi(strings.TrimSpace(high)); err != nil {
+				return nil, fmt.Errorf("invalid item status range %q", part)
+			}
+		}
+		if min > max {
+			return nil, fmt.Errorf("invalid item status range %q: min is greater than max", part)
+		}
+		ranges = append(ranges, StatusRange{Min: min, Max: max})
+	}
+	return ranges, nil
+}
+
+// WithAllowedItemStatuses ограничивает статусы товаров диапазонами
+// Пустой список отключает проверку
+func WithAllowedItemStatuses(ranges []StatusRange) Option {
+	return func(v *OrderValidator) {
+		v.allowedItemStatuses = ranges
+	}
+}
+
+// validateItemStatuses проверяет, что статус каждого товара входит в один из диапазонов
+// Статус вне диапазонов обычно означает поврежденные данные у поставщика
+func validateItemStatuses(order *model.Order, ranges []StatusRange) error {
+	for i, item := range order.Items {
+		if statusAllowed(item.Status, ranges) {
+			continue
+		}
+
+		allowed := make([]string, len(ranges))
+		for j, r := range ranges {
+			allowed[j] = r.String()
+		}
+		appErr := apperrors.NewWithCode(
+			apperrors.ErrorTypeValidation,
+			fmt.Sprintf("validation failed: item %d status %d is not allowed, expected %s",
+				i, item.Status, strings.Join(allowed, ",")),
+			"ITEM_STATUS_NOT_ALLOWED",
+		)
+		appErr.Cause = &RuleError{Rules: []string{RuleItemStatus}}
+		return appErr
+	}
+	return nil
+}
+
+func statusAllowed(status int, ranges []StatusRange) bool {
+	for _, r := range ranges {
+		if r.Contains(status) {
+			return true
+		}
+	}
+	return false
+}
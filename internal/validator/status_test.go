@@ -0,0 +1,81 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+
+	apperrors "wbtest/internal/errors"
+)
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected []StatusRange
+		wantErr  bool
+	}{
+		{"empty accepts any", "", nil, false},
+		{"range and single value", "200-299, 404", []StatusRange{{200, 299}, {404, 404}}, false},
+		{"not a number", "2xx", nil, true},
+		{"inverted range", "299-200", nil, true},
+		{"broken range", "200-", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatusRanges(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStatusRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseStatusRanges() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOrderValidator_AllowedItemStatuses(t *testing.T) {
+	ranges := []StatusRange{{Min: 200, Max: 299}, {Min: 404, Max: 404}}
+
+	tests := []struct {
+		name    string
+		ranges  []StatusRange
+		status  int
+		wantErr bool
+	}{
+		{"no ranges accepts any status", nil, 999, false},
+		{"lower bound", ranges, 200, false},
+		{"upper bound", ranges, 299, false},
+		{"single value", ranges, 404, false},
+		{"below range", ranges, 199, true},
+		{"between ranges", ranges, 300, true},
+		{"corrupted value", ranges, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewOrderValidator(WithAllowedItemStatuses(tt.ranges))
+			order := newValidOrder()
+			order.Items[1].Status = tt.status
+
+			err := v.Validate(order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			appErr, ok := err.(*apperrors.AppError)
+			if !ok {
+				t.Fatalf("Expected *AppError, got %T", err)
+			}
+			if appErr.Code != "ITEM_STATUS_NOT_ALLOWED" {
+				t.Errorf("Expected code ITEM_STATUS_NOT_ALLOWED, got %s", appErr.Code)
+			}
+			if rules := FailedRules(err); len(rules) != 1 || rules[0] != RuleItemStatus {
+				t.Errorf("FailedRules() = %v, want [%s]", rules, RuleItemStatus)
+			}
+		})
+	}
+}
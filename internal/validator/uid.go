@@ -0,0 +1,64 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+
+	apperrors "wbtest/internal/errors"
+	"wbtest/internal/model"
+)
+
+// Форматы UID заказа, задаются VALIDATION_ORDER_UID_FORMAT
+const (
+	OrderUIDFormatAny   = ""      // проверяется только длина
+	OrderUIDFormatUUID  = "uuid"  // UUID в каноническом виде, как у генератора тестовых данных
+	OrderUIDFormatRegex = "regex" // UID целиком совпадает с VALIDATION_ORDER_UID_PATTERN
+)
+
+// uuidPattern UUID в каноническом виде 8-4-4-4-12, регистр не важен
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ParseOrderUIDFormat возвращает выражение для проверки UID заказа
+// Шаблон формата regex должен совпадать с UID целиком, якоря добавляются сами.
+// Для формата по умолчанию возвращает nil - проверка выключена
+func ParseOrderUIDFormat(format, pattern string) (*regexp.Regexp, error) {
+	switch format {
+	case OrderUIDFormatAny:
+		return nil, nil
+	case OrderUIDFormatUUID:
+		return uuidPattern, nil
+	case OrderUIDFormatRegex:
+		if pattern == "" {
+			return nil, fmt.Errorf("pattern is required for format %q", OrderUIDFormatRegex)
+		}
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return re, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %q or %q", format, OrderUIDFormatUUID, OrderUIDFormatRegex)
+	}
+}
+
+// WithOrderUIDFormat требует, чтобы UID заказа совпадал с выражением
+// nil отключает проверку, остается только ограничение длины
+func WithOrderUIDFormat(re *regexp.Regexp) Option {
+	return func(v *OrderValidator) {
+		v.orderUIDFormat = re
+	}
+}
+
+// validateOrderUIDFormat проверяет формат UID заказа
+func validateOrderUIDFormat(order *model.Order, re *regexp.Regexp) error {
+	if re.MatchString(order.OrderUID) {
+		return nil
+	}
+	appErr := apperrors.NewWithCode(
+		apperrors.ErrorTypeValidation,
+		fmt.Sprintf("validation failed: order_uid '%s' does not match required format", order.OrderUID),
+		"ORDER_UID_FORMAT",
+	)
+	appErr.Cause = &RuleError{Rules: []string{RuleOrderUID}}
+	return appErr
+}
@@ -0,0 +1,86 @@
+package validator
+
+import (
+	"testing"
+
+	apperrors "wbtest/internal/errors"
+)
+
+func TestParseOrderUIDFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		pattern string
+		wantNil bool
+		wantErr bool
+	}{
+		{"default disables check", "", "", true, false},
+		{"uuid", "uuid", "", false, false},
+		{"regex", "regex", `WB-[0-9a-f]{16}`, false, false},
+		{"regex without pattern", "regex", "", true, true},
+		{"invalid regex", "regex", `WB-[`, true, true},
+		{"unknown format", "ulid", "", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := ParseOrderUIDFormat(tt.format, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOrderUIDFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (re == nil) != tt.wantNil {
+				t.Errorf("ParseOrderUIDFormat() = %v, want nil %v", re, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestOrderValidator_OrderUIDFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		pattern  string
+		orderUID string
+		wantErr  bool
+	}{
+		{"any format accepts legacy uid", "", "", "b563feb7b2b84b6test", false},
+		{"uuid valid", "uuid", "", "0b9c2d1e-6f4a-4c7b-9e3d-2a1f8b7c6d5e", false},
+		{"uuid uppercase valid", "uuid", "", "0B9C2D1E-6F4A-4C7B-9E3D-2A1F8B7C6D5E", false},
+		{"uuid invalid", "uuid", "", "b563feb7b2b84b6test", true},
+		{"uuid without dashes invalid", "uuid", "", "0b9c2d1e6f4a4c7b9e3d2a1f8b7c6d5e", true},
+		{"regex valid", "regex", `WB-[0-9a-f]{16}`, "WB-0123456789abcdef", false},
+		{"regex matches whole uid only", "regex", `WB-[0-9a-f]{16}`, "WB-0123456789abcdef-extra", true},
+		{"regex invalid", "regex", `WB-[0-9a-f]{16}`, "XX-0123456789abcdef", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := ParseOrderUIDFormat(tt.format, tt.pattern)
+			if err != nil {
+				t.Fatalf("ParseOrderUIDFormat() error = %v", err)
+			}
+			v := NewOrderValidator(WithOrderUIDFormat(re))
+			order := newValidOrder()
+			order.OrderUID = tt.orderUID
+
+			err = v.Validate(order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			appErr, ok := err.(*apperrors.AppError)
+			if !ok {
+				t.Fatalf("Expected *AppError, got %T", err)
+			}
+			if appErr.Code != "ORDER_UID_FORMAT" {
+				t.Errorf("Expected code ORDER_UID_FORMAT, got %s", appErr.Code)
+			}
+			if rules := FailedRules(err); len(rules) != 1 || rules[0] != RuleOrderUID {
+				t.Errorf("FailedRules() = %v, want [%s]", rules, RuleOrderUID)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
@@ -11,14 +14,161 @@ import (
 	"wbtest/internal/model"
 )
 
+// Правила валидации, по ним размечается метрика ValidationFailures
+// Набор фиксирован, чтобы число меток не росло
+const (
+	RuleOrderUID        = "order_uid"
+	RuleTrackNumber     = "track_number"
+	RuleLocale          = "locale"
+	RuleEntry           = "entry"
+	RuleOrder           = "order"
+	RuleEmail           = "email"
+	RulePhone           = "phone"
+	RuleDelivery        = "delivery"
+	RuleCurrency        = "currency"
+	RuleAmount          = "amount"
+	RulePayment         = "payment"
+	RuleItemsEmpty      = "items_empty"
+	RuleItemsCount      = "items_count"
+	RuleItem            = "item"
+	RuleItemTrackNumber = "item_track_number"
+	RuleItemStatus      = "item_status"
+	RuleDateCreated     = "date_created"
+	RuleOther           = "other"
+)
+
+// RuleError перечисляет правила, которые не прошел заказ
+// Передается как причина AppError, достается через FailedRules
+type RuleError struct {
+	Rules []string
+}
+
+func (e *RuleError) Error() string {
+	return "failed rules: " + strings.Join(e.Rules, ", ")
+}
+
+// FailedRules возвращает правила, нарушенные заказом
+// Для ошибок без информации о правилах возвращает RuleOther
+func FailedRules(err error) []string {
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) && len(ruleErr.Rules) > 0 {
+		return ruleErr.Rules
+	}
+	return []string{RuleOther}
+}
+
+// ruleFor сопоставляет ошибку поля с правилом
+func ruleFor(fieldErr validator.FieldError) string {
+	namespace := fieldErr.StructNamespace()
+
+	switch {
+	case strings.HasPrefix(namespace, "Order.Items["):
+		return RuleItem
+	case namespace == "Order.Items":
+		if fieldErr.Tag() == "max" {
+			return RuleItemsCount
+		}
+		return RuleItemsEmpty
+	case namespace == "Order.Delivery.Email":
+		return RuleEmail
+	case namespace == "Order.Delivery.Phone":
+		return RulePhone
+	case strings.HasPrefix(namespace, "Order.Delivery"):
+		return RuleDelivery
+	case namespace == "Order.Payment.Currency":
+		return RuleCurrency
+	case namespace == "Order.Payment.Amount":
+		return RuleAmount
+	case strings.HasPrefix(namespace, "Order.Payment"):
+		return RulePayment
+	case namespace == "Order.OrderUID":
+		return RuleOrderUID
+	case namespace == "Order.TrackNumber":
+		return RuleTrackNumber
+	case namespace == "Order.Locale":
+		return RuleLocale
+	case namespace == "Order.Entry":
+		return RuleEntry
+	case namespace == "Order.DateCreated":
+		return RuleDateCreated
+	default:
+		return RuleOrder
+	}
+}
+
+// DefaultMaxFutureSkew допустимое опережение date_created относительно текущего времени
+// Покрывает расхождение часов между сервисами
+const DefaultMaxFutureSkew = 5 * time.Minute
+
 type OrderValidator struct {
 	validator *validator.Validate
+
+	// requireItemTrackNumber требует совпадения трек-номера товаров с трек-номером заказа
+	requireItemTrackNumber bool
+
+	// allowedEntries допустимые значения entry, пустой - любое значение
+	allowedEntries map[string]bool
+
+	// allowedItemStatuses допустимые статусы товаров, пустой - любое значение
+	allowedItemStatuses []StatusRange
+
+	// maxFutureSkew насколько date_created может опережать текущее время
+	maxFutureSkew time.Duration
+
+	// orderUIDFormat формат UID заказа, nil - проверяется только длина
+	orderUIDFormat *regexp.Regexp
+
+	now func() time.Time
+}
+
+// Option настройка OrderValidator
+type Option func(*OrderValidator)
+
+// WithItemTrackNumberMatch включает проверку, что все товары
+// заказа имеют трек-номер самого заказа
+func WithItemTrackNumberMatch(enabled bool) Option {
+	return func(v *OrderValidator) {
+		v.requireItemTrackNumber = enabled
+	}
+}
+
+// WithAllowedEntries ограничивает entry заказа списком кодов
+// Пустой список отключает проверку
+func WithAllowedEntries(entries []string) Option {
+	return func(v *OrderValidator) {
+		if len(entries) == 0 {
+			v.allowedEntries = nil
+			return
+		}
+		v.allowedEntries = make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			v.allowedEntries[entry] = true
+		}
+	}
 }
 
-func NewOrderValidator() interfaces.OrderValidator {
-	return &OrderValidator{
-		validator: validator.New(),
+// WithMaxFutureSkew задает, насколько date_created может опережать текущее время
+// Отрицательное значение трактуется как 0
+func WithMaxFutureSkew(skew time.Duration) Option {
+	return func(v *OrderValidator) {
+		if skew < 0 {
+			skew = 0
+		}
+		v.maxFutureSkew = skew
+	}
+}
+
+func NewOrderValidator(opts ...Option) interfaces.OrderValidator {
+	v := &OrderValidator{
+		validator:     validator.New(),
+		maxFutureSkew: DefaultMaxFutureSkew,
+		now:           time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(v)
 	}
+	return v
 }
 
 func (v *OrderValidator) Validate(order *model.Order) error {
@@ -30,17 +180,81 @@ func (v *OrderValidator) Validate(order *model.Order) error {
 	if err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
 			var errorMessages []string
+			var rules []string
+			seen := make(map[string]bool)
 			for _, validationErr := range validationErrors {
 				errorMessages = append(errorMessages, fmt.Sprintf("field '%s' failed validation: %s", validationErr.Field(), validationErr.Tag()))
+				if rule := ruleFor(validationErr); !seen[rule] {
+					seen[rule] = true
+					rules = append(rules, rule)
+				}
 			}
-			return apperrors.NewWithCode(
+			appErr := apperrors.NewWithCode(
 				apperrors.ErrorTypeValidation,
 				"validation failed: "+strings.Join(errorMessages, "; "),
 				"VALIDATION_FAILED",
 			)
+			appErr.Cause = &RuleError{Rules: rules}
+			return appErr
 		}
 		return apperrors.Wrap(err, apperrors.ErrorTypeValidation, "validation error")
 	}
 
+	if v.orderUIDFormat != nil {
+		if err := validateOrderUIDFormat(order, v.orderUIDFormat); err != nil {
+			return err
+		}
+	}
+
+	if limit := v.now().Add(v.maxFutureSkew); order.DateCreated.After(limit) {
+		appErr := apperrors.NewWithCode(
+			apperrors.ErrorTypeValidation,
+			fmt.Sprintf("validation failed: field 'DateCreated' is in the future: %s",
+				order.DateCreated.Format(time.RFC3339)),
+			"DATE_CREATED_IN_FUTURE",
+		)
+		appErr.Cause = &RuleError{Rules: []string{RuleDateCreated}}
+		return appErr
+	}
+
+	if v.allowedEntries != nil && !v.allowedEntries[order.Entry] {
+		appErr := apperrors.NewWithCode(
+			apperrors.ErrorTypeValidation,
+			fmt.Sprintf("validation failed: entry '%s' is not allowed", order.Entry),
+			"ENTRY_NOT_ALLOWED",
+		)
+		appErr.Cause = &RuleError{Rules: []string{RuleEntry}}
+		return appErr
+	}
+
+	if v.requireItemTrackNumber {
+		if err := validateItemTrackNumbers(order); err != nil {
+			return err
+		}
+	}
+
+	if len(v.allowedItemStatuses) > 0 {
+		if err := validateItemStatuses(order, v.allowedItemStatuses); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateItemTrackNumbers проверяет, что трек-номер каждого товара совпадает с трек-номером заказа
+func validateItemTrackNumbers(order *model.Order) error {
+	for i, item := range order.Items {
+		if item.TrackNumber != order.TrackNumber {
+			appErr := apperrors.NewWithCode(
+				apperrors.ErrorTypeValidation,
+				fmt.Sprintf("validation failed: item %d track_number '%s' does not match order track_number '%s'",
+					i, item.TrackNumber, order.TrackNumber),
+				"ITEM_TRACK_NUMBER_MISMATCH",
+			)
+			appErr.Cause = &RuleError{Rules: []string{RuleItemTrackNumber}}
+			return appErr
+		}
+	}
 	return nil
 }
@@ -1,6 +1,8 @@
 package validator
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -223,3 +225,250 @@ func TestOrderValidator_Validate(t *testing.T) {
 		})
 	}
 }
+
+// newValidOrder возвращает заказ из примера WB, проходящий базовую валидацию
+func newValidOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+			{
+				ChrtID:      9934931,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       200,
+				Rid:         "ab4219087a764ae1btest",
+				Name:        "Lipstick",
+				Size:        "0",
+				TotalPrice:  200,
+				NmID:        2389213,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Now(),
+		OofShard:        "1",
+	}
+}
+
+func TestOrderValidator_ItemTrackNumberMatch(t *testing.T) {
+	mismatched := newValidOrder()
+	mismatched.Items[1].TrackNumber = "WBILMOTHERTRACK"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		order   *model.Order
+		wantErr bool
+	}{
+		{"matching track numbers", true, newValidOrder(), false},
+		{"mismatching track number", true, mismatched, true},
+		{"mismatch allowed when rule disabled", false, mismatched, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewOrderValidator(WithItemTrackNumberMatch(tt.enabled))
+
+			err := validator.Validate(tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+
+			appErr, ok := err.(*apperrors.AppError)
+			if !ok {
+				t.Fatalf("Expected *AppError, got %T", err)
+			}
+			if appErr.Type != apperrors.ErrorTypeValidation {
+				t.Errorf("Expected validation error type, got %v", appErr.Type)
+			}
+			if appErr.Code != "ITEM_TRACK_NUMBER_MISMATCH" {
+				t.Errorf("Expected code ITEM_TRACK_NUMBER_MISMATCH, got %s", appErr.Code)
+			}
+			if !strings.Contains(appErr.Message, "item 1") {
+				t.Errorf("Expected error to name item 1, got %q", appErr.Message)
+			}
+		})
+	}
+}
+
+func TestOrderValidator_FailedRules(t *testing.T) {
+	v := NewOrderValidator(WithItemTrackNumberMatch(true))
+
+	tests := []struct {
+		name     string
+		mutate   func(*model.Order)
+		expected string
+	}{
+		{"invalid email", func(o *model.Order) { o.Delivery.Email = "not-an-email" }, RuleEmail},
+		{"invalid currency", func(o *model.Order) { o.Payment.Currency = "US" }, RuleCurrency},
+		{"empty items", func(o *model.Order) { o.Items = []model.Item{} }, RuleItemsEmpty},
+		{"item track number mismatch", func(o *model.Order) { o.Items[1].TrackNumber = "OTHERTRACK" }, RuleItemTrackNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newValidOrder()
+			tt.mutate(order)
+
+			err := v.Validate(order)
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+
+			rules := FailedRules(err)
+			if len(rules) != 1 || rules[0] != tt.expected {
+				t.Errorf("FailedRules() = %v, want [%s]", rules, tt.expected)
+			}
+		})
+	}
+
+	if rules := FailedRules(errors.New("plain error")); len(rules) != 1 || rules[0] != RuleOther {
+		t.Errorf("FailedRules() for plain error = %v, want [%s]", rules, RuleOther)
+	}
+}
+
+func TestOrderValidator_AllowedEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		entry   string
+		wantErr bool
+	}{
+		{"empty allowlist accepts any entry", nil, "ANYTHING", false},
+		{"allowed entry", []string{"WBIL", "WBILMT"}, "WBILMT", false},
+		{"disallowed entry", []string{"WBIL", "WBILMT"}, "UNKNOWN", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewOrderValidator(WithAllowedEntries(tt.allowed))
+			order := newValidOrder()
+			order.Entry = tt.entry
+
+			err := v.Validate(order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			appErr, ok := err.(*apperrors.AppError)
+			if !ok {
+				t.Fatalf("Expected *AppError, got %T", err)
+			}
+			if appErr.Code != "ENTRY_NOT_ALLOWED" {
+				t.Errorf("Expected code ENTRY_NOT_ALLOWED, got %s", appErr.Code)
+			}
+			if rules := FailedRules(err); len(rules) != 1 || rules[0] != RuleEntry {
+				t.Errorf("FailedRules() = %v, want [%s]", rules, RuleEntry)
+			}
+		})
+	}
+}
+
+func TestOrderValidator_Ranges(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		modify   func(order *model.Order)
+		wantErr  bool
+		wantRule string
+		wantText string
+	}{
+		{
+			name:     "negative amount",
+			modify:   func(order *model.Order) { order.Payment.Amount = -100 },
+			wantErr:  true,
+			wantRule: RuleAmount,
+			wantText: "'Amount'",
+		},
+		{
+			name:     "sale over 100 percent",
+			modify:   func(order *model.Order) { order.Items[0].Sale = 150 },
+			wantErr:  true,
+			wantRule: RuleItem,
+			wantText: "'Sale'",
+		},
+		{
+			name:     "date created in the future",
+			modify:   func(order *model.Order) { order.DateCreated = now.Add(24 * time.Hour) },
+			wantErr:  true,
+			wantRule: RuleDateCreated,
+			wantText: "'DateCreated'",
+		},
+		{
+			name:    "date created within skew",
+			modify:  func(order *model.Order) { order.DateCreated = now.Add(time.Minute) },
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewOrderValidator(WithMaxFutureSkew(5 * time.Minute)).(*OrderValidator)
+			v.now = func() time.Time { return now }
+
+			order := newValidOrder()
+			order.DateCreated = now
+			tt.modify(order)
+
+			err := v.Validate(order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			if rules := FailedRules(err); len(rules) != 1 || rules[0] != tt.wantRule {
+				t.Errorf("FailedRules() = %v, want [%s]", rules, tt.wantRule)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("Expected error to mention %s, got %v", tt.wantText, err)
+			}
+		})
+	}
+}
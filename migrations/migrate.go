@@ -313,5 +313,76 @@ func getMigrations() []Migration {
 				DROP INDEX IF EXISTS idx_payment_order_uid;
 			`,
 		},
+		{
+			Version: 3,
+			Name:    "003_add_track_number_index",
+			UpSQL: `
+				CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+			`,
+			DownSQL: `
+				DROP INDEX IF EXISTS idx_orders_track_number;
+			`,
+		},
+		{
+			Version: 4,
+			Name:    "004_add_orders_deleted_at",
+			UpSQL: `
+				ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+				CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders(deleted_at);
+			`,
+			DownSQL: `
+				DROP INDEX IF EXISTS idx_orders_deleted_at;
+				ALTER TABLE orders DROP COLUMN IF EXISTS deleted_at;
+			`,
+		},
+		{
+			// У заказа одна оплата: GetOrderByUID собирает ее через row_to_json(p.*)
+			// Если в таблице уже есть дубли, миграция упадет и их нужно разобрать вручную
+			Version: 5,
+			Name:    "005_unique_payment_order_uid",
+			UpSQL: `
+				ALTER TABLE payment ADD CONSTRAINT payment_order_uid_key UNIQUE (order_uid);
+				DROP INDEX IF EXISTS idx_payment_order_uid;
+			`,
+			DownSQL: `
+				CREATE INDEX IF NOT EXISTS idx_payment_order_uid ON payment(order_uid);
+				ALTER TABLE payment DROP CONSTRAINT IF EXISTS payment_order_uid_key;
+			`,
+		},
+		{
+			// Индексы для GET /orders?sm_id=&oof_shard=
+			Version: 6,
+			Name:    "006_add_orders_shard_indexes",
+			UpSQL: `
+				CREATE INDEX IF NOT EXISTS idx_orders_sm_id ON orders(sm_id);
+				CREATE INDEX IF NOT EXISTS idx_orders_oof_shard ON orders(oof_shard);
+			`,
+			DownSQL: `
+				DROP INDEX IF EXISTS idx_orders_oof_shard;
+				DROP INDEX IF EXISTS idx_orders_sm_id;
+			`,
+		},
+		{
+			// Индекс для GET /items/brands/{brand}/count
+			Version: 7,
+			Name:    "007_add_items_brand_index",
+			UpSQL: `
+				CREATE INDEX IF NOT EXISTS idx_items_brand ON items(brand);
+			`,
+			DownSQL: `
+				DROP INDEX IF EXISTS idx_items_brand;
+			`,
+		},
+		{
+			// Версия заказа из compacted топика: offset сообщения, из которого он записан
+			Version: 8,
+			Name:    "008_add_orders_kafka_offset",
+			UpSQL: `
+				ALTER TABLE orders ADD COLUMN IF NOT EXISTS kafka_offset BIGINT;
+			`,
+			DownSQL: `
+				ALTER TABLE orders DROP COLUMN IF EXISTS kafka_offset;
+			`,
+		},
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,15 +10,20 @@ import (
 	"time"
 
 	"wbtest/internal/config"
+	"wbtest/internal/interfaces"
+	"wbtest/internal/kafka"
 	"wbtest/internal/model"
+	"wbtest/internal/ratelimit"
 
 	"github.com/brianvoe/gofakeit/v6"
+	"golang.org/x/sync/errgroup"
 )
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run scripts/generate_test_data.go <count>")
+		fmt.Println("Usage: go run scripts/generate_test_data.go <count> [kafka]")
 		fmt.Println("Example: go run scripts/generate_test_data.go 10")
+		fmt.Println("Example: go run scripts/generate_test_data.go 10 kafka")
 		os.Exit(1)
 	}
 
@@ -59,6 +65,82 @@ func main() {
 	}
 
 	log.Printf("Generated %d orders in %s", count, filename)
+
+	// Дополнительно отправляем заказы в Kafka
+	if len(os.Args) > 2 && os.Args[2] == "kafka" {
+		producer := kafka.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Topic)
+		defer producer.Close()
+
+		publisher := newPublisher(producer, cfg.Generator.RatePerSecond, cfg.Generator.Concurrency)
+		if err := publisher.publish(context.Background(), orders); err != nil {
+			log.Fatalf("Failed to publish orders: %v", err)
+		}
+		log.Printf("Published %d orders to topic %s (rate=%d/s, concurrency=%d)",
+			count, cfg.Kafka.Topic, cfg.Generator.RatePerSecond, cfg.Generator.Concurrency)
+	}
+}
+
+// publisher отправляет заказы в Kafka с ограниченной скоростью и числом одновременных отправок,
+// чтобы генератор не перегружал брокер и сервис
+type publisher struct {
+	producer interfaces.MessageProducer
+	// limiter выдает по токену на заказ, nil - без ограничения скорости
+	limiter     ratelimit.RateLimiter
+	concurrency int
+}
+
+// newPublisher создает publisher
+// rate - заказов в секунду, 0 - без ограничения; concurrency меньше 1 заменяется на 1
+func newPublisher(producer interfaces.MessageProducer, rate, concurrency int) *publisher {
+	p := &publisher{producer: producer, concurrency: max(concurrency, 1)}
+	if rate > 0 {
+		// Один токен за 1/rate секунды: заказы идут равномерно, без пачки в начале
+		p.limiter = ratelimit.NewTokenBucket(ratelimit.Config{
+			Requests: 1,
+			Window:   time.Second / time.Duration(rate),
+		})
+	}
+	return p
+}
+
+// publish отправляет заказы, первая ошибка или отмена ctx останавливает отправку
+func (p *publisher) publish(ctx context.Context, orders []*model.Order) error {
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(p.concurrency)
+
+	for _, order := range orders {
+		if p.limiter != nil {
+			if err := p.limiter.Wait(groupCtx, "generator"); err != nil {
+				break
+			}
+		}
+		group.Go(func() error {
+			return publishOrder(groupCtx, p.producer, order)
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// publishOrder отправляет один заказ через producer
+func publishOrder(ctx context.Context, producer interfaces.MessageProducer, order *model.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order %s: %w", order.OrderUID, err)
+	}
+
+	// trace-id позволяет найти обработку заказа в логах сервиса
+	headers := kafka.Headers{
+		kafka.HeaderTraceID:       kafka.NewTraceID(),
+		kafka.HeaderSchemaVersion: model.SchemaVersionV1,
+	}
+	if err := producer.Produce(kafka.ContextWithHeaders(ctx, headers), []byte(order.OrderUID), data); err != nil {
+		return fmt.Errorf("failed to produce order %s: %w", order.OrderUID, err)
+	}
+	return nil
 }
 
 func generateOrders(count int, cfg *config.Config) []*model.Order {
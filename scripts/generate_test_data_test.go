@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"wbtest/internal/kafka"
+	"wbtest/internal/model"
+)
+
+func TestPublisher_RespectsRate(t *testing.T) {
+	orders := make([]*model.Order, 1000)
+	for i := range orders {
+		orders[i] = &model.Order{OrderUID: "order-" + strconv.Itoa(i)}
+	}
+
+	const (
+		rate   = 100
+		window = 300 * time.Millisecond
+	)
+	producer := kafka.NewMemoryProducer()
+	publisher := newPublisher(producer, rate, 4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+
+	err := publisher.publish(ctx, orders)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected publishing to stop at the deadline, got %v", err)
+	}
+
+	// 100 заказов/с за 300ms - около 30 заказов, первый отправляется сразу
+	produced := len(producer.Messages())
+	limit := int(rate*window.Seconds()) + 1
+	if produced > limit {
+		t.Errorf("Expected at most %d orders in %v at %d/s, got %d", limit, window, rate, produced)
+	}
+	if produced < limit/2 {
+		t.Errorf("Expected about %d orders in %v at %d/s, got %d", limit, window, rate, produced)
+	}
+}
+
+func TestPublisher_Unlimited(t *testing.T) {
+	orders := make([]*model.Order, 50)
+	for i := range orders {
+		orders[i] = &model.Order{OrderUID: "order-" + strconv.Itoa(i)}
+	}
+
+	producer := kafka.NewMemoryProducer()
+	if err := newPublisher(producer, 0, 0).publish(context.Background(), orders); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	if got := len(producer.Messages()); got != len(orders) {
+		t.Errorf("Expected %d orders, got %d", len(orders), got)
+	}
+}